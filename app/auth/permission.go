@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"errors"
+	"flukis/invokiss/app/model"
+)
+
+var ErrPermissionInvalid = errors.New("permission: invalid")
+
+// Permission allows a group of operations. API keys are scoped with the
+// same permissions.
+type Permission string
+
+const (
+	PermCatalogRead    Permission = "catalog:read"
+	PermCatalogWrite   Permission = "catalog:write"
+	PermInventoryWrite Permission = "inventory:write"
+	PermBillingRead    Permission = "billing:read"
+	PermBillingWrite   Permission = "billing:write"
+	PermUsersManage    Permission = "users:manage"
+)
+
+var Permissions = []Permission{
+	PermCatalogRead,
+	PermCatalogWrite,
+	PermInventoryWrite,
+	PermBillingRead,
+	PermBillingWrite,
+	PermUsersManage,
+}
+
+func (r Permission) Validate() error {
+	for _, known := range Permissions {
+		if r == known {
+			return nil
+		}
+	}
+	return ErrPermissionInvalid
+}
+
+// rolePermissions lists what every role may do. Admins manage the catalog
+// and users; stock is only changed by the warehouse.
+var rolePermissions = map[model.Role][]Permission{
+	model.RoleAdmin: {
+		PermCatalogRead,
+		PermCatalogWrite,
+		PermBillingRead,
+		PermBillingWrite,
+		PermUsersManage,
+	},
+	model.RoleAccountant: {
+		PermCatalogRead,
+		PermBillingRead,
+		PermBillingWrite,
+	},
+	model.RoleWarehouse: {
+		PermCatalogRead,
+		PermInventoryWrite,
+	},
+	model.RoleViewer: {
+		PermCatalogRead,
+		PermBillingRead,
+	},
+}
+
+func (r Permission) grantedTo(role model.Role) bool {
+	for _, p := range rolePermissions[role] {
+		if p == r {
+			return true
+		}
+	}
+	return false
+}
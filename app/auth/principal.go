@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// Principal is who a request is made by, and the tenant they act for. It is
+// either a user, allowed what their role allows, or an API key, allowed
+// exactly its scopes.
+type Principal struct {
+	UserID   ulid.ULID
+	APIKeyID ulid.ULID
+	TenantID ulid.ULID
+	Role     model.Role
+	Scopes   []Permission
+}
+
+// APIKeyPrincipal is the principal of requests made with the key.
+func APIKeyPrincipal(key model.APIKey) Principal {
+	var scopes = make([]Permission, len(key.Scopes))
+	for idx := range key.Scopes {
+		scopes[idx] = Permission(key.Scopes[idx])
+	}
+	return Principal{
+		APIKeyID: key.ID,
+		TenantID: key.TenantID,
+		Scopes:   scopes,
+	}
+}
+
+func (p Principal) IsAPIKey() bool {
+	return p.APIKeyID != ulid.ULID{}
+}
+
+// Actor names the principal in audit records.
+func (p Principal) Actor() string {
+	if p.IsAPIKey() {
+		return "api_key:" + p.APIKeyID.String()
+	}
+	return "user:" + p.UserID.String()
+}
+
+// Can reports whether the principal has the permission.
+func (p Principal) Can(perm Permission) bool {
+	if p.IsAPIKey() {
+		for _, s := range p.Scopes {
+			if s == perm {
+				return true
+			}
+		}
+		return false
+	}
+	return perm.grantedTo(p.Role)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// ActorFrom names who work done with ctx is done by: the principal of the
+// request, or the system for background work.
+func ActorFrom(ctx context.Context) string {
+	if p, ok := PrincipalFrom(ctx); ok {
+		return p.Actor()
+	}
+	return "system"
+}
+
+type tenantKey struct{}
+
+// WithTenant scopes the database queries made with ctx to the tenant.
+func WithTenant(ctx context.Context, tenantID ulid.ULID) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+func TenantFrom(ctx context.Context) (ulid.ULID, bool) {
+	id, ok := ctx.Value(tenantKey{}).(ulid.ULID)
+	return id, ok
+}
@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"errors"
+	"flukis/invokiss/app/model"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/oklog/ulid/v2"
+)
+
+var ErrInvalidToken = errors.New("auth: invalid token")
+
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+)
+
+type claims struct {
+	jwt.RegisteredClaims
+	TokenType string     `json:"token_type"`
+	Role      model.Role `json:"role,omitempty"`
+	Tenant    string     `json:"tenant,omitempty"`
+}
+
+// TokenIssuer signs and verifies the HS256 access and refresh tokens.
+// Access tokens are short lived and carry the principal; refresh tokens only
+// carry the id of their model.RefreshToken record.
+type TokenIssuer struct {
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewTokenIssuer(secret string, accessTTL, refreshTTL time.Duration) *TokenIssuer {
+	return &TokenIssuer{
+		secret:     []byte(secret),
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+	}
+}
+
+func (t *TokenIssuer) RefreshTTL() time.Duration {
+	return t.refreshTTL
+}
+
+// Tokens is what a successful login or refresh answers with.
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// Issue signs an access token for the user and a refresh token for the
+// given session record.
+func (t *TokenIssuer) Issue(user model.User, refresh model.RefreshToken, at time.Time) (Tokens, error) {
+	access, err := t.sign(claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        ulid.Make().String(),
+			Subject:   user.ID.String(),
+			IssuedAt:  jwt.NewNumericDate(at),
+			ExpiresAt: jwt.NewNumericDate(at.Add(t.accessTTL)),
+		},
+		TokenType: tokenTypeAccess,
+		Role:      user.Role,
+		Tenant:    user.TenantID.String(),
+	})
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	refreshToken, err := t.sign(claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        refresh.ID.String(),
+			Subject:   refresh.UserID.String(),
+			IssuedAt:  jwt.NewNumericDate(refresh.CreatedAt),
+			ExpiresAt: jwt.NewNumericDate(refresh.ExpiresAt),
+		},
+		TokenType: tokenTypeRefresh,
+	})
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	return Tokens{
+		AccessToken:  access,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(t.accessTTL.Seconds()),
+	}, nil
+}
+
+func (t *TokenIssuer) sign(c claims) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString(t.secret)
+}
+
+// ParseAccess verifies an access token and returns who it was issued to.
+func (t *TokenIssuer) ParseAccess(token string) (Principal, error) {
+	c, err := t.parse(token, tokenTypeAccess)
+	if err != nil {
+		return Principal{}, err
+	}
+	userID, err := ulid.Parse(c.Subject)
+	if err != nil {
+		return Principal{}, ErrInvalidToken
+	}
+	tenantID, err := ulid.Parse(c.Tenant)
+	if err != nil {
+		return Principal{}, ErrInvalidToken
+	}
+	return Principal{UserID: userID, TenantID: tenantID, Role: c.Role}, nil
+}
+
+// ParseRefresh verifies a refresh token and returns the id of its session
+// record. Whether the record is still usable is up to the caller.
+func (t *TokenIssuer) ParseRefresh(token string) (ulid.ULID, error) {
+	c, err := t.parse(token, tokenTypeRefresh)
+	if err != nil {
+		return ulid.ULID{}, err
+	}
+	id, err := ulid.Parse(c.ID)
+	if err != nil {
+		return ulid.ULID{}, ErrInvalidToken
+	}
+	return id, nil
+}
+
+func (t *TokenIssuer) parse(token, tokenType string) (claims, error) {
+	var c claims
+	_, err := jwt.ParseWithClaims(
+		token,
+		&c,
+		func(*jwt.Token) (any, error) {
+			return t.secret, nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || c.TokenType != tokenType {
+		return claims{}, ErrInvalidToken
+	}
+	return c, nil
+}
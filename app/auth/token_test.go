@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"errors"
+	"flukis/invokiss/app/model"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/oklog/ulid/v2"
+)
+
+const testSecret = "secret"
+
+func testUser() model.User {
+	return model.User{ID: ulid.Make(), TenantID: model.DefaultTenantID, Role: model.RoleAdmin}
+}
+
+func TestTokenIssuerRoundTrip(t *testing.T) {
+	issuer := NewTokenIssuer(testSecret, time.Minute, time.Hour)
+	user := testUser()
+	refresh := model.NewRefreshToken(user.ID, issuer.RefreshTTL(), time.Now())
+
+	tokens, err := issuer.Issue(user, refresh, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	principal, err := issuer.ParseAccess(tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.UserID != user.ID || principal.TenantID != user.TenantID || principal.Role != user.Role {
+		t.Errorf("principal = %+v, want the user %+v", principal, user)
+	}
+	id, err := issuer.ParseRefresh(tokens.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != refresh.ID {
+		t.Errorf("refresh id = %s, want %s", id, refresh.ID)
+	}
+
+	if _, err := issuer.ParseAccess(tokens.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseAccess() of a refresh token error = %v, want %v", err, ErrInvalidToken)
+	}
+	if _, err := issuer.ParseRefresh(tokens.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseRefresh() of an access token error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestTokenIssuerRefusesWrongAlg(t *testing.T) {
+	issuer := NewTokenIssuer(testSecret, time.Minute, time.Hour)
+	user := testUser()
+	c := claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID.String(),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+		TokenType: tokenTypeAccess,
+		Role:      user.Role,
+		Tenant:    user.TenantID.String(),
+	}
+
+	tests := []struct {
+		name   string
+		method jwt.SigningMethod
+		key    any
+	}{
+		{"HS384 with the secret", jwt.SigningMethodHS384, []byte(testSecret)},
+		{"HS512 with the secret", jwt.SigningMethodHS512, []byte(testSecret)},
+		{"none", jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType},
+		{"HS256 with another secret", jwt.SigningMethodHS256, []byte("another")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := jwt.NewWithClaims(tt.method, c).SignedString(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := issuer.ParseAccess(token); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("ParseAccess() error = %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestTokenIssuerRefusesExpired(t *testing.T) {
+	issuer := NewTokenIssuer(testSecret, time.Minute, time.Hour)
+	user := testUser()
+
+	// Issued long enough ago for both tokens to have expired.
+	at := time.Now().Add(-2 * time.Hour)
+	refresh := model.NewRefreshToken(user.ID, issuer.RefreshTTL(), at)
+	tokens, err := issuer.Issue(user, refresh, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := issuer.ParseAccess(tokens.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseAccess() of an expired token error = %v, want %v", err, ErrInvalidToken)
+	}
+	if _, err := issuer.ParseRefresh(tokens.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseRefresh() of an expired token error = %v, want %v", err, ErrInvalidToken)
+	}
+
+	// A token without an expiry never expires, so it is refused as well.
+	token, err := issuer.sign(claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: user.ID.String()},
+		TokenType:        tokenTypeAccess,
+		Tenant:           user.TenantID.String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issuer.ParseAccess(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseAccess() of a token without expiry error = %v, want %v", err, ErrInvalidToken)
+	}
+}
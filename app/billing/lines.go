@@ -0,0 +1,49 @@
+package billing
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/database/querier"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// LineItem is a product and quantity to be billed.
+type LineItem struct {
+	ProductID ulid.ULID
+	Quantity  int
+}
+
+// LineBuilder prices line items the way every billing document does: the
+// product as it is now, taxed at the rate in effect where the customer is
+// billed.
+type LineBuilder struct {
+	readProduct querier.ProductReadModel
+	readTaxRate querier.TaxRateReadModel
+}
+
+func NewLineBuilder(
+	readProduct querier.ProductReadModel,
+	readTaxRate querier.TaxRateReadModel,
+) *LineBuilder {
+	return &LineBuilder{readProduct, readTaxRate}
+}
+
+// Build snapshots the products of items into invoice lines at the given
+// time.
+func (b *LineBuilder) Build(ctx context.Context, customer model.Customer, items []LineItem, at time.Time) ([]model.InvoiceLine, error) {
+	var lines = make([]model.InvoiceLine, len(items))
+	for idx := range items {
+		product, err := b.readProduct.GetOneByID(ctx, items[idx].ProductID)
+		if err != nil {
+			return nil, err
+		}
+		rate, err := b.readTaxRate.FindEffective(ctx, product.TaxClass, customer.BillingAddress.Country, at)
+		if err != nil {
+			return nil, err
+		}
+		lines[idx] = model.NewInvoiceLine(product, items[idx].Quantity, rate)
+	}
+	return lines, nil
+}
@@ -0,0 +1,242 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/http/middleware"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/database/querier"
+	"flukis/invokiss/lib/httpresponse"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+var (
+	errAPIKeyScope        = errors.New("api keys cannot manage users")
+	errAPIKeyScopeNotHeld = errors.New("api keys cannot be granted a permission their creator lacks")
+)
+
+type APIKeyController struct {
+	writeAPIKey querier.APIKeyWriteModel
+	readAPIKey  querier.APIKeyReadModel
+}
+
+func NewAPIKeyController(
+	writeAPIKey querier.APIKeyWriteModel,
+	readAPIKey querier.APIKeyReadModel,
+) *APIKeyController {
+	return &APIKeyController{writeAPIKey, readAPIKey}
+}
+
+func (p *APIKeyController) Routes() *chi.Mux {
+	r := chi.NewMux()
+
+	r.Use(middleware.RequirePermission(auth.PermUsersManage))
+
+	r.Get("/", p.GetAll)
+	r.Post("/", p.Create)
+	r.Delete("/{id}", p.Revoke)
+
+	return r
+}
+
+type createAPIKeyBodyRequest struct {
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	ExpiresAt null.Time `json:"expires_at"`
+}
+
+func (p createAPIKeyBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.Name, validation.Required, validation.Length(1, 100)),
+		validation.Field(&p.Scopes, validation.Required, validation.Each(validation.By(validateAPIKeyScope))),
+	)
+}
+
+// validateAPIKeyScope accepts any permission but managing users, so a
+// leaked key cannot mint more keys.
+func validateAPIKeyScope(value any) error {
+	perm := auth.Permission(value.(string))
+	if err := perm.Validate(); err != nil {
+		return err
+	}
+	if perm == auth.PermUsersManage {
+		return errAPIKeyScope
+	}
+	return nil
+}
+
+// authorize refuses scopes the principal creating the key doesn't have
+// itself, so a key never allows more than whoever minted it.
+func (p createAPIKeyBodyRequest) authorize(principal auth.Principal) error {
+	for _, scope := range p.Scopes {
+		if !principal.Can(auth.Permission(scope)) {
+			return errAPIKeyScopeNotHeld
+		}
+	}
+	return nil
+}
+
+// Create answers with the plain key. It is not stored and cannot be shown
+// again.
+func (p *APIKeyController) Create(w http.ResponseWriter, req *http.Request) {
+	var data createAPIKeyBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	principal, _ := auth.PrincipalFrom(ctx)
+	if err := data.authorize(principal); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusForbidden,
+			err,
+		)
+		return
+	}
+
+	key, plain, err := model.NewAPIKey(
+		principal.TenantID,
+		data.Name,
+		data.Scopes,
+		data.ExpiresAt,
+		time.Now(),
+	)
+	if err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+
+	if err := p.writeAPIKey.Save(ctx, key); err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+
+	var res struct {
+		model.APIKey
+		Key string `json:"key"`
+	}
+	res.APIKey = key
+	res.Key = plain
+
+	httpresponse.WriteData(w, http.StatusCreated, res, nil)
+}
+
+func (p *APIKeyController) Revoke(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	key, err := p.getAPIKey(ctx, id)
+	if err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+
+	if err := key.Revoke(time.Now()); err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+
+	if err := p.writeAPIKey.Revoke(ctx, key); err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+
+	httpresponse.WriteMessage(w, http.StatusOK, "api key revoked")
+}
+
+func (p *APIKeyController) GetAll(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	principal, _ := auth.PrincipalFrom(ctx)
+	data, err := p.readAPIKey.Fetch(ctx, principal.TenantID)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+	var meta struct {
+		Total int `json:"total"`
+	}
+
+	meta.Total = data.Count
+
+	httpresponse.WriteData(w, http.StatusOK, data.Data, meta)
+}
+
+// getAPIKey finds a key of the caller's tenant; keys of other tenants are
+// reported as not found.
+func (p *APIKeyController) getAPIKey(ctx context.Context, id ulid.ULID) (model.APIKey, error) {
+	key, err := p.readAPIKey.GetOneByID(ctx, id)
+	if err != nil {
+		return key, err
+	}
+	principal, _ := auth.PrincipalFrom(ctx)
+	if key.TenantID != principal.TenantID {
+		return model.APIKey{}, model.ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+func writeAPIKeyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, model.ErrAPIKeyNotFound):
+		httpresponse.WriteError(
+			w,
+			http.StatusNotFound,
+			err,
+		)
+	case errors.Is(err, model.ErrAPIKeyRevoked):
+		httpresponse.WriteError(
+			w,
+			http.StatusConflict,
+			err,
+		)
+	case errors.Is(err, model.ErrAPIKeyInvalidExpiry):
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+	default:
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+	}
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"context"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+type fakeAPIKeyWriteModel struct {
+	saved []model.APIKey
+}
+
+func (f *fakeAPIKeyWriteModel) Save(ctx context.Context, data model.APIKey) error {
+	f.saved = append(f.saved, data)
+	return nil
+}
+
+func (f *fakeAPIKeyWriteModel) Revoke(ctx context.Context, data model.APIKey) error {
+	return nil
+}
+
+func (f *fakeAPIKeyWriteModel) MarkUsed(ctx context.Context, id ulid.ULID, at time.Time) error {
+	return nil
+}
+
+func TestAPIKeyControllerCreateScopes(t *testing.T) {
+	admin := auth.Principal{
+		UserID:   ulid.Make(),
+		TenantID: model.DefaultTenantID,
+		Role:     model.RoleAdmin,
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"held scopes", `{"name":"erp","scopes":["catalog:read","billing:write"]}`, http.StatusCreated},
+		{"scope the creator lacks", `{"name":"erp","scopes":["catalog:read","inventory:write"]}`, http.StatusForbidden},
+		{"managing users", `{"name":"erp","scopes":["users:manage"]}`, http.StatusBadRequest},
+		{"unknown scope", `{"name":"erp","scopes":["stock:steal"]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			write := &fakeAPIKeyWriteModel{}
+			c := NewAPIKeyController(write, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req = req.WithContext(auth.WithPrincipal(req.Context(), admin))
+			rec := httptest.NewRecorder()
+			c.Create(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("Create() status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if saved := len(write.saved) == 1; saved != (tt.status == http.StatusCreated) {
+				t.Errorf("Create() saved %d keys", len(write.saved))
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/database/querier"
+	"flukis/invokiss/lib/httpresponse"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+type AuthController struct {
+	readUser          querier.UserReadModel
+	writeRefreshToken querier.RefreshTokenWriteModel
+	issuer            *auth.TokenIssuer
+}
+
+func NewAuthController(
+	readUser querier.UserReadModel,
+	writeRefreshToken querier.RefreshTokenWriteModel,
+	issuer *auth.TokenIssuer,
+) *AuthController {
+	return &AuthController{readUser, writeRefreshToken, issuer}
+}
+
+func (p *AuthController) Routes() *chi.Mux {
+	r := chi.NewMux()
+
+	r.Post("/login", p.Login)
+	r.Post("/refresh", p.Refresh)
+	r.Post("/logout", p.Logout)
+
+	return r
+}
+
+type loginBodyRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+func (p loginBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.Email, validation.Required, is.EmailFormat),
+		validation.Field(&p.Password, validation.Required),
+	)
+}
+
+func (p *AuthController) Login(w http.ResponseWriter, req *http.Request) {
+	var data loginBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	user, err := p.readUser.GetOneByEmail(ctx, data.Email)
+	if errors.Is(err, model.ErrUserNotFound) || (err == nil && !user.CheckPassword(data.Password)) {
+		err = model.ErrInvalidCredentials
+	}
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	now := time.Now()
+	refresh := model.NewRefreshToken(user.ID, p.issuer.RefreshTTL(), now)
+	if err := p.writeRefreshToken.Save(ctx, refresh); err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	tokens, err := p.issuer.Issue(user, refresh, now)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, tokens, nil)
+}
+
+type refreshTokenBodyRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (p refreshTokenBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.RefreshToken, validation.Required),
+	)
+}
+
+// Refresh trades a refresh token for a new pair. Each refresh token works
+// once; replaying one revokes the session it belongs to. The token's user
+// is checked before the token is used up, so a deleted user gets no new
+// pair and keeps nothing to retry with.
+func (p *AuthController) Refresh(w http.ResponseWriter, req *http.Request) {
+	var data refreshTokenBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	id, err := p.issuer.ParseRefresh(data.RefreshToken)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	ctx := req.Context()
+	now := time.Now()
+	var user model.User
+	next, err := p.writeRefreshToken.Rotate(ctx, id, func(t *model.RefreshToken) (model.RefreshToken, error) {
+		var err error
+		user, err = p.readUser.GetOneByID(ctx, t.UserID)
+		if err != nil {
+			return model.RefreshToken{}, err
+		}
+		if user.DeletedAt.Valid {
+			return model.RefreshToken{}, model.ErrUserAlreadyDeleted
+		}
+		return t.Rotate(p.issuer.RefreshTTL(), now)
+	})
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	tokens, err := p.issuer.Issue(user, next, now)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, tokens, nil)
+}
+
+// Logout revokes the session of the refresh token. Access tokens already
+// handed out stay valid until they expire.
+func (p *AuthController) Logout(w http.ResponseWriter, req *http.Request) {
+	var data refreshTokenBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	id, err := p.issuer.ParseRefresh(data.RefreshToken)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	ctx := req.Context()
+	if err := p.writeRefreshToken.Revoke(ctx, id); err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	httpresponse.WriteMessage(w, http.StatusOK, "logged out")
+}
+
+func writeAuthError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, model.ErrInvalidCredentials),
+		errors.Is(err, auth.ErrInvalidToken),
+		errors.Is(err, model.ErrRefreshTokenNotFound),
+		errors.Is(err, model.ErrRefreshTokenExpired),
+		errors.Is(err, model.ErrRefreshTokenRevoked),
+		errors.Is(err, model.ErrRefreshTokenReused),
+		errors.Is(err, model.ErrUserNotFound),
+		errors.Is(err, model.ErrUserAlreadyDeleted):
+		httpresponse.WriteError(
+			w,
+			http.StatusUnauthorized,
+			err,
+		)
+	default:
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+	}
+}
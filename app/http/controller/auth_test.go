@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"context"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/database/querier"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+type fakeUserReadModel struct {
+	users map[ulid.ULID]model.User
+}
+
+func (f *fakeUserReadModel) Fetch(ctx context.Context, tenantID ulid.ULID) (querier.UserList, error) {
+	return querier.UserList{}, nil
+}
+
+func (f *fakeUserReadModel) GetOneByID(ctx context.Context, id ulid.ULID) (model.User, error) {
+	user, ok := f.users[id]
+	if !ok {
+		return model.User{}, model.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (f *fakeUserReadModel) GetOneByEmail(ctx context.Context, email string) (model.User, error) {
+	return model.User{}, model.ErrUserNotFound
+}
+
+type fakeRefreshTokenWriteModel struct {
+	token model.RefreshToken
+	saved []model.RefreshToken
+}
+
+func (f *fakeRefreshTokenWriteModel) Save(ctx context.Context, data model.RefreshToken) error {
+	f.saved = append(f.saved, data)
+	return nil
+}
+
+func (f *fakeRefreshTokenWriteModel) Rotate(
+	ctx context.Context,
+	id ulid.ULID,
+	rotate func(*model.RefreshToken) (model.RefreshToken, error),
+) (model.RefreshToken, error) {
+	item := f.token
+	next, err := rotate(&item)
+	if err != nil {
+		return next, err
+	}
+	f.token = item
+	f.saved = append(f.saved, next)
+	return next, nil
+}
+
+func (f *fakeRefreshTokenWriteModel) Revoke(ctx context.Context, id ulid.ULID) error {
+	return nil
+}
+
+func TestAuthControllerRefreshChecksUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		deleted bool
+		missing bool
+		status  int
+	}{
+		{"active user", false, false, http.StatusOK},
+		{"deleted user", true, false, http.StatusUnauthorized},
+		{"missing user", false, true, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := auth.NewTokenIssuer("secret", time.Minute, time.Hour)
+			user := model.User{ID: ulid.Make(), TenantID: model.DefaultTenantID, Role: model.RoleAdmin}
+			if tt.deleted {
+				user.DeletedAt = null.TimeFrom(time.Now())
+			}
+			users := &fakeUserReadModel{users: map[ulid.ULID]model.User{}}
+			if !tt.missing {
+				users.users[user.ID] = user
+			}
+
+			refresh := model.NewRefreshToken(user.ID, issuer.RefreshTTL(), time.Now())
+			tokens, err := issuer.Issue(user, refresh, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			write := &fakeRefreshTokenWriteModel{token: refresh}
+			c := NewAuthController(users, write, issuer)
+
+			body := `{"refresh_token":"` + tokens.RefreshToken + `"}`
+			rec := httptest.NewRecorder()
+			c.Refresh(rec, httptest.NewRequest(http.MethodPost, "/refresh", strings.NewReader(body)))
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			rotated := tt.status == http.StatusOK
+			if write.token.UsedAt.Valid != rotated || (len(write.saved) == 1) != rotated {
+				t.Errorf("token used %v with %d successors, want rotated %v", write.token.UsedAt.Valid, len(write.saved), rotated)
+			}
+		})
+	}
+}
@@ -3,10 +3,13 @@ package controller
 import (
 	"encoding/json"
 	"errors"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/http/middleware"
 	"flukis/invokiss/app/model"
 	"flukis/invokiss/database/querier"
 	"flukis/invokiss/lib/httpresponse"
 	"net/http"
+	"net/url"
 
 	"github.com/go-chi/chi/v5"
 	validation "github.com/go-ozzo/ozzo-validation/v4"
@@ -28,16 +31,24 @@ func NewCategoryController(
 func (p *CategoryController) Routes() *chi.Mux {
 	r := chi.NewMux()
 
-	r.Get("/", p.GetAll)
-	r.Get("/{id}", p.GetOneByID)
-	r.Post("/", p.Create)
+	read := middleware.RequirePermission(auth.PermCatalogRead)
+	write := middleware.RequirePermission(auth.PermCatalogWrite)
+
+	r.With(read).Get("/", p.GetAll)
+	r.With(read).Get("/tree", p.Tree)
+	r.With(read).Get("/{id}", p.GetOneByID)
+	r.With(write).Post("/", p.Create)
+	r.With(write).Put("/{id}", p.Change)
+	r.With(write).Put("/{id}/parent", p.Move)
+	r.With(write).Delete("/{id}", p.Delete)
 
 	return r
 }
 
 type createCategoryBodyRequest struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	ParentID    *ulid.ULID `json:"parent_id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
 }
 
 func (p createCategoryBodyRequest) Validate() error {
@@ -69,7 +80,15 @@ func (p *CategoryController) Create(w http.ResponseWriter, req *http.Request) {
 	}
 
 	ctx := req.Context()
+	if data.ParentID != nil {
+		if _, err := p.readCategory.GetOneByID(ctx, *data.ParentID); err != nil {
+			writeCategoryParentError(w, err)
+			return
+		}
+	}
+
 	newCategory := model.NewCategory(
+		data.ParentID,
 		data.Name,
 		data.Description,
 	)
@@ -94,8 +113,18 @@ func (p *CategoryController) Create(w http.ResponseWriter, req *http.Request) {
 }
 
 func (p *CategoryController) GetAll(w http.ResponseWriter, req *http.Request) {
+	filter, err := categoryFilter(req.URL.Query())
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
 	ctx := req.Context()
-	data, err := p.readCategory.Fetch(ctx)
+	data, err := p.readCategory.Fetch(ctx, filter)
 	if err != nil {
 		httpresponse.WriteError(
 			w,
@@ -104,13 +133,30 @@ func (p *CategoryController) GetAll(w http.ResponseWriter, req *http.Request) {
 		)
 		return
 	}
-	var meta struct {
-		Total int `json:"total"`
+
+	httpresponse.WriteData(w, http.StatusOK, data.Data, data.Cursor)
+}
+
+// categoryFilter reads the pagination parameters and the include_deleted
+// and created_since filters.
+func categoryFilter(query url.Values) (querier.CategoryFilter, error) {
+	var (
+		filter querier.CategoryFilter
+		err    error
+	)
+
+	filter.Page, err = parsePage(query, "name", "created_at")
+	if err != nil {
+		return filter, err
 	}
 
-	meta.Total = data.Count
+	filter.IncludeDeleted, err = parseFlag(query, "include_deleted")
+	if err != nil {
+		return filter, err
+	}
 
-	httpresponse.WriteData(w, http.StatusOK, data.Data, meta)
+	filter.CreatedSince, err = parseCreatedSince(query)
+	return filter, err
 }
 
 func (p *CategoryController) GetOneByID(w http.ResponseWriter, req *http.Request) {
@@ -127,6 +173,122 @@ func (p *CategoryController) GetOneByID(w http.ResponseWriter, req *http.Request
 
 	ctx := req.Context()
 	data, err := p.readCategory.GetOneByID(ctx, id)
+	if err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, data, nil)
+}
+
+type changeCategoryBodyRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func (p changeCategoryBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.Name, validation.Required),
+		validation.Field(&p.Description, validation.Required),
+	)
+}
+
+func (p *CategoryController) Change(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	var data changeCategoryBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	category, err := p.readCategory.GetOneByID(ctx, id)
+	if err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+
+	category.Name = data.Name
+	category.Description = data.Description
+	if err := p.writeCategory.Save(ctx, category); err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, category.ID, nil)
+}
+
+// Delete soft-deletes the category. It is refused with 409 while products
+// are attached, unless ?reassign_to names the category to move them to.
+func (p *CategoryController) Delete(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	var reassignTo *ulid.ULID
+	if target := req.URL.Query().Get("reassign_to"); target != "" {
+		targetID, err := ulid.Parse(target)
+		if err != nil {
+			httpresponse.WriteError(
+				w,
+				http.StatusBadRequest,
+				err,
+			)
+			return
+		}
+		reassignTo = &targetID
+	}
+
+	ctx := req.Context()
+	category, err := p.readCategory.GetOneByID(ctx, id)
+	if err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+
+	if err := p.writeCategory.Delete(ctx, category, reassignTo); err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+
+	httpresponse.WriteMessage(w, http.StatusOK, "category deleted")
+}
+
+// Tree returns the active categories nested under their parents.
+func (p *CategoryController) Tree(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	data, err := p.readCategory.Tree(ctx)
 	if err != nil {
 		httpresponse.WriteError(
 			w,
@@ -138,3 +300,93 @@ func (p *CategoryController) GetOneByID(w http.ResponseWriter, req *http.Request
 
 	httpresponse.WriteData(w, http.StatusOK, data, nil)
 }
+
+type moveCategoryBodyRequest struct {
+	ParentID *ulid.ULID `json:"parent_id"`
+}
+
+// Move nests the category, with all its subcategories, under parent_id, or
+// moves it to the top when parent_id is null.
+func (p *CategoryController) Move(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	var data moveCategoryBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	if data.ParentID != nil {
+		if _, err := p.readCategory.GetOneByID(ctx, *data.ParentID); err != nil {
+			writeCategoryParentError(w, err)
+			return
+		}
+	}
+
+	if err := p.writeCategory.Move(ctx, id, data.ParentID); err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+
+	httpresponse.WriteMessage(w, http.StatusOK, "category moved")
+}
+
+// writeCategoryParentError reports a parent that cannot be used as a bad
+// request rather than the category itself not being found.
+func writeCategoryParentError(w http.ResponseWriter, err error) {
+	if errors.Is(err, model.ErrCategoryNotFound) || errors.Is(err, model.ErrCategoryAlreadyDeleted) {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+	writeCategoryError(w, err)
+}
+
+func writeCategoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, model.ErrCategoryNotFound),
+		errors.Is(err, model.ErrCategoryAlreadyDeleted):
+		httpresponse.WriteError(
+			w,
+			http.StatusNotFound,
+			err,
+		)
+	case errors.Is(err, model.ErrCategoryHasProducts),
+		errors.Is(err, model.ErrCategoryHasChildren),
+		errors.Is(err, model.ErrCategoryCycle):
+		httpresponse.WriteError(
+			w,
+			http.StatusConflict,
+			err,
+		)
+	case errors.Is(err, model.ErrCategoryReassignTarget):
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+	default:
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+	}
+}
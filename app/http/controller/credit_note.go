@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/http/middleware"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/database/querier"
+	"flukis/invokiss/lib/httpresponse"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/oklog/ulid/v2"
+)
+
+type CreditNoteController struct {
+	writeCreditNote querier.CreditNoteWriteModel
+	readCreditNote  querier.CreditNoteReadModel
+}
+
+func NewCreditNoteController(
+	writeCreditNote querier.CreditNoteWriteModel,
+	readCreditNote querier.CreditNoteReadModel,
+) *CreditNoteController {
+	return &CreditNoteController{writeCreditNote, readCreditNote}
+}
+
+func (p *CreditNoteController) Routes() *chi.Mux {
+	r := chi.NewMux()
+
+	read := middleware.RequirePermission(auth.PermBillingRead)
+	write := middleware.RequirePermission(auth.PermBillingWrite)
+
+	r.With(read).Get("/", p.GetAll)
+	r.With(read).Get("/{id}", p.GetOneByID)
+	r.With(write).Post("/", p.Create)
+
+	return r
+}
+
+type creditNoteLineBodyRequest struct {
+	InvoiceLineID ulid.ULID `json:"invoice_line_id"`
+	Quantity      int       `json:"quantity"`
+}
+
+func (p creditNoteLineBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.Quantity, validation.Required, validation.Min(1)),
+	)
+}
+
+// createCreditNoteBodyRequest credits the whole invoice when no lines are
+// given, otherwise only the listed quantities.
+type createCreditNoteBodyRequest struct {
+	InvoiceID ulid.ULID                   `json:"invoice_id"`
+	Reason    string                      `json:"reason"`
+	Restock   bool                        `json:"restock"`
+	Lines     []creditNoteLineBodyRequest `json:"lines"`
+}
+
+func (p createCreditNoteBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.Reason, validation.Required),
+		validation.Field(&p.Lines),
+	)
+}
+
+func (p *CreditNoteController) Create(w http.ResponseWriter, req *http.Request) {
+	var data createCreditNoteBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	var requested = make([]model.CreditedLine, len(data.Lines))
+	for idx, l := range data.Lines {
+		requested[idx] = model.CreditedLine{
+			InvoiceLineID: l.InvoiceLineID,
+			Quantity:      l.Quantity,
+		}
+	}
+
+	ctx := req.Context()
+	now := time.Now()
+	saved, err := p.writeCreditNote.Save(ctx, data.InvoiceID, func(inv model.Invoice, credited map[ulid.ULID]model.Credited) (model.CreditNote, error) {
+		if len(requested) == 0 {
+			return model.NewFullCreditNote(inv, credited, data.Reason, data.Restock, now)
+		}
+		return model.NewCreditNote(inv, credited, requested, data.Reason, data.Restock, now)
+	})
+	if err != nil {
+		writeCreditNoteError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusCreated, saved, nil)
+}
+
+func (p *CreditNoteController) GetAll(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	data, err := p.readCreditNote.Fetch(ctx)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+	var meta struct {
+		Total int `json:"total"`
+	}
+
+	meta.Total = data.Count
+
+	httpresponse.WriteData(w, http.StatusOK, data.Data, meta)
+}
+
+func (p *CreditNoteController) GetOneByID(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	data, err := p.readCreditNote.GetOneByID(ctx, id)
+	if err != nil {
+		writeCreditNoteError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, data, nil)
+}
+
+func writeCreditNoteError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, model.ErrCreditNoteNotFound),
+		errors.Is(err, model.ErrCreditNoteAlreadyDeleted):
+		httpresponse.WriteError(
+			w,
+			http.StatusNotFound,
+			err,
+		)
+	case errors.Is(err, model.ErrInvoiceNotCreditable),
+		errors.Is(err, model.ErrCreditNoteExceedsInvoice),
+		errors.Is(err, model.ErrInvalidTransition):
+		httpresponse.WriteError(
+			w,
+			http.StatusConflict,
+			err,
+		)
+	case errors.Is(err, model.ErrInvoiceNotFound),
+		errors.Is(err, model.ErrInvoiceAlreadyDeleted),
+		errors.Is(err, model.ErrCreditNoteEmpty),
+		errors.Is(err, model.ErrCreditNoteLineNotFound),
+		errors.Is(err, model.ErrCreditNoteInvalidQuantity):
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+	default:
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+	}
+}
@@ -0,0 +1,284 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/http/middleware"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/database/querier"
+	"flukis/invokiss/lib/httpresponse"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+	"github.com/oklog/ulid/v2"
+)
+
+type CustomerController struct {
+	writeCustomer querier.CustomerWriteModel
+	readCustomer  querier.CustomerReadModel
+}
+
+func NewCustomerController(
+	writeCustomer querier.CustomerWriteModel,
+	readCustomer querier.CustomerReadModel,
+) *CustomerController {
+	return &CustomerController{writeCustomer, readCustomer}
+}
+
+func (p *CustomerController) Routes() *chi.Mux {
+	r := chi.NewMux()
+
+	read := middleware.RequirePermission(auth.PermBillingRead)
+	write := middleware.RequirePermission(auth.PermBillingWrite)
+
+	r.With(read).Get("/", p.GetAll)
+	r.With(read).Get("/{id}", p.GetOneByID)
+	r.With(write).Post("/", p.Create)
+	r.With(write).Put("/{id}", p.Change)
+	r.With(write).Delete("/{id}", p.Delete)
+
+	return r
+}
+
+type addressBodyRequest struct {
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2"`
+	City       string `json:"city"`
+	Region     string `json:"region"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+}
+
+func (p addressBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.Line1, validation.Required),
+		validation.Field(&p.City, validation.Required),
+		validation.Field(&p.Country, validation.Required, is.CountryCode2),
+	)
+}
+
+func (p addressBodyRequest) Address() model.Address {
+	return model.Address{
+		Line1:      p.Line1,
+		Line2:      p.Line2,
+		City:       p.City,
+		Region:     p.Region,
+		PostalCode: p.PostalCode,
+		Country:    p.Country,
+	}
+}
+
+type customerBodyRequest struct {
+	LegalName       string             `json:"legal_name"`
+	BillingAddress  addressBodyRequest `json:"billing_address"`
+	ShippingAddress addressBodyRequest `json:"shipping_address"`
+	TaxID           string             `json:"tax_id"`
+	Currency        string             `json:"currency"`
+	PaymentTermDays int                `json:"payment_term_days"`
+	Emails          []string           `json:"emails"`
+}
+
+func (p customerBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.LegalName, validation.Required),
+		validation.Field(&p.BillingAddress),
+		validation.Field(&p.ShippingAddress),
+		validation.Field(&p.Currency, validation.Required, is.CurrencyCode),
+		validation.Field(&p.PaymentTermDays, validation.Min(0)),
+		validation.Field(&p.Emails, validation.Required, validation.Each(is.EmailFormat)),
+	)
+}
+
+func (p *CustomerController) Create(w http.ResponseWriter, req *http.Request) {
+	var data customerBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	newCustomer := model.NewCustomer(
+		data.LegalName,
+		data.BillingAddress.Address(),
+		data.ShippingAddress.Address(),
+		data.TaxID,
+		data.Currency,
+		data.PaymentTermDays,
+		data.Emails,
+	)
+	err := p.writeCustomer.Save(ctx, newCustomer)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+	httpresponse.WriteData(w, http.StatusCreated, newCustomer.ID, nil)
+}
+
+func (p *CustomerController) Change(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	var data customerBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	customer, err := p.readCustomer.GetOneByID(ctx, id)
+	if err != nil {
+		writeCustomerError(w, err)
+		return
+	}
+
+	customer.LegalName = data.LegalName
+	customer.BillingAddress = data.BillingAddress.Address()
+	customer.ShippingAddress = data.ShippingAddress.Address()
+	customer.TaxID = data.TaxID
+	customer.Currency = data.Currency
+	customer.PaymentTermDays = data.PaymentTermDays
+	customer.Emails = data.Emails
+
+	if err := p.writeCustomer.Save(ctx, customer); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusCreated, customer.ID, nil)
+}
+
+func (p *CustomerController) Delete(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	customer, err := p.readCustomer.GetOneByID(ctx, id)
+	if err != nil {
+		writeCustomerError(w, err)
+		return
+	}
+
+	if err := p.writeCustomer.Delete(ctx, customer); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	httpresponse.WriteMessage(w, http.StatusOK, "customer deleted")
+}
+
+func (p *CustomerController) GetAll(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	data, err := p.readCustomer.Fetch(ctx)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+	var meta struct {
+		Total int `json:"total"`
+	}
+
+	meta.Total = data.Count
+
+	httpresponse.WriteData(w, http.StatusOK, data.Data, meta)
+}
+
+func (p *CustomerController) GetOneByID(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	data, err := p.readCustomer.GetOneByID(ctx, id)
+	if err != nil {
+		writeCustomerError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, data, nil)
+}
+
+func writeCustomerError(w http.ResponseWriter, err error) {
+	if errors.Is(err, model.ErrCustomerNotFound) || errors.Is(err, model.ErrCustomerAlreadyDeleted) {
+		httpresponse.WriteError(
+			w,
+			http.StatusNotFound,
+			err,
+		)
+		return
+	}
+	httpresponse.WriteError(
+		w,
+		http.StatusInternalServerError,
+		err,
+	)
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/http/middleware"
+	"flukis/invokiss/database/querier"
+	"flukis/invokiss/lib/httpresponse"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oklog/ulid/v2"
+)
+
+type InventoryController struct {
+	readInventory querier.InventoryReadModel
+}
+
+func NewInventoryController(
+	readInventory querier.InventoryReadModel,
+) *InventoryController {
+	return &InventoryController{readInventory}
+}
+
+func (p *InventoryController) Routes() *chi.Mux {
+	r := chi.NewMux()
+
+	read := middleware.RequirePermission(auth.PermCatalogRead)
+
+	r.With(read).Get("/low-stock", p.LowStock)
+
+	return r
+}
+
+// LowStock reports the stock at or below its reorder point, in the
+// warehouse given by ?warehouse or in all of them.
+func (p *InventoryController) LowStock(w http.ResponseWriter, req *http.Request) {
+	var warehouseID *ulid.ULID
+	if warehouse := req.URL.Query().Get("warehouse"); warehouse != "" {
+		id, err := ulid.Parse(warehouse)
+		if err != nil {
+			httpresponse.WriteError(
+				w,
+				http.StatusBadRequest,
+				err,
+			)
+			return
+		}
+		warehouseID = &id
+	}
+
+	ctx := req.Context()
+	data, err := p.readInventory.LowStock(ctx, warehouseID)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+	var meta struct {
+		Total int `json:"total"`
+	}
+
+	meta.Total = data.Count
+
+	httpresponse.WriteData(w, http.StatusOK, data.Data, meta)
+}
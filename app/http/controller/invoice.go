@@ -0,0 +1,511 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/billing"
+	"flukis/invokiss/app/http/middleware"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/app/pdf"
+	"flukis/invokiss/database/querier"
+	"flukis/invokiss/lib/httpresponse"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/oklog/ulid/v2"
+)
+
+type InvoiceController struct {
+	writeInvoice querier.InvoiceWriteModel
+	readInvoice  querier.InvoiceReadModel
+	readProduct  querier.ProductReadModel
+	readCustomer querier.CustomerReadModel
+	readTaxRate  querier.TaxRateReadModel
+	readTenant   querier.TenantReadModel
+	renderer     *pdf.InvoiceRenderer
+
+	// reservationTTL is how long stock is held when no expiry is asked for.
+	reservationTTL time.Duration
+}
+
+func NewInvoiceController(
+	writeInvoice querier.InvoiceWriteModel,
+	readInvoice querier.InvoiceReadModel,
+	readProduct querier.ProductReadModel,
+	readCustomer querier.CustomerReadModel,
+	readTaxRate querier.TaxRateReadModel,
+	readTenant querier.TenantReadModel,
+	renderer *pdf.InvoiceRenderer,
+	reservationTTL time.Duration,
+) *InvoiceController {
+	return &InvoiceController{writeInvoice, readInvoice, readProduct, readCustomer, readTaxRate, readTenant, renderer, reservationTTL}
+}
+
+func (p *InvoiceController) Routes() *chi.Mux {
+	r := chi.NewMux()
+
+	read := middleware.RequirePermission(auth.PermBillingRead)
+	write := middleware.RequirePermission(auth.PermBillingWrite)
+
+	r.With(read).Get("/", p.GetAll)
+	r.With(read).Get("/{id}", p.GetOneByID)
+	r.With(read).Get("/{id}/pdf", p.GetPDF)
+	r.With(write).Post("/", p.Create)
+	r.With(write).Put("/{id}", p.Change)
+	r.With(write).Delete("/{id}", p.Delete)
+	r.With(write).Post("/{id}/issue", p.Issue)
+	r.With(write).Post("/{id}/void", p.Void)
+	r.With(write).Post("/{id}/overdue", p.MarkOverdue)
+	r.With(write).Post("/{id}/reservation", p.Reserve)
+	r.With(write).Delete("/{id}/reservation", p.Release)
+
+	return r
+}
+
+type invoiceLineBodyRequest struct {
+	ProductID ulid.ULID `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+}
+
+func (p invoiceLineBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.Quantity, validation.Required, validation.Min(1)),
+	)
+}
+
+type createInvoiceBodyRequest struct {
+	CustomerID ulid.ULID                `json:"customer_id"`
+	Lines      []invoiceLineBodyRequest `json:"lines"`
+	// WarehouseID names the warehouse the goods are sold from; the default
+	// one if nil.
+	WarehouseID *ulid.ULID `json:"warehouse_id"`
+}
+
+func (p createInvoiceBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.Lines, validation.Required),
+	)
+}
+
+func (p *InvoiceController) Create(w http.ResponseWriter, req *http.Request) {
+	var data createInvoiceBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	customer, err := p.readCustomer.GetOneByID(ctx, data.CustomerID)
+	if err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+
+	lines, err := buildInvoiceLines(ctx, p.readProduct, p.readTaxRate, customer, data.Lines)
+	if err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+
+	newInvoice, err := model.NewInvoice(customer, lines)
+	if err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+	if err := newInvoice.SetWarehouse(data.WarehouseID); err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+
+	if err := p.writeInvoice.Save(ctx, newInvoice); err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusCreated, newInvoice.ID, nil)
+}
+
+type changeInvoiceBodyRequest struct {
+	CustomerID ulid.ULID                `json:"customer_id"`
+	Lines      []invoiceLineBodyRequest `json:"lines"`
+	// WarehouseID names the warehouse the goods are sold from; the default
+	// one if nil.
+	WarehouseID *ulid.ULID `json:"warehouse_id"`
+}
+
+func (p changeInvoiceBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.Lines, validation.Required),
+	)
+}
+
+func (p *InvoiceController) Change(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	var data changeInvoiceBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	customer, err := p.readCustomer.GetOneByID(ctx, data.CustomerID)
+	if err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+
+	lines, err := buildInvoiceLines(ctx, p.readProduct, p.readTaxRate, customer, data.Lines)
+	if err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+
+	err = p.writeInvoice.Edit(ctx, id, func(inv *model.Invoice) error {
+		if err := inv.SetCustomer(customer); err != nil {
+			return err
+		}
+		if err := inv.SetWarehouse(data.WarehouseID); err != nil {
+			return err
+		}
+		return inv.SetLines(lines)
+	})
+	if err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusCreated, id, nil)
+}
+
+func (p *InvoiceController) Delete(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	if err := p.writeInvoice.Delete(ctx, model.Invoice{ID: id}); err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+
+	httpresponse.WriteMessage(w, http.StatusOK, "invoice deleted")
+}
+
+func (p *InvoiceController) Issue(w http.ResponseWriter, req *http.Request) {
+	p.transition(w, req, func(inv *model.Invoice) error {
+		return inv.Issue(time.Now())
+	})
+}
+
+func (p *InvoiceController) Void(w http.ResponseWriter, req *http.Request) {
+	p.transition(w, req, func(inv *model.Invoice) error {
+		return inv.Void(time.Now())
+	})
+}
+
+func (p *InvoiceController) MarkOverdue(w http.ResponseWriter, req *http.Request) {
+	p.transition(w, req, func(inv *model.Invoice) error {
+		return inv.MarkOverdue()
+	})
+}
+
+func (p *InvoiceController) transition(w http.ResponseWriter, req *http.Request, change func(*model.Invoice) error) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	data, err := p.writeInvoice.Transition(ctx, id, change)
+	if err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, data.Status, nil)
+}
+
+type reserveInvoiceBodyRequest struct {
+	// ExpiresIn is in minutes.
+	ExpiresIn int `json:"expires_in"`
+}
+
+func (p reserveInvoiceBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.ExpiresIn, validation.Min(0)),
+	)
+}
+
+// Reserve holds the stock of the draft invoice's lines so it isn't sold
+// to anyone else, for expires_in minutes or the configured default. The
+// body is optional.
+func (p *InvoiceController) Reserve(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	var data reserveInvoiceBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil && !errors.Is(err, io.EOF) {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ttl := p.reservationTTL
+	if data.ExpiresIn > 0 {
+		ttl = time.Minute * time.Duration(data.ExpiresIn)
+	}
+
+	ctx := req.Context()
+	reservations, err := p.writeInvoice.Reserve(ctx, id, time.Now().Add(ttl))
+	if err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusCreated, reservations, nil)
+}
+
+func (p *InvoiceController) Release(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	if err := p.writeInvoice.Release(ctx, id); err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+
+	httpresponse.WriteMessage(w, http.StatusOK, "stock released")
+}
+
+func (p *InvoiceController) GetAll(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	data, err := p.readInvoice.Fetch(ctx)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+	var meta struct {
+		Total int `json:"total"`
+	}
+
+	meta.Total = data.Count
+
+	httpresponse.WriteData(w, http.StatusOK, data.Data, meta)
+}
+
+func (p *InvoiceController) GetOneByID(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	data, err := p.readInvoice.GetOneByID(ctx, id)
+	if err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, data, nil)
+}
+
+func (p *InvoiceController) GetPDF(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	data, err := p.readInvoice.GetOneByID(ctx, id)
+	if err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+
+	// The invoice was read in the caller's tenant, so that is who issued it.
+	principal, _ := auth.PrincipalFrom(ctx)
+	tenant, err := p.readTenant.GetOneByID(ctx, principal.TenantID)
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := p.renderer.Render(&buf, tenant.InvoiceSeller(), data); err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+
+	w.Header().Add("content-type", "application/pdf")
+	w.Header().Add("content-disposition", fmt.Sprintf("inline; filename=%q", data.Number.String+".pdf"))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// buildInvoiceLines snapshots the requested products into invoice lines,
+// taxed at the rates currently in effect where the customer is billed.
+func buildInvoiceLines(
+	ctx context.Context,
+	readProduct querier.ProductReadModel,
+	readTaxRate querier.TaxRateReadModel,
+	customer model.Customer,
+	data []invoiceLineBodyRequest,
+) ([]model.InvoiceLine, error) {
+	var items = make([]billing.LineItem, len(data))
+	for idx := range data {
+		items[idx] = billing.LineItem{
+			ProductID: data[idx].ProductID,
+			Quantity:  data[idx].Quantity,
+		}
+	}
+	return billing.NewLineBuilder(readProduct, readTaxRate).Build(ctx, customer, items, time.Now())
+}
+
+func writeInvoiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, model.ErrInvoiceNotFound),
+		errors.Is(err, model.ErrInvoiceAlreadyDeleted):
+		httpresponse.WriteError(
+			w,
+			http.StatusNotFound,
+			err,
+		)
+	case errors.Is(err, model.ErrInvoiceAlreadyIssued),
+		errors.Is(err, model.ErrInvalidTransition),
+		errors.Is(err, model.ErrInvoiceHasPayments),
+		errors.Is(err, model.ErrInvoiceHasCredits),
+		errors.Is(err, model.ErrInsufficientStock),
+		errors.Is(err, pdf.ErrInvoiceNotIssued):
+		httpresponse.WriteError(
+			w,
+			http.StatusConflict,
+			err,
+		)
+	case errors.Is(err, model.ErrInvoiceEmpty),
+		errors.Is(err, model.ErrMoneyCurrencyMismatch),
+		errors.Is(err, model.ErrTaxRateNotFound),
+		errors.Is(err, model.ErrCustomerNotFound),
+		errors.Is(err, model.ErrCustomerAlreadyDeleted),
+		errors.Is(err, model.ErrProductNotFound),
+		errors.Is(err, model.ErrProductAlreadyDeleted),
+		errors.Is(err, model.ErrWarehouseNotFound),
+		errors.Is(err, model.ErrWarehouseAlreadyDeleted),
+		errors.Is(err, model.ErrStockReservationInvalidExpiry):
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+	default:
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+	}
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"errors"
+	"flukis/invokiss/database/querier"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+var (
+	errPageLimit  = errors.New("limit must be between 1 and 200")
+	errPageSort   = errors.New("sort is not supported")
+	errPageOrder  = errors.New("order must be asc or desc")
+	errPageSince  = errors.New("created_since must be a date or RFC 3339 time")
+	errPageFilter = errors.New("flags must be true or false")
+)
+
+// parsePage reads the after, limit, sort and order parameters of the
+// paginated lists. sorts are the sort options the list supports.
+func parsePage(query url.Values, sorts ...string) (querier.Page, error) {
+	page := querier.Page{
+		Limit: querier.DefaultPageLimit,
+	}
+
+	if after := query.Get("after"); after != "" {
+		id, err := ulid.Parse(after)
+		if err != nil {
+			return page, err
+		}
+		page.After = &id
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > querier.MaxPageLimit {
+			return page, errPageLimit
+		}
+		page.Limit = n
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		var known bool
+		for _, s := range sorts {
+			known = known || s == sort
+		}
+		if !known {
+			return page, errPageSort
+		}
+		page.Sort = sort
+	}
+
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		page.Desc = true
+	default:
+		return page, errPageOrder
+	}
+
+	return page, nil
+}
+
+// parseCreatedSince reads the created_since filter, a date or a time.
+func parseCreatedSince(query url.Values) (null.Time, error) {
+	since := query.Get("created_since")
+	if since == "" {
+		return null.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return null.TimeFrom(t), nil
+	}
+	t, err := time.Parse(time.DateOnly, since)
+	if err != nil {
+		return null.Time{}, errPageSince
+	}
+	return null.TimeFrom(t), nil
+}
+
+// parseFlag reads a boolean filter that is off unless given.
+func parseFlag(query url.Values, name string) (bool, error) {
+	v := query.Get(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, errPageFilter
+	}
+	return b, nil
+}
@@ -0,0 +1,299 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/http/middleware"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/database/querier"
+	"flukis/invokiss/lib/httpresponse"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/oklog/ulid/v2"
+)
+
+type PaymentController struct {
+	writePayment querier.PaymentWriteModel
+	readPayment  querier.PaymentReadModel
+	readCustomer querier.CustomerReadModel
+}
+
+func NewPaymentController(
+	writePayment querier.PaymentWriteModel,
+	readPayment querier.PaymentReadModel,
+	readCustomer querier.CustomerReadModel,
+) *PaymentController {
+	return &PaymentController{writePayment, readPayment, readCustomer}
+}
+
+func (p *PaymentController) Routes() *chi.Mux {
+	r := chi.NewMux()
+
+	read := middleware.RequirePermission(auth.PermBillingRead)
+	write := middleware.RequirePermission(auth.PermBillingWrite)
+
+	r.With(read).Get("/", p.GetAll)
+	r.With(read).Get("/{id}", p.GetOneByID)
+	r.With(write).Post("/", p.Create)
+	r.With(write).Post("/{id}/allocate", p.Allocate)
+	r.With(read).Get("/customer/{id}/credit", p.GetCustomerCredit)
+
+	return r
+}
+
+type paymentAllocationBodyRequest struct {
+	InvoiceID ulid.ULID   `json:"invoice_id"`
+	Amount    model.Money `json:"amount"`
+}
+
+func (p paymentAllocationBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.Amount),
+	)
+}
+
+func toAllocations(data []paymentAllocationBodyRequest) []querier.Allocation {
+	var res = make([]querier.Allocation, len(data))
+	for idx := range data {
+		res[idx] = querier.Allocation{
+			InvoiceID: data[idx].InvoiceID,
+			Amount:    data[idx].Amount,
+		}
+	}
+	return res
+}
+
+type createPaymentBodyRequest struct {
+	CustomerID  ulid.ULID                      `json:"customer_id"`
+	Amount      model.Money                    `json:"amount"`
+	Method      model.PaymentMethod            `json:"method"`
+	Reference   string                         `json:"reference"`
+	ReceivedAt  time.Time                      `json:"received_at"`
+	Allocations []paymentAllocationBodyRequest `json:"allocations"`
+}
+
+func (p createPaymentBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.Amount),
+		validation.Field(&p.Method, validation.Required),
+		validation.Field(&p.Reference, validation.Length(0, 100)),
+		validation.Field(&p.ReceivedAt, validation.Required),
+		validation.Field(&p.Allocations),
+	)
+}
+
+func (p *PaymentController) Create(w http.ResponseWriter, req *http.Request) {
+	var data createPaymentBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	customer, err := p.readCustomer.GetOneByID(ctx, data.CustomerID)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	newPayment, err := model.NewPayment(
+		customer.ID,
+		data.Amount,
+		data.Method,
+		data.Reference,
+		data.ReceivedAt,
+	)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	saved, err := p.writePayment.Save(ctx, newPayment, toAllocations(data.Allocations))
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusCreated, saved, nil)
+}
+
+type allocatePaymentBodyRequest struct {
+	Allocations []paymentAllocationBodyRequest `json:"allocations"`
+}
+
+func (p allocatePaymentBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.Allocations, validation.Required),
+	)
+}
+
+func (p *PaymentController) Allocate(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	var data allocatePaymentBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	saved, err := p.writePayment.Allocate(ctx, id, toAllocations(data.Allocations))
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, saved, nil)
+}
+
+func (p *PaymentController) GetAll(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	data, err := p.readPayment.Fetch(ctx)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+	var meta struct {
+		Total int `json:"total"`
+	}
+
+	meta.Total = data.Count
+
+	httpresponse.WriteData(w, http.StatusOK, data.Data, meta)
+}
+
+func (p *PaymentController) GetOneByID(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	data, err := p.readPayment.GetOneByID(ctx, id)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, data, nil)
+}
+
+func (p *PaymentController) GetCustomerCredit(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	if _, err := p.readCustomer.GetOneByID(ctx, id); err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	data, err := p.readPayment.CustomerCredit(ctx, id)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, data, nil)
+}
+
+func writePaymentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, model.ErrPaymentNotFound),
+		errors.Is(err, model.ErrPaymentAlreadyDeleted),
+		errors.Is(err, model.ErrCustomerNotFound),
+		errors.Is(err, model.ErrCustomerAlreadyDeleted):
+		httpresponse.WriteError(
+			w,
+			http.StatusNotFound,
+			err,
+		)
+	case errors.Is(err, model.ErrInvoiceNotPayable),
+		errors.Is(err, model.ErrPaymentExceedsBalance),
+		errors.Is(err, model.ErrPaymentOverAllocated),
+		errors.Is(err, model.ErrInvalidTransition):
+		httpresponse.WriteError(
+			w,
+			http.StatusConflict,
+			err,
+		)
+	case errors.Is(err, model.ErrInvoiceNotFound),
+		errors.Is(err, model.ErrInvoiceAlreadyDeleted),
+		errors.Is(err, model.ErrPaymentInvalidAmount),
+		errors.Is(err, model.ErrPaymentCustomerMismatch),
+		errors.Is(err, model.ErrPaymentMethodInvalid),
+		errors.Is(err, model.ErrMoneyCurrencyMismatch),
+		errors.Is(err, model.ErrMoneyInvalidAmount):
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+	default:
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+	}
+}
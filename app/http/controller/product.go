@@ -3,49 +3,78 @@ package controller
 import (
 	"encoding/json"
 	"errors"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/http/middleware"
 	"flukis/invokiss/app/model"
 	"flukis/invokiss/database/querier"
 	"flukis/invokiss/lib/httpresponse"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
 	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
 )
 
 type ProductController struct {
-	writeProduct querier.ProductWriteModel
-	readProduct  querier.ProductReadModel
+	writeProduct      querier.ProductWriteModel
+	readProduct       querier.ProductReadModel
+	readStockMovement querier.StockMovementReadModel
 }
 
 func NewProductController(
 	writeProduct querier.ProductWriteModel,
 	readProduct querier.ProductReadModel,
+	readStockMovement querier.StockMovementReadModel,
 ) *ProductController {
-	return &ProductController{writeProduct, readProduct}
+	return &ProductController{writeProduct, readProduct, readStockMovement}
 }
 
 func (p *ProductController) Routes() *chi.Mux {
 	r := chi.NewMux()
 
-	r.Get("/", p.GetAll)
-	r.Get("/{id}", p.GetOneByID)
-	r.Put("/{id}", p.Change)
-	r.Post("/", p.Create)
-	r.Patch("/{id}/inventory", p.AssignQuantity)
+	read := middleware.RequirePermission(auth.PermCatalogRead)
+	write := middleware.RequirePermission(auth.PermCatalogWrite)
+	stock := middleware.RequirePermission(auth.PermInventoryWrite)
+
+	r.With(read).Get("/", p.GetAll)
+	r.With(read).Get("/search", p.Search)
+	r.With(read).Get("/{id}", p.GetOneByID)
+	r.With(write).Put("/{id}", p.Change)
+	r.With(write).Post("/", p.Create)
+	r.With(write).Delete("/{id}", p.Delete)
+	r.With(write).Post("/{id}/restore", p.Restore)
+	r.With(write).Put("/{id}/categories", p.ReplaceCategories)
+	r.With(stock).Patch("/{id}/inventory", p.AssignQuantity)
+	r.With(stock).Post("/{id}/inventory/increment", p.IncrementQuantity)
+	r.With(stock).Post("/{id}/inventory/decrement", p.DecrementQuantity)
+	r.With(stock).Put("/{id}/inventory/reorder", p.SetReorderPoint)
+	r.With(read).Get("/{id}/inventory/movements", p.StockMovements)
 
 	return r
 }
 
 type assignQtyBodyRequest struct {
-	Quantity int `json:"qty"`
+	// Quantity is a pointer so that a missing qty and a count of 0 can be
+	// told apart.
+	Quantity  *int   `json:"qty"`
+	Reason    string `json:"reason"`
+	Reference string `json:"reference"`
+
+	// WarehouseID names the warehouse counted; the default one if nil.
+	WarehouseID *ulid.ULID `json:"warehouse_id"`
 }
 
 func (p assignQtyBodyRequest) Validate() error {
 	return validation.ValidateStruct(
 		&p,
-		validation.Field(&p.Quantity, validation.Required),
+		validation.Field(&p.Quantity, validation.NotNil, validation.Min(0)),
+		validation.Field(&p.Reason, validation.Length(0, 255)),
+		validation.Field(&p.Reference, validation.Length(0, 100)),
 	)
 }
 
@@ -81,9 +110,12 @@ func (p *ProductController) AssignQuantity(w http.ResponseWriter, req *http.Requ
 	}
 
 	ctx := req.Context()
-	err = p.writeProduct.AssignQuantity(ctx, id, data.Quantity)
+	err = p.writeProduct.AssignQuantity(ctx, id, data.WarehouseID, *data.Quantity, data.Reason, data.Reference)
 	if err != nil {
-		if errors.Is(err, model.ErrProductNotFound) || errors.Is(err, model.ErrProductSKUDuplicated) {
+		if errors.Is(err, model.ErrProductNotFound) ||
+			errors.Is(err, model.ErrProductAlreadyDeleted) ||
+			errors.Is(err, model.ErrWarehouseNotFound) ||
+			errors.Is(err, model.ErrWarehouseAlreadyDeleted) {
 			httpresponse.WriteError(
 				w,
 				http.StatusBadRequest,
@@ -99,7 +131,164 @@ func (p *ProductController) AssignQuantity(w http.ResponseWriter, req *http.Requ
 		return
 	}
 
-	httpresponse.WriteData(w, http.StatusCreated, data.Quantity, nil)
+	httpresponse.WriteData(w, http.StatusCreated, *data.Quantity, nil)
+}
+
+type adjustQtyBodyRequest struct {
+	Quantity  int                     `json:"qty"`
+	Type      model.StockMovementType `json:"type"`
+	Reason    string                  `json:"reason"`
+	Reference string                  `json:"reference"`
+
+	// WarehouseID names the warehouse the stock moves in; the default one
+	// if nil.
+	WarehouseID *ulid.ULID `json:"warehouse_id"`
+}
+
+func (p adjustQtyBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.Quantity, validation.Required, validation.Min(1)),
+		validation.Field(&p.Reason, validation.Length(0, 255)),
+		validation.Field(&p.Reference, validation.Length(0, 100)),
+	)
+}
+
+// IncrementQuantity adds qty to the stock of the product, booked as a
+// receipt unless the body names another type.
+func (p *ProductController) IncrementQuantity(w http.ResponseWriter, req *http.Request) {
+	p.adjustQuantity(w, req, 1, model.StockReceipt)
+}
+
+// DecrementQuantity takes qty off the stock of the product, booked as a
+// sale unless the body names another type. It fails with
+// model.ErrInsufficientStock rather than leaving the stock negative, unless
+// the product allows backorders.
+func (p *ProductController) DecrementQuantity(w http.ResponseWriter, req *http.Request) {
+	p.adjustQuantity(w, req, -1, model.StockSale)
+}
+
+func (p *ProductController) adjustQuantity(w http.ResponseWriter, req *http.Request, sign int, defaultType model.StockMovementType) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	var data adjustQtyBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+	if data.Type == "" {
+		data.Type = defaultType
+	}
+
+	ctx := req.Context()
+	movement, err := model.NewStockMovement(
+		id,
+		data.Type,
+		sign*data.Quantity,
+		data.Reason,
+		data.Reference,
+		auth.ActorFrom(ctx),
+		time.Now(),
+	)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	movement, err = p.writeProduct.AdjustQuantity(ctx, movement, data.WarehouseID)
+	if err != nil {
+		writeProductError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusCreated, movement, nil)
+}
+
+type reorderPointBodyRequest struct {
+	// ReorderPoint null turns the stock alerts off.
+	ReorderPoint    null.Int `json:"reorder_point"`
+	ReorderQuantity int      `json:"reorder_quantity"`
+
+	// WarehouseID names the warehouse the reorder point is for; the
+	// default one if nil.
+	WarehouseID *ulid.ULID `json:"warehouse_id"`
+}
+
+func (p reorderPointBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.ReorderPoint, validation.Min(int64(0))),
+		validation.Field(&p.ReorderQuantity, validation.Min(0)),
+	)
+}
+
+// SetReorderPoint sets the stock level at which the product runs low in a
+// warehouse, and how much to reorder then.
+func (p *ProductController) SetReorderPoint(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	var data reorderPointBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	err = p.writeProduct.SetReorderPoint(ctx, id, data.WarehouseID, data.ReorderPoint, data.ReorderQuantity)
+	if err != nil {
+		writeProductError(w, err)
+		return
+	}
+
+	httpresponse.WriteMessage(w, http.StatusOK, "reorder point set")
 }
 
 type createProductBodyRequest struct {
@@ -107,9 +296,13 @@ type createProductBodyRequest struct {
 	Name        string      `json:"name"`
 	Description string      `json:"description"`
 	Image       *[]byte     `json:"image"`
-	Amount      float64     `json:"amount"`
+	Amount      model.Money `json:"amount"`
 	Quantity    int         `json:"quantity"`
 	Categories  []ulid.ULID `json:"categories"`
+
+	TaxClass         model.TaxClass `json:"tax_class"`
+	PriceIncludesTax bool           `json:"price_includes_tax"`
+	AllowBackorder   bool           `json:"allow_backorder"`
 }
 
 func (p createProductBodyRequest) Validate() error {
@@ -119,7 +312,8 @@ func (p createProductBodyRequest) Validate() error {
 		validation.Field(&p.Sku, validation.Required),
 		validation.Field(&p.Description, validation.Required),
 		validation.Field(&p.Amount, validation.Required),
-		validation.Field(&p.Quantity, validation.Required),
+		validation.Field(&p.Quantity, validation.Min(0)),
+		validation.Field(&p.TaxClass, validation.Required),
 	)
 }
 
@@ -151,6 +345,9 @@ func (p *ProductController) Create(w http.ResponseWriter, req *http.Request) {
 		data.Image,
 		data.Amount,
 		data.Quantity,
+		data.TaxClass,
+		data.PriceIncludesTax,
+		data.AllowBackorder,
 	)
 	err := p.writeProduct.Save(ctx, newProduct)
 	if err != nil {
@@ -186,11 +383,15 @@ func (p *ProductController) Create(w http.ResponseWriter, req *http.Request) {
 }
 
 type changeProductBodyRequest struct {
-	Sku         string  `json:"sku"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Image       *[]byte `json:"image"`
-	Amount      float64 `json:"amount"`
+	Sku         string      `json:"sku"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Image       *[]byte     `json:"image"`
+	Amount      model.Money `json:"amount"`
+
+	TaxClass         model.TaxClass `json:"tax_class"`
+	PriceIncludesTax bool           `json:"price_includes_tax"`
+	AllowBackorder   bool           `json:"allow_backorder"`
 }
 
 func (p changeProductBodyRequest) Validate() error {
@@ -200,6 +401,7 @@ func (p changeProductBodyRequest) Validate() error {
 		validation.Field(&p.Sku, validation.Required),
 		validation.Field(&p.Description, validation.Required),
 		validation.Field(&p.Amount, validation.Required),
+		validation.Field(&p.TaxClass, validation.Required),
 	)
 }
 
@@ -242,6 +444,10 @@ func (p *ProductController) Change(w http.ResponseWriter, req *http.Request) {
 		Description: data.Description,
 		Image:       data.Image,
 		Amount:      data.Amount,
+
+		TaxClass:         data.TaxClass,
+		PriceIncludesTax: data.PriceIncludesTax,
+		AllowBackorder:   data.AllowBackorder,
 	}
 	err = p.writeProduct.Edit(ctx, newProduct)
 	if err != nil {
@@ -264,21 +470,106 @@ func (p *ProductController) Change(w http.ResponseWriter, req *http.Request) {
 	httpresponse.WriteData(w, http.StatusCreated, newProduct.ID, nil)
 }
 
+// GetAll pages through the products, optionally only those in any of the
+// comma separated ?category ids.
 func (p *ProductController) GetAll(w http.ResponseWriter, req *http.Request) {
-	filterInString := req.URL.Query().Get("category")
-	IdsInArray := strings.Split(filterInString, ",")
+	filter, err := productFilter(req.URL.Query())
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
 
-	var IdsUlid = make([]ulid.ULID, len(IdsInArray))
-	for i := range IdsInArray {
-		idInUlid, err := ulid.Parse(IdsInArray[i])
+	categoryIDs, err := parseCategoryIDs(req.URL.Query())
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	var data querier.ProductList
+	if categoryIDs != nil {
+		data, err = p.readProduct.FetchByCategoryID(ctx, categoryIDs, filter)
+	} else {
+		data, err = p.readProduct.Fetch(ctx, filter)
+	}
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, data.Data, data.Cursor)
+}
+
+// parseCategoryIDs reads the comma separated ?category ids, nil when there
+// are none.
+func parseCategoryIDs(query url.Values) ([]ulid.ULID, error) {
+	category := query.Get("category")
+	if category == "" {
+		return nil, nil
+	}
+
+	var ids []ulid.ULID
+	for _, idStr := range strings.Split(category, ",") {
+		id, err := ulid.Parse(idStr)
 		if err != nil {
-			continue
+			return nil, err
 		}
-		IdsUlid[i] = idInUlid
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+var errSearchQuery = errors.New("q is required")
+
+// Search looks products up by the words of ?q in their name or
+// description, or by a SKU resembling it, best matches first. ?category and
+// ?limit work as they do for the list.
+func (p *ProductController) Search(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	text := strings.TrimSpace(query.Get("q"))
+	if text == "" {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			errSearchQuery,
+		)
+		return
+	}
+
+	page, err := parsePage(query)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	categoryIDs, err := parseCategoryIDs(query)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
 	}
 
 	ctx := req.Context()
-	data, err := p.readProduct.FetchByCategoryID(ctx, IdsUlid)
+	data, err := p.readProduct.Search(ctx, text, categoryIDs, page.Limit)
 	if err != nil {
 		httpresponse.WriteError(
 			w,
@@ -296,6 +587,59 @@ func (p *ProductController) GetAll(w http.ResponseWriter, req *http.Request) {
 	httpresponse.WriteData(w, http.StatusOK, data.Data, meta)
 }
 
+// productFilter reads the pagination parameters and the currency,
+// min_price, max_price, in_stock, include_descendants and created_since
+// filters. Prices only compare within one currency, so filtering or sorting
+// by them needs one.
+func productFilter(query url.Values) (querier.ProductFilter, error) {
+	var (
+		filter querier.ProductFilter
+		err    error
+	)
+
+	filter.Page, err = parsePage(query, "name", "amount", "created_at", "stock")
+	if err != nil {
+		return filter, err
+	}
+
+	if currency := query.Get("currency"); currency != "" {
+		if err := is.CurrencyCode.Validate(currency); err != nil {
+			return filter, err
+		}
+		filter.Currency = currency
+	}
+	if minPrice := query.Get("min_price"); minPrice != "" {
+		amount, err := model.ParseMoney(minPrice, filter.Currency)
+		if err != nil {
+			return filter, err
+		}
+		filter.MinAmount = &amount
+	}
+	if maxPrice := query.Get("max_price"); maxPrice != "" {
+		amount, err := model.ParseMoney(maxPrice, filter.Currency)
+		if err != nil {
+			return filter, err
+		}
+		filter.MaxAmount = &amount
+	}
+	if filter.Currency == "" && (filter.MinAmount != nil || filter.MaxAmount != nil || filter.Sort == "amount") {
+		return filter, model.ErrProductPriceCurrency
+	}
+
+	filter.InStock, err = parseFlag(query, "in_stock")
+	if err != nil {
+		return filter, err
+	}
+
+	filter.IncludeDescendants, err = parseFlag(query, "include_descendants")
+	if err != nil {
+		return filter, err
+	}
+
+	filter.CreatedSince, err = parseCreatedSince(query)
+	return filter, err
+}
+
 func (p *ProductController) GetOneByID(w http.ResponseWriter, req *http.Request) {
 	var idStr = chi.URLParam(req, "id")
 	id, err := ulid.Parse(idStr)
@@ -321,3 +665,176 @@ func (p *ProductController) GetOneByID(w http.ResponseWriter, req *http.Request)
 
 	httpresponse.WriteData(w, http.StatusOK, data, nil)
 }
+
+func (p *ProductController) Delete(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	product, err := p.readProduct.GetOneByID(ctx, id)
+	if err != nil {
+		writeProductError(w, err)
+		return
+	}
+
+	if err := p.writeProduct.Delete(ctx, product); err != nil {
+		writeProductError(w, err)
+		return
+	}
+
+	httpresponse.WriteMessage(w, http.StatusOK, "product deleted")
+}
+
+func (p *ProductController) Restore(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	if err := p.writeProduct.Restore(ctx, id); err != nil {
+		writeProductError(w, err)
+		return
+	}
+
+	httpresponse.WriteMessage(w, http.StatusOK, "product restored")
+}
+
+type replaceCategoriesBodyRequest struct {
+	Categories []ulid.ULID `json:"categories"`
+}
+
+func (p replaceCategoriesBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.Categories, validation.NotNil),
+	)
+}
+
+// ReplaceCategories sets the product's categories to exactly the ones in
+// the body; an empty list removes them all.
+func (p *ProductController) ReplaceCategories(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	var data replaceCategoriesBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	if err := p.writeProduct.ReplaceCategories(ctx, id, data.Categories); err != nil {
+		writeProductError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, data.Categories, nil)
+}
+
+// StockMovements pages through the stock ledger of the product, to audit
+// how its stock got to the current quantity.
+func (p *ProductController) StockMovements(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	page, err := parsePage(req.URL.Query())
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	data, err := p.readStockMovement.FetchByProductID(ctx, id, page)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, data.Data, data.Cursor)
+}
+
+func writeProductError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, model.ErrProductNotFound),
+		errors.Is(err, model.ErrProductAlreadyDeleted):
+		httpresponse.WriteError(
+			w,
+			http.StatusNotFound,
+			err,
+		)
+	case errors.Is(err, model.ErrProductNotDeleted),
+		errors.Is(err, model.ErrProductSKUDuplicated),
+		errors.Is(err, model.ErrInsufficientStock):
+		httpresponse.WriteError(
+			w,
+			http.StatusConflict,
+			err,
+		)
+	case errors.Is(err, model.ErrCategoryNotFound),
+		errors.Is(err, model.ErrWarehouseNotFound),
+		errors.Is(err, model.ErrWarehouseAlreadyDeleted):
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+	default:
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+	}
+}
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"errors"
+	"flukis/invokiss/app/model"
+	"net/url"
+	"testing"
+)
+
+func TestProductFilterPriceCurrency(t *testing.T) {
+	tests := []struct {
+		query    string
+		err      error
+		currency string
+	}{
+		{"", nil, ""},
+		{"sort=name", nil, ""},
+		{"currency=EUR", nil, "EUR"},
+		{"min_price=10&currency=EUR", nil, "EUR"},
+		{"max_price=10&currency=EUR", nil, "EUR"},
+		{"sort=amount&currency=EUR", nil, "EUR"},
+		{"min_price=10", model.ErrProductPriceCurrency, ""},
+		{"max_price=10", model.ErrProductPriceCurrency, ""},
+		{"sort=amount", model.ErrProductPriceCurrency, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			filter, err := productFilter(query)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("productFilter() error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if filter.Currency != tt.currency {
+				t.Errorf("currency = %q, want %q", filter.Currency, tt.currency)
+			}
+			for _, amount := range []*model.Money{filter.MinAmount, filter.MaxAmount} {
+				if amount != nil && amount.Currency != tt.currency {
+					t.Errorf("price in %q, want %q", amount.Currency, tt.currency)
+				}
+			}
+		})
+	}
+
+	if _, err := productFilter(url.Values{"currency": {"euro"}}); err == nil {
+		t.Error("productFilter() accepted an unknown currency")
+	}
+}
@@ -0,0 +1,266 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/http/middleware"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/database/querier"
+	"flukis/invokiss/lib/httpresponse"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/oklog/ulid/v2"
+)
+
+type QuoteController struct {
+	writeQuote   querier.QuoteWriteModel
+	readQuote    querier.QuoteReadModel
+	readInvoice  querier.InvoiceReadModel
+	readProduct  querier.ProductReadModel
+	readCustomer querier.CustomerReadModel
+	readTaxRate  querier.TaxRateReadModel
+}
+
+func NewQuoteController(
+	writeQuote querier.QuoteWriteModel,
+	readQuote querier.QuoteReadModel,
+	readInvoice querier.InvoiceReadModel,
+	readProduct querier.ProductReadModel,
+	readCustomer querier.CustomerReadModel,
+	readTaxRate querier.TaxRateReadModel,
+) *QuoteController {
+	return &QuoteController{writeQuote, readQuote, readInvoice, readProduct, readCustomer, readTaxRate}
+}
+
+func (p *QuoteController) Routes() *chi.Mux {
+	r := chi.NewMux()
+
+	read := middleware.RequirePermission(auth.PermBillingRead)
+	write := middleware.RequirePermission(auth.PermBillingWrite)
+
+	r.With(read).Get("/", p.GetAll)
+	r.With(read).Get("/{id}", p.GetOneByID)
+	r.With(write).Post("/", p.Create)
+	r.With(write).Post("/{id}/accept", p.Accept)
+	r.With(write).Post("/{id}/reject", p.Reject)
+	r.With(write).Post("/{id}/convert", p.Convert)
+
+	return r
+}
+
+type createQuoteBodyRequest struct {
+	CustomerID ulid.ULID                `json:"customer_id"`
+	ExpiresAt  time.Time                `json:"expires_at"`
+	Lines      []invoiceLineBodyRequest `json:"lines"`
+}
+
+func (p createQuoteBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.ExpiresAt, validation.Required),
+		validation.Field(&p.Lines, validation.Required),
+	)
+}
+
+func (p *QuoteController) Create(w http.ResponseWriter, req *http.Request) {
+	var data createQuoteBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	customer, err := p.readCustomer.GetOneByID(ctx, data.CustomerID)
+	if err != nil {
+		writeQuoteError(w, err)
+		return
+	}
+
+	lines, err := buildInvoiceLines(ctx, p.readProduct, p.readTaxRate, customer, data.Lines)
+	if err != nil {
+		writeQuoteError(w, err)
+		return
+	}
+
+	newQuote, err := model.NewQuote(customer, lines, data.ExpiresAt)
+	if err != nil {
+		writeQuoteError(w, err)
+		return
+	}
+
+	if err := p.writeQuote.Save(ctx, newQuote); err != nil {
+		writeQuoteError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusCreated, newQuote.ID, nil)
+}
+
+func (p *QuoteController) Accept(w http.ResponseWriter, req *http.Request) {
+	p.transition(w, req, func(q *model.Quote) error {
+		return q.Accept(time.Now())
+	})
+}
+
+func (p *QuoteController) Reject(w http.ResponseWriter, req *http.Request) {
+	p.transition(w, req, func(q *model.Quote) error {
+		return q.Reject(time.Now())
+	})
+}
+
+func (p *QuoteController) transition(w http.ResponseWriter, req *http.Request, change func(*model.Quote) error) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	data, err := p.writeQuote.Transition(ctx, id, change)
+	if err != nil {
+		writeQuoteError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, data.Status, nil)
+}
+
+// Convert answers with the draft invoice of the quote. Converting again
+// returns the same invoice with 200 instead of 201.
+func (p *QuoteController) Convert(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	invoiceID, created, err := p.writeQuote.Convert(ctx, id, func(q *model.Quote) (model.Invoice, error) {
+		return q.Convert(time.Now())
+	})
+	if err != nil {
+		writeQuoteError(w, err)
+		return
+	}
+
+	data, err := p.readInvoice.GetOneByID(ctx, invoiceID)
+	if err != nil {
+		writeInvoiceError(w, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	httpresponse.WriteData(w, status, data, nil)
+}
+
+func (p *QuoteController) GetAll(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	data, err := p.readQuote.Fetch(ctx)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+	var meta struct {
+		Total int `json:"total"`
+	}
+
+	meta.Total = data.Count
+
+	httpresponse.WriteData(w, http.StatusOK, data.Data, meta)
+}
+
+func (p *QuoteController) GetOneByID(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	data, err := p.readQuote.GetOneByID(ctx, id)
+	if err != nil {
+		writeQuoteError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, data, nil)
+}
+
+func writeQuoteError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, model.ErrQuoteNotFound),
+		errors.Is(err, model.ErrQuoteAlreadyDeleted):
+		httpresponse.WriteError(
+			w,
+			http.StatusNotFound,
+			err,
+		)
+	case errors.Is(err, model.ErrQuoteExpired),
+		errors.Is(err, model.ErrQuoteInvalidTransition),
+		errors.Is(err, model.ErrQuoteNotAccepted):
+		httpresponse.WriteError(
+			w,
+			http.StatusConflict,
+			err,
+		)
+	case errors.Is(err, model.ErrQuoteEmpty),
+		errors.Is(err, model.ErrQuoteInvalidExpiry),
+		errors.Is(err, model.ErrInvoiceEmpty),
+		errors.Is(err, model.ErrMoneyCurrencyMismatch),
+		errors.Is(err, model.ErrTaxRateNotFound),
+		errors.Is(err, model.ErrCustomerNotFound),
+		errors.Is(err, model.ErrCustomerAlreadyDeleted),
+		errors.Is(err, model.ErrProductNotFound),
+		errors.Is(err, model.ErrProductAlreadyDeleted):
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+	default:
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+	}
+}
@@ -0,0 +1,228 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/http/middleware"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/database/querier"
+	"flukis/invokiss/lib/httpresponse"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+type RecurringInvoiceController struct {
+	writeRecurringInvoice querier.RecurringInvoiceWriteModel
+	readRecurringInvoice  querier.RecurringInvoiceReadModel
+	readProduct           querier.ProductReadModel
+	readCustomer          querier.CustomerReadModel
+}
+
+func NewRecurringInvoiceController(
+	writeRecurringInvoice querier.RecurringInvoiceWriteModel,
+	readRecurringInvoice querier.RecurringInvoiceReadModel,
+	readProduct querier.ProductReadModel,
+	readCustomer querier.CustomerReadModel,
+) *RecurringInvoiceController {
+	return &RecurringInvoiceController{writeRecurringInvoice, readRecurringInvoice, readProduct, readCustomer}
+}
+
+func (p *RecurringInvoiceController) Routes() *chi.Mux {
+	r := chi.NewMux()
+
+	read := middleware.RequirePermission(auth.PermBillingRead)
+	write := middleware.RequirePermission(auth.PermBillingWrite)
+
+	r.With(read).Get("/", p.GetAll)
+	r.With(read).Get("/{id}", p.GetOneByID)
+	r.With(write).Post("/", p.Create)
+	r.With(write).Delete("/{id}", p.Delete)
+
+	return r
+}
+
+type createRecurringInvoiceBodyRequest struct {
+	CustomerID ulid.ULID                `json:"customer_id"`
+	Interval   model.RecurrenceInterval `json:"interval"`
+	AnchorDay  int                      `json:"anchor_day"`
+	StartsAt   time.Time                `json:"starts_at"`
+	EndsAt     null.Time                `json:"ends_at"`
+	MaxCount   null.Int                 `json:"max_count"`
+	AutoIssue  bool                     `json:"auto_issue"`
+	Lines      []invoiceLineBodyRequest `json:"lines"`
+}
+
+func (p createRecurringInvoiceBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.Interval, validation.Required),
+		validation.Field(&p.AnchorDay, validation.Required, validation.Min(1), validation.Max(31)),
+		validation.Field(&p.StartsAt, validation.Required),
+		validation.Field(&p.Lines, validation.Required),
+	)
+}
+
+func (p *RecurringInvoiceController) Create(w http.ResponseWriter, req *http.Request) {
+	var data createRecurringInvoiceBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	customer, err := p.readCustomer.GetOneByID(ctx, data.CustomerID)
+	if err != nil {
+		writeRecurringInvoiceError(w, err)
+		return
+	}
+
+	var lines = make([]model.RecurringInvoiceLine, len(data.Lines))
+	for idx, l := range data.Lines {
+		if _, err := p.readProduct.GetOneByID(ctx, l.ProductID); err != nil {
+			writeRecurringInvoiceError(w, err)
+			return
+		}
+		lines[idx] = model.NewRecurringInvoiceLine(l.ProductID, l.Quantity)
+	}
+
+	newRecurringInvoice, err := model.NewRecurringInvoice(
+		customer.ID,
+		data.Interval,
+		data.AnchorDay,
+		data.StartsAt,
+		data.EndsAt,
+		data.MaxCount,
+		data.AutoIssue,
+		lines,
+	)
+	if err != nil {
+		writeRecurringInvoiceError(w, err)
+		return
+	}
+
+	if err := p.writeRecurringInvoice.Save(ctx, newRecurringInvoice); err != nil {
+		writeRecurringInvoiceError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusCreated, newRecurringInvoice.ID, nil)
+}
+
+func (p *RecurringInvoiceController) Delete(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	recurringInvoice, err := p.readRecurringInvoice.GetOneByID(ctx, id)
+	if err != nil {
+		writeRecurringInvoiceError(w, err)
+		return
+	}
+
+	if err := p.writeRecurringInvoice.Delete(ctx, recurringInvoice); err != nil {
+		writeRecurringInvoiceError(w, err)
+		return
+	}
+
+	httpresponse.WriteMessage(w, http.StatusOK, "recurring invoice deleted")
+}
+
+func (p *RecurringInvoiceController) GetAll(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	data, err := p.readRecurringInvoice.Fetch(ctx)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+	var meta struct {
+		Total int `json:"total"`
+	}
+
+	meta.Total = data.Count
+
+	httpresponse.WriteData(w, http.StatusOK, data.Data, meta)
+}
+
+func (p *RecurringInvoiceController) GetOneByID(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	data, err := p.readRecurringInvoice.GetOneByID(ctx, id)
+	if err != nil {
+		writeRecurringInvoiceError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, data, nil)
+}
+
+func writeRecurringInvoiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, model.ErrRecurringInvoiceNotFound),
+		errors.Is(err, model.ErrRecurringInvoiceAlreadyDeleted):
+		httpresponse.WriteError(
+			w,
+			http.StatusNotFound,
+			err,
+		)
+	case errors.Is(err, model.ErrRecurringInvoiceEmpty),
+		errors.Is(err, model.ErrRecurringInvoiceAnchorDay),
+		errors.Is(err, model.ErrRecurringInvoiceInvalidEnd),
+		errors.Is(err, model.ErrRecurrenceIntervalInvalid),
+		errors.Is(err, model.ErrCustomerNotFound),
+		errors.Is(err, model.ErrCustomerAlreadyDeleted),
+		errors.Is(err, model.ErrProductNotFound),
+		errors.Is(err, model.ErrProductAlreadyDeleted):
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+	default:
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+	}
+}
@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/http/middleware"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/database/querier"
+	"flukis/invokiss/lib/httpresponse"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+type TaxRateController struct {
+	writeTaxRate querier.TaxRateWriteModel
+	readTaxRate  querier.TaxRateReadModel
+}
+
+func NewTaxRateController(
+	writeTaxRate querier.TaxRateWriteModel,
+	readTaxRate querier.TaxRateReadModel,
+) *TaxRateController {
+	return &TaxRateController{writeTaxRate, readTaxRate}
+}
+
+func (p *TaxRateController) Routes() *chi.Mux {
+	r := chi.NewMux()
+
+	read := middleware.RequirePermission(auth.PermBillingRead)
+	write := middleware.RequirePermission(auth.PermBillingWrite)
+
+	r.With(read).Get("/", p.GetAll)
+	r.With(read).Get("/{id}", p.GetOneByID)
+	r.With(write).Post("/", p.Create)
+	r.With(write).Delete("/{id}", p.Delete)
+
+	return r
+}
+
+type createTaxRateBodyRequest struct {
+	Class        model.TaxClass `json:"class"`
+	Jurisdiction string         `json:"jurisdiction"`
+	Name         string         `json:"name"`
+	Rate         int            `json:"rate"`
+	ValidFrom    time.Time      `json:"valid_from"`
+	ValidTo      null.Time      `json:"valid_to"`
+}
+
+func (p createTaxRateBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.Class, validation.Required),
+		validation.Field(&p.Jurisdiction, is.CountryCode2),
+		validation.Field(&p.Name, validation.Required),
+		validation.Field(&p.Rate, validation.Min(0)),
+		validation.Field(&p.ValidFrom, validation.Required),
+	)
+}
+
+func (p *TaxRateController) Create(w http.ResponseWriter, req *http.Request) {
+	var data createTaxRateBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	newTaxRate, err := model.NewTaxRate(
+		data.Class,
+		data.Jurisdiction,
+		data.Name,
+		data.Rate,
+		data.ValidFrom,
+		data.ValidTo,
+	)
+	if err != nil {
+		writeTaxRateError(w, err)
+		return
+	}
+
+	if err := p.writeTaxRate.Save(ctx, newTaxRate); err != nil {
+		writeTaxRateError(w, err)
+		return
+	}
+	httpresponse.WriteData(w, http.StatusCreated, newTaxRate.ID, nil)
+}
+
+func (p *TaxRateController) Delete(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	taxRate, err := p.readTaxRate.GetOneByID(ctx, id)
+	if err != nil {
+		writeTaxRateError(w, err)
+		return
+	}
+
+	if err := p.writeTaxRate.Delete(ctx, taxRate); err != nil {
+		writeTaxRateError(w, err)
+		return
+	}
+
+	httpresponse.WriteMessage(w, http.StatusOK, "tax rate deleted")
+}
+
+func (p *TaxRateController) GetAll(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	data, err := p.readTaxRate.Fetch(ctx)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+	var meta struct {
+		Total int `json:"total"`
+	}
+
+	meta.Total = data.Count
+
+	httpresponse.WriteData(w, http.StatusOK, data.Data, meta)
+}
+
+func (p *TaxRateController) GetOneByID(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	data, err := p.readTaxRate.GetOneByID(ctx, id)
+	if err != nil {
+		writeTaxRateError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, data, nil)
+}
+
+func writeTaxRateError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, model.ErrTaxRateNotFound),
+		errors.Is(err, model.ErrTaxRateAlreadyDeleted):
+		httpresponse.WriteError(
+			w,
+			http.StatusNotFound,
+			err,
+		)
+	case errors.Is(err, model.ErrTaxRateOverlap):
+		httpresponse.WriteError(
+			w,
+			http.StatusConflict,
+			err,
+		)
+	case errors.Is(err, model.ErrTaxRateInvalidPeriod):
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+	default:
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+	}
+}
@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/http/middleware"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/database/querier"
+	"flukis/invokiss/lib/httpresponse"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+// TenantController serves the caller's own tenant.
+type TenantController struct {
+	writeTenant querier.TenantWriteModel
+	readTenant  querier.TenantReadModel
+}
+
+func NewTenantController(
+	writeTenant querier.TenantWriteModel,
+	readTenant querier.TenantReadModel,
+) *TenantController {
+	return &TenantController{writeTenant, readTenant}
+}
+
+func (p *TenantController) Routes() *chi.Mux {
+	r := chi.NewMux()
+
+	read := middleware.RequirePermission(auth.PermBillingRead)
+	manage := middleware.RequirePermission(auth.PermUsersManage)
+
+	r.With(read).Get("/", p.Get)
+	r.With(manage).Put("/seller", p.ChangeSeller)
+	r.With(manage).Put("/alert-webhook", p.ChangeAlertWebhook)
+
+	return r
+}
+
+type sellerBodyRequest struct {
+	Name                string             `json:"name"`
+	Address             addressBodyRequest `json:"address"`
+	TaxID               string             `json:"tax_id"`
+	Email               string             `json:"email"`
+	PaymentInstructions string             `json:"payment_instructions"`
+}
+
+func (p sellerBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.Name, validation.Required),
+		validation.Field(&p.Address),
+		validation.Field(&p.Email, is.EmailFormat),
+	)
+}
+
+func (p *TenantController) Get(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	principal, _ := auth.PrincipalFrom(ctx)
+	data, err := p.readTenant.GetOneByID(ctx, principal.TenantID)
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, data, nil)
+}
+
+// ChangeSeller sets the details printed on the tenant's invoices, including
+// the PDFs of the invoices issued before.
+func (p *TenantController) ChangeSeller(w http.ResponseWriter, req *http.Request) {
+	var data sellerBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	principal, _ := auth.PrincipalFrom(ctx)
+	seller := model.Seller{
+		Name:                data.Name,
+		Address:             data.Address.Address(),
+		TaxID:               data.TaxID,
+		Email:               data.Email,
+		PaymentInstructions: data.PaymentInstructions,
+	}
+	if err := p.writeTenant.EditSeller(ctx, principal.TenantID, seller); err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, principal.TenantID, nil)
+}
+
+type alertWebhookBodyRequest struct {
+	URL string `json:"url"`
+}
+
+func (p alertWebhookBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.URL, is.URL),
+	)
+}
+
+// ChangeAlertWebhook sets where the tenant's stock alerts are posted; an
+// empty URL sends them to the server's own webhook again.
+func (p *TenantController) ChangeAlertWebhook(w http.ResponseWriter, req *http.Request) {
+	var data alertWebhookBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	principal, _ := auth.PrincipalFrom(ctx)
+	if err := p.writeTenant.EditAlertWebhook(ctx, principal.TenantID, data.URL); err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, principal.TenantID, nil)
+}
+
+func writeTenantError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, model.ErrTenantNotFound):
+		httpresponse.WriteError(
+			w,
+			http.StatusNotFound,
+			err,
+		)
+	default:
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+	}
+}
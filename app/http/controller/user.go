@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/http/middleware"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/database/querier"
+	"flukis/invokiss/lib/httpresponse"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+	"github.com/oklog/ulid/v2"
+)
+
+type UserController struct {
+	writeUser querier.UserWriteModel
+	readUser  querier.UserReadModel
+}
+
+func NewUserController(
+	writeUser querier.UserWriteModel,
+	readUser querier.UserReadModel,
+) *UserController {
+	return &UserController{writeUser, readUser}
+}
+
+func (p *UserController) Routes() *chi.Mux {
+	r := chi.NewMux()
+
+	r.Use(middleware.RequirePermission(auth.PermUsersManage))
+
+	r.Get("/", p.GetAll)
+	r.Get("/{id}", p.GetOneByID)
+	r.Post("/", p.Create)
+	r.Delete("/{id}", p.Delete)
+
+	return r
+}
+
+type createUserBodyRequest struct {
+	Email    string     `json:"email"`
+	Name     string     `json:"name"`
+	Password string     `json:"password"`
+	Role     model.Role `json:"role"`
+}
+
+func (p createUserBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.Email, validation.Required, is.EmailFormat),
+		validation.Field(&p.Name, validation.Required),
+		validation.Field(&p.Password, validation.Required, validation.Length(8, 72)),
+		validation.Field(&p.Role, validation.Required),
+	)
+}
+
+func (p *UserController) Create(w http.ResponseWriter, req *http.Request) {
+	var data createUserBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	principal, _ := auth.PrincipalFrom(ctx)
+	newUser, err := model.NewUser(principal.TenantID, data.Email, data.Name, data.Password, data.Role)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	if err := p.writeUser.Save(ctx, newUser); err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusCreated, newUser.ID, nil)
+}
+
+func (p *UserController) Delete(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	user, err := p.getUser(ctx, id)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	if err := p.writeUser.Delete(ctx, user); err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	httpresponse.WriteMessage(w, http.StatusOK, "user deleted")
+}
+
+func (p *UserController) GetAll(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	principal, _ := auth.PrincipalFrom(ctx)
+	data, err := p.readUser.Fetch(ctx, principal.TenantID)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+	var meta struct {
+		Total int `json:"total"`
+	}
+
+	meta.Total = data.Count
+
+	httpresponse.WriteData(w, http.StatusOK, data.Data, meta)
+}
+
+func (p *UserController) GetOneByID(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	data, err := p.getUser(ctx, id)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, data, nil)
+}
+
+// getUser finds a user of the caller's tenant; users of other tenants are
+// reported as not found.
+func (p *UserController) getUser(ctx context.Context, id ulid.ULID) (model.User, error) {
+	user, err := p.readUser.GetOneByID(ctx, id)
+	if err != nil {
+		return user, err
+	}
+	principal, _ := auth.PrincipalFrom(ctx)
+	if user.TenantID != principal.TenantID {
+		return model.User{}, model.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func writeUserError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, model.ErrUserNotFound),
+		errors.Is(err, model.ErrUserAlreadyDeleted):
+		httpresponse.WriteError(
+			w,
+			http.StatusNotFound,
+			err,
+		)
+	case errors.Is(err, model.ErrRoleInvalid):
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+	case errors.Is(err, model.ErrUserEmailDuplicated):
+		httpresponse.WriteError(
+			w,
+			http.StatusConflict,
+			err,
+		)
+	default:
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+	}
+}
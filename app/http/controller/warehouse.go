@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/http/middleware"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/database/querier"
+	"flukis/invokiss/lib/httpresponse"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/oklog/ulid/v2"
+)
+
+type WarehouseController struct {
+	writeWarehouse querier.WarehouseWriteModel
+	readWarehouse  querier.WarehouseReadModel
+}
+
+func NewWarehouseController(
+	writeWarehouse querier.WarehouseWriteModel,
+	readWarehouse querier.WarehouseReadModel,
+) *WarehouseController {
+	return &WarehouseController{writeWarehouse, readWarehouse}
+}
+
+func (p *WarehouseController) Routes() *chi.Mux {
+	r := chi.NewMux()
+
+	read := middleware.RequirePermission(auth.PermCatalogRead)
+	write := middleware.RequirePermission(auth.PermCatalogWrite)
+
+	r.With(read).Get("/", p.GetAll)
+	r.With(read).Get("/{id}", p.GetOneByID)
+	r.With(write).Post("/", p.Create)
+	r.With(write).Put("/{id}", p.Change)
+	r.With(write).Delete("/{id}", p.Delete)
+
+	return r
+}
+
+type warehouseBodyRequest struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+func (p warehouseBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.Code, validation.Required, validation.Length(1, 25)),
+		validation.Field(&p.Name, validation.Required),
+	)
+}
+
+func (p *WarehouseController) Create(w http.ResponseWriter, req *http.Request) {
+	var data warehouseBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	newWarehouse := model.NewWarehouse(data.Code, data.Name)
+	if err := p.writeWarehouse.Save(ctx, newWarehouse); err != nil {
+		writeWarehouseError(w, err)
+		return
+	}
+	httpresponse.WriteData(w, http.StatusCreated, newWarehouse.ID, nil)
+}
+
+func (p *WarehouseController) GetAll(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	data, err := p.readWarehouse.Fetch(ctx)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+	var meta struct {
+		Total int `json:"total"`
+	}
+
+	meta.Total = data.Count
+
+	httpresponse.WriteData(w, http.StatusOK, data.Data, meta)
+}
+
+func (p *WarehouseController) GetOneByID(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	data, err := p.readWarehouse.GetOneByID(ctx, id)
+	if err != nil {
+		writeWarehouseError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, data, nil)
+}
+
+func (p *WarehouseController) Change(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	var data warehouseBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	warehouse, err := p.readWarehouse.GetOneByID(ctx, id)
+	if err != nil {
+		writeWarehouseError(w, err)
+		return
+	}
+
+	warehouse.Code = data.Code
+	warehouse.Name = data.Name
+	if err := p.writeWarehouse.Edit(ctx, warehouse); err != nil {
+		writeWarehouseError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, warehouse.ID, nil)
+}
+
+// Delete soft-deletes the warehouse. It is refused with 409 for the default
+// warehouse and while the warehouse holds stock or has some in transit.
+func (p *WarehouseController) Delete(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	if err := p.writeWarehouse.Delete(ctx, id); err != nil {
+		writeWarehouseError(w, err)
+		return
+	}
+
+	httpresponse.WriteMessage(w, http.StatusOK, "warehouse deleted")
+}
+
+func writeWarehouseError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, model.ErrWarehouseNotFound),
+		errors.Is(err, model.ErrWarehouseAlreadyDeleted):
+		httpresponse.WriteError(
+			w,
+			http.StatusNotFound,
+			err,
+		)
+	case errors.Is(err, model.ErrWarehouseCodeDuplicated),
+		errors.Is(err, model.ErrWarehouseIsDefault),
+		errors.Is(err, model.ErrWarehouseHasStock):
+		httpresponse.WriteError(
+			w,
+			http.StatusConflict,
+			err,
+		)
+	default:
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+	}
+}
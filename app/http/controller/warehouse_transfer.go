@@ -0,0 +1,267 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/http/middleware"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/database/querier"
+	"flukis/invokiss/lib/httpresponse"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/oklog/ulid/v2"
+)
+
+var errWarehouseTransferStatus = errors.New("status must be in_transit, received or cancelled")
+
+type WarehouseTransferController struct {
+	writeWarehouseTransfer querier.WarehouseTransferWriteModel
+	readWarehouseTransfer  querier.WarehouseTransferReadModel
+}
+
+func NewWarehouseTransferController(
+	writeWarehouseTransfer querier.WarehouseTransferWriteModel,
+	readWarehouseTransfer querier.WarehouseTransferReadModel,
+) *WarehouseTransferController {
+	return &WarehouseTransferController{writeWarehouseTransfer, readWarehouseTransfer}
+}
+
+func (p *WarehouseTransferController) Routes() *chi.Mux {
+	r := chi.NewMux()
+
+	read := middleware.RequirePermission(auth.PermCatalogRead)
+	stock := middleware.RequirePermission(auth.PermInventoryWrite)
+
+	r.With(read).Get("/", p.GetAll)
+	r.With(read).Get("/{id}", p.GetOneByID)
+	r.With(stock).Post("/", p.Create)
+	r.With(stock).Post("/{id}/receive", p.Receive)
+	r.With(stock).Post("/{id}/cancel", p.Cancel)
+
+	return r
+}
+
+type createWarehouseTransferBodyRequest struct {
+	ProductID       ulid.ULID `json:"product_id"`
+	FromWarehouseID ulid.ULID `json:"from_warehouse_id"`
+	ToWarehouseID   ulid.ULID `json:"to_warehouse_id"`
+	Quantity        int       `json:"qty"`
+	Reference       string    `json:"reference"`
+}
+
+func (p createWarehouseTransferBodyRequest) Validate() error {
+	return validation.ValidateStruct(
+		&p,
+		validation.Field(&p.Quantity, validation.Required, validation.Min(1)),
+		validation.Field(&p.Reference, validation.Length(0, 100)),
+	)
+}
+
+// Create ships qty of the product from one warehouse to another. The stock
+// leaves the source right away and stays in transit until the transfer is
+// received or cancelled.
+func (p *WarehouseTransferController) Create(w http.ResponseWriter, req *http.Request) {
+	var data createWarehouseTransferBodyRequest
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	if err := data.Validate(); err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	transfer, err := model.NewWarehouseTransfer(
+		data.ProductID,
+		data.FromWarehouseID,
+		data.ToWarehouseID,
+		data.Quantity,
+		data.Reference,
+		time.Now(),
+	)
+	if err != nil {
+		writeWarehouseTransferError(w, err)
+		return
+	}
+
+	if err := p.writeWarehouseTransfer.Ship(ctx, transfer); err != nil {
+		writeWarehouseTransferError(w, err)
+		return
+	}
+	httpresponse.WriteData(w, http.StatusCreated, transfer, nil)
+}
+
+func (p *WarehouseTransferController) GetAll(w http.ResponseWriter, req *http.Request) {
+	filter, err := warehouseTransferFilter(req.URL.Query())
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	data, err := p.readWarehouseTransfer.Fetch(ctx, filter)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, data.Data, data.Cursor)
+}
+
+// warehouseTransferFilter reads the pagination parameters and the status,
+// product and warehouse filters.
+func warehouseTransferFilter(query url.Values) (querier.WarehouseTransferFilter, error) {
+	var (
+		filter querier.WarehouseTransferFilter
+		err    error
+	)
+
+	filter.Page, err = parsePage(query)
+	if err != nil {
+		return filter, err
+	}
+
+	switch status := model.WarehouseTransferStatus(query.Get("status")); status {
+	case "",
+		model.WarehouseTransferInTransit,
+		model.WarehouseTransferReceived,
+		model.WarehouseTransferCancelled:
+		filter.Status = status
+	default:
+		return filter, errWarehouseTransferStatus
+	}
+
+	if product := query.Get("product"); product != "" {
+		id, err := ulid.Parse(product)
+		if err != nil {
+			return filter, err
+		}
+		filter.ProductID = &id
+	}
+
+	if warehouse := query.Get("warehouse"); warehouse != "" {
+		id, err := ulid.Parse(warehouse)
+		if err != nil {
+			return filter, err
+		}
+		filter.WarehouseID = &id
+	}
+
+	return filter, nil
+}
+
+func (p *WarehouseTransferController) GetOneByID(w http.ResponseWriter, req *http.Request) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	data, err := p.readWarehouseTransfer.GetOneByID(ctx, id)
+	if err != nil {
+		writeWarehouseTransferError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, data, nil)
+}
+
+// Receive books the transfer's stock into the destination warehouse.
+func (p *WarehouseTransferController) Receive(w http.ResponseWriter, req *http.Request) {
+	p.settle(w, req, func(t *model.WarehouseTransfer) error {
+		return t.Receive(time.Now())
+	})
+}
+
+// Cancel books the transfer's stock back into the source warehouse.
+func (p *WarehouseTransferController) Cancel(w http.ResponseWriter, req *http.Request) {
+	p.settle(w, req, func(t *model.WarehouseTransfer) error {
+		return t.Cancel(time.Now())
+	})
+}
+
+func (p *WarehouseTransferController) settle(w http.ResponseWriter, req *http.Request, change func(*model.WarehouseTransfer) error) {
+	var idStr = chi.URLParam(req, "id")
+	id, err := ulid.Parse(idStr)
+	if err != nil {
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	ctx := req.Context()
+	transfer, err := p.writeWarehouseTransfer.Settle(ctx, id, change)
+	if err != nil {
+		writeWarehouseTransferError(w, err)
+		return
+	}
+
+	httpresponse.WriteData(w, http.StatusOK, transfer, nil)
+}
+
+func writeWarehouseTransferError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, model.ErrWarehouseTransferNotFound):
+		httpresponse.WriteError(
+			w,
+			http.StatusNotFound,
+			err,
+		)
+	case errors.Is(err, model.ErrWarehouseTransferNotInTransit),
+		errors.Is(err, model.ErrInsufficientStock):
+		httpresponse.WriteError(
+			w,
+			http.StatusConflict,
+			err,
+		)
+	case errors.Is(err, model.ErrWarehouseTransferInvalidQuantity),
+		errors.Is(err, model.ErrWarehouseTransferSameWarehouse),
+		errors.Is(err, model.ErrProductNotFound),
+		errors.Is(err, model.ErrProductAlreadyDeleted),
+		errors.Is(err, model.ErrWarehouseNotFound),
+		errors.Is(err, model.ErrWarehouseAlreadyDeleted):
+		httpresponse.WriteError(
+			w,
+			http.StatusBadRequest,
+			err,
+		)
+	default:
+		httpresponse.WriteError(
+			w,
+			http.StatusInternalServerError,
+			err,
+		)
+	}
+}
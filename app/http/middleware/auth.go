@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/database/querier"
+	"flukis/invokiss/lib/httpresponse"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var ErrMissingToken = errors.New("auth: missing bearer token")
+
+// Authenticate rejects requests without a valid access token or API key and
+// stores the caller's principal in the request context, scoping the request
+// to the caller's tenant. Access tokens are sent as Authorization: Bearer;
+// API keys either the same way or in the X-API-Key header.
+func Authenticate(
+	issuer *auth.TokenIssuer,
+	readAPIKey querier.APIKeyReadModel,
+	writeAPIKey querier.APIKeyWriteModel,
+) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var (
+				principal auth.Principal
+				err       error
+			)
+			if key := req.Header.Get("X-API-Key"); key != "" {
+				principal, err = authenticateAPIKey(req.Context(), readAPIKey, writeAPIKey, key)
+			} else if token, ok := bearerToken(req); !ok {
+				err = ErrMissingToken
+			} else if strings.HasPrefix(token, model.APIKeyPrefix) {
+				principal, err = authenticateAPIKey(req.Context(), readAPIKey, writeAPIKey, token)
+			} else {
+				principal, err = issuer.ParseAccess(token)
+			}
+			if err != nil {
+				status := http.StatusUnauthorized
+				if !isCredentialError(err) {
+					status = http.StatusInternalServerError
+				}
+				httpresponse.WriteError(
+					w,
+					status,
+					err,
+				)
+				return
+			}
+
+			ctx := auth.WithPrincipal(req.Context(), principal)
+			ctx = auth.WithTenant(ctx, principal.TenantID)
+			next.ServeHTTP(w, req.WithContext(ctx))
+		})
+	}
+}
+
+func authenticateAPIKey(
+	ctx context.Context,
+	readAPIKey querier.APIKeyReadModel,
+	writeAPIKey querier.APIKeyWriteModel,
+	plain string,
+) (auth.Principal, error) {
+	id, secret, err := model.ParseAPIKey(plain)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+
+	key, err := readAPIKey.GetOneByID(ctx, id)
+	if errors.Is(err, model.ErrAPIKeyNotFound) {
+		err = model.ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return auth.Principal{}, err
+	}
+
+	now := time.Now()
+	if err := key.Verify(secret, now); err != nil {
+		return auth.Principal{}, err
+	}
+	if err := writeAPIKey.MarkUsed(ctx, key.ID, now); err != nil {
+		return auth.Principal{}, err
+	}
+
+	return auth.APIKeyPrincipal(key), nil
+}
+
+// isCredentialError tells a rejected credential apart from a failure to
+// check it.
+func isCredentialError(err error) bool {
+	return errors.Is(err, ErrMissingToken) ||
+		errors.Is(err, auth.ErrInvalidToken) ||
+		errors.Is(err, model.ErrAPIKeyInvalid) ||
+		errors.Is(err, model.ErrAPIKeyExpired) ||
+		errors.Is(err, model.ErrAPIKeyRevoked)
+}
+
+func bearerToken(req *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+var ErrForbidden = errors.New("auth: permission denied")
+
+// RequirePermission only lets requests through whose principal has perm. It
+// has to run after Authenticate.
+func RequirePermission(perm auth.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			principal, ok := auth.PrincipalFrom(req.Context())
+			if !ok {
+				httpresponse.WriteError(
+					w,
+					http.StatusUnauthorized,
+					ErrMissingToken,
+				)
+				return
+			}
+			if !principal.Can(perm) {
+				httpresponse.WriteError(
+					w,
+					http.StatusForbidden,
+					ErrForbidden,
+				)
+				return
+			}
+
+			next.ServeHTTP(w, req)
+		})
+	}
+}
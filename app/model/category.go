@@ -11,6 +11,10 @@ import (
 var (
 	ErrCategoryNotFound       = errors.New("category: not found")
 	ErrCategoryAlreadyDeleted = errors.New("category: already deleted")
+	ErrCategoryHasProducts    = errors.New("category: still has products")
+	ErrCategoryReassignTarget = errors.New("category: products cannot be moved to that category")
+	ErrCategoryHasChildren    = errors.New("category: still has subcategories")
+	ErrCategoryCycle          = errors.New("category: cannot be moved below itself")
 )
 
 type Category struct {
@@ -19,18 +23,64 @@ type Category struct {
 	UpdatedAt null.Time `json:"updated_at"`
 	DeletedAt null.Time `json:"deleted_at"`
 
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	// ParentID is the category this one is nested in, nil at the top.
+	ParentID    *ulid.ULID `json:"parent_id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
 }
 
 func NewCategory(
+	ParentID *ulid.ULID,
 	Name, Description string,
 ) Category {
 	id := ulid.Make()
 	return Category{
 		ID:          id,
 		CreatedAt:   time.Now(),
+		ParentID:    ParentID,
 		Name:        Name,
 		Description: Description,
 	}
 }
+
+// CategoryNode is a category with its subcategories.
+type CategoryNode struct {
+	Category
+	Children []CategoryNode `json:"children"`
+}
+
+// BuildCategoryTree nests the categories under their parents, keeping their
+// order among siblings. Categories whose parent is not among them are
+// placed at the top.
+func BuildCategoryTree(categories []Category) []CategoryNode {
+	var known = make(map[ulid.ULID]bool, len(categories))
+	for _, c := range categories {
+		known[c.ID] = true
+	}
+
+	var (
+		roots    []Category
+		children = make(map[ulid.ULID][]Category)
+	)
+	for _, c := range categories {
+		if c.ParentID == nil || !known[*c.ParentID] {
+			roots = append(roots, c)
+			continue
+		}
+		children[*c.ParentID] = append(children[*c.ParentID], c)
+	}
+
+	var build func(level []Category) []CategoryNode
+	build = func(level []Category) []CategoryNode {
+		var nodes = make([]CategoryNode, len(level))
+		for idx, c := range level {
+			nodes[idx] = CategoryNode{
+				Category: c,
+				Children: build(children[c.ID]),
+			}
+		}
+		return nodes
+	}
+
+	return build(roots)
+}
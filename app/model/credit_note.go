@@ -0,0 +1,249 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+var (
+	ErrCreditNoteNotFound        = errors.New("credit note: not found")
+	ErrCreditNoteAlreadyDeleted  = errors.New("credit note: already deleted")
+	ErrCreditNoteEmpty           = errors.New("credit note: has no lines")
+	ErrCreditNoteLineNotFound    = errors.New("credit note: line is not on the invoice")
+	ErrCreditNoteInvalidQuantity = errors.New("credit note: quantity must be positive")
+	ErrCreditNoteExceedsInvoice  = errors.New("credit note: quantity exceeds what is left to credit")
+)
+
+// CreditNote corrects an issued invoice. Its lines copy the invoice lines
+// they credit, tax rate included, so the tax is reversed exactly as it was
+// charged.
+type CreditNote struct {
+	ID        ulid.ULID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt null.Time `json:"updated_at"`
+	DeletedAt null.Time `json:"deleted_at"`
+
+	Number     string    `json:"number"`
+	InvoiceID  ulid.ULID `json:"invoice_id"`
+	CustomerID ulid.ULID `json:"customer_id"`
+	BillTo     BillTo    `json:"bill_to"`
+	Currency   string    `json:"currency"`
+	Reason     string    `json:"reason"`
+	Restock    bool      `json:"restock"`
+	IssuedAt   time.Time `json:"issued_at"`
+
+	// Applied is the part of the total taken off the invoice balance; the
+	// rest is owed back to the customer.
+	Applied Money `json:"applied"`
+
+	Lines []CreditNoteLine `json:"lines"`
+}
+
+type CreditNoteLine struct {
+	ID            ulid.ULID `json:"id"`
+	CreditNoteID  ulid.ULID `json:"credit_note_id"`
+	InvoiceLineID ulid.ULID `json:"invoice_line_id"`
+	ProductID     ulid.ULID `json:"product_id"`
+
+	Sku      string `json:"sku"`
+	Name     string `json:"name"`
+	Amount   Money  `json:"amount"`
+	Quantity int    `json:"quantity"`
+
+	TaxClass         TaxClass `json:"tax_class"`
+	TaxName          string   `json:"tax_name"`
+	TaxRate          int      `json:"tax_rate"`
+	PriceIncludesTax bool     `json:"price_includes_tax"`
+	// TaxAmount is the tax the line takes back, shown as its tax.
+	TaxAmount Money `json:"-"`
+}
+
+// CreditedLine asks for quantity of an invoice line to be credited.
+type CreditedLine struct {
+	InvoiceLineID ulid.ULID
+	Quantity      int
+}
+
+// Credited is what earlier credit notes took back of an invoice line.
+type Credited struct {
+	Quantity int
+	Tax      Money
+}
+
+// NewCreditNote credits the requested quantities of the invoice lines.
+// credited holds what earlier credit notes already took back, per invoice
+// line, so no line can be credited beyond what was invoiced. Tax is rounded
+// per line, so the tax of a line credited in parts would not add up to what
+// was charged; the credit that completes a line takes back whatever tax is
+// left of it instead.
+func NewCreditNote(
+	inv Invoice,
+	credited map[ulid.ULID]Credited,
+	requested []CreditedLine,
+	Reason string,
+	Restock bool,
+	at time.Time,
+) (CreditNote, error) {
+	if !inv.IsCreditable() {
+		return CreditNote{}, ErrInvoiceNotCreditable
+	}
+	if len(requested) == 0 {
+		return CreditNote{}, ErrCreditNoteEmpty
+	}
+
+	var invoiceLines = make(map[ulid.ULID]InvoiceLine, len(inv.Lines))
+	for _, l := range inv.Lines {
+		invoiceLines[l.ID] = l
+	}
+
+	id := ulid.Make()
+	var (
+		taken    = make(map[ulid.ULID]int, len(requested))
+		takenTax = make(map[ulid.ULID]int64, len(requested))
+		lines    = make([]CreditNoteLine, 0, len(requested))
+	)
+	for _, r := range requested {
+		l, ok := invoiceLines[r.InvoiceLineID]
+		if !ok {
+			return CreditNote{}, ErrCreditNoteLineNotFound
+		}
+		if r.Quantity <= 0 {
+			return CreditNote{}, ErrCreditNoteInvalidQuantity
+		}
+		taken[l.ID] += r.Quantity
+		done := credited[l.ID].Quantity + taken[l.ID]
+		if done > l.Quantity {
+			return CreditNote{}, ErrCreditNoteExceedsInvoice
+		}
+
+		tax := CalculateLineTax(l.Amount.Mul(int64(r.Quantity)), l.TaxRate, l.PriceIncludesTax).Tax
+		if done == l.Quantity {
+			tax.Amount = l.Tax().Tax.Amount - credited[l.ID].Tax.Amount - takenTax[l.ID]
+		}
+		takenTax[l.ID] += tax.Amount
+
+		lines = append(lines, CreditNoteLine{
+			ID:               ulid.Make(),
+			CreditNoteID:     id,
+			InvoiceLineID:    l.ID,
+			ProductID:        l.ProductID,
+			Sku:              l.Sku,
+			Name:             l.Name,
+			Amount:           l.Amount,
+			Quantity:         r.Quantity,
+			TaxClass:         l.TaxClass,
+			TaxName:          l.TaxName,
+			TaxRate:          l.TaxRate,
+			PriceIncludesTax: l.PriceIncludesTax,
+			TaxAmount:        tax,
+		})
+	}
+
+	return CreditNote{
+		ID:         id,
+		CreatedAt:  at,
+		InvoiceID:  inv.ID,
+		CustomerID: inv.CustomerID,
+		BillTo:     inv.BillTo,
+		Currency:   inv.Currency,
+		Reason:     Reason,
+		Restock:    Restock,
+		IssuedAt:   at,
+		Applied:    NewMoney(0, inv.Currency),
+		Lines:      lines,
+	}, nil
+}
+
+// NewFullCreditNote credits everything on the invoice that has not been
+// credited yet.
+func NewFullCreditNote(
+	inv Invoice,
+	credited map[ulid.ULID]Credited,
+	Reason string,
+	Restock bool,
+	at time.Time,
+) (CreditNote, error) {
+	var requested []CreditedLine
+	for _, l := range inv.Lines {
+		if left := l.Quantity - credited[l.ID].Quantity; left > 0 {
+			requested = append(requested, CreditedLine{
+				InvoiceLineID: l.ID,
+				Quantity:      left,
+			})
+		}
+	}
+	return NewCreditNote(inv, credited, requested, Reason, Restock, at)
+}
+
+func (l CreditNoteLine) Taxed() TaxedLine {
+	tax := l.TaxAmount
+	return TaxedLine{
+		Amount:    l.Amount.Mul(int64(l.Quantity)),
+		TaxClass:  l.TaxClass,
+		TaxName:   l.TaxName,
+		TaxRate:   l.TaxRate,
+		Inclusive: l.PriceIncludesTax,
+		Tax:       &tax,
+	}
+}
+
+func (l CreditNoteLine) Tax() LineTax {
+	return l.Taxed().Calculate()
+}
+
+func (l CreditNoteLine) MarshalJSON() ([]byte, error) {
+	type creditNoteLine CreditNoteLine
+	var j struct {
+		creditNoteLine
+		LineTax
+	}
+
+	j.creditNoteLine = creditNoteLine(l)
+	j.LineTax = l.Tax()
+
+	return json.Marshal(j)
+}
+
+// Totals are the amounts credited, with the tax reversed per rate. The
+// amounts are positive; a credit note always reduces what is owed.
+func (c CreditNote) Totals() TaxSummary {
+	var lines = make([]TaxedLine, len(c.Lines))
+	for idx := range c.Lines {
+		lines[idx] = c.Lines[idx].Taxed()
+	}
+	return SummarizeTax(c.Currency, lines)
+}
+
+func (c CreditNote) Total() Money {
+	return c.Totals().Total
+}
+
+// CustomerCredit is the part of the total the invoice had no balance left
+// for, e.g. because it was paid already. It is held for the customer as an
+// unallocated payment of its own, to be allocated to other invoices; ok is
+// false when all of the credit was applied to the invoice.
+func (c CreditNote) CustomerCredit() (credit Payment, ok bool) {
+	excess := c.Total().Amount - c.Applied.Amount
+	if excess <= 0 {
+		return Payment{}, false
+	}
+	credit, err := NewPayment(c.CustomerID, NewMoney(excess, c.Currency), PaymentMethodCreditNote, c.Number, c.IssuedAt)
+	return credit, err == nil
+}
+
+func (c CreditNote) MarshalJSON() ([]byte, error) {
+	type creditNote CreditNote
+	var j struct {
+		creditNote
+		TaxSummary
+	}
+
+	j.creditNote = creditNote(c)
+	j.TaxSummary = c.Totals()
+
+	return json.Marshal(j)
+}
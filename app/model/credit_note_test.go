@@ -0,0 +1,137 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+// issuedInvoice bills quantity of a product priced at amount cents, plus
+// 20% tax, and issues it.
+func issuedInvoice(t *testing.T, amount int64, quantity int) Invoice {
+	t.Helper()
+
+	rate, err := NewTaxRate(TaxClassStandard, "", "VAT 20%", 2000, time.Now().AddDate(-1, 0, 0), null.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	product := NewProduct("SKU-1", "Product", "", nil, NewMoney(amount, "USD"), 0, TaxClassStandard, false, false)
+	customer := NewCustomer("Customer", Address{}, Address{}, "", "USD", 30, []string{})
+
+	inv, err := NewInvoice(customer, []InvoiceLine{NewInvoiceLine(product, quantity, rate)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := inv.Issue(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	return inv
+}
+
+func TestNewCreditNoteLastCreditTakesRemainingTax(t *testing.T) {
+	// 3 × 3.33 is taxed 2.00 in total, while a single unit rounds to 0.67;
+	// crediting the units one by one would take back 2.01.
+	inv := issuedInvoice(t, 333, 3)
+	line := inv.Lines[0]
+	if got := line.Tax().Tax.Amount; got != 200 {
+		t.Fatalf("invoice line tax = %d, want 200", got)
+	}
+
+	tests := []struct {
+		name  string
+		notes [][]int
+		want  [][]int64
+	}{
+		{"one unit per note", [][]int{{1}, {1}, {1}}, [][]int64{{67}, {67}, {66}}},
+		{"all units in one note", [][]int{{3}}, [][]int64{{200}}},
+		{"one unit per line of one note", [][]int{{1, 1, 1}}, [][]int64{{67, 67, 66}}},
+		{"rest after a part", [][]int{{2}, {1}}, [][]int64{{133}, {67}}},
+		{"part of the line", [][]int{{1}, {1}}, [][]int64{{67}, {67}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credited := map[ulid.ULID]Credited{}
+			var total int64
+			for n, quantities := range tt.notes {
+				var requested []CreditedLine
+				for _, q := range quantities {
+					requested = append(requested, CreditedLine{InvoiceLineID: line.ID, Quantity: q})
+				}
+				note, err := NewCreditNote(inv, credited, requested, "returned", false, time.Now())
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				for idx, l := range note.Lines {
+					if got := l.Tax().Tax.Amount; got != tt.want[n][idx] {
+						t.Errorf("note %d line %d tax = %d, want %d", n, idx, got, tt.want[n][idx])
+					}
+					c := credited[line.ID]
+					c.Quantity += l.Quantity
+					c.Tax = NewMoney(c.Tax.Amount+l.TaxAmount.Amount, "USD")
+					credited[line.ID] = c
+				}
+				if got, want := note.Totals().TaxTotal.Amount, note.Totals().Total.Amount-note.Totals().Subtotal.Amount; got != want {
+					t.Errorf("note %d tax total = %d, want total less subtotal %d", n, got, want)
+				}
+				total += note.Totals().TaxTotal.Amount
+			}
+			if credited[line.ID].Quantity == line.Quantity && total != line.Tax().Tax.Amount {
+				t.Errorf("tax credited in total = %d, want the %d charged", total, line.Tax().Tax.Amount)
+			}
+		})
+	}
+}
+
+func TestCreditNoteCustomerCredit(t *testing.T) {
+	tests := []struct {
+		name    string
+		paid    int64
+		credit  int
+		applied int64
+		owed    int64
+	}{
+		{"unpaid invoice takes all of it", 0, 1, 1200, 0},
+		{"paid invoice owes all of it back", 3600, 1, 0, 1200},
+		{"partly paid invoice owes back what exceeds the balance", 3000, 1, 600, 600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv := issuedInvoice(t, 1000, 3)
+			if tt.paid > 0 {
+				if err := inv.ApplyPayment(NewMoney(tt.paid, "USD"), time.Now()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			note, err := NewCreditNote(inv, nil, []CreditedLine{{InvoiceLineID: inv.Lines[0].ID, Quantity: tt.credit}}, "returned", false, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			note.Number = "CN-000001"
+			note.Applied, err = inv.ApplyCredit(note.Total(), note.IssuedAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if note.Applied.Amount != tt.applied {
+				t.Errorf("applied = %d, want %d", note.Applied.Amount, tt.applied)
+			}
+
+			credit, ok := note.CustomerCredit()
+			if ok != (tt.owed > 0) {
+				t.Fatalf("CustomerCredit() ok = %v, want %v", ok, tt.owed > 0)
+			}
+			if !ok {
+				return
+			}
+			if credit.Amount != NewMoney(tt.owed, "USD") || credit.Unallocated != credit.Amount {
+				t.Errorf("customer credit = %+v unallocated %+v, want %d", credit.Amount, credit.Unallocated, tt.owed)
+			}
+			if credit.CustomerID != inv.CustomerID || credit.Method != PaymentMethodCreditNote || credit.Reference != note.Number {
+				t.Errorf("customer credit = %+v, want a credit note payment of the customer referencing %s", credit, note.Number)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+var (
+	ErrCustomerNotFound       = errors.New("customer: not found")
+	ErrCustomerAlreadyDeleted = errors.New("customer: already deleted")
+)
+
+type Address struct {
+	Line1      string `json:"line1" yaml:"line1"`
+	Line2      string `json:"line2" yaml:"line2"`
+	City       string `json:"city" yaml:"city"`
+	Region     string `json:"region" yaml:"region"`
+	PostalCode string `json:"postal_code" yaml:"postal_code"`
+	Country    string `json:"country" yaml:"country"`
+}
+
+type Customer struct {
+	ID        ulid.ULID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt null.Time `json:"updated_at"`
+	DeletedAt null.Time `json:"deleted_at"`
+
+	LegalName       string   `json:"legal_name"`
+	BillingAddress  Address  `json:"billing_address"`
+	ShippingAddress Address  `json:"shipping_address"`
+	TaxID           string   `json:"tax_id"`
+	Currency        string   `json:"currency"`
+	PaymentTermDays int      `json:"payment_term_days"`
+	Emails          []string `json:"emails"`
+}
+
+func NewCustomer(
+	LegalName string,
+	BillingAddress, ShippingAddress Address,
+	TaxID, Currency string,
+	PaymentTermDays int,
+	Emails []string,
+) Customer {
+	id := ulid.Make()
+	return Customer{
+		ID:              id,
+		CreatedAt:       time.Now(),
+		LegalName:       LegalName,
+		BillingAddress:  BillingAddress,
+		ShippingAddress: ShippingAddress,
+		TaxID:           TaxID,
+		Currency:        Currency,
+		PaymentTermDays: PaymentTermDays,
+		Emails:          Emails,
+	}
+}
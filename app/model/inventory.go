@@ -11,15 +11,37 @@ import (
 var (
 	ErrInventoryNotFound       = errors.New("inventory: not found")
 	ErrInventoryAlreadyDeleted = errors.New("inventory: already deleted")
+	ErrInsufficientStock       = errors.New("inventory: insufficient stock")
 )
 
+// Inventory is the stock of a product in one warehouse. On a product it
+// is the total over all warehouses instead.
 type Inventory struct {
 	ID        ulid.ULID `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt null.Time `json:"updated_at"`
 	DeletedAt null.Time `json:"deleted_at"`
 
-	Quantity int `json:"quantity"`
+	WarehouseID ulid.ULID `json:"warehouse_id"`
+
+	// Quantity is what is on hand; Reserved the part of it held by
+	// unexpired stock reservations. InTransit is what is being transferred
+	// between warehouses and is in none of them.
+	Quantity  int `json:"quantity"`
+	Reserved  int `json:"reserved"`
+	InTransit int `json:"in_transit"`
+
+	// ReorderPoint is the on-hand quantity at or below which the product
+	// runs low in the warehouse, and ReorderQuantity how much to order
+	// then. Without a reorder point the stock never runs low.
+	ReorderPoint    null.Int `json:"reorder_point"`
+	ReorderQuantity int      `json:"reorder_quantity"`
+}
+
+// Available is the stock that can still be sold or reserved. It is
+// negative when reservations or backorders exceed what is on hand.
+func (inv Inventory) Available() int {
+	return inv.Quantity - inv.Reserved
 }
 
 func NewInventory(
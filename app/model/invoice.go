@@ -0,0 +1,383 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+var (
+	ErrInvoiceNotFound       = errors.New("invoice: not found")
+	ErrInvoiceAlreadyDeleted = errors.New("invoice: already deleted")
+	ErrInvoiceEmpty          = errors.New("invoice: has no lines")
+	ErrInvoiceAlreadyIssued  = errors.New("invoice: already issued")
+	ErrInvalidTransition     = errors.New("invoice: invalid status transition")
+	ErrInvoiceNotPayable     = errors.New("invoice: not open for payment")
+	ErrInvoiceHasPayments    = errors.New("invoice: has payments allocated")
+	ErrInvoiceHasCredits     = errors.New("invoice: has credit notes")
+	ErrInvoiceNotCreditable  = errors.New("invoice: only issued invoices can be credited")
+	ErrPaymentExceedsBalance = errors.New("invoice: payment exceeds the outstanding balance")
+)
+
+type InvoiceStatus string
+
+const (
+	InvoiceStatusDraft         InvoiceStatus = "draft"
+	InvoiceStatusIssued        InvoiceStatus = "issued"
+	InvoiceStatusPartiallyPaid InvoiceStatus = "partially_paid"
+	InvoiceStatusPaid          InvoiceStatus = "paid"
+	InvoiceStatusVoid          InvoiceStatus = "void"
+	InvoiceStatusOverdue       InvoiceStatus = "overdue"
+)
+
+// invoiceTransitions lists, for every status, the statuses an invoice may
+// move to next. Paid and void are terminal.
+var invoiceTransitions = map[InvoiceStatus][]InvoiceStatus{
+	InvoiceStatusDraft: {
+		InvoiceStatusIssued,
+		InvoiceStatusVoid,
+	},
+	InvoiceStatusIssued: {
+		InvoiceStatusPartiallyPaid,
+		InvoiceStatusPaid,
+		InvoiceStatusOverdue,
+		InvoiceStatusVoid,
+	},
+	InvoiceStatusPartiallyPaid: {
+		InvoiceStatusPaid,
+		InvoiceStatusOverdue,
+	},
+	InvoiceStatusOverdue: {
+		InvoiceStatusPartiallyPaid,
+		InvoiceStatusPaid,
+		InvoiceStatusVoid,
+	},
+}
+
+func (s InvoiceStatus) CanTransitionTo(to InvoiceStatus) bool {
+	for _, next := range invoiceTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+type Invoice struct {
+	ID        ulid.ULID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt null.Time `json:"updated_at"`
+	DeletedAt null.Time `json:"deleted_at"`
+
+	Number          null.String   `json:"number"`
+	CustomerID      ulid.ULID     `json:"customer_id"`
+	BillTo          BillTo        `json:"bill_to"`
+	Currency        string        `json:"currency"`
+	Status          InvoiceStatus `json:"status"`
+	PaymentTermDays int           `json:"payment_term_days"`
+	IssuedAt        null.Time     `json:"issued_at"`
+	DueAt           null.Time     `json:"due_at"`
+	VoidedAt        null.Time     `json:"voided_at"`
+	PaidAmount      Money         `json:"paid_amount"`
+	CreditedAmount  Money         `json:"credited_amount"`
+
+	// RecurringInvoiceID is the schedule that generated the invoice, if any.
+	RecurringInvoiceID *ulid.ULID `json:"recurring_invoice_id"`
+	// WarehouseID is the warehouse the goods are sold from, the tenant's
+	// default one when nil.
+	WarehouseID *ulid.ULID `json:"warehouse_id"`
+
+	Lines []InvoiceLine `json:"lines"`
+}
+
+// BillTo is the customer as printed on the invoice.
+type BillTo struct {
+	LegalName string  `json:"legal_name"`
+	Address   Address `json:"address"`
+	TaxID     string  `json:"tax_id"`
+}
+
+// InvoiceLine keeps a copy of the product data at the time the invoice was
+// issued, so later changes to the product do not alter the invoice.
+type InvoiceLine struct {
+	ID        ulid.ULID `json:"id"`
+	InvoiceID ulid.ULID `json:"invoice_id"`
+	ProductID ulid.ULID `json:"product_id"`
+
+	Sku      string `json:"sku"`
+	Name     string `json:"name"`
+	Amount   Money  `json:"amount"`
+	Quantity int    `json:"quantity"`
+
+	TaxClass         TaxClass `json:"tax_class"`
+	TaxName          string   `json:"tax_name"`
+	TaxRate          int      `json:"tax_rate"`
+	PriceIncludesTax bool     `json:"price_includes_tax"`
+}
+
+func NewInvoice(
+	customer Customer,
+	lines []InvoiceLine,
+) (Invoice, error) {
+	invoice := Invoice{
+		ID:        ulid.Make(),
+		CreatedAt: time.Now(),
+		Status:    InvoiceStatusDraft,
+	}
+	if err := invoice.SetCustomer(customer); err != nil {
+		return Invoice{}, err
+	}
+	if err := invoice.SetLines(lines); err != nil {
+		return Invoice{}, err
+	}
+
+	return invoice, nil
+}
+
+// SetCustomer bills a draft invoice to the customer, in the customer's
+// currency, copying the details that end up printed on the invoice.
+func (i *Invoice) SetCustomer(c Customer) error {
+	if i.Status != InvoiceStatusDraft {
+		return ErrInvoiceAlreadyIssued
+	}
+
+	i.CustomerID = c.ID
+	i.Currency = c.Currency
+	i.BillTo = BillTo{
+		LegalName: c.LegalName,
+		Address:   c.BillingAddress,
+		TaxID:     c.TaxID,
+	}
+	i.PaymentTermDays = c.PaymentTermDays
+
+	return nil
+}
+
+// SetLines replaces the lines of a draft invoice. Issued invoices can not be
+// edited anymore.
+func (i *Invoice) SetLines(lines []InvoiceLine) error {
+	if i.Status != InvoiceStatusDraft {
+		return ErrInvoiceAlreadyIssued
+	}
+	if len(lines) == 0 {
+		return ErrInvoiceEmpty
+	}
+
+	for idx := range lines {
+		if lines[idx].Amount.Currency != i.Currency {
+			return ErrMoneyCurrencyMismatch
+		}
+		lines[idx].InvoiceID = i.ID
+	}
+	i.Lines = lines
+
+	return nil
+}
+
+// SetWarehouse sells the goods of a draft invoice from the warehouse, or
+// from the default one when warehouseID is nil.
+func (i *Invoice) SetWarehouse(warehouseID *ulid.ULID) error {
+	if i.Status != InvoiceStatusDraft {
+		return ErrInvoiceAlreadyIssued
+	}
+	i.WarehouseID = warehouseID
+	return nil
+}
+
+func (i *Invoice) TransitionTo(to InvoiceStatus, at time.Time) error {
+	if !i.Status.CanTransitionTo(to) {
+		return ErrInvalidTransition
+	}
+
+	switch to {
+	case InvoiceStatusIssued:
+		i.IssuedAt = null.TimeFrom(at)
+		i.DueAt = null.TimeFrom(at.AddDate(0, 0, i.PaymentTermDays))
+	case InvoiceStatusVoid:
+		i.VoidedAt = null.TimeFrom(at)
+	}
+	i.Status = to
+
+	return nil
+}
+
+func (i *Invoice) Issue(at time.Time) error {
+	if i.Status != InvoiceStatusDraft {
+		return ErrInvoiceAlreadyIssued
+	}
+	return i.TransitionTo(InvoiceStatusIssued, at)
+}
+
+// Void cancels an invoice nobody has paid anything on yet. Invoices that
+// were partly credited have to be credited in full instead.
+func (i *Invoice) Void(at time.Time) error {
+	if i.PaidAmount.IsPositive() {
+		return ErrInvoiceHasPayments
+	}
+	if i.CreditedAmount.IsPositive() {
+		return ErrInvoiceHasCredits
+	}
+	return i.TransitionTo(InvoiceStatusVoid, at)
+}
+
+// IsPayable reports whether payments can be allocated to the invoice.
+func (i Invoice) IsPayable() bool {
+	switch i.Status {
+	case InvoiceStatusIssued, InvoiceStatusPartiallyPaid, InvoiceStatusOverdue:
+		return true
+	}
+	return false
+}
+
+// Balance is the amount still to be paid, after payments and credit notes.
+func (i Invoice) Balance() Money {
+	total := i.Total()
+	return NewMoney(total.Amount-i.PaidAmount.Amount-i.CreditedAmount.Amount, i.Currency)
+}
+
+// ApplyPayment records amount as paid and moves the invoice to paid or
+// partially paid. The balance can never become negative.
+func (i *Invoice) ApplyPayment(amount Money, at time.Time) error {
+	if !i.IsPayable() {
+		return ErrInvoiceNotPayable
+	}
+	if amount.Currency != i.Currency {
+		return ErrMoneyCurrencyMismatch
+	}
+	if !amount.IsPositive() {
+		return ErrMoneyInvalidAmount
+	}
+
+	balance := i.Balance()
+	if amount.Amount > balance.Amount {
+		return ErrPaymentExceedsBalance
+	}
+	i.PaidAmount = NewMoney(i.PaidAmount.Amount+amount.Amount, i.Currency)
+
+	next := InvoiceStatusPartiallyPaid
+	if amount.Amount == balance.Amount {
+		next = InvoiceStatusPaid
+	}
+	if next == i.Status {
+		return nil
+	}
+	return i.TransitionTo(next, at)
+}
+
+// IsCreditable reports whether credit notes can be raised against the
+// invoice. Drafts are still editable and void invoices are cancelled.
+func (i Invoice) IsCreditable() bool {
+	return i.IsPayable() || i.Status == InvoiceStatusPaid
+}
+
+// ApplyCredit takes a credit note total off the outstanding balance and
+// returns the part that was applied. Whatever exceeds the balance, e.g. when
+// the invoice was already paid, is owed back to the customer. An invoice
+// left with nothing to pay is settled.
+func (i *Invoice) ApplyCredit(amount Money, at time.Time) (Money, error) {
+	if !i.IsCreditable() {
+		return Money{}, ErrInvoiceNotCreditable
+	}
+	if amount.Currency != i.Currency {
+		return Money{}, ErrMoneyCurrencyMismatch
+	}
+
+	balance := i.Balance()
+	applied := amount
+	if applied.Amount > balance.Amount {
+		applied = balance
+	}
+	i.CreditedAmount = NewMoney(i.CreditedAmount.Amount+applied.Amount, i.Currency)
+
+	if applied.Amount == balance.Amount && i.Status != InvoiceStatusPaid {
+		if err := i.TransitionTo(InvoiceStatusPaid, at); err != nil {
+			return Money{}, err
+		}
+	}
+	return applied, nil
+}
+
+func (i *Invoice) MarkOverdue() error {
+	return i.TransitionTo(InvoiceStatusOverdue, time.Now())
+}
+
+// NewInvoiceLine snapshots the product together with the tax rate that
+// applies to its tax class.
+func NewInvoiceLine(
+	product Product,
+	quantity int,
+	rate TaxRate,
+) InvoiceLine {
+	id := ulid.Make()
+	return InvoiceLine{
+		ID:               id,
+		ProductID:        product.ID,
+		Sku:              product.Sku,
+		Name:             product.Name,
+		Amount:           product.Amount,
+		Quantity:         quantity,
+		TaxClass:         product.TaxClass,
+		TaxName:          rate.Name,
+		TaxRate:          rate.Rate,
+		PriceIncludesTax: product.PriceIncludesTax,
+	}
+}
+
+func (l InvoiceLine) Taxed() TaxedLine {
+	return TaxedLine{
+		Amount:    l.Amount.Mul(int64(l.Quantity)),
+		TaxClass:  l.TaxClass,
+		TaxName:   l.TaxName,
+		TaxRate:   l.TaxRate,
+		Inclusive: l.PriceIncludesTax,
+	}
+}
+
+func (l InvoiceLine) Tax() LineTax {
+	t := l.Taxed()
+	return CalculateLineTax(t.Amount, t.TaxRate, t.Inclusive)
+}
+
+func (l InvoiceLine) MarshalJSON() ([]byte, error) {
+	type invoiceLine InvoiceLine
+	var j struct {
+		invoiceLine
+		LineTax
+	}
+
+	j.invoiceLine = invoiceLine(l)
+	j.LineTax = l.Tax()
+
+	return json.Marshal(j)
+}
+
+// Totals calculates the net, tax and gross amounts of the invoice with the
+// breakdown per tax rate. Lines always share the invoice currency, which
+// NewInvoice and SetLines make sure of.
+func (i Invoice) Totals() TaxSummary {
+	var lines = make([]TaxedLine, len(i.Lines))
+	for idx := range i.Lines {
+		lines[idx] = i.Lines[idx].Taxed()
+	}
+	return SummarizeTax(i.Currency, lines)
+}
+
+func (i Invoice) Total() Money {
+	return i.Totals().Total
+}
+
+func (i Invoice) MarshalJSON() ([]byte, error) {
+	type invoice Invoice
+	var j struct {
+		invoice
+		TaxSummary
+	}
+
+	j.invoice = invoice(i)
+	j.TaxSummary = i.Totals()
+
+	return json.Marshal(j)
+}
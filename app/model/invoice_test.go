@@ -0,0 +1,116 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var invoiceStatuses = []InvoiceStatus{
+	InvoiceStatusDraft,
+	InvoiceStatusIssued,
+	InvoiceStatusPartiallyPaid,
+	InvoiceStatusPaid,
+	InvoiceStatusVoid,
+	InvoiceStatusOverdue,
+}
+
+func TestInvoiceStatusCanTransitionTo(t *testing.T) {
+	allowed := map[InvoiceStatus][]InvoiceStatus{
+		InvoiceStatusDraft:         {InvoiceStatusIssued, InvoiceStatusVoid},
+		InvoiceStatusIssued:        {InvoiceStatusPartiallyPaid, InvoiceStatusPaid, InvoiceStatusOverdue, InvoiceStatusVoid},
+		InvoiceStatusPartiallyPaid: {InvoiceStatusPaid, InvoiceStatusOverdue},
+		InvoiceStatusOverdue:       {InvoiceStatusPartiallyPaid, InvoiceStatusPaid, InvoiceStatusVoid},
+		InvoiceStatusPaid:          {},
+		InvoiceStatusVoid:          {},
+	}
+
+	for _, from := range invoiceStatuses {
+		for _, to := range invoiceStatuses {
+			want := false
+			for _, next := range allowed[from] {
+				want = want || next == to
+			}
+			t.Run(string(from)+" to "+string(to), func(t *testing.T) {
+				if got := from.CanTransitionTo(to); got != want {
+					t.Errorf("CanTransitionTo() = %v, want %v", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestInvoiceIssue(t *testing.T) {
+	at := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, status := range invoiceStatuses {
+		t.Run(string(status), func(t *testing.T) {
+			inv := Invoice{Status: status, PaymentTermDays: 30}
+			err := inv.Issue(at)
+
+			if status != InvoiceStatusDraft {
+				if !errors.Is(err, ErrInvoiceAlreadyIssued) {
+					t.Fatalf("Issue() error = %v, want %v", err, ErrInvoiceAlreadyIssued)
+				}
+				if inv.Status != status || inv.IssuedAt.Valid {
+					t.Errorf("refused Issue() left status %s issued at %v", inv.Status, inv.IssuedAt)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if inv.Status != InvoiceStatusIssued {
+				t.Errorf("status = %s, want %s", inv.Status, InvoiceStatusIssued)
+			}
+			if !inv.IssuedAt.Time.Equal(at) || !inv.DueAt.Time.Equal(at.AddDate(0, 0, 30)) {
+				t.Errorf("issued at %v due at %v, want %v due 30 days later", inv.IssuedAt.Time, inv.DueAt.Time, at)
+			}
+		})
+	}
+}
+
+func TestInvoiceVoid(t *testing.T) {
+	at := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		status   InvoiceStatus
+		paid     int64
+		credited int64
+		err      error
+	}{
+		{"draft", InvoiceStatusDraft, 0, 0, nil},
+		{"issued", InvoiceStatusIssued, 0, 0, nil},
+		{"overdue", InvoiceStatusOverdue, 0, 0, nil},
+		{"partially paid", InvoiceStatusPartiallyPaid, 100, 0, ErrInvoiceHasPayments},
+		{"paid", InvoiceStatusPaid, 1000, 0, ErrInvoiceHasPayments},
+		{"credited in part", InvoiceStatusIssued, 0, 100, ErrInvoiceHasCredits},
+		{"credited in full", InvoiceStatusPaid, 0, 1000, ErrInvoiceHasCredits},
+		{"overdue with payments", InvoiceStatusOverdue, 100, 0, ErrInvoiceHasPayments},
+		{"paid without payments", InvoiceStatusPaid, 0, 0, ErrInvalidTransition},
+		{"void", InvoiceStatusVoid, 0, 0, ErrInvalidTransition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv := Invoice{
+				Status:         tt.status,
+				Currency:       "USD",
+				PaidAmount:     NewMoney(tt.paid, "USD"),
+				CreditedAmount: NewMoney(tt.credited, "USD"),
+			}
+			err := inv.Void(at)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Void() error = %v, want %v", err, tt.err)
+			}
+
+			if tt.err != nil {
+				if inv.Status != tt.status || inv.VoidedAt.Valid {
+					t.Errorf("refused Void() left status %s voided at %v", inv.Status, inv.VoidedAt)
+				}
+				return
+			}
+			if inv.Status != InvoiceStatusVoid || !inv.VoidedAt.Time.Equal(at) {
+				t.Errorf("status %s voided at %v, want %s at %v", inv.Status, inv.VoidedAt.Time, InvoiceStatusVoid, at)
+			}
+		})
+	}
+}
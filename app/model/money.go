@@ -0,0 +1,347 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrMoneyInvalidAmount    = errors.New("money: invalid amount")
+	ErrMoneyInvalidCurrency  = errors.New("money: invalid currency")
+	ErrMoneyCurrencyMismatch = errors.New("money: currency mismatch")
+	ErrMoneyInvalidRatios    = errors.New("money: invalid allocation ratios")
+)
+
+// moneyScale is the number of minor units in one major unit. Amounts are kept
+// with two decimals, matching the NUMERIC(12,2) columns they are stored in.
+const moneyScale = 100
+
+// Money is an exact monetary amount in minor units of an ISO-4217 currency.
+//
+// Money scans from and encodes to a NUMERIC column holding the amount only;
+// the currency lives in a column of its own and is scanned separately, e.g.
+// row.Scan(&item.Amount, &item.Amount.Currency).
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+func NewMoney(minor int64, currency string) Money {
+	return Money{
+		Amount:   minor,
+		Currency: currency,
+	}
+}
+
+// ParseMoney reads a decimal amount such as "1250.5" or "-3.75".
+func ParseMoney(amount, currency string) (Money, error) {
+	minor, err := parseMinorUnits(amount)
+	if err != nil {
+		return Money{}, err
+	}
+	return NewMoney(minor, currency), nil
+}
+
+func parseMinorUnits(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrMoneyInvalidAmount
+	}
+
+	var negative bool
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, ErrMoneyInvalidAmount
+	}
+	if len(frac) > 2 {
+		if strings.Trim(frac[2:], "0") != "" {
+			return 0, ErrMoneyInvalidAmount
+		}
+		frac = frac[:2]
+	}
+	for len(frac) < 2 {
+		frac += "0"
+	}
+	if whole == "" {
+		whole = "0"
+	}
+
+	units, err := strconv.ParseUint(whole, 10, 63)
+	if err != nil {
+		return 0, ErrMoneyInvalidAmount
+	}
+	cents, err := strconv.ParseUint(frac, 10, 8)
+	if err != nil {
+		return 0, ErrMoneyInvalidAmount
+	}
+
+	// Amounts that don't fit in int64 minor units would wrap around.
+	if units > (math.MaxInt64-cents)/moneyScale {
+		return 0, ErrMoneyInvalidAmount
+	}
+
+	minor := int64(units)*moneyScale + int64(cents)
+	if negative {
+		minor = -minor
+	}
+	return minor, nil
+}
+
+// String renders the amount as a decimal with two places, without currency.
+func (m Money) String() string {
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/moneyScale, amount%moneyScale)
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) Neg() Money {
+	return NewMoney(-m.Amount, m.Currency)
+}
+
+// Zero returns a zero amount in the same currency.
+func (m Money) Zero() Money {
+	return NewMoney(0, m.Currency)
+}
+
+func (m Money) sameCurrency(o Money) error {
+	if m.Currency != o.Currency {
+		return ErrMoneyCurrencyMismatch
+	}
+	return nil
+}
+
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	return NewMoney(m.Amount+o.Amount, m.Currency), nil
+}
+
+func (m Money) Sub(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	return NewMoney(m.Amount-o.Amount, m.Currency), nil
+}
+
+// Cmp returns -1, 0 or +1 depending on whether m is less than, equal to or
+// greater than o.
+func (m Money) Cmp(o Money) (int, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Mul multiplies the amount by a quantity.
+func (m Money) Mul(quantity int64) Money {
+	return NewMoney(m.Amount*quantity, m.Currency)
+}
+
+// MulRatio multiplies the amount by num/den, rounding half away from zero.
+func (m Money) MulRatio(num, den int64) Money {
+	return NewMoney(divRound(m.Amount*num, den), m.Currency)
+}
+
+func divRound(n, d int64) int64 {
+	if d < 0 {
+		n, d = -n, -d
+	}
+	q, r := n/d, n%d
+	if r < 0 {
+		r = -r
+	}
+	if r*2 >= d {
+		if n < 0 {
+			q--
+		} else {
+			q++
+		}
+	}
+	return q
+}
+
+// Allocate splits the amount in proportion to ratios. The minor units lost
+// to rounding are handed out one at a time starting from the first share, so
+// the shares always add up to the original amount.
+func (m Money) Allocate(ratios ...int64) ([]Money, error) {
+	var total int64
+	for _, r := range ratios {
+		if r < 0 {
+			return nil, ErrMoneyInvalidRatios
+		}
+		total += r
+	}
+	if total == 0 {
+		return nil, ErrMoneyInvalidRatios
+	}
+
+	var shares = make([]Money, len(ratios))
+	remainder := m.Amount
+	for idx, r := range ratios {
+		shares[idx] = NewMoney(m.Amount*r/total, m.Currency)
+		remainder -= shares[idx].Amount
+	}
+
+	step := int64(1)
+	if remainder < 0 {
+		step = -1
+	}
+	for idx := 0; remainder != 0; idx = (idx + 1) % len(shares) {
+		if ratios[idx] == 0 {
+			continue
+		}
+		shares[idx].Amount += step
+		remainder -= step
+	}
+
+	return shares, nil
+}
+
+// Split divides the amount into n parts that differ by at most one minor
+// unit.
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, ErrMoneyInvalidRatios
+	}
+	var ratios = make([]int64, n)
+	for idx := range ratios {
+		ratios[idx] = 1
+	}
+	return m.Allocate(ratios...)
+}
+
+// SumMoney adds up amounts of the given currency.
+func SumMoney(currency string, items ...Money) (Money, error) {
+	total := NewMoney(0, currency)
+	for idx := range items {
+		var err error
+		total, err = total.Add(items[idx])
+		if err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// Validate checks a money value received from a client: a known currency
+// format and an amount that is not negative.
+func (m Money) Validate() error {
+	if !validCurrency(m.Currency) {
+		return ErrMoneyInvalidCurrency
+	}
+	if m.IsNegative() {
+		return ErrMoneyInvalidAmount
+	}
+	return nil
+}
+
+func validCurrency(c string) bool {
+	if len(c) != 3 {
+		return false
+	}
+	for idx := range c {
+		if c[idx] < 'A' || c[idx] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Scan implements sql.Scanner for NUMERIC amount columns. Only the amount is
+// set, the currency is left untouched.
+func (m *Money) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		m.Amount = 0
+		return nil
+	case string:
+		minor, err := parseMinorUnits(v)
+		if err != nil {
+			return err
+		}
+		m.Amount = minor
+		return nil
+	case []byte:
+		return m.Scan(string(v))
+	case int64:
+		m.Amount = v * moneyScale
+		return nil
+	}
+	return fmt.Errorf("money: cannot scan %T", src)
+}
+
+// Value implements driver.Valuer, encoding the amount as a decimal string.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// MarshalJSON encodes the amount as a string so clients never see it as a
+// float.
+func (m Money) MarshalJSON() ([]byte, error) {
+	var j struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}
+
+	j.Amount = m.String()
+	j.Currency = m.Currency
+
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON accepts the amount both as a string and as a JSON number; in
+// either case it is read digit by digit and never goes through a float.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var j struct {
+		Amount   json.Number `json:"amount"`
+		Currency string      `json:"currency"`
+	}
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	minor, err := parseMinorUnits(j.Amount.String())
+	if err != nil {
+		return err
+	}
+	m.Amount = minor
+	m.Currency = strings.ToUpper(j.Currency)
+
+	return nil
+}
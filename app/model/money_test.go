@@ -0,0 +1,244 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseMinorUnits(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		err  error
+	}{
+		{"0", 0, nil},
+		{"12", 1200, nil},
+		{"12.5", 1250, nil},
+		{"12.05", 1205, nil},
+		{"12.50000", 1250, nil},
+		{".5", 50, nil},
+		{"7.", 700, nil},
+		{"+3.75", 375, nil},
+		{"-3.75", -375, nil},
+		{"-.01", -1, nil},
+		{" 1.10 ", 110, nil},
+		{"92233720368547758.07", 9223372036854775807, nil},
+		{"", 0, ErrMoneyInvalidAmount},
+		{".", 0, ErrMoneyInvalidAmount},
+		{"-", 0, ErrMoneyInvalidAmount},
+		{"1.234", 0, ErrMoneyInvalidAmount},
+		{"1.2.3", 0, ErrMoneyInvalidAmount},
+		{"1e3", 0, ErrMoneyInvalidAmount},
+		{"--1", 0, ErrMoneyInvalidAmount},
+		{"1,50", 0, ErrMoneyInvalidAmount},
+		{"abc", 0, ErrMoneyInvalidAmount},
+		{"92233720368547758.08", 0, ErrMoneyInvalidAmount},
+		{"100000000000000000", 0, ErrMoneyInvalidAmount},
+	}
+	for _, tt := range tests {
+		got, err := parseMinorUnits(tt.in)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("parseMinorUnits(%q) error = %v, want %v", tt.in, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseMinorUnits(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{1250, "12.50"},
+		{-1, "-0.01"},
+		{-123456, "-1234.56"},
+	}
+	for _, tt := range tests {
+		if got := NewMoney(tt.in, "USD").String(); got != tt.want {
+			t.Errorf("NewMoney(%d).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDivRound(t *testing.T) {
+	tests := []struct {
+		n, d, want int64
+	}{
+		{6, 3, 2},
+		{4, 3, 1},
+		{5, 3, 2},
+		{5, 2, 3},
+		{1, 2, 1},
+		{1, 3, 0},
+		{-1, 2, -1},
+		{-4, 3, -1},
+		{-5, 2, -3},
+		{5, -2, -3},
+		{-5, -2, 3},
+		{0, 7, 0},
+	}
+	for _, tt := range tests {
+		if got := divRound(tt.n, tt.d); got != tt.want {
+			t.Errorf("divRound(%d, %d) = %d, want %d", tt.n, tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyMulRatio(t *testing.T) {
+	tests := []struct {
+		amount, num, den, want int64
+	}{
+		{1000, 2000, 10000, 200},
+		{999, 725, 10000, 72},
+		{50, 1000, 10000, 5},
+		{5, 1000, 10000, 1},
+		{-5, 1000, 10000, -1},
+		{1200, 10000, 12000, 1000},
+	}
+	for _, tt := range tests {
+		got := NewMoney(tt.amount, "EUR").MulRatio(tt.num, tt.den)
+		if got.Amount != tt.want || got.Currency != "EUR" {
+			t.Errorf("MulRatio(%d, %d/%d) = %v %s, want %d EUR", tt.amount, tt.num, tt.den, got.Amount, got.Currency, tt.want)
+		}
+	}
+}
+
+func TestMoneyAllocate(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount int64
+		ratios []int64
+		want   []int64
+		err    error
+	}{
+		{"even", 100, []int64{1, 1}, []int64{50, 50}, nil},
+		{"remainder to first shares", 100, []int64{1, 1, 1}, []int64{34, 33, 33}, nil},
+		{"proportional", 1000, []int64{3, 7}, []int64{300, 700}, nil},
+		{"rounding in order", 5, []int64{2, 1}, []int64{4, 1}, nil},
+		{"less than one unit each", 2, []int64{1, 1, 1}, []int64{1, 1, 0}, nil},
+		{"zero ratio gets nothing", 101, []int64{1, 0, 1}, []int64{51, 0, 50}, nil},
+		{"zero ratio skipped for remainder", 2, []int64{0, 1, 1, 1}, []int64{0, 1, 1, 0}, nil},
+		{"negative amount", -100, []int64{1, 1, 1}, []int64{-34, -33, -33}, nil},
+		{"negative proportional", -1001, []int64{1, 3}, []int64{-251, -750}, nil},
+		{"zero amount", 0, []int64{1, 2}, []int64{0, 0}, nil},
+		{"single share", 999, []int64{4}, []int64{999}, nil},
+		{"negative ratio", 100, []int64{1, -1}, nil, ErrMoneyInvalidRatios},
+		{"all zero ratios", 100, []int64{0, 0}, nil, ErrMoneyInvalidRatios},
+		{"no ratios", 100, nil, nil, ErrMoneyInvalidRatios},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shares, err := NewMoney(tt.amount, "USD").Allocate(tt.ratios...)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Allocate error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+
+			var (
+				got = make([]int64, len(shares))
+				sum int64
+			)
+			for idx, s := range shares {
+				if s.Currency != "USD" {
+					t.Errorf("share %d currency = %q, want USD", idx, s.Currency)
+				}
+				got[idx] = s.Amount
+				sum += s.Amount
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Allocate(%d, %v) = %v, want %v", tt.amount, tt.ratios, got, tt.want)
+			}
+			if sum != tt.amount {
+				t.Errorf("shares add up to %d, want %d", sum, tt.amount)
+			}
+		})
+	}
+}
+
+func TestMoneySplit(t *testing.T) {
+	tests := []struct {
+		amount int64
+		n      int
+		want   []int64
+		err    error
+	}{
+		{100, 3, []int64{34, 33, 33}, nil},
+		{-100, 3, []int64{-34, -33, -33}, nil},
+		{10, 4, []int64{3, 3, 2, 2}, nil},
+		{7, 1, []int64{7}, nil},
+		{100, 0, nil, ErrMoneyInvalidRatios},
+		{100, -1, nil, ErrMoneyInvalidRatios},
+	}
+	for _, tt := range tests {
+		shares, err := NewMoney(tt.amount, "USD").Split(tt.n)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Split(%d, %d) error = %v, want %v", tt.amount, tt.n, err, tt.err)
+			continue
+		}
+		var got []int64
+		for _, s := range shares {
+			got = append(got, s.Amount)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Split(%d, %d) = %v, want %v", tt.amount, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyCurrencyMismatch(t *testing.T) {
+	usd, eur := NewMoney(100, "USD"), NewMoney(100, "EUR")
+
+	if _, err := usd.Add(eur); !errors.Is(err, ErrMoneyCurrencyMismatch) {
+		t.Errorf("Add error = %v, want %v", err, ErrMoneyCurrencyMismatch)
+	}
+	if _, err := usd.Sub(eur); !errors.Is(err, ErrMoneyCurrencyMismatch) {
+		t.Errorf("Sub error = %v, want %v", err, ErrMoneyCurrencyMismatch)
+	}
+	if _, err := usd.Cmp(eur); !errors.Is(err, ErrMoneyCurrencyMismatch) {
+		t.Errorf("Cmp error = %v, want %v", err, ErrMoneyCurrencyMismatch)
+	}
+	if _, err := SumMoney("USD", usd, eur); !errors.Is(err, ErrMoneyCurrencyMismatch) {
+		t.Errorf("SumMoney error = %v, want %v", err, ErrMoneyCurrencyMismatch)
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Money
+		err  bool
+	}{
+		{`{"amount":"12.50","currency":"usd"}`, NewMoney(1250, "USD"), false},
+		{`{"amount":0.1,"currency":"EUR"}`, NewMoney(10, "EUR"), false},
+		{`{"amount":19.99,"currency":"EUR"}`, NewMoney(1999, "EUR"), false},
+		{`{"amount":"1.001","currency":"EUR"}`, Money{}, true},
+	}
+	for _, tt := range tests {
+		var got Money
+		err := json.Unmarshal([]byte(tt.in), &got)
+		if (err != nil) != tt.err {
+			t.Errorf("Unmarshal(%s) error = %v, want error %v", tt.in, err, tt.err)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("Unmarshal(%s) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+
+	out, err := json.Marshal(NewMoney(-1205, "IDR"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"amount":"-12.05","currency":"IDR"}`; string(out) != want {
+		t.Errorf("Marshal = %s, want %s", out, want)
+	}
+}
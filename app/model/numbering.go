@@ -0,0 +1,72 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNumberFormatYearlyResetWithoutYear   = errors.New("number format: a series that restarts every year must print the year")
+	ErrNumberFormatMonthlyResetWithoutMonth = errors.New("number format: a series that restarts every month must print the year and month")
+	ErrNumberFormatMonthWithoutYear         = errors.New("number format: the month can only be printed after the year")
+)
+
+// NumberFormat describes how the legal number of a document is rendered, for
+// example INV-2026-000123, or INV-2026-03-000123 with the month. Series
+// identifies the sequence the running number is drawn from.
+type NumberFormat struct {
+	Series       string
+	Prefix       string
+	Padding      int
+	WithYear     bool
+	WithMonth    bool
+	YearlyReset  bool
+	MonthlyReset bool
+}
+
+// Validate rejects formats that would repeat numbers. A series restarting
+// every year without the year in its numbers hands out the same numbers
+// again each year, and one restarting every month the same each month.
+func (f NumberFormat) Validate() error {
+	if f.WithMonth && !f.WithYear {
+		return ErrNumberFormatMonthWithoutYear
+	}
+	if f.YearlyReset && !f.WithYear {
+		return ErrNumberFormatYearlyResetWithoutYear
+	}
+	if f.MonthlyReset && !f.WithMonth {
+		return ErrNumberFormatMonthlyResetWithoutMonth
+	}
+	return nil
+}
+
+// Period returns the sequence period the number for a document issued at
+// the given time belongs to: the year, or the year and month as YYYYMM.
+// Sequences that never reset use period 0.
+func (f NumberFormat) Period(at time.Time) int {
+	switch {
+	case f.MonthlyReset:
+		return at.Year()*100 + int(at.Month())
+	case f.YearlyReset:
+		return at.Year()
+	}
+	return 0
+}
+
+func (f NumberFormat) Format(at time.Time, seq int64) string {
+	var parts []string
+	if f.Prefix != "" {
+		parts = append(parts, f.Prefix)
+	}
+	if f.WithYear {
+		parts = append(parts, fmt.Sprintf("%04d", at.Year()))
+	}
+	if f.WithMonth {
+		parts = append(parts, fmt.Sprintf("%02d", at.Month()))
+	}
+	parts = append(parts, fmt.Sprintf("%0*d", f.Padding, seq))
+
+	return strings.Join(parts, "-")
+}
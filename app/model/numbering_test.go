@@ -0,0 +1,99 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNumberFormatFormat(t *testing.T) {
+	at := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		format NumberFormat
+		seq    int64
+		want   string
+	}{
+		{"prefix, year and padding", NumberFormat{Prefix: "INV", Padding: 6, WithYear: true}, 123, "INV-2026-000123"},
+		{"year and month", NumberFormat{Prefix: "INV", Padding: 6, WithYear: true, WithMonth: true}, 123, "INV-2026-03-000123"},
+		{"without year", NumberFormat{Prefix: "CN", Padding: 4}, 7, "CN-0007"},
+		{"without prefix", NumberFormat{Padding: 3, WithYear: true}, 7, "2026-007"},
+		{"without padding", NumberFormat{Prefix: "INV"}, 42, "INV-42"},
+		{"number wider than the padding", NumberFormat{Prefix: "INV", Padding: 3}, 12345, "INV-12345"},
+		{"number as wide as the padding", NumberFormat{Prefix: "INV", Padding: 3}, 999, "INV-999"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.format.Format(at, tt.seq); got != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNumberFormatPeriod(t *testing.T) {
+	var (
+		never   = NumberFormat{WithYear: true, WithMonth: true}
+		yearly  = NumberFormat{WithYear: true, YearlyReset: true}
+		monthly = NumberFormat{WithYear: true, WithMonth: true, MonthlyReset: true}
+
+		lastOf2025  = time.Date(2025, 12, 31, 23, 59, 59, 0, time.UTC)
+		firstOf2026 = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		endOfJan    = time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC)
+		startOfFeb  = time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	)
+	tests := []struct {
+		name   string
+		format NumberFormat
+		before time.Time
+		after  time.Time
+		rolls  bool
+	}{
+		{"never into a new year", never, lastOf2025, firstOf2026, false},
+		{"yearly into a new year", yearly, lastOf2025, firstOf2026, true},
+		{"yearly into a new month", yearly, endOfJan, startOfFeb, false},
+		{"monthly into a new month", monthly, endOfJan, startOfFeb, true},
+		{"monthly into a new year", monthly, lastOf2025, firstOf2026, true},
+		{"monthly within a month", monthly, startOfFeb, startOfFeb.AddDate(0, 0, 27), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after := tt.format.Period(tt.before), tt.format.Period(tt.after)
+			if rolls := before != after; rolls != tt.rolls {
+				t.Errorf("periods %d and %d, want a new one %v", before, after, tt.rolls)
+			}
+		})
+	}
+
+	if got := yearly.Period(firstOf2026); got != 2026 {
+		t.Errorf("yearly Period() = %d, want 2026", got)
+	}
+	if got := monthly.Period(startOfFeb); got != 202602 {
+		t.Errorf("monthly Period() = %d, want 202602", got)
+	}
+	if got := never.Period(firstOf2026); got != 0 {
+		t.Errorf("Period() of a series that never restarts = %d, want 0", got)
+	}
+}
+
+func TestNumberFormatValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		format NumberFormat
+		err    error
+	}{
+		{"yearly reset with year", NumberFormat{WithYear: true, YearlyReset: true}, nil},
+		{"yearly reset without year", NumberFormat{YearlyReset: true}, ErrNumberFormatYearlyResetWithoutYear},
+		{"monthly reset with month", NumberFormat{WithYear: true, WithMonth: true, MonthlyReset: true}, nil},
+		{"monthly reset with year only", NumberFormat{WithYear: true, MonthlyReset: true}, ErrNumberFormatMonthlyResetWithoutMonth},
+		{"month without year", NumberFormat{WithMonth: true}, ErrNumberFormatMonthWithoutYear},
+		{"no reset", NumberFormat{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.format.Validate(); !errors.Is(err, tt.err) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+var (
+	ErrPaymentNotFound         = errors.New("payment: not found")
+	ErrPaymentAlreadyDeleted   = errors.New("payment: already deleted")
+	ErrPaymentInvalidAmount    = errors.New("payment: amount must be positive")
+	ErrPaymentOverAllocated    = errors.New("payment: allocations exceed the unallocated amount")
+	ErrPaymentCustomerMismatch = errors.New("payment: invoice belongs to another customer")
+	ErrPaymentMethodInvalid    = errors.New("payment: invalid method")
+)
+
+type PaymentMethod string
+
+const (
+	PaymentMethodBankTransfer PaymentMethod = "bank_transfer"
+	PaymentMethodCard         PaymentMethod = "card"
+	PaymentMethodCash         PaymentMethod = "cash"
+	PaymentMethodCheque       PaymentMethod = "cheque"
+	PaymentMethodOther        PaymentMethod = "other"
+	// PaymentMethodCreditNote holds what a credit note owes back to the
+	// customer. Only credit notes book it, so it is not a valid method to
+	// record a payment with.
+	PaymentMethodCreditNote PaymentMethod = "credit_note"
+)
+
+func (m PaymentMethod) Validate() error {
+	switch m {
+	case PaymentMethodBankTransfer,
+		PaymentMethodCard,
+		PaymentMethodCash,
+		PaymentMethodCheque,
+		PaymentMethodOther:
+		return nil
+	}
+	return ErrPaymentMethodInvalid
+}
+
+// Payment is money received from a customer. Whatever has not been
+// allocated to invoices yet is held as credit of the customer.
+type Payment struct {
+	ID        ulid.ULID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt null.Time `json:"updated_at"`
+	DeletedAt null.Time `json:"deleted_at"`
+
+	CustomerID  ulid.ULID     `json:"customer_id"`
+	Amount      Money         `json:"amount"`
+	Unallocated Money         `json:"unallocated"`
+	Method      PaymentMethod `json:"method"`
+	Reference   string        `json:"reference"`
+	ReceivedAt  time.Time     `json:"received_at"`
+
+	Allocations []PaymentAllocation `json:"allocations"`
+}
+
+type PaymentAllocation struct {
+	ID        ulid.ULID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	PaymentID ulid.ULID `json:"payment_id"`
+	InvoiceID ulid.ULID `json:"invoice_id"`
+	Amount    Money     `json:"amount"`
+}
+
+func NewPayment(
+	CustomerID ulid.ULID,
+	Amount Money,
+	Method PaymentMethod,
+	Reference string,
+	ReceivedAt time.Time,
+) (Payment, error) {
+	if !Amount.IsPositive() {
+		return Payment{}, ErrPaymentInvalidAmount
+	}
+
+	id := ulid.Make()
+	return Payment{
+		ID:          id,
+		CreatedAt:   time.Now(),
+		CustomerID:  CustomerID,
+		Amount:      Amount,
+		Unallocated: Amount,
+		Method:      Method,
+		Reference:   Reference,
+		ReceivedAt:  ReceivedAt,
+		Allocations: []PaymentAllocation{},
+	}, nil
+}
+
+// Allocate settles amount of the invoice from the unallocated part of the
+// payment.
+func (p *Payment) Allocate(inv *Invoice, amount Money, at time.Time) (PaymentAllocation, error) {
+	if inv.CustomerID != p.CustomerID {
+		return PaymentAllocation{}, ErrPaymentCustomerMismatch
+	}
+	if amount.Currency != p.Unallocated.Currency {
+		return PaymentAllocation{}, ErrMoneyCurrencyMismatch
+	}
+	if amount.Amount > p.Unallocated.Amount {
+		return PaymentAllocation{}, ErrPaymentOverAllocated
+	}
+	if err := inv.ApplyPayment(amount, at); err != nil {
+		return PaymentAllocation{}, err
+	}
+
+	p.Unallocated.Amount -= amount.Amount
+	allocation := PaymentAllocation{
+		ID:        ulid.Make(),
+		CreatedAt: at,
+		PaymentID: p.ID,
+		InvoiceID: inv.ID,
+		Amount:    amount,
+	}
+	p.Allocations = append(p.Allocations, allocation)
+
+	return allocation, nil
+}
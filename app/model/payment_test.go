@@ -0,0 +1,115 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+func TestInvoiceApplyPayment(t *testing.T) {
+	// The invoice is 3 × 10.00 plus 20% tax, 36.00 in all.
+	tests := []struct {
+		name     string
+		payments []Money
+		err      error
+		paid     int64
+		status   InvoiceStatus
+	}{
+		{"part", []Money{NewMoney(1000, "USD")}, nil, 1000, InvoiceStatusPartiallyPaid},
+		{"all at once", []Money{NewMoney(3600, "USD")}, nil, 3600, InvoiceStatusPaid},
+		{"rest after a part", []Money{NewMoney(1000, "USD"), NewMoney(2600, "USD")}, nil, 3600, InvoiceStatusPaid},
+		{"two parts", []Money{NewMoney(1000, "USD"), NewMoney(1000, "USD")}, nil, 2000, InvoiceStatusPartiallyPaid},
+		{"more than the balance", []Money{NewMoney(3601, "USD")}, ErrPaymentExceedsBalance, 0, InvoiceStatusIssued},
+		{"more than what is left", []Money{NewMoney(3000, "USD"), NewMoney(601, "USD")}, ErrPaymentExceedsBalance, 3000, InvoiceStatusPartiallyPaid},
+		{"another currency", []Money{NewMoney(1000, "EUR")}, ErrMoneyCurrencyMismatch, 0, InvoiceStatusIssued},
+		{"nothing", []Money{NewMoney(0, "USD")}, ErrMoneyInvalidAmount, 0, InvoiceStatusIssued},
+		{"after it was paid", []Money{NewMoney(3600, "USD"), NewMoney(1, "USD")}, ErrInvoiceNotPayable, 3600, InvoiceStatusPaid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv := issuedInvoice(t, 1000, 3)
+
+			var err error
+			for _, amount := range tt.payments {
+				if err = inv.ApplyPayment(amount, time.Now()); err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ApplyPayment() error = %v, want %v", err, tt.err)
+			}
+			if inv.PaidAmount.Amount != tt.paid {
+				t.Errorf("paid = %d, want %d", inv.PaidAmount.Amount, tt.paid)
+			}
+			if inv.Status != tt.status {
+				t.Errorf("status = %s, want %s", inv.Status, tt.status)
+			}
+		})
+	}
+
+	draft := issuedInvoice(t, 1000, 3)
+	draft.Status = InvoiceStatusDraft
+	if err := draft.ApplyPayment(NewMoney(1000, "USD"), time.Now()); !errors.Is(err, ErrInvoiceNotPayable) {
+		t.Errorf("ApplyPayment() on a draft error = %v, want %v", err, ErrInvoiceNotPayable)
+	}
+}
+
+func TestPaymentAllocate(t *testing.T) {
+	tests := []struct {
+		name          string
+		received      Money
+		allocated     Money
+		otherCustomer bool
+		err           error
+		unallocated   int64
+		status        InvoiceStatus
+	}{
+		{"part of the invoice", NewMoney(5000, "USD"), NewMoney(1000, "USD"), false, nil, 4000, InvoiceStatusPartiallyPaid},
+		{"the whole invoice", NewMoney(5000, "USD"), NewMoney(3600, "USD"), false, nil, 1400, InvoiceStatusPaid},
+		{"all of the payment", NewMoney(3600, "USD"), NewMoney(3600, "USD"), false, nil, 0, InvoiceStatusPaid},
+		{"more than the payment", NewMoney(1000, "USD"), NewMoney(1001, "USD"), false, ErrPaymentOverAllocated, 1000, InvoiceStatusIssued},
+		{"more than the invoice", NewMoney(5000, "USD"), NewMoney(3601, "USD"), false, ErrPaymentExceedsBalance, 5000, InvoiceStatusIssued},
+		{"another currency than the payment", NewMoney(5000, "USD"), NewMoney(1000, "EUR"), false, ErrMoneyCurrencyMismatch, 5000, InvoiceStatusIssued},
+		{"a payment in another currency than the invoice", NewMoney(5000, "EUR"), NewMoney(1000, "EUR"), false, ErrMoneyCurrencyMismatch, 5000, InvoiceStatusIssued},
+		{"another customer's invoice", NewMoney(5000, "USD"), NewMoney(1000, "USD"), true, ErrPaymentCustomerMismatch, 5000, InvoiceStatusIssued},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv := issuedInvoice(t, 1000, 3)
+			customerID := inv.CustomerID
+			if tt.otherCustomer {
+				customerID = ulid.Make()
+			}
+			payment, err := NewPayment(customerID, tt.received, PaymentMethodBankTransfer, "", time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			allocation, err := payment.Allocate(&inv, tt.allocated, time.Now())
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Allocate() error = %v, want %v", err, tt.err)
+			}
+			if payment.Unallocated.Amount != tt.unallocated {
+				t.Errorf("unallocated = %d, want %d", payment.Unallocated.Amount, tt.unallocated)
+			}
+			if inv.Status != tt.status {
+				t.Errorf("invoice status = %s, want %s", inv.Status, tt.status)
+			}
+
+			if err != nil {
+				if len(payment.Allocations) != 0 || inv.PaidAmount.IsPositive() {
+					t.Errorf("refused Allocate() left %d allocations and %d paid", len(payment.Allocations), inv.PaidAmount.Amount)
+				}
+				return
+			}
+			if allocation.PaymentID != payment.ID || allocation.InvoiceID != inv.ID || allocation.Amount != tt.allocated {
+				t.Errorf("allocation = %+v, want %+v of the invoice from the payment", allocation, tt.allocated)
+			}
+			if len(payment.Allocations) != 1 || inv.PaidAmount != tt.allocated {
+				t.Errorf("%d allocations and %+v paid, want the one of %+v", len(payment.Allocations), inv.PaidAmount, tt.allocated)
+			}
+		})
+	}
+}
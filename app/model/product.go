@@ -13,6 +13,8 @@ var (
 	ErrProductSKUDuplicated  = errors.New("product: sku duplicated")
 	ErrProductNotFound       = errors.New("product: not found")
 	ErrProductAlreadyDeleted = errors.New("product: already deleted")
+	ErrProductNotDeleted     = errors.New("product: not deleted")
+	ErrProductPriceCurrency  = errors.New("product: prices only compare within a currency")
 )
 
 type Product struct {
@@ -24,9 +26,18 @@ type Product struct {
 	Name        string     `json:"name"`
 	Description string     `json:"description"`
 	Image       *[]byte    `json:"image"`
-	Amount      float64    `json:"amount"`
+	Amount      Money      `json:"amount"`
 	Categories  []Category `json:"categories"`
 	Inventory   Inventory  `json:"inventory"`
+
+	// Locations is the stock per warehouse.
+	Locations []StockLocation `json:"locations"`
+
+	TaxClass         TaxClass `json:"tax_class"`
+	PriceIncludesTax bool     `json:"price_includes_tax"`
+
+	// AllowBackorder lets the product be sold below zero stock.
+	AllowBackorder bool `json:"allow_backorder"`
 }
 
 type CategoryProduct struct {
@@ -37,8 +48,11 @@ type CategoryProduct struct {
 func NewProduct(
 	Sku, Name, Description string,
 	Image *[]byte,
-	Amount float64,
+	Amount Money,
 	quantity int,
+	TaxClass TaxClass,
+	PriceIncludesTax bool,
+	AllowBackorder bool,
 ) Product {
 	id := ulid.Make()
 	idInv := ulid.Make()
@@ -58,12 +72,17 @@ func NewProduct(
 		Image:       Image,
 		Amount:      Amount,
 		Inventory:   inventory,
+
+		TaxClass:         TaxClass,
+		PriceIncludesTax: PriceIncludesTax,
+		AllowBackorder:   AllowBackorder,
 	}
 }
 
 func (t Product) MarshalJSON() ([]byte, error) {
-	s := t.Categories[0].ID.String()
-	if s == "00000000000000000000000000" {
+	// Lists only load category names; a product without categories has
+	// nothing to tell either way.
+	if len(t.Categories) == 0 || t.Categories[0].ID == (ulid.ULID{}) {
 		var j struct {
 			ID          ulid.ULID `json:"id"`
 			CreatedAt   time.Time `json:"created_at"`
@@ -71,9 +90,19 @@ func (t Product) MarshalJSON() ([]byte, error) {
 			Name        string    `json:"name"`
 			Description string    `json:"description"`
 			Image       *[]byte   `json:"image"`
-			Amount      float64   `json:"amount"`
+			Amount      Money     `json:"amount"`
 			Categories  []string  `json:"categories"`
 			Inventory   int       `json:"inventory"`
+			OnHand      int       `json:"on_hand"`
+			Reserved    int       `json:"reserved"`
+			Available   int       `json:"available"`
+			InTransit   int       `json:"in_transit"`
+
+			Locations []StockLocation `json:"locations"`
+
+			TaxClass         TaxClass `json:"tax_class"`
+			PriceIncludesTax bool     `json:"price_includes_tax"`
+			AllowBackorder   bool     `json:"allow_backorder"`
 		}
 
 		var x = make([]string, len(t.Categories))
@@ -90,6 +119,14 @@ func (t Product) MarshalJSON() ([]byte, error) {
 		j.Amount = t.Amount
 		j.Categories = x
 		j.Inventory = t.Inventory.Quantity
+		j.OnHand = t.Inventory.Quantity
+		j.Reserved = t.Inventory.Reserved
+		j.Available = t.Inventory.Available()
+		j.InTransit = t.Inventory.InTransit
+		j.Locations = t.Locations
+		j.TaxClass = t.TaxClass
+		j.PriceIncludesTax = t.PriceIncludesTax
+		j.AllowBackorder = t.AllowBackorder
 
 		return json.Marshal(j)
 	} else {
@@ -105,9 +142,19 @@ func (t Product) MarshalJSON() ([]byte, error) {
 			Name        string    `json:"name"`
 			Description string    `json:"description"`
 			Image       *[]byte   `json:"image"`
-			Amount      float64   `json:"amount"`
+			Amount      Money     `json:"amount"`
 			Categories  []Cats    `json:"categories"`
 			Inventory   int       `json:"inventory"`
+			OnHand      int       `json:"on_hand"`
+			Reserved    int       `json:"reserved"`
+			Available   int       `json:"available"`
+			InTransit   int       `json:"in_transit"`
+
+			Locations []StockLocation `json:"locations"`
+
+			TaxClass         TaxClass `json:"tax_class"`
+			PriceIncludesTax bool     `json:"price_includes_tax"`
+			AllowBackorder   bool     `json:"allow_backorder"`
 		}
 
 		var x = make([]Cats, len(t.Categories))
@@ -128,6 +175,14 @@ func (t Product) MarshalJSON() ([]byte, error) {
 		j.Amount = t.Amount
 		j.Categories = x
 		j.Inventory = t.Inventory.Quantity
+		j.OnHand = t.Inventory.Quantity
+		j.Reserved = t.Inventory.Reserved
+		j.Available = t.Inventory.Available()
+		j.InTransit = t.Inventory.InTransit
+		j.Locations = t.Locations
+		j.TaxClass = t.TaxClass
+		j.PriceIncludesTax = t.PriceIncludesTax
+		j.AllowBackorder = t.AllowBackorder
 
 		return json.Marshal(j)
 	}
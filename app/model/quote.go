@@ -0,0 +1,240 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+var (
+	ErrQuoteNotFound          = errors.New("quote: not found")
+	ErrQuoteAlreadyDeleted    = errors.New("quote: already deleted")
+	ErrQuoteEmpty             = errors.New("quote: has no lines")
+	ErrQuoteExpired           = errors.New("quote: expired")
+	ErrQuoteInvalidExpiry     = errors.New("quote: expiry must be in the future")
+	ErrQuoteInvalidTransition = errors.New("quote: invalid status transition")
+	ErrQuoteNotAccepted       = errors.New("quote: only accepted quotes can be converted")
+)
+
+type QuoteStatus string
+
+const (
+	QuoteStatusPending  QuoteStatus = "pending"
+	QuoteStatusAccepted QuoteStatus = "accepted"
+	QuoteStatusRejected QuoteStatus = "rejected"
+)
+
+// Quote is an offer sent to a customer before invoicing. Its lines are
+// snapshotted exactly like invoice lines, so converting an accepted quote
+// bills the prices and taxes that were offered.
+type Quote struct {
+	ID        ulid.ULID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt null.Time `json:"updated_at"`
+	DeletedAt null.Time `json:"deleted_at"`
+
+	CustomerID      ulid.ULID   `json:"customer_id"`
+	BillTo          BillTo      `json:"bill_to"`
+	Currency        string      `json:"currency"`
+	PaymentTermDays int         `json:"payment_term_days"`
+	Status          QuoteStatus `json:"status"`
+	ExpiresAt       time.Time   `json:"expires_at"`
+	AcceptedAt      null.Time   `json:"accepted_at"`
+	RejectedAt      null.Time   `json:"rejected_at"`
+
+	// InvoiceID is the draft invoice the quote was converted into, if any.
+	InvoiceID *ulid.ULID `json:"invoice_id"`
+
+	Lines []QuoteLine `json:"lines"`
+}
+
+type QuoteLine struct {
+	ID        ulid.ULID `json:"id"`
+	QuoteID   ulid.ULID `json:"quote_id"`
+	ProductID ulid.ULID `json:"product_id"`
+
+	Sku      string `json:"sku"`
+	Name     string `json:"name"`
+	Amount   Money  `json:"amount"`
+	Quantity int    `json:"quantity"`
+
+	TaxClass         TaxClass `json:"tax_class"`
+	TaxName          string   `json:"tax_name"`
+	TaxRate          int      `json:"tax_rate"`
+	PriceIncludesTax bool     `json:"price_includes_tax"`
+}
+
+// NewQuote offers the lines to the customer until ExpiresAt. The lines are
+// built the same way as invoice lines, with NewInvoiceLine.
+func NewQuote(
+	customer Customer,
+	lines []InvoiceLine,
+	ExpiresAt time.Time,
+) (Quote, error) {
+	now := time.Now()
+	if !ExpiresAt.After(now) {
+		return Quote{}, ErrQuoteInvalidExpiry
+	}
+	if len(lines) == 0 {
+		return Quote{}, ErrQuoteEmpty
+	}
+
+	quote := Quote{
+		ID:              ulid.Make(),
+		CreatedAt:       now,
+		CustomerID:      customer.ID,
+		Currency:        customer.Currency,
+		PaymentTermDays: customer.PaymentTermDays,
+		BillTo: BillTo{
+			LegalName: customer.LegalName,
+			Address:   customer.BillingAddress,
+			TaxID:     customer.TaxID,
+		},
+		Status:    QuoteStatusPending,
+		ExpiresAt: ExpiresAt,
+	}
+
+	quote.Lines = make([]QuoteLine, len(lines))
+	for idx, l := range lines {
+		if l.Amount.Currency != quote.Currency {
+			return Quote{}, ErrMoneyCurrencyMismatch
+		}
+		quote.Lines[idx] = QuoteLine{
+			ID:               l.ID,
+			QuoteID:          quote.ID,
+			ProductID:        l.ProductID,
+			Sku:              l.Sku,
+			Name:             l.Name,
+			Amount:           l.Amount,
+			Quantity:         l.Quantity,
+			TaxClass:         l.TaxClass,
+			TaxName:          l.TaxName,
+			TaxRate:          l.TaxRate,
+			PriceIncludesTax: l.PriceIncludesTax,
+		}
+	}
+
+	return quote, nil
+}
+
+func (q Quote) IsExpired(at time.Time) bool {
+	return !at.Before(q.ExpiresAt)
+}
+
+// Accept records the customer's acceptance of a pending quote that has not
+// expired yet.
+func (q *Quote) Accept(at time.Time) error {
+	if q.Status != QuoteStatusPending {
+		return ErrQuoteInvalidTransition
+	}
+	if q.IsExpired(at) {
+		return ErrQuoteExpired
+	}
+	q.Status = QuoteStatusAccepted
+	q.AcceptedAt = null.TimeFrom(at)
+	return nil
+}
+
+func (q *Quote) Reject(at time.Time) error {
+	if q.Status != QuoteStatusPending {
+		return ErrQuoteInvalidTransition
+	}
+	q.Status = QuoteStatusRejected
+	q.RejectedAt = null.TimeFrom(at)
+	return nil
+}
+
+// Convert turns an accepted quote into a draft invoice for the same
+// customer with identical lines and prices, and remembers the invoice on
+// the quote.
+func (q *Quote) Convert(at time.Time) (Invoice, error) {
+	if q.Status != QuoteStatusAccepted {
+		return Invoice{}, ErrQuoteNotAccepted
+	}
+
+	inv := Invoice{
+		ID:              ulid.Make(),
+		CreatedAt:       at,
+		CustomerID:      q.CustomerID,
+		BillTo:          q.BillTo,
+		Currency:        q.Currency,
+		PaymentTermDays: q.PaymentTermDays,
+		Status:          InvoiceStatusDraft,
+	}
+
+	var lines = make([]InvoiceLine, len(q.Lines))
+	for idx, l := range q.Lines {
+		lines[idx] = InvoiceLine{
+			ID:               ulid.Make(),
+			ProductID:        l.ProductID,
+			Sku:              l.Sku,
+			Name:             l.Name,
+			Amount:           l.Amount,
+			Quantity:         l.Quantity,
+			TaxClass:         l.TaxClass,
+			TaxName:          l.TaxName,
+			TaxRate:          l.TaxRate,
+			PriceIncludesTax: l.PriceIncludesTax,
+		}
+	}
+	if err := inv.SetLines(lines); err != nil {
+		return Invoice{}, err
+	}
+
+	q.InvoiceID = &inv.ID
+	return inv, nil
+}
+
+func (l QuoteLine) Taxed() TaxedLine {
+	return TaxedLine{
+		Amount:    l.Amount.Mul(int64(l.Quantity)),
+		TaxClass:  l.TaxClass,
+		TaxName:   l.TaxName,
+		TaxRate:   l.TaxRate,
+		Inclusive: l.PriceIncludesTax,
+	}
+}
+
+func (l QuoteLine) Tax() LineTax {
+	t := l.Taxed()
+	return CalculateLineTax(t.Amount, t.TaxRate, t.Inclusive)
+}
+
+func (l QuoteLine) MarshalJSON() ([]byte, error) {
+	type quoteLine QuoteLine
+	var j struct {
+		quoteLine
+		LineTax
+	}
+
+	j.quoteLine = quoteLine(l)
+	j.LineTax = l.Tax()
+
+	return json.Marshal(j)
+}
+
+func (q Quote) Totals() TaxSummary {
+	var lines = make([]TaxedLine, len(q.Lines))
+	for idx := range q.Lines {
+		lines[idx] = q.Lines[idx].Taxed()
+	}
+	return SummarizeTax(q.Currency, lines)
+}
+
+func (q Quote) MarshalJSON() ([]byte, error) {
+	type quote Quote
+	var j struct {
+		quote
+		TaxSummary
+		Expired bool `json:"expired"`
+	}
+
+	j.quote = quote(q)
+	j.TaxSummary = q.Totals()
+	j.Expired = q.Status == QuoteStatusPending && q.IsExpired(time.Now())
+
+	return json.Marshal(j)
+}
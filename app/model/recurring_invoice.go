@@ -0,0 +1,195 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+var (
+	ErrRecurringInvoiceNotFound       = errors.New("recurring invoice: not found")
+	ErrRecurringInvoiceAlreadyDeleted = errors.New("recurring invoice: already deleted")
+	ErrRecurringInvoiceEmpty          = errors.New("recurring invoice: has no lines")
+	ErrRecurringInvoiceAnchorDay      = errors.New("recurring invoice: anchor day must be between 1 and 31")
+	ErrRecurringInvoiceInvalidEnd     = errors.New("recurring invoice: end must be after the start and count positive")
+	ErrRecurrenceIntervalInvalid      = errors.New("recurrence interval: invalid")
+)
+
+type RecurrenceInterval string
+
+const (
+	RecurrenceMonthly   RecurrenceInterval = "monthly"
+	RecurrenceQuarterly RecurrenceInterval = "quarterly"
+	RecurrenceYearly    RecurrenceInterval = "yearly"
+)
+
+func (i RecurrenceInterval) Validate() error {
+	if i.Months() == 0 {
+		return ErrRecurrenceIntervalInvalid
+	}
+	return nil
+}
+
+// Months is the length of the interval in months.
+func (i RecurrenceInterval) Months() int {
+	switch i {
+	case RecurrenceMonthly:
+		return 1
+	case RecurrenceQuarterly:
+		return 3
+	case RecurrenceYearly:
+		return 12
+	}
+	return 0
+}
+
+// RecurringInvoice bills a customer the same products at a fixed interval.
+// Invoices are generated on the anchor day of the month, or on the last day
+// of shorter months, starting at StartsAt and ending after EndsAt or once
+// MaxCount invoices were generated, whichever comes first. Prices and tax
+// rates are taken when each invoice is generated.
+type RecurringInvoice struct {
+	ID        ulid.ULID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt null.Time `json:"updated_at"`
+	DeletedAt null.Time `json:"deleted_at"`
+
+	// TenantID is set by the database to the tenant the schedule was
+	// created for; its invoices are generated for the same tenant.
+	TenantID       ulid.ULID          `json:"tenant_id"`
+	CustomerID     ulid.ULID          `json:"customer_id"`
+	Interval       RecurrenceInterval `json:"interval"`
+	AnchorDay      int                `json:"anchor_day"`
+	StartsAt       time.Time          `json:"starts_at"`
+	EndsAt         null.Time          `json:"ends_at"`
+	MaxCount       null.Int           `json:"max_count"`
+	AutoIssue      bool               `json:"auto_issue"`
+	GeneratedCount int                `json:"generated_count"`
+	NextRunAt      null.Time          `json:"next_run_at"`
+
+	Lines []RecurringInvoiceLine `json:"lines"`
+}
+
+type RecurringInvoiceLine struct {
+	ID                 ulid.ULID `json:"id"`
+	RecurringInvoiceID ulid.ULID `json:"recurring_invoice_id"`
+	ProductID          ulid.ULID `json:"product_id"`
+	Quantity           int       `json:"quantity"`
+}
+
+func NewRecurringInvoiceLine(ProductID ulid.ULID, Quantity int) RecurringInvoiceLine {
+	id := ulid.Make()
+	return RecurringInvoiceLine{
+		ID:        id,
+		ProductID: ProductID,
+		Quantity:  Quantity,
+	}
+}
+
+func NewRecurringInvoice(
+	CustomerID ulid.ULID,
+	Interval RecurrenceInterval,
+	AnchorDay int,
+	StartsAt time.Time,
+	EndsAt null.Time,
+	MaxCount null.Int,
+	AutoIssue bool,
+	lines []RecurringInvoiceLine,
+) (RecurringInvoice, error) {
+	if err := Interval.Validate(); err != nil {
+		return RecurringInvoice{}, err
+	}
+	if AnchorDay < 1 || AnchorDay > 31 {
+		return RecurringInvoice{}, ErrRecurringInvoiceAnchorDay
+	}
+	if (EndsAt.Valid && !EndsAt.Time.After(StartsAt)) || (MaxCount.Valid && MaxCount.Int64 <= 0) {
+		return RecurringInvoice{}, ErrRecurringInvoiceInvalidEnd
+	}
+	if len(lines) == 0 {
+		return RecurringInvoice{}, ErrRecurringInvoiceEmpty
+	}
+
+	r := RecurringInvoice{
+		ID:         ulid.Make(),
+		CreatedAt:  time.Now(),
+		CustomerID: CustomerID,
+		Interval:   Interval,
+		AnchorDay:  AnchorDay,
+		StartsAt:   StartsAt,
+		EndsAt:     EndsAt,
+		MaxCount:   MaxCount,
+		AutoIssue:  AutoIssue,
+		Lines:      lines,
+	}
+	for idx := range r.Lines {
+		r.Lines[idx].RecurringInvoiceID = r.ID
+	}
+	r.schedule()
+
+	return r, nil
+}
+
+// Occurrence returns when the n-th invoice, counting from zero, is due. The
+// first occurrence is the first anchor day at or after StartsAt.
+func (r RecurringInvoice) Occurrence(n int) time.Time {
+	start := r.StartsAt
+	offset := 0
+	if anchorDate(start.Year(), start.Month(), r.AnchorDay, start).Before(start) {
+		offset = 1
+	}
+	month := start.Month() + time.Month(offset+n*r.Interval.Months())
+	return anchorDate(start.Year(), month, r.AnchorDay, start)
+}
+
+// anchorDate is the anchor day of the month, at the time of day of clock.
+// Months shorter than the anchor day use their last day. month may be out of
+// range, time.Date normalises it.
+func anchorDate(year int, month time.Month, day int, clock time.Time) time.Time {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, clock.Location()).Day()
+	if day > last {
+		day = last
+	}
+	return time.Date(year, month, day, clock.Hour(), clock.Minute(), clock.Second(), 0, clock.Location())
+}
+
+// schedule sets NextRunAt to the next occurrence, or clears it once the
+// schedule has ended.
+func (r *RecurringInvoice) schedule() {
+	next := r.Occurrence(r.GeneratedCount)
+	if (r.MaxCount.Valid && int64(r.GeneratedCount) >= r.MaxCount.Int64) ||
+		(r.EndsAt.Valid && next.After(r.EndsAt.Time)) {
+		r.NextRunAt = null.Time{}
+		return
+	}
+	r.NextRunAt = null.TimeFrom(next)
+}
+
+// IsDue reports whether an invoice should have been generated by at.
+func (r RecurringInvoice) IsDue(at time.Time) bool {
+	return r.NextRunAt.Valid && !r.NextRunAt.Time.After(at)
+}
+
+// Generate bills the current occurrence as a draft invoice, issued right
+// away at at when AutoIssue is set, and moves the schedule on to the next
+// occurrence. lines are the schedule's lines priced for the customer.
+func (r *RecurringInvoice) Generate(customer Customer, lines []InvoiceLine, at time.Time) (Invoice, error) {
+	inv, err := NewInvoice(customer, lines)
+	if err != nil {
+		return Invoice{}, err
+	}
+	inv.CreatedAt = at
+	inv.RecurringInvoiceID = &r.ID
+
+	if r.AutoIssue {
+		if err := inv.Issue(at); err != nil {
+			return Invoice{}, err
+		}
+	}
+
+	r.GeneratedCount++
+	r.schedule()
+
+	return inv, nil
+}
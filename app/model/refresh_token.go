@@ -0,0 +1,63 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token: not found")
+	ErrRefreshTokenExpired  = errors.New("refresh token: expired")
+	ErrRefreshTokenRevoked  = errors.New("refresh token: revoked")
+	ErrRefreshTokenReused   = errors.New("refresh token: already used")
+)
+
+// RefreshToken is one link of a login session. Every refresh uses up the
+// token and hands out the next one of the same family; presenting a used
+// token again means it was stolen, and the whole family is revoked.
+type RefreshToken struct {
+	ID        ulid.ULID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID    ulid.ULID `json:"user_id"`
+	FamilyID  ulid.ULID `json:"family_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	UsedAt    null.Time `json:"used_at"`
+	RevokedAt null.Time `json:"revoked_at"`
+}
+
+// NewRefreshToken starts a new session for the user.
+func NewRefreshToken(UserID ulid.ULID, ttl time.Duration, at time.Time) RefreshToken {
+	id := ulid.Make()
+	return RefreshToken{
+		ID:        id,
+		CreatedAt: at,
+		UserID:    UserID,
+		FamilyID:  id,
+		ExpiresAt: at.Add(ttl),
+	}
+}
+
+// Rotate uses up the token and returns its successor in the same family.
+func (t *RefreshToken) Rotate(ttl time.Duration, at time.Time) (RefreshToken, error) {
+	switch {
+	case t.RevokedAt.Valid:
+		return RefreshToken{}, ErrRefreshTokenRevoked
+	case t.UsedAt.Valid:
+		return RefreshToken{}, ErrRefreshTokenReused
+	case !at.Before(t.ExpiresAt):
+		return RefreshToken{}, ErrRefreshTokenExpired
+	}
+
+	t.UsedAt = null.TimeFrom(at)
+	return RefreshToken{
+		ID:        ulid.Make(),
+		CreatedAt: at,
+		UserID:    t.UserID,
+		FamilyID:  t.FamilyID,
+		ExpiresAt: at.Add(ttl),
+	}, nil
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestRefreshTokenRotate(t *testing.T) {
+	at := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	token := NewRefreshToken(ulid.Make(), time.Hour, at)
+
+	next, err := token.Rotate(time.Hour, at.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !token.UsedAt.Valid {
+		t.Error("rotated token isn't used up")
+	}
+	if next.ID == token.ID || next.FamilyID != token.FamilyID || next.UserID != token.UserID {
+		t.Errorf("successor = %+v, want a new token of family %s", next, token.FamilyID)
+	}
+	if !next.ExpiresAt.Equal(at.Add(time.Minute + time.Hour)) {
+		t.Errorf("successor expires at %v, want an hour after the rotation", next.ExpiresAt)
+	}
+
+	// Presenting the used token again is how a stolen one shows.
+	if _, err := token.Rotate(time.Hour, at.Add(2*time.Minute)); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("Rotate() of a used token error = %v, want %v", err, ErrRefreshTokenReused)
+	}
+	if _, err := next.Rotate(time.Hour, at.Add(2*time.Minute)); err != nil {
+		t.Errorf("Rotate() of the successor error = %v", err)
+	}
+}
+
+func TestRefreshTokenRotateRefused(t *testing.T) {
+	at := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		usedAt  null.Time
+		revoked null.Time
+		after   time.Duration
+		err     error
+	}{
+		{"used", null.TimeFrom(at), null.Time{}, time.Minute, ErrRefreshTokenReused},
+		{"revoked", null.Time{}, null.TimeFrom(at), time.Minute, ErrRefreshTokenRevoked},
+		{"revoked after use", null.TimeFrom(at), null.TimeFrom(at), time.Minute, ErrRefreshTokenRevoked},
+		{"expired", null.Time{}, null.Time{}, time.Hour + time.Minute, ErrRefreshTokenExpired},
+		{"expiring", null.Time{}, null.Time{}, time.Hour, ErrRefreshTokenExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := NewRefreshToken(ulid.Make(), time.Hour, at)
+			token.UsedAt = tt.usedAt
+			token.RevokedAt = tt.revoked
+
+			if _, err := token.Rotate(time.Hour, at.Add(tt.after)); !errors.Is(err, tt.err) {
+				t.Fatalf("Rotate() error = %v, want %v", err, tt.err)
+			}
+			if token.UsedAt != tt.usedAt {
+				t.Errorf("refused Rotate() changed used at to %v", token.UsedAt)
+			}
+		})
+	}
+}
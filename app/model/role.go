@@ -0,0 +1,30 @@
+package model
+
+import "errors"
+
+var ErrRoleInvalid = errors.New("role: invalid")
+
+type Role string
+
+const (
+	RoleAdmin      Role = "admin"
+	RoleAccountant Role = "accountant"
+	RoleWarehouse  Role = "warehouse"
+	RoleViewer     Role = "viewer"
+)
+
+var Roles = []Role{
+	RoleAdmin,
+	RoleAccountant,
+	RoleWarehouse,
+	RoleViewer,
+}
+
+func (r Role) Validate() error {
+	for _, known := range Roles {
+		if r == known {
+			return nil
+		}
+	}
+	return ErrRoleInvalid
+}
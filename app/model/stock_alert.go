@@ -0,0 +1,84 @@
+package model
+
+import (
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+type StockAlertType string
+
+const (
+	// StockAlertLow is raised when the stock drops to or below the reorder
+	// point.
+	StockAlertLow StockAlertType = "low_stock"
+	// StockAlertRestocked is raised when the stock rises above the reorder
+	// point again.
+	StockAlertRestocked StockAlertType = "restocked"
+)
+
+// StockAlert tells that a stock movement took a product's stock in a
+// warehouse across its reorder point.
+type StockAlert struct {
+	Type StockAlertType `json:"type"`
+
+	TenantID        ulid.ULID `json:"tenant_id"`
+	ProductID       ulid.ULID `json:"product_id"`
+	WarehouseID     ulid.ULID `json:"warehouse_id"`
+	MovementID      ulid.ULID `json:"movement_id"`
+	Quantity        int       `json:"quantity"`
+	ReorderPoint    int       `json:"reorder_point"`
+	ReorderQuantity int       `json:"reorder_quantity"`
+	At              time.Time `json:"at"`
+}
+
+// Alert tells whether the movement, already applied to the inventory, took
+// its stock across the reorder point, and in which direction.
+func (inv Inventory) Alert(m StockMovement) (StockAlert, bool) {
+	if !inv.ReorderPoint.Valid {
+		return StockAlert{}, false
+	}
+
+	var (
+		point  = int(inv.ReorderPoint.Int64)
+		after  = m.QuantityAfter
+		before = after - m.Quantity
+		kind   StockAlertType
+	)
+	switch {
+	case before > point && after <= point:
+		kind = StockAlertLow
+	case before <= point && after > point:
+		kind = StockAlertRestocked
+	default:
+		return StockAlert{}, false
+	}
+
+	return StockAlert{
+		Type:            kind,
+		ProductID:       m.ProductID,
+		WarehouseID:     m.WarehouseID,
+		MovementID:      m.ID,
+		Quantity:        after,
+		ReorderPoint:    point,
+		ReorderQuantity: inv.ReorderQuantity,
+		At:              m.CreatedAt,
+	}, true
+}
+
+// LowStock is the stock of a product in a warehouse that is at or below
+// its reorder point. Shortfall is how far below the reorder point it is.
+type LowStock struct {
+	ProductID       ulid.ULID `json:"product_id"`
+	Sku             string    `json:"sku"`
+	Name            string    `json:"name"`
+	WarehouseID     ulid.ULID `json:"warehouse_id"`
+	WarehouseCode   string    `json:"warehouse_code"`
+	OnHand          int       `json:"on_hand"`
+	Reserved        int       `json:"reserved"`
+	Available       int       `json:"available"`
+	InTransit       int       `json:"in_transit"`
+	ReorderPoint    int       `json:"reorder_point"`
+	ReorderQuantity int       `json:"reorder_quantity"`
+	Shortfall       int       `json:"shortfall"`
+}
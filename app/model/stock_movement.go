@@ -0,0 +1,116 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+var (
+	ErrStockMovementTypeInvalid     = errors.New("stock movement: invalid type")
+	ErrStockMovementInvalidQuantity = errors.New("stock movement: quantity does not fit the type")
+)
+
+type StockMovementType string
+
+const (
+	StockReceipt    StockMovementType = "receipt"
+	StockSale       StockMovementType = "sale"
+	StockAdjustment StockMovementType = "adjustment"
+	StockReturn     StockMovementType = "return"
+	StockTransfer   StockMovementType = "transfer"
+)
+
+func (t StockMovementType) Validate() error {
+	switch t {
+	case StockReceipt, StockSale, StockAdjustment, StockReturn, StockTransfer:
+		return nil
+	}
+	return ErrStockMovementTypeInvalid
+}
+
+// validQuantity reports whether quantity moves stock the way the type
+// does: receipts and returns bring stock in, sales take it out, and
+// adjustments and transfers go either way.
+func (t StockMovementType) validQuantity(quantity int) bool {
+	switch t {
+	case StockReceipt, StockReturn:
+		return quantity > 0
+	case StockSale:
+		return quantity < 0
+	}
+	return quantity != 0
+}
+
+// StockMovement is one entry of the append-only stock ledger. Quantity is
+// the signed change of the on-hand quantity, QuantityAfter what it was
+// left at. Actor is who made the change, e.g. user:<id>, api_key:<id> or
+// system. InvoiceID and CreditNoteID name the billing document the goods
+// were sold or returned for, if any; Reference is only for people to read.
+type StockMovement struct {
+	ID        ulid.ULID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	InventoryID   ulid.ULID         `json:"inventory_id"`
+	WarehouseID   ulid.ULID         `json:"warehouse_id"`
+	ProductID     ulid.ULID         `json:"product_id"`
+	Type          StockMovementType `json:"type"`
+	Quantity      int               `json:"quantity"`
+	QuantityAfter int               `json:"quantity_after"`
+	Reason        string            `json:"reason"`
+	Reference     string            `json:"reference"`
+	Actor         string            `json:"actor"`
+	InvoiceID     *ulid.ULID        `json:"invoice_id"`
+	CreditNoteID  *ulid.ULID        `json:"credit_note_id"`
+}
+
+// NewStockMovement records a change of the product's stock by quantity.
+// Which inventory it moves and what it leaves are filled in once it is
+// applied, by Inventory.Move or by the database.
+func NewStockMovement(
+	ProductID ulid.ULID,
+	Type StockMovementType,
+	Quantity int,
+	Reason, Reference, Actor string,
+	at time.Time,
+) (StockMovement, error) {
+	if err := Type.Validate(); err != nil {
+		return StockMovement{}, err
+	}
+	if !Type.validQuantity(Quantity) {
+		return StockMovement{}, ErrStockMovementInvalidQuantity
+	}
+
+	return StockMovement{
+		ID:        ulid.Make(),
+		CreatedAt: at,
+		ProductID: ProductID,
+		Type:      Type,
+		Quantity:  Quantity,
+		Reason:    Reason,
+		Reference: Reference,
+		Actor:     Actor,
+	}, nil
+}
+
+// Move changes the on-hand quantity by quantity and returns the ledger
+// entry recording it.
+func (inv *Inventory) Move(
+	ProductID ulid.ULID,
+	Type StockMovementType,
+	Quantity int,
+	Reason, Reference, Actor string,
+	at time.Time,
+) (StockMovement, error) {
+	m, err := NewStockMovement(ProductID, Type, Quantity, Reason, Reference, Actor, at)
+	if err != nil {
+		return StockMovement{}, err
+	}
+
+	inv.Quantity += Quantity
+	m.InventoryID = inv.ID
+	m.WarehouseID = inv.WarehouseID
+	m.QuantityAfter = inv.Quantity
+	return m, nil
+}
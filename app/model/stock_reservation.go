@@ -0,0 +1,78 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+var (
+	ErrStockReservationInvalidQuantity = errors.New("stock reservation: quantity must be positive")
+	ErrStockReservationInvalidExpiry   = errors.New("stock reservation: expiry must be in the future")
+)
+
+// StockReservation holds stock of a product for a draft invoice until
+// ExpiresAt, so it isn't sold twice. Held stock stays on hand but is no
+// longer available; issuing the invoice consumes the hold.
+type StockReservation struct {
+	ID        ulid.ULID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	InventoryID ulid.ULID `json:"inventory_id"`
+	ProductID   ulid.ULID `json:"product_id"`
+	InvoiceID   ulid.ULID `json:"invoice_id"`
+	Quantity    int       `json:"quantity"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+func NewStockReservation(
+	InvoiceID, ProductID ulid.ULID,
+	Quantity int,
+	ExpiresAt, at time.Time,
+) (StockReservation, error) {
+	if Quantity <= 0 {
+		return StockReservation{}, ErrStockReservationInvalidQuantity
+	}
+	if !ExpiresAt.After(at) {
+		return StockReservation{}, ErrStockReservationInvalidExpiry
+	}
+
+	return StockReservation{
+		ID:        ulid.Make(),
+		CreatedAt: at,
+		ProductID: ProductID,
+		InvoiceID: InvoiceID,
+		Quantity:  Quantity,
+		ExpiresAt: ExpiresAt,
+	}, nil
+}
+
+func (r StockReservation) IsExpired(at time.Time) bool {
+	return !at.Before(r.ExpiresAt)
+}
+
+// Reserve holds the reservation's quantity of the available stock. Products
+// that allow backorders can hold more than is available.
+func (inv *Inventory) Reserve(r *StockReservation, allowBackorder bool) error {
+	if !allowBackorder && r.Quantity > inv.Available() {
+		return ErrInsufficientStock
+	}
+	inv.Reserved += r.Quantity
+	r.InventoryID = inv.ID
+	return nil
+}
+
+// Consume checks that quantity can be sold when held of it is covered by
+// the invoice's own reservations, and gives up the hold. What isn't held has
+// to be available, unless the product allows backorders.
+func (inv *Inventory) Consume(quantity, held int, allowBackorder bool) error {
+	if held > quantity {
+		held = quantity
+	}
+	if !allowBackorder && quantity-held > inv.Available() {
+		return ErrInsufficientStock
+	}
+	inv.Reserved -= held
+	return nil
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+func TestInventoryReserve(t *testing.T) {
+	tests := []struct {
+		name           string
+		onHand         int
+		reserved       int
+		quantity       int
+		allowBackorder bool
+		err            error
+		wantReserved   int
+	}{
+		{"within the available stock", 10, 0, 4, false, nil, 4},
+		{"all of the available stock", 10, 6, 4, false, nil, 10},
+		{"more than is available", 10, 0, 11, false, ErrInsufficientStock, 0},
+		{"stock already held for others", 10, 8, 3, false, ErrInsufficientStock, 8},
+		{"nothing on hand", 0, 0, 1, false, ErrInsufficientStock, 0},
+		{"backorder past the stock", 10, 8, 5, true, nil, 13},
+		{"backorder with nothing on hand", 0, 0, 3, true, nil, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv := Inventory{ID: ulid.Make(), Quantity: tt.onHand, Reserved: tt.reserved}
+			r, err := NewStockReservation(ulid.Make(), ulid.Make(), tt.quantity, time.Now().Add(time.Hour), time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = inv.Reserve(&r, tt.allowBackorder)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Reserve() error = %v, want %v", err, tt.err)
+			}
+			if inv.Reserved != tt.wantReserved {
+				t.Errorf("reserved = %d, want %d", inv.Reserved, tt.wantReserved)
+			}
+			if inv.Quantity != tt.onHand {
+				t.Errorf("on hand = %d, want it left at %d", inv.Quantity, tt.onHand)
+			}
+			if held := err == nil; (r.InventoryID == inv.ID) != held {
+				t.Errorf("reservation inventory = %s, want held %v in %s", r.InventoryID, held, inv.ID)
+			}
+		})
+	}
+}
+
+func TestInventoryConsume(t *testing.T) {
+	tests := []struct {
+		name           string
+		onHand         int
+		reserved       int
+		quantity       int
+		held           int
+		allowBackorder bool
+		err            error
+		wantReserved   int
+	}{
+		{"fully held", 5, 5, 5, 5, false, nil, 0},
+		{"held among others' holds", 10, 8, 3, 3, false, nil, 5},
+		{"partly held, rest available", 10, 4, 6, 4, false, nil, 0},
+		{"partly held, rest not available", 10, 8, 6, 2, false, ErrInsufficientStock, 8},
+		{"not held, available", 10, 0, 10, 0, false, nil, 0},
+		{"not held, held for others", 10, 5, 6, 0, false, ErrInsufficientStock, 5},
+		{"held more than is sold", 10, 8, 3, 8, false, nil, 5},
+		{"more than on hand", 5, 0, 6, 0, false, ErrInsufficientStock, 0},
+		{"backorder, not held", 5, 5, 6, 0, true, nil, 5},
+		{"backorder, partly held", 2, 2, 6, 2, true, nil, 0},
+		{"backorder with nothing on hand", 0, 0, 4, 0, true, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv := Inventory{ID: ulid.Make(), Quantity: tt.onHand, Reserved: tt.reserved}
+
+			err := inv.Consume(tt.quantity, tt.held, tt.allowBackorder)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Consume() error = %v, want %v", err, tt.err)
+			}
+			if inv.Reserved != tt.wantReserved {
+				t.Errorf("reserved = %d, want %d", inv.Reserved, tt.wantReserved)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package model
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+var (
+	ErrTaxRateNotFound       = errors.New("tax rate: not found")
+	ErrTaxRateAlreadyDeleted = errors.New("tax rate: already deleted")
+	ErrTaxRateOverlap        = errors.New("tax rate: overlaps another rate of the same class")
+	ErrTaxRateInvalidPeriod  = errors.New("tax rate: valid_to must be after valid_from")
+	ErrTaxClassInvalid       = errors.New("tax class: invalid")
+)
+
+type TaxClass string
+
+const (
+	TaxClassStandard TaxClass = "standard"
+	TaxClassReduced  TaxClass = "reduced"
+	TaxClassZero     TaxClass = "zero"
+	TaxClassExempt   TaxClass = "exempt"
+)
+
+var TaxClasses = []TaxClass{
+	TaxClassStandard,
+	TaxClassReduced,
+	TaxClassZero,
+	TaxClassExempt,
+}
+
+func (c TaxClass) Validate() error {
+	for _, known := range TaxClasses {
+		if c == known {
+			return nil
+		}
+	}
+	return ErrTaxClassInvalid
+}
+
+// taxRateScale is the denominator of TaxRate.Rate; rates are kept in basis
+// points, so 2000 is 20% and 725 is 7.25%.
+const taxRateScale = 10000
+
+// TaxRate is the rate of a tax class in a jurisdiction for a period of time.
+// An empty Jurisdiction applies wherever no specific rate exists. ValidTo is
+// exclusive; an open end means the rate is still in effect.
+type TaxRate struct {
+	ID        ulid.ULID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt null.Time `json:"updated_at"`
+	DeletedAt null.Time `json:"deleted_at"`
+
+	Class        TaxClass  `json:"class"`
+	Jurisdiction string    `json:"jurisdiction"`
+	Name         string    `json:"name"`
+	Rate         int       `json:"rate"`
+	ValidFrom    time.Time `json:"valid_from"`
+	ValidTo      null.Time `json:"valid_to"`
+}
+
+func NewTaxRate(
+	Class TaxClass,
+	Jurisdiction, Name string,
+	Rate int,
+	ValidFrom time.Time,
+	ValidTo null.Time,
+) (TaxRate, error) {
+	if ValidTo.Valid && !ValidTo.Time.After(ValidFrom) {
+		return TaxRate{}, ErrTaxRateInvalidPeriod
+	}
+
+	id := ulid.Make()
+	return TaxRate{
+		ID:           id,
+		CreatedAt:    time.Now(),
+		Class:        Class,
+		Jurisdiction: Jurisdiction,
+		Name:         Name,
+		Rate:         Rate,
+		ValidFrom:    ValidFrom,
+		ValidTo:      ValidTo,
+	}, nil
+}
+
+// UntaxedRate is used for classes that need no configured rate: exempt
+// supplies always, zero-rated supplies when no rate has been set up.
+func UntaxedRate(class TaxClass) TaxRate {
+	name := "Zero rated"
+	if class == TaxClassExempt {
+		name = "Exempt"
+	}
+	return TaxRate{
+		Class: class,
+		Name:  name,
+	}
+}
+
+// LineTax is the tax calculated for one line. Prices that include tax are
+// split into net and tax, prices that exclude it get the tax added on top.
+type LineTax struct {
+	Net   Money `json:"net"`
+	Tax   Money `json:"tax"`
+	Gross Money `json:"gross"`
+}
+
+func CalculateLineTax(amount Money, rate int, inclusive bool) LineTax {
+	if inclusive {
+		net := amount.MulRatio(taxRateScale, int64(taxRateScale+rate))
+		return LineTax{
+			Net:   net,
+			Tax:   NewMoney(amount.Amount-net.Amount, amount.Currency),
+			Gross: amount,
+		}
+	}
+
+	tax := amount.MulRatio(int64(rate), taxRateScale)
+	return LineTax{
+		Net:   amount,
+		Tax:   tax,
+		Gross: NewMoney(amount.Amount+tax.Amount, amount.Currency),
+	}
+}
+
+// TaxBreakdown sums up the lines taxed at the same rate, as it has to be
+// printed on the invoice.
+type TaxBreakdown struct {
+	Class TaxClass `json:"class"`
+	Name  string   `json:"name"`
+	Rate  int      `json:"rate"`
+	Net   Money    `json:"net"`
+	Tax   Money    `json:"tax"`
+}
+
+type TaxSummary struct {
+	Subtotal  Money          `json:"subtotal"`
+	TaxTotal  Money          `json:"tax_total"`
+	Total     Money          `json:"total"`
+	Breakdown []TaxBreakdown `json:"tax_breakdown"`
+}
+
+// TaxedLine is the part of a document line the tax calculation needs.
+type TaxedLine struct {
+	Amount    Money
+	TaxClass  TaxClass
+	TaxName   string
+	TaxRate   int
+	Inclusive bool
+	// Tax is what the line was taxed at before, e.g. the share of an
+	// invoice line's tax a credit note took back; calculated from the rate
+	// when nil.
+	Tax *Money
+}
+
+// Calculate taxes the line, unless its tax was settled before.
+func (l TaxedLine) Calculate() LineTax {
+	if l.Tax == nil {
+		return CalculateLineTax(l.Amount, l.TaxRate, l.Inclusive)
+	}
+	if l.Inclusive {
+		return LineTax{
+			Net:   NewMoney(l.Amount.Amount-l.Tax.Amount, l.Amount.Currency),
+			Tax:   *l.Tax,
+			Gross: l.Amount,
+		}
+	}
+	return LineTax{
+		Net:   l.Amount,
+		Tax:   *l.Tax,
+		Gross: NewMoney(l.Amount.Amount+l.Tax.Amount, l.Amount.Currency),
+	}
+}
+
+// SummarizeTax calculates every line and groups the result per rate. Tax is
+// rounded per line, so the breakdown always adds up to the line totals.
+func SummarizeTax(currency string, lines []TaxedLine) TaxSummary {
+	type key struct {
+		class TaxClass
+		name  string
+		rate  int
+	}
+
+	summary := TaxSummary{
+		Subtotal:  NewMoney(0, currency),
+		TaxTotal:  NewMoney(0, currency),
+		Total:     NewMoney(0, currency),
+		Breakdown: []TaxBreakdown{},
+	}
+
+	var groups = map[key]int{}
+	for _, l := range lines {
+		t := l.Calculate()
+		summary.Subtotal.Amount += t.Net.Amount
+		summary.TaxTotal.Amount += t.Tax.Amount
+		summary.Total.Amount += t.Gross.Amount
+
+		k := key{l.TaxClass, l.TaxName, l.TaxRate}
+		idx, ok := groups[k]
+		if !ok {
+			idx = len(summary.Breakdown)
+			groups[k] = idx
+			summary.Breakdown = append(summary.Breakdown, TaxBreakdown{
+				Class: l.TaxClass,
+				Name:  l.TaxName,
+				Rate:  l.TaxRate,
+				Net:   NewMoney(0, currency),
+				Tax:   NewMoney(0, currency),
+			})
+		}
+		summary.Breakdown[idx].Net.Amount += t.Net.Amount
+		summary.Breakdown[idx].Tax.Amount += t.Tax.Amount
+	}
+
+	sort.SliceStable(summary.Breakdown, func(i, j int) bool {
+		return summary.Breakdown[i].Rate > summary.Breakdown[j].Rate
+	})
+
+	return summary
+}
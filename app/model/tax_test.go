@@ -0,0 +1,174 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCalculateLineTax(t *testing.T) {
+	tests := []struct {
+		name      string
+		amount    int64
+		rate      int
+		inclusive bool
+		want      LineTax
+	}{
+		{
+			"exclusive",
+			1000, 2000, false,
+			LineTax{NewMoney(1000, "EUR"), NewMoney(200, "EUR"), NewMoney(1200, "EUR")},
+		},
+		{
+			"inclusive",
+			1200, 2000, true,
+			LineTax{NewMoney(1000, "EUR"), NewMoney(200, "EUR"), NewMoney(1200, "EUR")},
+		},
+		{
+			"exclusive rounds down",
+			999, 725, false,
+			LineTax{NewMoney(999, "EUR"), NewMoney(72, "EUR"), NewMoney(1071, "EUR")},
+		},
+		{
+			"exclusive rounds half up",
+			5, 1000, false,
+			LineTax{NewMoney(5, "EUR"), NewMoney(1, "EUR"), NewMoney(6, "EUR")},
+		},
+		{
+			"inclusive keeps gross",
+			1000, 725, true,
+			LineTax{NewMoney(932, "EUR"), NewMoney(68, "EUR"), NewMoney(1000, "EUR")},
+		},
+		{
+			"zero rate",
+			1234, 0, false,
+			LineTax{NewMoney(1234, "EUR"), NewMoney(0, "EUR"), NewMoney(1234, "EUR")},
+		},
+		{
+			"zero rate inclusive",
+			1234, 0, true,
+			LineTax{NewMoney(1234, "EUR"), NewMoney(0, "EUR"), NewMoney(1234, "EUR")},
+		},
+		{
+			"negative rounds away from zero",
+			-5, 1000, false,
+			LineTax{NewMoney(-5, "EUR"), NewMoney(-1, "EUR"), NewMoney(-6, "EUR")},
+		},
+		{
+			"negative inclusive",
+			-1200, 2000, true,
+			LineTax{NewMoney(-1000, "EUR"), NewMoney(-200, "EUR"), NewMoney(-1200, "EUR")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CalculateLineTax(NewMoney(tt.amount, "EUR"), tt.rate, tt.inclusive)
+			if got != tt.want {
+				t.Errorf("CalculateLineTax(%d, %d, %v) = %+v, want %+v", tt.amount, tt.rate, tt.inclusive, got, tt.want)
+			}
+			if got.Net.Amount+got.Tax.Amount != got.Gross.Amount {
+				t.Errorf("net %d + tax %d != gross %d", got.Net.Amount, got.Tax.Amount, got.Gross.Amount)
+			}
+		})
+	}
+}
+
+func TestSummarizeTax(t *testing.T) {
+	standard := func(amount int64, inclusive bool) TaxedLine {
+		return TaxedLine{NewMoney(amount, "EUR"), TaxClassStandard, "VAT 20%", 2000, inclusive, nil}
+	}
+	reduced := func(amount int64) TaxedLine {
+		return TaxedLine{NewMoney(amount, "EUR"), TaxClassReduced, "VAT 5%", 500, false, nil}
+	}
+	exempt := func(amount int64) TaxedLine {
+		return TaxedLine{NewMoney(amount, "EUR"), TaxClassExempt, "Exempt", 0, false, nil}
+	}
+
+	tests := []struct {
+		name  string
+		lines []TaxedLine
+		want  TaxSummary
+	}{
+		{
+			"no lines",
+			nil,
+			TaxSummary{
+				Subtotal:  NewMoney(0, "EUR"),
+				TaxTotal:  NewMoney(0, "EUR"),
+				Total:     NewMoney(0, "EUR"),
+				Breakdown: []TaxBreakdown{},
+			},
+		},
+		{
+			"grouped per rate, highest first",
+			[]TaxedLine{exempt(300), reduced(1000), standard(1000, false), standard(1200, true)},
+			TaxSummary{
+				Subtotal: NewMoney(3300, "EUR"),
+				TaxTotal: NewMoney(450, "EUR"),
+				Total:    NewMoney(3750, "EUR"),
+				Breakdown: []TaxBreakdown{
+					{TaxClassStandard, "VAT 20%", 2000, NewMoney(2000, "EUR"), NewMoney(400, "EUR")},
+					{TaxClassReduced, "VAT 5%", 500, NewMoney(1000, "EUR"), NewMoney(50, "EUR")},
+					{TaxClassExempt, "Exempt", 0, NewMoney(300, "EUR"), NewMoney(0, "EUR")},
+				},
+			},
+		},
+		{
+			// Rounding per line: three lines of 0.05 at 5% are 0.00 tax
+			// each, though 0.15 at 5% would round to 0.01.
+			"rounded per line",
+			[]TaxedLine{reduced(5), reduced(5), reduced(5)},
+			TaxSummary{
+				Subtotal: NewMoney(15, "EUR"),
+				TaxTotal: NewMoney(0, "EUR"),
+				Total:    NewMoney(15, "EUR"),
+				Breakdown: []TaxBreakdown{
+					{TaxClassReduced, "VAT 5%", 500, NewMoney(15, "EUR"), NewMoney(0, "EUR")},
+				},
+			},
+		},
+		{
+			"same rate under another name is its own group",
+			[]TaxedLine{
+				standard(1000, false),
+				{NewMoney(1000, "EUR"), TaxClassStandard, "GST 20%", 2000, false, nil},
+			},
+			TaxSummary{
+				Subtotal: NewMoney(2000, "EUR"),
+				TaxTotal: NewMoney(400, "EUR"),
+				Total:    NewMoney(2400, "EUR"),
+				Breakdown: []TaxBreakdown{
+					{TaxClassStandard, "VAT 20%", 2000, NewMoney(1000, "EUR"), NewMoney(200, "EUR")},
+					{TaxClassStandard, "GST 20%", 2000, NewMoney(1000, "EUR"), NewMoney(200, "EUR")},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SummarizeTax("EUR", tt.lines)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SummarizeTax() = %+v, want %+v", got, tt.want)
+			}
+
+			var net, tax int64
+			for _, b := range got.Breakdown {
+				net += b.Net.Amount
+				tax += b.Tax.Amount
+			}
+			if net != got.Subtotal.Amount || tax != got.TaxTotal.Amount {
+				t.Errorf("breakdown adds up to %d + %d, want %d + %d", net, tax, got.Subtotal.Amount, got.TaxTotal.Amount)
+			}
+		})
+	}
+}
+
+func TestTaxClassValidate(t *testing.T) {
+	for _, c := range TaxClasses {
+		if err := c.Validate(); err != nil {
+			t.Errorf("%q.Validate() = %v, want nil", c, err)
+		}
+	}
+	if err := TaxClass("luxury").Validate(); err != ErrTaxClassInvalid {
+		t.Errorf("Validate() = %v, want %v", err, ErrTaxClassInvalid)
+	}
+}
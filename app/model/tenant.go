@@ -0,0 +1,59 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+var (
+	ErrTenantNotFound     = errors.New("tenant: not found")
+	ErrTenantAdminInvalid = errors.New("tenant: the first user must be an admin of the tenant")
+)
+
+// DefaultTenantID is the organisation that owns the data created before the
+// service served several of them, and the first admin user.
+var DefaultTenantID = ulid.ULID{15: 1}
+
+// Tenant is one of the organisations the service keeps the data of apart.
+type Tenant struct {
+	ID        ulid.ULID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt null.Time `json:"updated_at"`
+	DeletedAt null.Time `json:"deleted_at"`
+
+	Name   string `json:"name"`
+	Seller Seller `json:"seller"`
+	// AlertWebhook is the URL the tenant's stock alerts are posted to,
+	// empty for the server's own.
+	AlertWebhook string `json:"alert_webhook"`
+}
+
+func NewTenant(name string) Tenant {
+	return Tenant{
+		ID:        ulid.Make(),
+		CreatedAt: time.Now(),
+		Name:      name,
+	}
+}
+
+// InvoiceSeller is who the tenant's invoices are issued by, the tenant's
+// name standing in until the seller's is set.
+func (t Tenant) InvoiceSeller() Seller {
+	seller := t.Seller
+	if seller.Name == "" {
+		seller.Name = t.Name
+	}
+	return seller
+}
+
+// Seller is the tenant as the issuing company printed on its invoices.
+type Seller struct {
+	Name                string  `json:"name"`
+	Address             Address `json:"address"`
+	TaxID               string  `json:"tax_id"`
+	Email               string  `json:"email"`
+	PaymentInstructions string  `json:"payment_instructions"`
+}
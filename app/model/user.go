@@ -0,0 +1,61 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/guregu/null.v4"
+)
+
+var (
+	ErrUserNotFound        = errors.New("user: not found")
+	ErrUserAlreadyDeleted  = errors.New("user: already deleted")
+	ErrUserEmailDuplicated = errors.New("user: email duplicated")
+	ErrInvalidCredentials  = errors.New("user: invalid email or password")
+)
+
+type User struct {
+	ID        ulid.ULID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt null.Time `json:"updated_at"`
+	DeletedAt null.Time `json:"deleted_at"`
+
+	TenantID     ulid.ULID `json:"tenant_id"`
+	Email        string    `json:"email"`
+	Name         string    `json:"name"`
+	Role         Role      `json:"role"`
+	PasswordHash string    `json:"-"`
+}
+
+func NewUser(
+	TenantID ulid.ULID,
+	Email, Name, password string,
+	Role Role,
+) (User, error) {
+	if err := Role.Validate(); err != nil {
+		return User{}, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return User{}, err
+	}
+
+	id := ulid.Make()
+	return User{
+		ID:           id,
+		CreatedAt:    time.Now(),
+		TenantID:     TenantID,
+		Email:        strings.ToLower(Email),
+		Name:         Name,
+		Role:         Role,
+		PasswordHash: string(hash),
+	}, nil
+}
+
+// CheckPassword reports whether password matches the stored hash.
+func (u User) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+var (
+	ErrWarehouseNotFound       = errors.New("warehouse: not found")
+	ErrWarehouseAlreadyDeleted = errors.New("warehouse: already deleted")
+	ErrWarehouseCodeDuplicated = errors.New("warehouse: code duplicated")
+	ErrWarehouseIsDefault      = errors.New("warehouse: the default warehouse cannot be deleted")
+	ErrWarehouseHasStock       = errors.New("warehouse: still has stock")
+)
+
+// Warehouse is a location goods are stocked in. Every tenant has one
+// default warehouse; stock is kept there unless another one is named.
+type Warehouse struct {
+	ID        ulid.ULID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt null.Time `json:"updated_at"`
+	DeletedAt null.Time `json:"deleted_at"`
+
+	Code      string `json:"code"`
+	Name      string `json:"name"`
+	IsDefault bool   `json:"is_default"`
+}
+
+func NewWarehouse(Code, Name string) Warehouse {
+	return Warehouse{
+		ID:        ulid.Make(),
+		CreatedAt: time.Now(),
+		Code:      Code,
+		Name:      Name,
+	}
+}
+
+// StockLocation is the stock of a product in one warehouse. InTransit is
+// what is on its way there from other warehouses.
+type StockLocation struct {
+	WarehouseID   ulid.ULID `json:"warehouse_id"`
+	WarehouseCode string    `json:"warehouse_code"`
+	OnHand        int       `json:"on_hand"`
+	Reserved      int       `json:"reserved"`
+	Available     int       `json:"available"`
+	InTransit     int       `json:"in_transit"`
+
+	ReorderPoint    null.Int `json:"reorder_point"`
+	ReorderQuantity int      `json:"reorder_quantity"`
+}
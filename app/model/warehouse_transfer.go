@@ -0,0 +1,90 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+var (
+	ErrWarehouseTransferNotFound        = errors.New("warehouse transfer: not found")
+	ErrWarehouseTransferInvalidQuantity = errors.New("warehouse transfer: quantity must be positive")
+	ErrWarehouseTransferSameWarehouse   = errors.New("warehouse transfer: source and destination are the same warehouse")
+	ErrWarehouseTransferNotInTransit    = errors.New("warehouse transfer: not in transit")
+)
+
+type WarehouseTransferStatus string
+
+const (
+	WarehouseTransferInTransit WarehouseTransferStatus = "in_transit"
+	WarehouseTransferReceived  WarehouseTransferStatus = "received"
+	WarehouseTransferCancelled WarehouseTransferStatus = "cancelled"
+)
+
+// WarehouseTransfer moves stock of a product between two warehouses.
+// Shipping takes it out of the source right away; it is in transit until
+// the destination receives it, or back in the source if the transfer is
+// cancelled.
+type WarehouseTransfer struct {
+	ID        ulid.ULID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt null.Time `json:"updated_at"`
+
+	ProductID       ulid.ULID               `json:"product_id"`
+	FromWarehouseID ulid.ULID               `json:"from_warehouse_id"`
+	ToWarehouseID   ulid.ULID               `json:"to_warehouse_id"`
+	Quantity        int                     `json:"quantity"`
+	Status          WarehouseTransferStatus `json:"status"`
+	Reference       string                  `json:"reference"`
+	ShippedAt       time.Time               `json:"shipped_at"`
+	ReceivedAt      null.Time               `json:"received_at"`
+	CancelledAt     null.Time               `json:"cancelled_at"`
+}
+
+// NewWarehouseTransfer ships quantity of the product from one warehouse to
+// another at at.
+func NewWarehouseTransfer(
+	ProductID, FromWarehouseID, ToWarehouseID ulid.ULID,
+	Quantity int,
+	Reference string,
+	at time.Time,
+) (WarehouseTransfer, error) {
+	if Quantity <= 0 {
+		return WarehouseTransfer{}, ErrWarehouseTransferInvalidQuantity
+	}
+	if FromWarehouseID == ToWarehouseID {
+		return WarehouseTransfer{}, ErrWarehouseTransferSameWarehouse
+	}
+
+	return WarehouseTransfer{
+		ID:              ulid.Make(),
+		CreatedAt:       at,
+		ProductID:       ProductID,
+		FromWarehouseID: FromWarehouseID,
+		ToWarehouseID:   ToWarehouseID,
+		Quantity:        Quantity,
+		Status:          WarehouseTransferInTransit,
+		Reference:       Reference,
+		ShippedAt:       at,
+	}, nil
+}
+
+func (t *WarehouseTransfer) Receive(at time.Time) error {
+	if t.Status != WarehouseTransferInTransit {
+		return ErrWarehouseTransferNotInTransit
+	}
+	t.Status = WarehouseTransferReceived
+	t.ReceivedAt = null.TimeFrom(at)
+	return nil
+}
+
+func (t *WarehouseTransfer) Cancel(at time.Time) error {
+	if t.Status != WarehouseTransferInTransit {
+		return ErrWarehouseTransferNotInTransit
+	}
+	t.Status = WarehouseTransferCancelled
+	t.CancelledAt = null.TimeFrom(at)
+	return nil
+}
@@ -0,0 +1,38 @@
+package notify
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Notifier delivers stock alerts. Alerts are sent once the stock change
+// that raised them is committed, so a failing notifier never undoes it.
+type Notifier interface {
+	Notify(ctx context.Context, alert model.StockAlert) error
+}
+
+// LogNotifier writes the alerts to the server log.
+type LogNotifier struct{}
+
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, alert model.StockAlert) error {
+	event := log.Info()
+	if alert.Type == model.StockAlertLow {
+		event = log.Warn()
+	}
+	event.
+		Str("type", string(alert.Type)).
+		Str("tenant_id", alert.TenantID.String()).
+		Str("product_id", alert.ProductID.String()).
+		Str("warehouse_id", alert.WarehouseID.String()).
+		Int("quantity", alert.Quantity).
+		Int("reorder_point", alert.ReorderPoint).
+		Int("reorder_quantity", alert.ReorderQuantity).
+		Msg("stock crossed its reorder point")
+	return nil
+}
@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"flukis/invokiss/app/model"
+
+	"github.com/rs/zerolog/log"
+)
+
+var ErrQueueFull = errors.New("notify: too many alerts waiting to be sent")
+
+// QueueNotifier sends the alerts in the background, so a slow or
+// unreachable notifier never holds up the request that moved the stock. At
+// most size alerts wait to be sent; the ones raised past that are dropped.
+type QueueNotifier struct {
+	next   Notifier
+	alerts chan model.StockAlert
+}
+
+func NewQueueNotifier(next Notifier, size int) *QueueNotifier {
+	return &QueueNotifier{
+		next:   next,
+		alerts: make(chan model.StockAlert, size),
+	}
+}
+
+// Notify queues the alert without waiting for it to be sent.
+func (n *QueueNotifier) Notify(ctx context.Context, alert model.StockAlert) error {
+	select {
+	case n.alerts <- alert:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run sends the queued alerts, one at a time, until ctx is done. Failures
+// are only logged.
+func (n *QueueNotifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case alert := <-n.alerts:
+			if err := n.next.Notify(ctx, alert); err != nil {
+				log.Error().
+					Err(err).
+					Str("tenant_id", alert.TenantID.String()).
+					Str("product_id", alert.ProductID.String()).
+					Msg("cannot send stock alert")
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/model"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// WebhookNotifier posts every alert as JSON to a URL. Any answer other
+// than a 2xx counts as a failed delivery; it isn't retried.
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url string, timeout time.Duration) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, alert model.StockAlert) error {
+	return post(ctx, n.client, n.url, alert)
+}
+
+// Webhooks finds the URL a tenant wants its stock alerts posted to, empty
+// when it has none.
+type Webhooks interface {
+	AlertWebhook(ctx context.Context, tenantID ulid.ULID) (string, error)
+}
+
+// TenantWebhookNotifier posts every alert to the webhook of the tenant it
+// was raised in, and hands the alerts of tenants without one to fallback.
+type TenantWebhookNotifier struct {
+	webhooks Webhooks
+	fallback Notifier
+	client   *http.Client
+}
+
+func NewTenantWebhookNotifier(webhooks Webhooks, fallback Notifier, timeout time.Duration) *TenantWebhookNotifier {
+	return &TenantWebhookNotifier{
+		webhooks: webhooks,
+		fallback: fallback,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+func (n *TenantWebhookNotifier) Notify(ctx context.Context, alert model.StockAlert) error {
+	url, err := n.webhooks.AlertWebhook(auth.WithTenant(ctx, alert.TenantID), alert.TenantID)
+	if err != nil {
+		return err
+	}
+	if url == "" {
+		return n.fallback.Notify(ctx, alert)
+	}
+	return post(ctx, n.client, url, alert)
+}
+
+func post(ctx context.Context, client *http.Client, url string, alert model.StockAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("notify: webhook answered %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flukis/invokiss/app/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+type webhooks map[ulid.ULID]string
+
+func (w webhooks) AlertWebhook(ctx context.Context, tenantID ulid.ULID) (string, error) {
+	return w[tenantID], nil
+}
+
+type recorder chan model.StockAlert
+
+func (r recorder) Notify(ctx context.Context, alert model.StockAlert) error {
+	r <- alert
+	return nil
+}
+
+func TestTenantWebhookNotifier(t *testing.T) {
+	posted := make(chan model.StockAlert, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var alert model.StockAlert
+		if err := json.NewDecoder(req.Body).Decode(&alert); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		posted <- alert
+	}))
+	defer server.Close()
+
+	var (
+		withWebhook    = ulid.Make()
+		withoutWebhook = ulid.Make()
+		fallback       = make(recorder, 1)
+		n              = NewTenantWebhookNotifier(webhooks{withWebhook: server.URL}, fallback, time.Second)
+	)
+
+	if err := n.Notify(context.Background(), model.StockAlert{Type: model.StockAlertLow, TenantID: withWebhook}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case alert := <-posted:
+		if alert.TenantID != withWebhook {
+			t.Errorf("posted tenant_id = %s, want %s", alert.TenantID, withWebhook)
+		}
+	default:
+		t.Error("alert wasn't posted to the tenant's webhook")
+	}
+
+	if err := n.Notify(context.Background(), model.StockAlert{Type: model.StockAlertLow, TenantID: withoutWebhook}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case alert := <-fallback:
+		if alert.TenantID != withoutWebhook {
+			t.Errorf("fallback tenant_id = %s, want %s", alert.TenantID, withoutWebhook)
+		}
+	case <-posted:
+		t.Error("alert of a tenant without a webhook was posted")
+	}
+}
+
+func TestQueueNotifier(t *testing.T) {
+	var (
+		sent = make(recorder)
+		n    = NewQueueNotifier(sent, 1)
+	)
+
+	if err := n.Notify(context.Background(), model.StockAlert{Quantity: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), model.StockAlert{Quantity: 2}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Notify() on a full queue error = %v, want %v", err, ErrQueueFull)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.Run(ctx)
+
+	select {
+	case alert := <-sent:
+		if alert.Quantity != 1 {
+			t.Errorf("sent quantity = %d, want 1", alert.Quantity)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queued alert wasn't sent")
+	}
+}
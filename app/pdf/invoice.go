@@ -0,0 +1,283 @@
+package pdf
+
+import (
+	_ "embed"
+	"errors"
+	"flukis/invokiss/app/model"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+var (
+	//go:embed fonts/DejaVuSansCondensed.ttf
+	fontRegular []byte
+	//go:embed fonts/DejaVuSansCondensed-Bold.ttf
+	fontBold []byte
+)
+
+var ErrInvoiceNotIssued = errors.New("pdf: only issued invoices can be rendered")
+
+const (
+	fontFamily = "DejaVu"
+	dateLayout = "02 Jan 2006"
+
+	pageMargin  = 15.0
+	lineHeight  = 5.0
+	contentWide = 180.0
+)
+
+type InvoiceRenderer struct{}
+
+func NewInvoiceRenderer() *InvoiceRenderer {
+	return &InvoiceRenderer{}
+}
+
+// Render writes the invoice, issued by seller, as an A4 PDF. The output
+// only depends on the invoice and seller, document dates are taken from the
+// issue date, so the same invoice always renders to the same bytes.
+func (r *InvoiceRenderer) Render(w io.Writer, seller model.Seller, inv model.Invoice) error {
+	if !inv.IssuedAt.Valid || !inv.Number.Valid {
+		return ErrInvoiceNotIssued
+	}
+
+	doc := fpdf.New("P", "mm", "A4", "")
+	doc.AddUTF8FontFromBytes(fontFamily, "", fontRegular)
+	doc.AddUTF8FontFromBytes(fontFamily, "B", fontBold)
+	doc.SetMargins(pageMargin, pageMargin, pageMargin)
+	doc.SetAutoPageBreak(true, pageMargin)
+	doc.SetCreationDate(inv.IssuedAt.Time)
+	doc.SetModificationDate(inv.IssuedAt.Time)
+	doc.SetCatalogSort(true)
+	doc.SetTitle(fmt.Sprintf("Invoice %s", inv.Number.String), true)
+	doc.SetAuthor(seller.Name, true)
+	doc.SetFooterFunc(func() {
+		doc.SetY(-pageMargin)
+		doc.SetFont(fontFamily, "", 8)
+		doc.CellFormat(0, lineHeight, fmt.Sprintf("%s · page %d/{nb}", inv.Number.String, doc.PageNo()), "", 0, "C", false, 0, "")
+	})
+	doc.AliasNbPages("")
+	doc.AddPage()
+
+	totals := inv.Totals()
+	r.header(doc, inv)
+	r.parties(doc, seller, inv)
+	r.lines(doc, inv)
+	r.taxBreakdown(doc, totals)
+	r.totals(doc, totals)
+	r.paymentInstructions(doc, seller, inv)
+
+	if err := doc.Error(); err != nil {
+		return err
+	}
+	return doc.Output(w)
+}
+
+func (r *InvoiceRenderer) header(doc *fpdf.Fpdf, inv model.Invoice) {
+	doc.SetFont(fontFamily, "B", 20)
+	doc.CellFormat(contentWide/2, 10, "INVOICE", "", 0, "L", false, 0, "")
+
+	doc.SetFont(fontFamily, "", 10)
+	x := doc.GetX()
+	y := doc.GetY()
+	for _, row := range [][2]string{
+		{"Number", inv.Number.String},
+		{"Issue date", inv.IssuedAt.Time.Format(dateLayout)},
+		{"Due date", formatDate(inv.DueAt.Time, inv.DueAt.Valid)},
+	} {
+		doc.SetXY(x, y)
+		doc.CellFormat(contentWide/4, lineHeight, row[0], "", 0, "L", false, 0, "")
+		doc.CellFormat(contentWide/4, lineHeight, row[1], "", 0, "R", false, 0, "")
+		y += lineHeight
+	}
+	doc.SetXY(pageMargin, y+lineHeight)
+}
+
+func (r *InvoiceRenderer) parties(doc *fpdf.Fpdf, seller model.Seller, inv model.Invoice) {
+	from := append([]string{seller.Name}, addressLines(seller.Address)...)
+	if seller.TaxID != "" {
+		from = append(from, "Tax ID: "+seller.TaxID)
+	}
+	if seller.Email != "" {
+		from = append(from, seller.Email)
+	}
+
+	customer := append([]string{inv.BillTo.LegalName}, addressLines(inv.BillTo.Address)...)
+	if inv.BillTo.TaxID != "" {
+		customer = append(customer, "Tax ID: "+inv.BillTo.TaxID)
+	}
+
+	top := doc.GetY()
+	r.block(doc, pageMargin, top, "From", from)
+	bottom := doc.GetY()
+	r.block(doc, pageMargin+contentWide/2, top, "Bill to", customer)
+	if doc.GetY() > bottom {
+		bottom = doc.GetY()
+	}
+	doc.SetXY(pageMargin, bottom+lineHeight)
+}
+
+func (r *InvoiceRenderer) block(doc *fpdf.Fpdf, x, y float64, title string, rows []string) {
+	doc.SetXY(x, y)
+	doc.SetFont(fontFamily, "B", 10)
+	doc.CellFormat(contentWide/2, lineHeight, title, "", 2, "L", false, 0, "")
+	doc.SetFont(fontFamily, "", 10)
+	for _, row := range rows {
+		doc.SetX(x)
+		doc.CellFormat(contentWide/2, lineHeight, row, "", 2, "L", false, 0, "")
+	}
+}
+
+var lineColumns = []struct {
+	title string
+	width float64
+	align string
+}{
+	{"SKU", 25, "L"},
+	{"Description", 55, "L"},
+	{"Qty", 15, "R"},
+	{"Unit price", 25, "R"},
+	{"Tax", 20, "R"},
+	{"Net", 20, "R"},
+	{"Total", 20, "R"},
+}
+
+func (r *InvoiceRenderer) lines(doc *fpdf.Fpdf, inv model.Invoice) {
+	doc.SetFont(fontFamily, "B", 9)
+	doc.SetFillColor(235, 235, 235)
+	for _, col := range lineColumns {
+		doc.CellFormat(col.width, lineHeight+1, col.title, "B", 0, col.align, true, 0, "")
+	}
+	doc.Ln(-1)
+
+	doc.SetFont(fontFamily, "", 9)
+	for _, l := range inv.Lines {
+		t := l.Tax()
+		cells := []string{
+			l.Sku,
+			l.Name,
+			fmt.Sprintf("%d", l.Quantity),
+			l.Amount.String(),
+			formatRate(l.TaxRate),
+			t.Net.String(),
+			t.Gross.String(),
+		}
+		for idx, col := range lineColumns {
+			doc.CellFormat(col.width, lineHeight+1, fit(doc, cells[idx], col.width), "B", 0, col.align, false, 0, "")
+		}
+		doc.Ln(-1)
+	}
+	doc.Ln(lineHeight)
+}
+
+func (r *InvoiceRenderer) taxBreakdown(doc *fpdf.Fpdf, totals model.TaxSummary) {
+	const labelWidth, amountWidth = 60.0, 30.0
+
+	doc.SetFont(fontFamily, "B", 9)
+	doc.CellFormat(labelWidth, lineHeight+1, "Tax", "B", 0, "L", true, 0, "")
+	doc.CellFormat(amountWidth, lineHeight+1, "Rate", "B", 0, "R", true, 0, "")
+	doc.CellFormat(amountWidth, lineHeight+1, "Net", "B", 0, "R", true, 0, "")
+	doc.CellFormat(amountWidth, lineHeight+1, "Tax amount", "B", 1, "R", true, 0, "")
+
+	doc.SetFont(fontFamily, "", 9)
+	for _, b := range totals.Breakdown {
+		doc.CellFormat(labelWidth, lineHeight+1, b.Name, "B", 0, "L", false, 0, "")
+		doc.CellFormat(amountWidth, lineHeight+1, formatRate(b.Rate), "B", 0, "R", false, 0, "")
+		doc.CellFormat(amountWidth, lineHeight+1, b.Net.String(), "B", 0, "R", false, 0, "")
+		doc.CellFormat(amountWidth, lineHeight+1, b.Tax.String(), "B", 1, "R", false, 0, "")
+	}
+	doc.Ln(lineHeight)
+}
+
+func (r *InvoiceRenderer) totals(doc *fpdf.Fpdf, totals model.TaxSummary) {
+	const labelWidth, amountWidth = 40.0, 35.0
+	x := pageMargin + contentWide - labelWidth - amountWidth
+
+	for _, row := range []struct {
+		label string
+		value model.Money
+		bold  bool
+	}{
+		{"Subtotal", totals.Subtotal, false},
+		{"Tax", totals.TaxTotal, false},
+		{"Total", totals.Total, true},
+	} {
+		style := ""
+		if row.bold {
+			style = "B"
+		}
+		doc.SetFont(fontFamily, style, 10)
+		doc.SetX(x)
+		doc.CellFormat(labelWidth, lineHeight+1, row.label, "", 0, "L", false, 0, "")
+		doc.CellFormat(amountWidth, lineHeight+1, formatMoney(row.value), "", 1, "R", false, 0, "")
+	}
+	doc.Ln(lineHeight)
+}
+
+func (r *InvoiceRenderer) paymentInstructions(doc *fpdf.Fpdf, seller model.Seller, inv model.Invoice) {
+	if seller.PaymentInstructions == "" {
+		return
+	}
+
+	doc.SetFont(fontFamily, "B", 10)
+	doc.CellFormat(0, lineHeight, "Payment instructions", "", 1, "L", false, 0, "")
+	doc.SetFont(fontFamily, "", 9)
+	text := fmt.Sprintf(
+		"%s\nPlease quote %s as the payment reference.",
+		seller.PaymentInstructions,
+		inv.Number.String,
+	)
+	doc.MultiCell(0, lineHeight, text, "", "L", false)
+}
+
+func addressLines(a model.Address) []string {
+	var lines []string
+	for _, l := range []string{
+		a.Line1,
+		a.Line2,
+		strings.TrimSpace(a.PostalCode + " " + a.City),
+		a.Region,
+		a.Country,
+	} {
+		if l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// fit shortens text with an ellipsis until it fits a cell of the given
+// width.
+func fit(doc *fpdf.Fpdf, text string, width float64) string {
+	const padding = 2.0
+	if doc.GetStringWidth(text) <= width-padding {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && doc.GetStringWidth(string(runes)+"…") > width-padding {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+func formatDate(t time.Time, valid bool) string {
+	if !valid {
+		return "-"
+	}
+	return t.Format(dateLayout)
+}
+
+func formatMoney(m model.Money) string {
+	return m.Currency + " " + m.String()
+}
+
+// formatRate prints a rate in basis points as a percentage, e.g. 2000 as
+// 20% and 725 as 7.25%.
+func formatRate(rate int) string {
+	s := fmt.Sprintf("%d.%02d", rate/100, rate%100)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	return s + "%"
+}
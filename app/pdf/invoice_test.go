@@ -0,0 +1,107 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"flukis/invokiss/app/model"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var testSeller = model.Seller{
+	Name: "Invokiss Ltd",
+	Address: model.Address{
+		Line1:      "1 Market Street",
+		City:       "London",
+		PostalCode: "EC1A 1AA",
+		Country:    "GB",
+	},
+	TaxID:               "GB123456789",
+	Email:               "billing@invokiss.test",
+	PaymentInstructions: "Bank transfer to GB29 NWBK 6016 1331 9268 19.",
+}
+
+func testInvoice() model.Invoice {
+	issuedAt := time.Date(2024, time.March, 1, 9, 30, 0, 0, time.UTC)
+	line := func(sku, name string, amount int64, quantity int, class model.TaxClass, taxName string, rate int, inclusive bool) model.InvoiceLine {
+		return model.InvoiceLine{
+			Sku:              sku,
+			Name:             name,
+			Amount:           model.NewMoney(amount, "GBP"),
+			Quantity:         quantity,
+			TaxClass:         class,
+			TaxName:          taxName,
+			TaxRate:          rate,
+			PriceIncludesTax: inclusive,
+		}
+	}
+
+	return model.Invoice{
+		CreatedAt: issuedAt,
+		Number:    null.StringFrom("INV-2024-0001"),
+		BillTo: model.BillTo{
+			LegalName: "Acme Trading GmbH",
+			Address: model.Address{
+				Line1:      "Hauptstraße 5",
+				Line2:      "2. OG",
+				City:       "München",
+				PostalCode: "80331",
+				Country:    "DE",
+			},
+			TaxID: "DE987654321",
+		},
+		Currency:        "GBP",
+		Status:          model.InvoiceStatusIssued,
+		PaymentTermDays: 30,
+		IssuedAt:        null.TimeFrom(issuedAt),
+		DueAt:           null.TimeFrom(issuedAt.AddDate(0, 0, 30)),
+		PaidAmount:      model.NewMoney(0, "GBP"),
+		CreditedAmount:  model.NewMoney(0, "GBP"),
+		Lines: []model.InvoiceLine{
+			line("DESK-01", "Standing desk", 45000, 2, model.TaxClassStandard, "VAT 20%", 2000, false),
+			line("CHAIR-07", "Ergonomic office chair with an unusually long product name", 23999, 1, model.TaxClassStandard, "VAT 20%", 2000, true),
+			line("BOOK-12", "Handbook", 1250, 3, model.TaxClassZero, "Zero rated", 0, false),
+		},
+	}
+}
+
+func TestInvoiceRendererRender(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewInvoiceRenderer().Render(&buf, testSeller, testInvoice()); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	golden := filepath.Join("testdata", "invoice.pdf.golden")
+	if *update {
+		if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (run go test with -update to create it)", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("Render() output differs from %s, run go test with -update if the change is intended", golden)
+	}
+}
+
+func TestInvoiceRendererRenderDraft(t *testing.T) {
+	inv := testInvoice()
+	inv.Status = model.InvoiceStatusDraft
+	inv.Number = null.String{}
+	inv.IssuedAt = null.Time{}
+
+	err := NewInvoiceRenderer().Render(&bytes.Buffer{}, testSeller, inv)
+	if !errors.Is(err, ErrInvoiceNotIssued) {
+		t.Errorf("Render() error = %v, want %v", err, ErrInvoiceNotIssued)
+	}
+}
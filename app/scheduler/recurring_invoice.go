@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"context"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/billing"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/database/querier"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RecurringInvoiceScheduler periodically generates the invoices of due
+// recurring invoice schedules.
+type RecurringInvoiceScheduler struct {
+	writeRecurringInvoice querier.RecurringInvoiceWriteModel
+	readCustomer          querier.CustomerReadModel
+	lines                 *billing.LineBuilder
+	interval              time.Duration
+}
+
+func NewRecurringInvoiceScheduler(
+	writeRecurringInvoice querier.RecurringInvoiceWriteModel,
+	readCustomer querier.CustomerReadModel,
+	lines *billing.LineBuilder,
+	interval time.Duration,
+) *RecurringInvoiceScheduler {
+	return &RecurringInvoiceScheduler{writeRecurringInvoice, readCustomer, lines, interval}
+}
+
+// Run checks for due schedules right away and then every interval until ctx
+// is done. Occurrences missed while the server was down are generated on
+// the first check.
+func (s *RecurringInvoiceScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *RecurringInvoiceScheduler) RunOnce(ctx context.Context) {
+	now := time.Now()
+	generated, err := s.writeRecurringInvoice.RunDue(ctx, now, func(ctx context.Context, r *model.RecurringInvoice) ([]model.Invoice, error) {
+		return s.generate(ctx, r, now)
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("cannot generate recurring invoices")
+	}
+	if generated > 0 {
+		log.Info().Int("count", generated).Msg("generated recurring invoices")
+	}
+}
+
+// generate bills every occurrence of r that is due by now, priced at now.
+func (s *RecurringInvoiceScheduler) generate(ctx context.Context, r *model.RecurringInvoice, now time.Time) ([]model.Invoice, error) {
+	ctx = auth.WithTenant(ctx, r.TenantID)
+	customer, err := s.readCustomer.GetOneByID(ctx, r.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var items = make([]billing.LineItem, len(r.Lines))
+	for idx := range r.Lines {
+		items[idx] = billing.LineItem{
+			ProductID: r.Lines[idx].ProductID,
+			Quantity:  r.Lines[idx].Quantity,
+		}
+	}
+
+	var invoices []model.Invoice
+	for r.IsDue(now) {
+		lines, err := s.lines.Build(ctx, customer, items, now)
+		if err != nil {
+			return nil, err
+		}
+		inv, err := r.Generate(customer, lines, now)
+		if err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, inv)
+	}
+
+	return invoices, nil
+}
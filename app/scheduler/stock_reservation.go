@@ -0,0 +1,49 @@
+package scheduler
+
+import (
+	"context"
+	"flukis/invokiss/database/querier"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// StockReservationSweeper periodically releases the stock reservations that
+// expired.
+type StockReservationSweeper struct {
+	writeStockReservation querier.StockReservationWriteModel
+	interval              time.Duration
+}
+
+func NewStockReservationSweeper(
+	writeStockReservation querier.StockReservationWriteModel,
+	interval time.Duration,
+) *StockReservationSweeper {
+	return &StockReservationSweeper{writeStockReservation, interval}
+}
+
+// Run sweeps right away and then every interval until ctx is done.
+func (s *StockReservationSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *StockReservationSweeper) RunOnce(ctx context.Context) {
+	released, err := s.writeStockReservation.ReleaseExpired(ctx, time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("cannot release expired stock reservations")
+	}
+	if released > 0 {
+		log.Info().Int64("count", released).Msg("released expired stock reservations")
+	}
+}
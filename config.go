@@ -1,10 +1,13 @@
 package main
 
 import (
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/app/notify"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"gopkg.in/yaml.v2"
@@ -30,6 +33,18 @@ func loadEnvUint(key string, res *uint) {
 	*res = uint(si)
 }
 
+func loadEnvBool(key string, res *bool) {
+	s, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return
+	}
+	*res = b
+}
+
 type pgConfig struct {
 	Host     string `yaml:"host" json:"host"`
 	Port     uint   `yaml:"port" json:"port"`
@@ -49,8 +64,8 @@ func defaultPgConfig() pgConfig {
 		Port:     5433,
 		DBName:   "postgres",
 		SslMode:  "disable",
-		Password: "mysecret",
-		Username: "postgres",
+		Password: "invokiss",
+		Username: "invokiss",
 	}
 }
 
@@ -93,12 +108,22 @@ func (l *listenConfig) loadFromEnv() {
 	loadEnvUint("LISTEN_IDLE_TIMEOUT", &l.IdleTimeout)
 }
 
+// jwtConfig sets up token signing. RefreshExpTime is in days,
+// AccessExpTime in minutes.
 type jwtConfig struct {
 	Secret         string `yaml:"secret" json:"secret"`
 	RefreshExpTime uint   `yaml:"refresh_exp" json:"refresh_exp"`
 	AccessExpTime  uint   `yaml:"access_exp" json:"access_exp"`
 }
 
+func (p jwtConfig) AccessTTL() time.Duration {
+	return time.Minute * time.Duration(p.AccessExpTime)
+}
+
+func (p jwtConfig) RefreshTTL() time.Duration {
+	return time.Hour * 24 * time.Duration(p.RefreshExpTime)
+}
+
 func defaultJwtConfig() jwtConfig {
 	return jwtConfig{
 		Secret:         "mysecret",
@@ -113,23 +138,227 @@ func (p *jwtConfig) loadFromEnv() {
 	loadEnvUint("JWT_ACCESS_TOKEN_EXP_TIME", &p.AccessExpTime)
 }
 
+// adminConfig is the first user, created at start up as long as there are
+// no users at all.
+type adminConfig struct {
+	Email    string `yaml:"email" json:"email"`
+	Name     string `yaml:"name" json:"name"`
+	Password string `yaml:"password" json:"password"`
+}
+
+func defaultAdminConfig() adminConfig {
+	return adminConfig{
+		Name: "Administrator",
+	}
+}
+
+func (a *adminConfig) loadFromEnv() {
+	loadEnvStr("ADMIN_EMAIL", &a.Email)
+	loadEnvStr("ADMIN_NAME", &a.Name)
+	loadEnvStr("ADMIN_PASSWORD", &a.Password)
+}
+
+type numberFormatConfig struct {
+	Prefix       string `yaml:"prefix" json:"prefix"`
+	Padding      uint   `yaml:"padding" json:"padding"`
+	WithYear     bool   `yaml:"with_year" json:"with_year"`
+	WithMonth    bool   `yaml:"with_month" json:"with_month"`
+	YearlyReset  bool   `yaml:"yearly_reset" json:"yearly_reset"`
+	MonthlyReset bool   `yaml:"monthly_reset" json:"monthly_reset"`
+}
+
+func (n numberFormatConfig) NumberFormat(series string) model.NumberFormat {
+	return model.NumberFormat{
+		Series:       series,
+		Prefix:       n.Prefix,
+		Padding:      int(n.Padding),
+		WithYear:     n.WithYear,
+		WithMonth:    n.WithMonth,
+		YearlyReset:  n.YearlyReset,
+		MonthlyReset: n.MonthlyReset,
+	}
+}
+
+func (n *numberFormatConfig) loadFromEnv(prefix string) {
+	loadEnvStr(prefix+"_PREFIX", &n.Prefix)
+	loadEnvUint(prefix+"_PADDING", &n.Padding)
+	loadEnvBool(prefix+"_WITH_YEAR", &n.WithYear)
+	loadEnvBool(prefix+"_WITH_MONTH", &n.WithMonth)
+	loadEnvBool(prefix+"_YEARLY_RESET", &n.YearlyReset)
+	loadEnvBool(prefix+"_MONTHLY_RESET", &n.MonthlyReset)
+}
+
+type numberingConfig struct {
+	Invoice    numberFormatConfig `yaml:"invoice" json:"invoice"`
+	CreditNote numberFormatConfig `yaml:"credit_note" json:"credit_note"`
+}
+
+func defaultNumberingConfig() numberingConfig {
+	return numberingConfig{
+		Invoice: numberFormatConfig{
+			Prefix:      "INV",
+			Padding:     6,
+			WithYear:    true,
+			YearlyReset: true,
+		},
+		CreditNote: numberFormatConfig{
+			Prefix:      "CN",
+			Padding:     6,
+			WithYear:    true,
+			YearlyReset: true,
+		},
+	}
+}
+
+func (n numberingConfig) validate() error {
+	if err := n.Invoice.NumberFormat("invoice").Validate(); err != nil {
+		return fmt.Errorf("numbering.invoice: %w", err)
+	}
+	if err := n.CreditNote.NumberFormat("credit_note").Validate(); err != nil {
+		return fmt.Errorf("numbering.credit_note: %w", err)
+	}
+	return nil
+}
+
+func (n *numberingConfig) loadFromEnv() {
+	n.Invoice.loadFromEnv("INVOICE_NUMBER")
+	n.CreditNote.loadFromEnv("CREDIT_NOTE_NUMBER")
+}
+
+type sellerConfig struct {
+	Name                string        `yaml:"name" json:"name"`
+	Address             model.Address `yaml:"address" json:"address"`
+	TaxID               string        `yaml:"tax_id" json:"tax_id"`
+	Email               string        `yaml:"email" json:"email"`
+	PaymentInstructions string        `yaml:"payment_instructions" json:"payment_instructions"`
+}
+
+// Seller is the default tenant's seller until it sets its own.
+func (s sellerConfig) Seller() model.Seller {
+	return model.Seller{
+		Name:                s.Name,
+		Address:             s.Address,
+		TaxID:               s.TaxID,
+		Email:               s.Email,
+		PaymentInstructions: s.PaymentInstructions,
+	}
+}
+
+func defaultSellerConfig() sellerConfig {
+	return sellerConfig{
+		Name: "Invokiss",
+	}
+}
+
+func (s *sellerConfig) loadFromEnv() {
+	loadEnvStr("SELLER_NAME", &s.Name)
+	loadEnvStr("SELLER_TAX_ID", &s.TaxID)
+	loadEnvStr("SELLER_EMAIL", &s.Email)
+	loadEnvStr("SELLER_PAYMENT_INSTRUCTIONS", &s.PaymentInstructions)
+}
+
+type schedulerConfig struct {
+	// RecurringInvoiceInterval is how often, in seconds, due recurring
+	// invoices are looked for; 0 disables the scheduler on this instance.
+	RecurringInvoiceInterval uint `yaml:"recurring_invoice_interval" json:"recurring_invoice_interval"`
+	// StockReservationInterval is how often, in seconds, expired stock
+	// reservations are released; 0 disables the sweeper on this instance.
+	StockReservationInterval uint `yaml:"stock_reservation_interval" json:"stock_reservation_interval"`
+}
+
+func defaultSchedulerConfig() schedulerConfig {
+	return schedulerConfig{
+		RecurringInvoiceInterval: 60,
+		StockReservationInterval: 60,
+	}
+}
+
+func (s *schedulerConfig) loadFromEnv() {
+	loadEnvUint("SCHEDULER_RECURRING_INVOICE_INTERVAL", &s.RecurringInvoiceInterval)
+	loadEnvUint("SCHEDULER_STOCK_RESERVATION_INTERVAL", &s.StockReservationInterval)
+}
+
+// stockConfig sets up stock keeping. ReservationExpTime, how long stock is
+// held by default, is in minutes. Stock alerts are posted to the tenant's
+// webhook, or else to AlertWebhook, giving up after AlertWebhookTimeout
+// seconds, or only logged without either. At most AlertQueueSize alerts
+// wait to be sent.
+type stockConfig struct {
+	ReservationExpTime  uint   `yaml:"reservation_exp" json:"reservation_exp"`
+	AlertWebhook        string `yaml:"alert_webhook" json:"alert_webhook"`
+	AlertWebhookTimeout uint   `yaml:"alert_webhook_timeout" json:"alert_webhook_timeout"`
+	AlertQueueSize      uint   `yaml:"alert_queue_size" json:"alert_queue_size"`
+}
+
+func (s stockConfig) ReservationTTL() time.Duration {
+	return time.Minute * time.Duration(s.ReservationExpTime)
+}
+
+// Notifier queues the alerts for the tenants' webhooks; the queue has to
+// be run for them to be sent.
+func (s stockConfig) Notifier(webhooks notify.Webhooks) *notify.QueueNotifier {
+	var (
+		timeout  = time.Second * time.Duration(s.AlertWebhookTimeout)
+		fallback notify.Notifier
+	)
+	if s.AlertWebhook == "" {
+		fallback = notify.NewLogNotifier()
+	} else {
+		fallback = notify.NewWebhookNotifier(s.AlertWebhook, timeout)
+	}
+	return notify.NewQueueNotifier(
+		notify.NewTenantWebhookNotifier(webhooks, fallback, timeout),
+		int(s.AlertQueueSize),
+	)
+}
+
+func defaultStockConfig() stockConfig {
+	return stockConfig{
+		ReservationExpTime:  60,
+		AlertWebhookTimeout: 5,
+		AlertQueueSize:      1000,
+	}
+}
+
+func (s *stockConfig) loadFromEnv() {
+	loadEnvUint("STOCK_RESERVATION_EXP_TIME", &s.ReservationExpTime)
+	loadEnvStr("STOCK_ALERT_WEBHOOK", &s.AlertWebhook)
+	loadEnvUint("STOCK_ALERT_WEBHOOK_TIMEOUT", &s.AlertWebhookTimeout)
+	loadEnvUint("STOCK_ALERT_QUEUE_SIZE", &s.AlertQueueSize)
+}
+
 type config struct {
-	Listen listenConfig `yaml:"listen" json:"listen"`
-	DBCfg  pgConfig     `yaml:"db" json:"db"`
-	JwtCfg jwtConfig    `yaml:"jwt" json:"jwt"`
+	Listen    listenConfig    `yaml:"listen" json:"listen"`
+	DBCfg     pgConfig        `yaml:"db" json:"db"`
+	JwtCfg    jwtConfig       `yaml:"jwt" json:"jwt"`
+	Admin     adminConfig     `yaml:"admin" json:"admin"`
+	Numbering numberingConfig `yaml:"numbering" json:"numbering"`
+	Seller    sellerConfig    `yaml:"seller" json:"seller"`
+	Scheduler schedulerConfig `yaml:"scheduler" json:"scheduler"`
+	Stock     stockConfig     `yaml:"stock" json:"stock"`
 }
 
 func (c *config) loadFromEnv() {
 	c.Listen.loadFromEnv()
 	c.DBCfg.loadFromEnv()
 	c.JwtCfg.loadFromEnv()
+	c.Admin.loadFromEnv()
+	c.Numbering.loadFromEnv()
+	c.Seller.loadFromEnv()
+	c.Scheduler.loadFromEnv()
+	c.Stock.loadFromEnv()
 }
 
 func defaultConfig() config {
 	return config{
-		Listen: defaultListenConfig(),
-		DBCfg:  defaultPgConfig(),
-		JwtCfg: defaultJwtConfig(),
+		Listen:    defaultListenConfig(),
+		DBCfg:     defaultPgConfig(),
+		JwtCfg:    defaultJwtConfig(),
+		Admin:     defaultAdminConfig(),
+		Numbering: defaultNumberingConfig(),
+		Seller:    defaultSellerConfig(),
+		Scheduler: defaultSchedulerConfig(),
+		Stock:     defaultStockConfig(),
 	}
 }
 
@@ -157,5 +386,8 @@ func loadConfig(fn string) config {
 			log.Warn().Str("file", fn).Err(err).Msg("cannot load config file, use defaults")
 		}
 	}
+	if err := cfg.Numbering.validate(); err != nil {
+		log.Fatal().Err(err).Msg("invalid numbering configuration")
+	}
 	return cfg
 }
@@ -0,0 +1,17 @@
+#!/bin/sh
+set -e
+
+# The server connects as a role of its own. Unlike the superuser the
+# migrations run as, it is subject to row level security, which keeps
+# tenants apart. It may read and write the tables the migrations create,
+# but not change the schema.
+psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$POSTGRES_DB" <<-EOSQL
+	CREATE ROLE "$APP_DB_USER" LOGIN PASSWORD '$APP_DB_PASSWORD' NOSUPERUSER NOBYPASSRLS NOCREATEDB NOCREATEROLE;
+	GRANT USAGE ON SCHEMA public TO "$APP_DB_USER";
+	GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO "$APP_DB_USER";
+	GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO "$APP_DB_USER";
+	ALTER DEFAULT PRIVILEGES FOR ROLE "$POSTGRES_USER" IN SCHEMA public
+		GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO "$APP_DB_USER";
+	ALTER DEFAULT PRIVILEGES FOR ROLE "$POSTGRES_USER" IN SCHEMA public
+		GRANT USAGE, SELECT ON SEQUENCES TO "$APP_DB_USER";
+EOSQL
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_invoice_lines_invoice;
+DROP TABLE IF EXISTS invoice_lines;
+DROP TABLE IF EXISTS invoices;
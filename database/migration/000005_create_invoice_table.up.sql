@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS invoices (
+	id BYTEA PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP,
+	deleted_at TIMESTAMP,
+
+	issued_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS invoice_lines (
+	id BYTEA PRIMARY KEY,
+	invoice_id BYTEA NOT NULL,
+	product_id BYTEA NOT NULL,
+	position INTEGER NOT NULL,
+
+	sku varchar(25) NOT NULL,
+	name varchar(100) NOT NULL,
+	amount NUMERIC(12,2) NOT NULL,
+	quantity INTEGER NOT NULL,
+
+	FOREIGN KEY (invoice_id) REFERENCES invoices(id),
+	FOREIGN KEY (product_id) REFERENCES products(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_invoice_lines_invoice ON invoice_lines(invoice_id);
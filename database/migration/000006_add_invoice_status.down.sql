@@ -0,0 +1,17 @@
+DROP INDEX IF EXISTS idx_invoice_status;
+
+ALTER TABLE invoices
+DROP CONSTRAINT IF EXISTS chk_invoice_status;
+
+UPDATE invoices
+SET issued_at = created_at
+WHERE issued_at IS NULL;
+
+ALTER TABLE invoices
+ALTER COLUMN issued_at SET NOT NULL;
+
+ALTER TABLE invoices
+DROP COLUMN IF EXISTS voided_at;
+
+ALTER TABLE invoices
+DROP COLUMN IF EXISTS status;
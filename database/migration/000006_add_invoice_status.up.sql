@@ -0,0 +1,17 @@
+ALTER TABLE invoices
+ADD COLUMN status varchar(20) NOT NULL DEFAULT 'issued';
+
+ALTER TABLE invoices
+ADD COLUMN voided_at TIMESTAMP;
+
+ALTER TABLE invoices
+ALTER COLUMN issued_at DROP NOT NULL;
+
+ALTER TABLE invoices
+ALTER COLUMN status SET DEFAULT 'draft';
+
+ALTER TABLE invoices
+ADD CONSTRAINT chk_invoice_status
+CHECK (status IN ('draft', 'issued', 'partially_paid', 'paid', 'void', 'overdue'));
+
+CREATE INDEX IF NOT EXISTS idx_invoice_status ON invoices(status);
@@ -0,0 +1,4 @@
+ALTER TABLE invoices
+DROP COLUMN IF EXISTS number;
+
+DROP TABLE IF EXISTS number_sequences;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS number_sequences (
+	series varchar(25) NOT NULL,
+	period INTEGER NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP,
+
+	last_value BIGINT NOT NULL,
+	PRIMARY KEY (series, period)
+);
+
+ALTER TABLE invoices
+ADD COLUMN number varchar(50) UNIQUE;
@@ -0,0 +1,8 @@
+ALTER TABLE invoice_lines
+DROP COLUMN IF EXISTS currency;
+
+ALTER TABLE invoices
+DROP COLUMN IF EXISTS currency;
+
+ALTER TABLE products
+DROP COLUMN IF EXISTS currency;
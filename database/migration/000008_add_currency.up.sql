@@ -0,0 +1,17 @@
+ALTER TABLE products
+ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+ALTER TABLE invoices
+ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+ALTER TABLE invoice_lines
+ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+ALTER TABLE products
+ALTER COLUMN currency DROP DEFAULT;
+
+ALTER TABLE invoices
+ALTER COLUMN currency DROP DEFAULT;
+
+ALTER TABLE invoice_lines
+ALTER COLUMN currency DROP DEFAULT;
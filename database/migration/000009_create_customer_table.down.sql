@@ -0,0 +1,18 @@
+DROP INDEX IF EXISTS idx_invoice_customer;
+
+ALTER TABLE invoices
+DROP COLUMN IF EXISTS due_at;
+
+ALTER TABLE invoices
+DROP COLUMN IF EXISTS payment_term_days;
+
+ALTER TABLE invoices
+DROP COLUMN IF EXISTS bill_to;
+
+ALTER TABLE invoices
+DROP CONSTRAINT IF EXISTS fk_customer;
+
+ALTER TABLE invoices
+DROP COLUMN IF EXISTS customer_id;
+
+DROP TABLE IF EXISTS customers;
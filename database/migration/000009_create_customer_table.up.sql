@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS customers (
+	id BYTEA PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP,
+	deleted_at TIMESTAMP,
+
+	legal_name varchar(150) NOT NULL,
+	billing_address JSONB NOT NULL,
+	shipping_address JSONB NOT NULL,
+	tax_id varchar(50) NOT NULL,
+	currency CHAR(3) NOT NULL,
+	payment_term_days INTEGER NOT NULL,
+	emails TEXT[] NOT NULL
+);
+
+ALTER TABLE invoices
+ADD COLUMN customer_id BYTEA;
+
+ALTER TABLE invoices
+ADD CONSTRAINT fk_customer
+FOREIGN KEY (customer_id)
+REFERENCES customers(id);
+
+ALTER TABLE invoices
+ADD COLUMN bill_to JSONB;
+
+ALTER TABLE invoices
+ADD COLUMN payment_term_days INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE invoices
+ADD COLUMN due_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_invoice_customer ON invoices(customer_id);
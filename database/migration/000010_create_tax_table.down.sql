@@ -0,0 +1,20 @@
+ALTER TABLE invoice_lines
+DROP COLUMN IF EXISTS price_includes_tax;
+
+ALTER TABLE invoice_lines
+DROP COLUMN IF EXISTS tax_rate;
+
+ALTER TABLE invoice_lines
+DROP COLUMN IF EXISTS tax_name;
+
+ALTER TABLE invoice_lines
+DROP COLUMN IF EXISTS tax_class;
+
+ALTER TABLE products
+DROP COLUMN IF EXISTS price_includes_tax;
+
+ALTER TABLE products
+DROP COLUMN IF EXISTS tax_class;
+
+DROP INDEX IF EXISTS idx_tax_rate_lookup;
+DROP TABLE IF EXISTS tax_rates;
@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS tax_rates (
+	id BYTEA PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP,
+	deleted_at TIMESTAMP,
+
+	class varchar(20) NOT NULL,
+	jurisdiction varchar(2) NOT NULL DEFAULT '',
+	name varchar(50) NOT NULL,
+	rate INTEGER NOT NULL CHECK (rate >= 0),
+	valid_from TIMESTAMP NOT NULL,
+	valid_to TIMESTAMP,
+	CHECK (class IN ('standard', 'reduced', 'zero', 'exempt')),
+	CHECK (valid_to IS NULL OR valid_to > valid_from)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tax_rate_lookup ON tax_rates(class, jurisdiction, valid_from);
+
+ALTER TABLE products
+ADD COLUMN tax_class varchar(20) NOT NULL DEFAULT 'standard';
+
+ALTER TABLE products
+ADD COLUMN price_includes_tax BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE invoice_lines
+ADD COLUMN tax_class varchar(20) NOT NULL DEFAULT 'exempt';
+
+ALTER TABLE invoice_lines
+ADD COLUMN tax_name varchar(50) NOT NULL DEFAULT 'Exempt';
+
+ALTER TABLE invoice_lines
+ADD COLUMN tax_rate INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE invoice_lines
+ADD COLUMN price_includes_tax BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS idx_payment_allocation_invoice;
+DROP INDEX IF EXISTS idx_payment_allocation_payment;
+DROP TABLE IF EXISTS payment_allocations;
+
+DROP INDEX IF EXISTS idx_payment_customer;
+DROP TABLE IF EXISTS payments;
+
+ALTER TABLE invoices
+DROP CONSTRAINT IF EXISTS chk_invoice_paid_amount;
+
+ALTER TABLE invoices
+DROP COLUMN IF EXISTS paid_amount;
+
+ALTER TABLE invoices
+DROP COLUMN IF EXISTS total_amount;
@@ -0,0 +1,65 @@
+ALTER TABLE invoices
+ADD COLUMN total_amount NUMERIC(12,2) NOT NULL DEFAULT 0;
+
+ALTER TABLE invoices
+ADD COLUMN paid_amount NUMERIC(12,2) NOT NULL DEFAULT 0;
+
+UPDATE invoices i
+SET total_amount = t.total
+FROM (
+	SELECT
+		invoice_id,
+		SUM(
+			CASE WHEN price_includes_tax
+				THEN amount * quantity
+				ELSE amount * quantity + ROUND(amount * quantity * tax_rate / 10000.0, 2)
+			END
+		) AS total
+	FROM invoice_lines
+	GROUP BY invoice_id
+) t
+WHERE t.invoice_id = i.id;
+
+ALTER TABLE invoices
+ADD CONSTRAINT chk_invoice_paid_amount
+CHECK (paid_amount >= 0 AND paid_amount <= total_amount);
+
+CREATE TABLE IF NOT EXISTS payments (
+	id BYTEA PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP,
+	deleted_at TIMESTAMP,
+
+	customer_id BYTEA NOT NULL,
+	amount NUMERIC(12,2) NOT NULL CHECK (amount > 0),
+	unallocated_amount NUMERIC(12,2) NOT NULL,
+	currency CHAR(3) NOT NULL,
+	method varchar(20) NOT NULL,
+	reference varchar(100) NOT NULL DEFAULT '',
+	received_at TIMESTAMP NOT NULL,
+	CONSTRAINT fk_customer
+		FOREIGN KEY (customer_id)
+		REFERENCES customers(id),
+	CHECK (unallocated_amount >= 0 AND unallocated_amount <= amount),
+	CHECK (method IN ('bank_transfer', 'card', 'cash', 'cheque', 'other'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_customer ON payments(customer_id);
+
+CREATE TABLE IF NOT EXISTS payment_allocations (
+	id BYTEA PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+	payment_id BYTEA NOT NULL,
+	invoice_id BYTEA NOT NULL,
+	amount NUMERIC(12,2) NOT NULL CHECK (amount > 0),
+	CONSTRAINT fk_payment
+		FOREIGN KEY (payment_id)
+		REFERENCES payments(id),
+	CONSTRAINT fk_invoice
+		FOREIGN KEY (invoice_id)
+		REFERENCES invoices(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_allocation_payment ON payment_allocations(payment_id);
+CREATE INDEX IF NOT EXISTS idx_payment_allocation_invoice ON payment_allocations(invoice_id);
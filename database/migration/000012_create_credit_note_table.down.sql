@@ -0,0 +1,16 @@
+DROP INDEX IF EXISTS idx_credit_note_line_invoice_line;
+DROP INDEX IF EXISTS idx_credit_note_line_credit_note;
+DROP TABLE IF EXISTS credit_note_lines;
+
+DROP INDEX IF EXISTS idx_credit_note_invoice;
+DROP TABLE IF EXISTS credit_notes;
+
+ALTER TABLE invoices
+DROP CONSTRAINT IF EXISTS chk_invoice_paid_amount;
+
+ALTER TABLE invoices
+ADD CONSTRAINT chk_invoice_paid_amount
+CHECK (paid_amount >= 0 AND paid_amount <= total_amount);
+
+ALTER TABLE invoices
+DROP COLUMN IF EXISTS credited_amount;
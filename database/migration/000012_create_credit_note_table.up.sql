@@ -0,0 +1,64 @@
+ALTER TABLE invoices
+ADD COLUMN credited_amount NUMERIC(12,2) NOT NULL DEFAULT 0;
+
+ALTER TABLE invoices
+DROP CONSTRAINT IF EXISTS chk_invoice_paid_amount;
+
+ALTER TABLE invoices
+ADD CONSTRAINT chk_invoice_paid_amount
+CHECK (
+	paid_amount >= 0
+	AND credited_amount >= 0
+	AND paid_amount + credited_amount <= total_amount
+);
+
+CREATE TABLE IF NOT EXISTS credit_notes (
+	id BYTEA PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP,
+	deleted_at TIMESTAMP,
+
+	number varchar(50) NOT NULL UNIQUE,
+	invoice_id BYTEA NOT NULL,
+	customer_id BYTEA NOT NULL,
+	bill_to JSONB NOT NULL,
+	currency CHAR(3) NOT NULL,
+	reason TEXT NOT NULL DEFAULT '',
+	restock BOOLEAN NOT NULL DEFAULT FALSE,
+	issued_at TIMESTAMP NOT NULL,
+	applied_amount NUMERIC(12,2) NOT NULL DEFAULT 0,
+	CONSTRAINT fk_invoice
+		FOREIGN KEY (invoice_id)
+		REFERENCES invoices(id),
+	CONSTRAINT fk_customer
+		FOREIGN KEY (customer_id)
+		REFERENCES customers(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_credit_note_invoice ON credit_notes(invoice_id);
+
+CREATE TABLE IF NOT EXISTS credit_note_lines (
+	id BYTEA PRIMARY KEY,
+	credit_note_id BYTEA NOT NULL,
+	invoice_line_id BYTEA NOT NULL,
+	product_id BYTEA NOT NULL,
+	position INTEGER NOT NULL,
+	sku varchar(25) NOT NULL,
+	name varchar(100) NOT NULL,
+	amount NUMERIC(12,2) NOT NULL,
+	currency CHAR(3) NOT NULL,
+	quantity INTEGER NOT NULL CHECK (quantity > 0),
+	tax_class varchar(20) NOT NULL,
+	tax_name varchar(50) NOT NULL,
+	tax_rate INTEGER NOT NULL,
+	price_includes_tax BOOLEAN NOT NULL,
+	CONSTRAINT fk_credit_note
+		FOREIGN KEY (credit_note_id)
+		REFERENCES credit_notes(id),
+	CONSTRAINT fk_invoice_line
+		FOREIGN KEY (invoice_line_id)
+		REFERENCES invoice_lines(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_credit_note_line_credit_note ON credit_note_lines(credit_note_id);
+CREATE INDEX IF NOT EXISTS idx_credit_note_line_invoice_line ON credit_note_lines(invoice_line_id);
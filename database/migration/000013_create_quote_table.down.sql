@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_quote_lines_quote;
+DROP TABLE IF EXISTS quote_lines;
+
+DROP INDEX IF EXISTS idx_quote_customer;
+DROP TABLE IF EXISTS quotes;
@@ -0,0 +1,47 @@
+CREATE TABLE IF NOT EXISTS quotes (
+	id BYTEA PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP,
+	deleted_at TIMESTAMP,
+
+	customer_id BYTEA NOT NULL,
+	bill_to JSONB NOT NULL,
+	currency CHAR(3) NOT NULL,
+	payment_term_days INTEGER NOT NULL DEFAULT 0,
+	status varchar(20) NOT NULL DEFAULT 'pending',
+	expires_at TIMESTAMP NOT NULL,
+	accepted_at TIMESTAMP,
+	rejected_at TIMESTAMP,
+	invoice_id BYTEA UNIQUE,
+	CONSTRAINT fk_customer
+		FOREIGN KEY (customer_id)
+		REFERENCES customers(id),
+	CONSTRAINT fk_invoice
+		FOREIGN KEY (invoice_id)
+		REFERENCES invoices(id),
+	CHECK (status IN ('pending', 'accepted', 'rejected'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_quote_customer ON quotes(customer_id);
+
+CREATE TABLE IF NOT EXISTS quote_lines (
+	id BYTEA PRIMARY KEY,
+	quote_id BYTEA NOT NULL,
+	product_id BYTEA NOT NULL,
+	position INTEGER NOT NULL,
+
+	sku varchar(25) NOT NULL,
+	name varchar(100) NOT NULL,
+	amount NUMERIC(12,2) NOT NULL,
+	currency CHAR(3) NOT NULL,
+	quantity INTEGER NOT NULL,
+	tax_class varchar(20) NOT NULL,
+	tax_name varchar(50) NOT NULL,
+	tax_rate INTEGER NOT NULL,
+	price_includes_tax BOOLEAN NOT NULL,
+
+	FOREIGN KEY (quote_id) REFERENCES quotes(id),
+	FOREIGN KEY (product_id) REFERENCES products(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quote_lines_quote ON quote_lines(quote_id);
@@ -0,0 +1,11 @@
+ALTER TABLE invoices
+DROP CONSTRAINT IF EXISTS fk_recurring_invoice;
+
+ALTER TABLE invoices
+DROP COLUMN IF EXISTS recurring_invoice_id;
+
+DROP INDEX IF EXISTS idx_recurring_invoice_lines_recurring_invoice;
+DROP TABLE IF EXISTS recurring_invoice_lines;
+
+DROP INDEX IF EXISTS idx_recurring_invoice_next_run;
+DROP TABLE IF EXISTS recurring_invoices;
@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS recurring_invoices (
+	id BYTEA PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP,
+	deleted_at TIMESTAMP,
+
+	customer_id BYTEA NOT NULL,
+	billing_interval varchar(20) NOT NULL,
+	anchor_day INTEGER NOT NULL CHECK (anchor_day BETWEEN 1 AND 31),
+	starts_at TIMESTAMP NOT NULL,
+	ends_at TIMESTAMP,
+	max_count INTEGER CHECK (max_count > 0),
+	auto_issue BOOLEAN NOT NULL DEFAULT FALSE,
+	generated_count INTEGER NOT NULL DEFAULT 0,
+	next_run_at TIMESTAMP,
+	CONSTRAINT fk_customer
+		FOREIGN KEY (customer_id)
+		REFERENCES customers(id),
+	CHECK (billing_interval IN ('monthly', 'quarterly', 'yearly'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_recurring_invoice_next_run
+ON recurring_invoices(next_run_at)
+WHERE deleted_at IS NULL AND next_run_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS recurring_invoice_lines (
+	id BYTEA PRIMARY KEY,
+	recurring_invoice_id BYTEA NOT NULL,
+	product_id BYTEA NOT NULL,
+	position INTEGER NOT NULL,
+	quantity INTEGER NOT NULL CHECK (quantity > 0),
+
+	FOREIGN KEY (recurring_invoice_id) REFERENCES recurring_invoices(id),
+	FOREIGN KEY (product_id) REFERENCES products(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_recurring_invoice_lines_recurring_invoice ON recurring_invoice_lines(recurring_invoice_id);
+
+ALTER TABLE invoices
+ADD COLUMN recurring_invoice_id BYTEA;
+
+ALTER TABLE invoices
+ADD CONSTRAINT fk_recurring_invoice
+FOREIGN KEY (recurring_invoice_id)
+REFERENCES recurring_invoices(id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_refresh_token_family;
+DROP TABLE IF EXISTS refresh_tokens;
+
+DROP INDEX IF EXISTS idx_user_email;
+DROP TABLE IF EXISTS users;
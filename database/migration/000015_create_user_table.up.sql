@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS users (
+	id BYTEA PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP,
+	deleted_at TIMESTAMP,
+
+	email varchar(150) NOT NULL,
+	name varchar(100) NOT NULL,
+	password_hash varchar(100) NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_email ON users(email) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	id BYTEA PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+	user_id BYTEA NOT NULL,
+	family_id BYTEA NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP,
+	revoked_at TIMESTAMP,
+	CONSTRAINT fk_user
+		FOREIGN KEY (user_id)
+		REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_token_family ON refresh_tokens(family_id);
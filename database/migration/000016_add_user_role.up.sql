@@ -0,0 +1,8 @@
+ALTER TABLE users
+ADD COLUMN role varchar(20) NOT NULL DEFAULT 'viewer';
+
+-- Users created before roles existed could do everything.
+UPDATE users SET role = 'admin';
+
+ALTER TABLE users
+ADD CONSTRAINT chk_user_role CHECK (role IN ('admin', 'accountant', 'warehouse', 'viewer'));
@@ -0,0 +1,59 @@
+DROP POLICY IF EXISTS tenant_isolation ON category_products;
+ALTER TABLE category_products NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE category_products DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON products;
+ALTER TABLE products NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE products DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON categories;
+ALTER TABLE categories NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE categories DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON inventories;
+ALTER TABLE inventories NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE inventories DISABLE ROW LEVEL SECURITY;
+
+ALTER TABLE category_products
+DROP CONSTRAINT IF EXISTS fk_category,
+DROP CONSTRAINT IF EXISTS fk_product;
+
+ALTER TABLE products
+DROP CONSTRAINT IF EXISTS fk_inventory,
+DROP CONSTRAINT IF EXISTS uq_product_tenant,
+DROP CONSTRAINT IF EXISTS fk_tenant;
+
+ALTER TABLE categories
+DROP CONSTRAINT IF EXISTS uq_category_tenant,
+DROP CONSTRAINT IF EXISTS fk_tenant;
+
+ALTER TABLE inventories
+DROP CONSTRAINT IF EXISTS uq_inventory_tenant,
+DROP CONSTRAINT IF EXISTS fk_tenant;
+
+ALTER TABLE category_products
+ADD CONSTRAINT category_products_category_id_fkey
+	FOREIGN KEY (category_id) REFERENCES categories(id),
+ADD CONSTRAINT category_products_product_id_fkey
+	FOREIGN KEY (product_id) REFERENCES products(id);
+
+ALTER TABLE products
+ADD CONSTRAINT fk_inventory
+FOREIGN KEY (inventory_id)
+REFERENCES inventories(id);
+
+DROP INDEX IF EXISTS idx_product_tenant_sku;
+
+ALTER TABLE products
+ADD CONSTRAINT products_sku_key UNIQUE (sku);
+
+CREATE INDEX IF NOT EXISTS idx_sku ON products(sku);
+
+ALTER TABLE recurring_invoices DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE category_products DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE products DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE categories DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE inventories DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE users DROP COLUMN IF EXISTS tenant_id;
+
+DROP TABLE IF EXISTS tenants;
@@ -0,0 +1,114 @@
+CREATE TABLE IF NOT EXISTS tenants (
+	id BYTEA PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP,
+	deleted_at TIMESTAMP,
+
+	name varchar(100) NOT NULL
+);
+
+-- model.DefaultTenantID owns everything created so far.
+INSERT INTO tenants (id, name)
+VALUES ('\x00000000000000000000000000000001', 'Default');
+
+-- Rows are scoped to the tenant the connection was set to by the server,
+-- which is also the tenant new rows are written for. A connection without
+-- a tenant sees nothing.
+ALTER TABLE users
+ADD COLUMN tenant_id BYTEA NOT NULL DEFAULT '\x00000000000000000000000000000001';
+
+ALTER TABLE users
+ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE users
+ADD CONSTRAINT fk_tenant
+FOREIGN KEY (tenant_id)
+REFERENCES tenants(id);
+
+ALTER TABLE inventories
+ADD COLUMN tenant_id BYTEA NOT NULL DEFAULT '\x00000000000000000000000000000001';
+
+ALTER TABLE categories
+ADD COLUMN tenant_id BYTEA NOT NULL DEFAULT '\x00000000000000000000000000000001';
+
+ALTER TABLE products
+ADD COLUMN tenant_id BYTEA NOT NULL DEFAULT '\x00000000000000000000000000000001';
+
+ALTER TABLE category_products
+ADD COLUMN tenant_id BYTEA NOT NULL DEFAULT '\x00000000000000000000000000000001';
+
+ALTER TABLE recurring_invoices
+ADD COLUMN tenant_id BYTEA NOT NULL DEFAULT '\x00000000000000000000000000000001';
+
+ALTER TABLE inventories ALTER COLUMN tenant_id SET DEFAULT decode(current_setting('app.tenant_id', true), 'hex');
+ALTER TABLE categories ALTER COLUMN tenant_id SET DEFAULT decode(current_setting('app.tenant_id', true), 'hex');
+ALTER TABLE products ALTER COLUMN tenant_id SET DEFAULT decode(current_setting('app.tenant_id', true), 'hex');
+ALTER TABLE category_products ALTER COLUMN tenant_id SET DEFAULT decode(current_setting('app.tenant_id', true), 'hex');
+ALTER TABLE recurring_invoices ALTER COLUMN tenant_id SET DEFAULT decode(current_setting('app.tenant_id', true), 'hex');
+
+ALTER TABLE inventories
+ADD CONSTRAINT fk_tenant FOREIGN KEY (tenant_id) REFERENCES tenants(id),
+ADD CONSTRAINT uq_inventory_tenant UNIQUE (id, tenant_id);
+
+ALTER TABLE categories
+ADD CONSTRAINT fk_tenant FOREIGN KEY (tenant_id) REFERENCES tenants(id),
+ADD CONSTRAINT uq_category_tenant UNIQUE (id, tenant_id);
+
+ALTER TABLE recurring_invoices
+ADD CONSTRAINT fk_tenant FOREIGN KEY (tenant_id) REFERENCES tenants(id);
+
+-- SKUs only have to be unique within a tenant.
+ALTER TABLE products
+DROP CONSTRAINT IF EXISTS products_sku_key;
+
+DROP INDEX IF EXISTS idx_sku;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_tenant_sku ON products(tenant_id, sku);
+
+-- Foreign keys are checked without row level security, so references must
+-- carry the tenant to stay inside it.
+ALTER TABLE products
+DROP CONSTRAINT IF EXISTS fk_inventory;
+
+ALTER TABLE products
+ADD CONSTRAINT fk_tenant FOREIGN KEY (tenant_id) REFERENCES tenants(id),
+ADD CONSTRAINT uq_product_tenant UNIQUE (id, tenant_id),
+ADD CONSTRAINT fk_inventory
+	FOREIGN KEY (inventory_id, tenant_id)
+	REFERENCES inventories(id, tenant_id);
+
+ALTER TABLE category_products
+DROP CONSTRAINT IF EXISTS category_products_category_id_fkey,
+DROP CONSTRAINT IF EXISTS category_products_product_id_fkey;
+
+ALTER TABLE category_products
+ADD CONSTRAINT fk_category
+	FOREIGN KEY (category_id, tenant_id)
+	REFERENCES categories(id, tenant_id),
+ADD CONSTRAINT fk_product
+	FOREIGN KEY (product_id, tenant_id)
+	REFERENCES products(id, tenant_id);
+
+ALTER TABLE inventories ENABLE ROW LEVEL SECURITY;
+ALTER TABLE inventories FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON inventories
+	USING (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'))
+	WITH CHECK (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'));
+
+ALTER TABLE categories ENABLE ROW LEVEL SECURITY;
+ALTER TABLE categories FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON categories
+	USING (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'))
+	WITH CHECK (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'));
+
+ALTER TABLE products ENABLE ROW LEVEL SECURITY;
+ALTER TABLE products FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON products
+	USING (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'))
+	WITH CHECK (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'));
+
+ALTER TABLE category_products ENABLE ROW LEVEL SECURITY;
+ALTER TABLE category_products FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON category_products
+	USING (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'))
+	WITH CHECK (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'));
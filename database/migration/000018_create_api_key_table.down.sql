@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_api_key_tenant;
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+	id BYTEA PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+	tenant_id BYTEA NOT NULL,
+	name varchar(100) NOT NULL,
+	scopes TEXT[] NOT NULL,
+	secret_hash BYTEA NOT NULL,
+	expires_at TIMESTAMP,
+	last_used_at TIMESTAMP,
+	revoked_at TIMESTAMP,
+	CONSTRAINT fk_tenant
+		FOREIGN KEY (tenant_id)
+		REFERENCES tenants(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_key_tenant ON api_keys(tenant_id);
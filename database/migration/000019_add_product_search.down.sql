@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_product_sku_trgm;
+DROP INDEX IF EXISTS idx_product_search;
+
+ALTER TABLE products
+DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,13 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- The simple configuration does not stem, so prefixes of the words as
+-- typed still match.
+ALTER TABLE products
+ADD COLUMN search_vector tsvector
+GENERATED ALWAYS AS (
+	setweight(to_tsvector('simple', name), 'A') ||
+	setweight(to_tsvector('simple', description), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_product_search ON products USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_product_sku_trgm ON products USING GIN (sku gin_trgm_ops);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_category_parent;
+
+ALTER TABLE categories
+DROP CONSTRAINT IF EXISTS chk_category_parent,
+DROP CONSTRAINT IF EXISTS fk_parent;
+
+ALTER TABLE categories
+DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,10 @@
+ALTER TABLE categories
+ADD COLUMN parent_id BYTEA;
+
+ALTER TABLE categories
+ADD CONSTRAINT fk_parent
+	FOREIGN KEY (parent_id, tenant_id)
+	REFERENCES categories(id, tenant_id),
+ADD CONSTRAINT chk_category_parent CHECK (parent_id <> id);
+
+CREATE INDEX IF NOT EXISTS idx_category_parent ON categories(parent_id);
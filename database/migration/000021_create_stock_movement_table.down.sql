@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS trg_stock_movement_append_only ON stock_movements;
+DROP FUNCTION IF EXISTS reject_stock_movement_change();
+
+DROP INDEX IF EXISTS idx_stock_movement_product;
+DROP TABLE IF EXISTS stock_movements;
@@ -0,0 +1,78 @@
+CREATE TABLE IF NOT EXISTS stock_movements (
+	id BYTEA PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+	tenant_id BYTEA NOT NULL DEFAULT decode(current_setting('app.tenant_id', true), 'hex'),
+	inventory_id BYTEA NOT NULL,
+	product_id BYTEA NOT NULL,
+	movement_type varchar(20) NOT NULL,
+	quantity INTEGER NOT NULL,
+	quantity_after INTEGER NOT NULL,
+	reason varchar(255) NOT NULL DEFAULT '',
+	reference varchar(100) NOT NULL DEFAULT '',
+	actor varchar(50) NOT NULL,
+	CONSTRAINT fk_inventory
+		FOREIGN KEY (inventory_id, tenant_id)
+		REFERENCES inventories(id, tenant_id),
+	CONSTRAINT fk_product
+		FOREIGN KEY (product_id, tenant_id)
+		REFERENCES products(id, tenant_id),
+	CHECK (movement_type IN ('receipt', 'sale', 'adjustment', 'return', 'transfer')),
+	CHECK (quantity <> 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movement_product ON stock_movements(product_id, id);
+
+-- The ledger is only ever appended to.
+CREATE OR REPLACE FUNCTION reject_stock_movement_change() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'stock_movements is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_stock_movement_append_only
+BEFORE UPDATE OR DELETE ON stock_movements
+FOR EACH ROW EXECUTE FUNCTION reject_stock_movement_change();
+
+-- Open the ledger of every product with its current quantity. The entry
+-- reuses the inventory's id, which dates it at the inventory's creation.
+-- Row level security is lifted for the owner meanwhile, so every tenant's
+-- stock is seen.
+ALTER TABLE inventories NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE products NO FORCE ROW LEVEL SECURITY;
+
+INSERT INTO stock_movements (
+	id,
+	created_at,
+	tenant_id,
+	inventory_id,
+	product_id,
+	movement_type,
+	quantity,
+	quantity_after,
+	reason,
+	actor
+)
+SELECT
+	i.id,
+	i.created_at,
+	i.tenant_id,
+	i.id,
+	p.id,
+	'adjustment',
+	i.quantity,
+	i.quantity,
+	'opening balance',
+	'system'
+FROM inventories i
+JOIN products p ON p.inventory_id = i.id
+WHERE i.quantity <> 0;
+
+ALTER TABLE inventories FORCE ROW LEVEL SECURITY;
+ALTER TABLE products FORCE ROW LEVEL SECURITY;
+
+ALTER TABLE stock_movements ENABLE ROW LEVEL SECURITY;
+ALTER TABLE stock_movements FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON stock_movements
+	USING (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'))
+	WITH CHECK (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'));
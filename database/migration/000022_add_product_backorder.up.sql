@@ -0,0 +1,2 @@
+ALTER TABLE products
+ADD COLUMN allow_backorder BOOLEAN NOT NULL DEFAULT false;
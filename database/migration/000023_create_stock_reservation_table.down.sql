@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_stock_reservation_expires;
+DROP INDEX IF EXISTS idx_stock_reservation_invoice;
+DROP INDEX IF EXISTS idx_stock_reservation_inventory;
+DROP TABLE IF EXISTS stock_reservations;
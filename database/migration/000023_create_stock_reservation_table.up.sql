@@ -0,0 +1,27 @@
+-- Reservations are not under row level security, like recurring_invoices,
+-- so that the sweeper can release expired ones of every tenant. They are
+-- only ever read through the inventories they hold stock of, which are.
+CREATE TABLE IF NOT EXISTS stock_reservations (
+	id BYTEA PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+	tenant_id BYTEA NOT NULL DEFAULT decode(current_setting('app.tenant_id', true), 'hex'),
+	inventory_id BYTEA NOT NULL,
+	product_id BYTEA NOT NULL,
+	invoice_id BYTEA NOT NULL,
+	quantity INTEGER NOT NULL CHECK (quantity > 0),
+	expires_at TIMESTAMP NOT NULL,
+	CONSTRAINT fk_inventory
+		FOREIGN KEY (inventory_id, tenant_id)
+		REFERENCES inventories(id, tenant_id),
+	CONSTRAINT fk_product
+		FOREIGN KEY (product_id, tenant_id)
+		REFERENCES products(id, tenant_id),
+	CONSTRAINT fk_invoice
+		FOREIGN KEY (invoice_id)
+		REFERENCES invoices(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_reservation_inventory ON stock_reservations(inventory_id, expires_at);
+CREATE INDEX IF NOT EXISTS idx_stock_reservation_invoice ON stock_reservations(invoice_id);
+CREATE INDEX IF NOT EXISTS idx_stock_reservation_expires ON stock_reservations(expires_at);
@@ -0,0 +1,42 @@
+DROP INDEX IF EXISTS idx_warehouse_transfer_product;
+DROP TABLE IF EXISTS warehouse_transfers;
+
+-- Only the stock in the default warehouse, which products link to, is
+-- kept.
+ALTER TABLE inventories NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE products NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE stock_movements NO FORCE ROW LEVEL SECURITY;
+
+DELETE FROM stock_reservations r
+USING inventories i
+WHERE r.inventory_id = i.id
+	AND NOT EXISTS (SELECT 1 FROM products p WHERE p.inventory_id = i.id);
+
+ALTER TABLE stock_movements DISABLE TRIGGER trg_stock_movement_append_only;
+
+DELETE FROM stock_movements m
+USING inventories i
+WHERE m.inventory_id = i.id
+	AND NOT EXISTS (SELECT 1 FROM products p WHERE p.inventory_id = i.id);
+
+ALTER TABLE stock_movements ENABLE TRIGGER trg_stock_movement_append_only;
+
+DELETE FROM inventories i
+WHERE NOT EXISTS (SELECT 1 FROM products p WHERE p.inventory_id = i.id);
+
+ALTER TABLE inventories FORCE ROW LEVEL SECURITY;
+ALTER TABLE products FORCE ROW LEVEL SECURITY;
+ALTER TABLE stock_movements FORCE ROW LEVEL SECURITY;
+
+ALTER TABLE inventories
+DROP CONSTRAINT IF EXISTS uq_inventory_product_warehouse,
+DROP CONSTRAINT IF EXISTS fk_warehouse,
+DROP CONSTRAINT IF EXISTS fk_product;
+
+ALTER TABLE inventories
+DROP COLUMN IF EXISTS warehouse_id,
+DROP COLUMN IF EXISTS product_id;
+
+DROP INDEX IF EXISTS idx_warehouse_tenant_default;
+DROP INDEX IF EXISTS idx_warehouse_tenant_code;
+DROP TABLE IF EXISTS warehouses;
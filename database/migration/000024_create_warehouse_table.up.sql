@@ -0,0 +1,121 @@
+CREATE TABLE IF NOT EXISTS warehouses (
+	id BYTEA PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP,
+	deleted_at TIMESTAMP,
+
+	tenant_id BYTEA NOT NULL DEFAULT decode(current_setting('app.tenant_id', true), 'hex'),
+	code varchar(25) NOT NULL,
+	name varchar(100) NOT NULL,
+	is_default BOOLEAN NOT NULL DEFAULT false,
+	CONSTRAINT fk_tenant
+		FOREIGN KEY (tenant_id)
+		REFERENCES tenants(id),
+	CONSTRAINT uq_warehouse_tenant UNIQUE (id, tenant_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_warehouse_tenant_code ON warehouses(tenant_id, code);
+
+-- Every tenant has exactly one default warehouse, which products are
+-- stocked in unless told otherwise.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_warehouse_tenant_default ON warehouses(tenant_id) WHERE is_default;
+
+-- Inventories become the stock of one product in one warehouse. The
+-- inventory a product links to is its stock in the default warehouse.
+ALTER TABLE inventories
+ADD COLUMN product_id BYTEA,
+ADD COLUMN warehouse_id BYTEA;
+
+-- Row level security is lifted for the owner while every tenant's stock is
+-- moved into its default warehouse. The default warehouse reuses the
+-- tenant's id.
+ALTER TABLE inventories NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE products NO FORCE ROW LEVEL SECURITY;
+
+INSERT INTO warehouses (
+	id,
+	created_at,
+	tenant_id,
+	code,
+	name,
+	is_default
+)
+SELECT
+	t.id,
+	CURRENT_TIMESTAMP,
+	t.id,
+	'MAIN',
+	'Main warehouse',
+	true
+FROM tenants t;
+
+UPDATE inventories i
+SET
+	product_id = p.id,
+	warehouse_id = i.tenant_id
+FROM products p
+WHERE p.inventory_id = i.id;
+
+-- Stock no product links to can't be told apart from nothing.
+DELETE FROM inventories WHERE product_id IS NULL;
+
+ALTER TABLE inventories FORCE ROW LEVEL SECURITY;
+ALTER TABLE products FORCE ROW LEVEL SECURITY;
+
+ALTER TABLE inventories
+ALTER COLUMN product_id SET NOT NULL,
+ALTER COLUMN warehouse_id SET NOT NULL;
+
+-- A product's inventory is created just before the product itself, so the
+-- reference back to it is only checked at commit.
+ALTER TABLE inventories
+ADD CONSTRAINT fk_product
+	FOREIGN KEY (product_id, tenant_id)
+	REFERENCES products(id, tenant_id)
+	DEFERRABLE INITIALLY DEFERRED,
+ADD CONSTRAINT fk_warehouse
+	FOREIGN KEY (warehouse_id, tenant_id)
+	REFERENCES warehouses(id, tenant_id),
+ADD CONSTRAINT uq_inventory_product_warehouse UNIQUE (product_id, warehouse_id);
+
+CREATE TABLE IF NOT EXISTS warehouse_transfers (
+	id BYTEA PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP,
+
+	tenant_id BYTEA NOT NULL DEFAULT decode(current_setting('app.tenant_id', true), 'hex'),
+	product_id BYTEA NOT NULL,
+	from_warehouse_id BYTEA NOT NULL,
+	to_warehouse_id BYTEA NOT NULL,
+	quantity INTEGER NOT NULL CHECK (quantity > 0),
+	status varchar(20) NOT NULL DEFAULT 'in_transit',
+	reference varchar(100) NOT NULL DEFAULT '',
+	shipped_at TIMESTAMP NOT NULL,
+	received_at TIMESTAMP,
+	cancelled_at TIMESTAMP,
+	CONSTRAINT fk_product
+		FOREIGN KEY (product_id, tenant_id)
+		REFERENCES products(id, tenant_id),
+	CONSTRAINT fk_from_warehouse
+		FOREIGN KEY (from_warehouse_id, tenant_id)
+		REFERENCES warehouses(id, tenant_id),
+	CONSTRAINT fk_to_warehouse
+		FOREIGN KEY (to_warehouse_id, tenant_id)
+		REFERENCES warehouses(id, tenant_id),
+	CHECK (from_warehouse_id <> to_warehouse_id),
+	CHECK (status IN ('in_transit', 'received', 'cancelled'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_warehouse_transfer_product ON warehouse_transfers(product_id) WHERE status = 'in_transit';
+
+ALTER TABLE warehouses ENABLE ROW LEVEL SECURITY;
+ALTER TABLE warehouses FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON warehouses
+	USING (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'))
+	WITH CHECK (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'));
+
+ALTER TABLE warehouse_transfers ENABLE ROW LEVEL SECURITY;
+ALTER TABLE warehouse_transfers FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON warehouse_transfers
+	USING (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'))
+	WITH CHECK (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'));
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_inventory_reorder_point;
+
+ALTER TABLE inventories
+DROP COLUMN IF EXISTS reorder_quantity,
+DROP COLUMN IF EXISTS reorder_point;
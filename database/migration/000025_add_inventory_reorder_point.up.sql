@@ -0,0 +1,5 @@
+ALTER TABLE inventories
+ADD COLUMN reorder_point INTEGER CHECK (reorder_point >= 0),
+ADD COLUMN reorder_quantity INTEGER NOT NULL DEFAULT 0 CHECK (reorder_quantity >= 0);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_reorder_point ON inventories(warehouse_id) WHERE reorder_point IS NOT NULL;
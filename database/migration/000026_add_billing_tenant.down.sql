@@ -0,0 +1,212 @@
+DROP POLICY IF EXISTS tenant_isolation ON number_sequences;
+ALTER TABLE number_sequences NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE number_sequences DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON recurring_invoice_lines;
+ALTER TABLE recurring_invoice_lines NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE recurring_invoice_lines DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON quote_lines;
+ALTER TABLE quote_lines NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE quote_lines DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON quotes;
+ALTER TABLE quotes NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE quotes DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON credit_note_lines;
+ALTER TABLE credit_note_lines NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE credit_note_lines DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON credit_notes;
+ALTER TABLE credit_notes NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE credit_notes DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON payment_allocations;
+ALTER TABLE payment_allocations NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE payment_allocations DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON payments;
+ALTER TABLE payments NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE payments DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON invoice_lines;
+ALTER TABLE invoice_lines NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE invoice_lines DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON invoices;
+ALTER TABLE invoices NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE invoices DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON tax_rates;
+ALTER TABLE tax_rates NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE tax_rates DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON customers;
+ALTER TABLE customers NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE customers DISABLE ROW LEVEL SECURITY;
+
+ALTER TABLE stock_reservations
+DROP CONSTRAINT IF EXISTS fk_invoice;
+
+ALTER TABLE recurring_invoice_lines
+DROP CONSTRAINT IF EXISTS fk_recurring_invoice,
+DROP CONSTRAINT IF EXISTS fk_product;
+
+ALTER TABLE quote_lines
+DROP CONSTRAINT IF EXISTS fk_quote,
+DROP CONSTRAINT IF EXISTS fk_product;
+
+ALTER TABLE quotes
+DROP CONSTRAINT IF EXISTS fk_invoice,
+DROP CONSTRAINT IF EXISTS fk_customer,
+DROP CONSTRAINT IF EXISTS uq_quote_tenant;
+
+ALTER TABLE credit_note_lines
+DROP CONSTRAINT IF EXISTS fk_invoice_line,
+DROP CONSTRAINT IF EXISTS fk_credit_note;
+
+ALTER TABLE credit_notes
+DROP CONSTRAINT IF EXISTS fk_customer,
+DROP CONSTRAINT IF EXISTS fk_invoice,
+DROP CONSTRAINT IF EXISTS uq_credit_note_tenant;
+
+ALTER TABLE payment_allocations
+DROP CONSTRAINT IF EXISTS fk_invoice,
+DROP CONSTRAINT IF EXISTS fk_payment;
+
+ALTER TABLE payments
+DROP CONSTRAINT IF EXISTS fk_customer,
+DROP CONSTRAINT IF EXISTS uq_payment_tenant;
+
+ALTER TABLE invoice_lines
+DROP CONSTRAINT IF EXISTS fk_product,
+DROP CONSTRAINT IF EXISTS fk_invoice,
+DROP CONSTRAINT IF EXISTS uq_invoice_line_tenant;
+
+ALTER TABLE invoices
+DROP CONSTRAINT IF EXISTS fk_recurring_invoice,
+DROP CONSTRAINT IF EXISTS fk_customer,
+DROP CONSTRAINT IF EXISTS uq_invoice_tenant,
+DROP CONSTRAINT IF EXISTS fk_tenant;
+
+ALTER TABLE recurring_invoices
+DROP CONSTRAINT IF EXISTS fk_customer,
+DROP CONSTRAINT IF EXISTS uq_recurring_invoice_tenant;
+
+ALTER TABLE tax_rates
+DROP CONSTRAINT IF EXISTS fk_tenant;
+
+ALTER TABLE customers
+DROP CONSTRAINT IF EXISTS uq_customer_tenant,
+DROP CONSTRAINT IF EXISTS fk_tenant;
+
+ALTER TABLE stock_reservations
+ADD CONSTRAINT fk_invoice
+	FOREIGN KEY (invoice_id)
+	REFERENCES invoices(id);
+
+ALTER TABLE recurring_invoices
+ADD CONSTRAINT fk_customer
+	FOREIGN KEY (customer_id)
+	REFERENCES customers(id);
+
+ALTER TABLE recurring_invoice_lines
+ADD CONSTRAINT recurring_invoice_lines_recurring_invoice_id_fkey
+	FOREIGN KEY (recurring_invoice_id) REFERENCES recurring_invoices(id),
+ADD CONSTRAINT recurring_invoice_lines_product_id_fkey
+	FOREIGN KEY (product_id) REFERENCES products(id);
+
+ALTER TABLE quotes
+ADD CONSTRAINT fk_customer
+	FOREIGN KEY (customer_id)
+	REFERENCES customers(id),
+ADD CONSTRAINT fk_invoice
+	FOREIGN KEY (invoice_id)
+	REFERENCES invoices(id);
+
+ALTER TABLE quote_lines
+ADD CONSTRAINT quote_lines_quote_id_fkey
+	FOREIGN KEY (quote_id) REFERENCES quotes(id),
+ADD CONSTRAINT quote_lines_product_id_fkey
+	FOREIGN KEY (product_id) REFERENCES products(id);
+
+ALTER TABLE credit_notes
+ADD CONSTRAINT fk_invoice
+	FOREIGN KEY (invoice_id)
+	REFERENCES invoices(id),
+ADD CONSTRAINT fk_customer
+	FOREIGN KEY (customer_id)
+	REFERENCES customers(id);
+
+ALTER TABLE credit_note_lines
+ADD CONSTRAINT fk_credit_note
+	FOREIGN KEY (credit_note_id)
+	REFERENCES credit_notes(id),
+ADD CONSTRAINT fk_invoice_line
+	FOREIGN KEY (invoice_line_id)
+	REFERENCES invoice_lines(id);
+
+ALTER TABLE payments
+ADD CONSTRAINT fk_customer
+	FOREIGN KEY (customer_id)
+	REFERENCES customers(id);
+
+ALTER TABLE payment_allocations
+ADD CONSTRAINT fk_payment
+	FOREIGN KEY (payment_id)
+	REFERENCES payments(id),
+ADD CONSTRAINT fk_invoice
+	FOREIGN KEY (invoice_id)
+	REFERENCES invoices(id);
+
+ALTER TABLE invoices
+ADD CONSTRAINT fk_customer
+	FOREIGN KEY (customer_id)
+	REFERENCES customers(id),
+ADD CONSTRAINT fk_recurring_invoice
+	FOREIGN KEY (recurring_invoice_id)
+	REFERENCES recurring_invoices(id);
+
+ALTER TABLE invoice_lines
+ADD CONSTRAINT invoice_lines_invoice_id_fkey
+	FOREIGN KEY (invoice_id) REFERENCES invoices(id),
+ADD CONSTRAINT invoice_lines_product_id_fkey
+	FOREIGN KEY (product_id) REFERENCES products(id);
+
+-- Numbers go back to one series shared by every tenant, which continues
+-- from the furthest any tenant got.
+DROP INDEX IF EXISTS idx_credit_note_tenant_number;
+DROP INDEX IF EXISTS idx_invoice_tenant_number;
+
+ALTER TABLE credit_notes
+ADD CONSTRAINT credit_notes_number_key UNIQUE (number);
+
+ALTER TABLE invoices
+ADD CONSTRAINT invoices_number_key UNIQUE (number);
+
+DELETE FROM number_sequences s
+USING number_sequences o
+WHERE o.series = s.series
+	AND o.period = s.period
+	AND (o.last_value > s.last_value OR (o.last_value = s.last_value AND o.tenant_id < s.tenant_id));
+
+ALTER TABLE number_sequences
+DROP CONSTRAINT IF EXISTS fk_tenant,
+DROP CONSTRAINT IF EXISTS number_sequences_pkey;
+
+ALTER TABLE number_sequences
+ADD PRIMARY KEY (series, period);
+
+ALTER TABLE number_sequences DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE recurring_invoice_lines DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE quote_lines DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE quotes DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE credit_note_lines DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE credit_notes DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE payment_allocations DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE payments DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE invoice_lines DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE invoices DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE tax_rates DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE customers DROP COLUMN IF EXISTS tenant_id;
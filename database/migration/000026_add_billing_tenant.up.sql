@@ -0,0 +1,351 @@
+-- Billing data is scoped to tenants the same way the catalog is.
+ALTER TABLE customers ADD COLUMN tenant_id BYTEA NOT NULL DEFAULT '\x00000000000000000000000000000001';
+ALTER TABLE tax_rates ADD COLUMN tenant_id BYTEA NOT NULL DEFAULT '\x00000000000000000000000000000001';
+ALTER TABLE invoices ADD COLUMN tenant_id BYTEA NOT NULL DEFAULT '\x00000000000000000000000000000001';
+ALTER TABLE invoice_lines ADD COLUMN tenant_id BYTEA NOT NULL DEFAULT '\x00000000000000000000000000000001';
+ALTER TABLE payments ADD COLUMN tenant_id BYTEA NOT NULL DEFAULT '\x00000000000000000000000000000001';
+ALTER TABLE payment_allocations ADD COLUMN tenant_id BYTEA NOT NULL DEFAULT '\x00000000000000000000000000000001';
+ALTER TABLE credit_notes ADD COLUMN tenant_id BYTEA NOT NULL DEFAULT '\x00000000000000000000000000000001';
+ALTER TABLE credit_note_lines ADD COLUMN tenant_id BYTEA NOT NULL DEFAULT '\x00000000000000000000000000000001';
+ALTER TABLE quotes ADD COLUMN tenant_id BYTEA NOT NULL DEFAULT '\x00000000000000000000000000000001';
+ALTER TABLE quote_lines ADD COLUMN tenant_id BYTEA NOT NULL DEFAULT '\x00000000000000000000000000000001';
+ALTER TABLE recurring_invoice_lines ADD COLUMN tenant_id BYTEA NOT NULL DEFAULT '\x00000000000000000000000000000001';
+ALTER TABLE number_sequences ADD COLUMN tenant_id BYTEA NOT NULL DEFAULT '\x00000000000000000000000000000001';
+
+-- Existing rows belong to the tenant of the products they were billed
+-- for, or of the schedule that generated them; what can't be told belongs
+-- to the default tenant. Row level security is lifted for the owner
+-- meanwhile, so every tenant's products are seen.
+ALTER TABLE products NO FORCE ROW LEVEL SECURITY;
+
+UPDATE invoices i
+SET tenant_id = r.tenant_id
+FROM recurring_invoices r
+WHERE r.id = i.recurring_invoice_id;
+
+UPDATE invoices i
+SET tenant_id = p.tenant_id
+FROM invoice_lines l
+JOIN products p ON p.id = l.product_id
+WHERE l.invoice_id = i.id;
+
+UPDATE quotes q
+SET tenant_id = p.tenant_id
+FROM quote_lines l
+JOIN products p ON p.id = l.product_id
+WHERE l.quote_id = q.id;
+
+UPDATE customers c
+SET tenant_id = r.tenant_id
+FROM recurring_invoices r
+WHERE r.customer_id = c.id;
+
+UPDATE customers c
+SET tenant_id = q.tenant_id
+FROM quotes q
+WHERE q.customer_id = c.id;
+
+UPDATE customers c
+SET tenant_id = i.tenant_id
+FROM invoices i
+WHERE i.customer_id = c.id;
+
+ALTER TABLE products FORCE ROW LEVEL SECURITY;
+
+UPDATE invoice_lines l
+SET tenant_id = i.tenant_id
+FROM invoices i
+WHERE i.id = l.invoice_id;
+
+UPDATE payments p
+SET tenant_id = c.tenant_id
+FROM customers c
+WHERE c.id = p.customer_id;
+
+UPDATE payment_allocations a
+SET tenant_id = p.tenant_id
+FROM payments p
+WHERE p.id = a.payment_id;
+
+UPDATE credit_notes n
+SET tenant_id = i.tenant_id
+FROM invoices i
+WHERE i.id = n.invoice_id;
+
+UPDATE credit_note_lines l
+SET tenant_id = n.tenant_id
+FROM credit_notes n
+WHERE n.id = l.credit_note_id;
+
+UPDATE quote_lines l
+SET tenant_id = q.tenant_id
+FROM quotes q
+WHERE q.id = l.quote_id;
+
+UPDATE recurring_invoice_lines l
+SET tenant_id = r.tenant_id
+FROM recurring_invoices r
+WHERE r.id = l.recurring_invoice_id;
+
+-- Numbers are drawn per tenant from now on. Every tenant carries on from
+-- where the shared series stood, so no number already handed out is drawn
+-- again.
+INSERT INTO number_sequences (
+	tenant_id,
+	series,
+	period,
+	created_at,
+	last_value
+)
+SELECT
+	t.id,
+	s.series,
+	s.period,
+	s.created_at,
+	s.last_value
+FROM number_sequences s
+CROSS JOIN tenants t
+WHERE s.tenant_id = '\x00000000000000000000000000000001'
+	AND t.id <> s.tenant_id;
+
+ALTER TABLE customers ALTER COLUMN tenant_id SET DEFAULT decode(current_setting('app.tenant_id', true), 'hex');
+ALTER TABLE tax_rates ALTER COLUMN tenant_id SET DEFAULT decode(current_setting('app.tenant_id', true), 'hex');
+ALTER TABLE invoices ALTER COLUMN tenant_id SET DEFAULT decode(current_setting('app.tenant_id', true), 'hex');
+ALTER TABLE invoice_lines ALTER COLUMN tenant_id SET DEFAULT decode(current_setting('app.tenant_id', true), 'hex');
+ALTER TABLE payments ALTER COLUMN tenant_id SET DEFAULT decode(current_setting('app.tenant_id', true), 'hex');
+ALTER TABLE payment_allocations ALTER COLUMN tenant_id SET DEFAULT decode(current_setting('app.tenant_id', true), 'hex');
+ALTER TABLE credit_notes ALTER COLUMN tenant_id SET DEFAULT decode(current_setting('app.tenant_id', true), 'hex');
+ALTER TABLE credit_note_lines ALTER COLUMN tenant_id SET DEFAULT decode(current_setting('app.tenant_id', true), 'hex');
+ALTER TABLE quotes ALTER COLUMN tenant_id SET DEFAULT decode(current_setting('app.tenant_id', true), 'hex');
+ALTER TABLE quote_lines ALTER COLUMN tenant_id SET DEFAULT decode(current_setting('app.tenant_id', true), 'hex');
+ALTER TABLE recurring_invoice_lines ALTER COLUMN tenant_id SET DEFAULT decode(current_setting('app.tenant_id', true), 'hex');
+ALTER TABLE number_sequences ALTER COLUMN tenant_id SET DEFAULT decode(current_setting('app.tenant_id', true), 'hex');
+
+-- Sequences and document numbers only have to be unique within a tenant.
+ALTER TABLE number_sequences
+DROP CONSTRAINT IF EXISTS number_sequences_pkey;
+
+ALTER TABLE number_sequences
+ADD PRIMARY KEY (tenant_id, series, period),
+ADD CONSTRAINT fk_tenant FOREIGN KEY (tenant_id) REFERENCES tenants(id);
+
+ALTER TABLE invoices
+DROP CONSTRAINT IF EXISTS invoices_number_key;
+
+ALTER TABLE credit_notes
+DROP CONSTRAINT IF EXISTS credit_notes_number_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoice_tenant_number ON invoices(tenant_id, number);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_credit_note_tenant_number ON credit_notes(tenant_id, number);
+
+-- Foreign keys are checked without row level security, so references must
+-- carry the tenant to stay inside it.
+ALTER TABLE invoice_lines
+DROP CONSTRAINT IF EXISTS invoice_lines_invoice_id_fkey,
+DROP CONSTRAINT IF EXISTS invoice_lines_product_id_fkey;
+
+ALTER TABLE invoices
+DROP CONSTRAINT IF EXISTS fk_customer,
+DROP CONSTRAINT IF EXISTS fk_recurring_invoice;
+
+ALTER TABLE payments
+DROP CONSTRAINT IF EXISTS fk_customer;
+
+ALTER TABLE payment_allocations
+DROP CONSTRAINT IF EXISTS fk_payment,
+DROP CONSTRAINT IF EXISTS fk_invoice;
+
+ALTER TABLE credit_note_lines
+DROP CONSTRAINT IF EXISTS fk_credit_note,
+DROP CONSTRAINT IF EXISTS fk_invoice_line;
+
+ALTER TABLE credit_notes
+DROP CONSTRAINT IF EXISTS fk_invoice,
+DROP CONSTRAINT IF EXISTS fk_customer;
+
+ALTER TABLE quote_lines
+DROP CONSTRAINT IF EXISTS quote_lines_quote_id_fkey,
+DROP CONSTRAINT IF EXISTS quote_lines_product_id_fkey;
+
+ALTER TABLE quotes
+DROP CONSTRAINT IF EXISTS fk_customer,
+DROP CONSTRAINT IF EXISTS fk_invoice;
+
+ALTER TABLE recurring_invoice_lines
+DROP CONSTRAINT IF EXISTS recurring_invoice_lines_recurring_invoice_id_fkey,
+DROP CONSTRAINT IF EXISTS recurring_invoice_lines_product_id_fkey;
+
+ALTER TABLE recurring_invoices
+DROP CONSTRAINT IF EXISTS fk_customer;
+
+ALTER TABLE stock_reservations
+DROP CONSTRAINT IF EXISTS fk_invoice;
+
+ALTER TABLE customers
+ADD CONSTRAINT fk_tenant FOREIGN KEY (tenant_id) REFERENCES tenants(id),
+ADD CONSTRAINT uq_customer_tenant UNIQUE (id, tenant_id);
+
+ALTER TABLE tax_rates
+ADD CONSTRAINT fk_tenant FOREIGN KEY (tenant_id) REFERENCES tenants(id);
+
+ALTER TABLE recurring_invoices
+ADD CONSTRAINT uq_recurring_invoice_tenant UNIQUE (id, tenant_id),
+ADD CONSTRAINT fk_customer
+	FOREIGN KEY (customer_id, tenant_id)
+	REFERENCES customers(id, tenant_id);
+
+ALTER TABLE invoices
+ADD CONSTRAINT fk_tenant FOREIGN KEY (tenant_id) REFERENCES tenants(id),
+ADD CONSTRAINT uq_invoice_tenant UNIQUE (id, tenant_id),
+ADD CONSTRAINT fk_customer
+	FOREIGN KEY (customer_id, tenant_id)
+	REFERENCES customers(id, tenant_id),
+ADD CONSTRAINT fk_recurring_invoice
+	FOREIGN KEY (recurring_invoice_id, tenant_id)
+	REFERENCES recurring_invoices(id, tenant_id);
+
+ALTER TABLE invoice_lines
+ADD CONSTRAINT uq_invoice_line_tenant UNIQUE (id, tenant_id),
+ADD CONSTRAINT fk_invoice
+	FOREIGN KEY (invoice_id, tenant_id)
+	REFERENCES invoices(id, tenant_id),
+ADD CONSTRAINT fk_product
+	FOREIGN KEY (product_id, tenant_id)
+	REFERENCES products(id, tenant_id);
+
+ALTER TABLE payments
+ADD CONSTRAINT uq_payment_tenant UNIQUE (id, tenant_id),
+ADD CONSTRAINT fk_customer
+	FOREIGN KEY (customer_id, tenant_id)
+	REFERENCES customers(id, tenant_id);
+
+ALTER TABLE payment_allocations
+ADD CONSTRAINT fk_payment
+	FOREIGN KEY (payment_id, tenant_id)
+	REFERENCES payments(id, tenant_id),
+ADD CONSTRAINT fk_invoice
+	FOREIGN KEY (invoice_id, tenant_id)
+	REFERENCES invoices(id, tenant_id);
+
+ALTER TABLE credit_notes
+ADD CONSTRAINT uq_credit_note_tenant UNIQUE (id, tenant_id),
+ADD CONSTRAINT fk_invoice
+	FOREIGN KEY (invoice_id, tenant_id)
+	REFERENCES invoices(id, tenant_id),
+ADD CONSTRAINT fk_customer
+	FOREIGN KEY (customer_id, tenant_id)
+	REFERENCES customers(id, tenant_id);
+
+ALTER TABLE credit_note_lines
+ADD CONSTRAINT fk_credit_note
+	FOREIGN KEY (credit_note_id, tenant_id)
+	REFERENCES credit_notes(id, tenant_id),
+ADD CONSTRAINT fk_invoice_line
+	FOREIGN KEY (invoice_line_id, tenant_id)
+	REFERENCES invoice_lines(id, tenant_id);
+
+ALTER TABLE quotes
+ADD CONSTRAINT uq_quote_tenant UNIQUE (id, tenant_id),
+ADD CONSTRAINT fk_customer
+	FOREIGN KEY (customer_id, tenant_id)
+	REFERENCES customers(id, tenant_id),
+ADD CONSTRAINT fk_invoice
+	FOREIGN KEY (invoice_id, tenant_id)
+	REFERENCES invoices(id, tenant_id);
+
+ALTER TABLE quote_lines
+ADD CONSTRAINT fk_quote
+	FOREIGN KEY (quote_id, tenant_id)
+	REFERENCES quotes(id, tenant_id),
+ADD CONSTRAINT fk_product
+	FOREIGN KEY (product_id, tenant_id)
+	REFERENCES products(id, tenant_id);
+
+ALTER TABLE recurring_invoice_lines
+ADD CONSTRAINT fk_recurring_invoice
+	FOREIGN KEY (recurring_invoice_id, tenant_id)
+	REFERENCES recurring_invoices(id, tenant_id),
+ADD CONSTRAINT fk_product
+	FOREIGN KEY (product_id, tenant_id)
+	REFERENCES products(id, tenant_id);
+
+ALTER TABLE stock_reservations
+ADD CONSTRAINT fk_invoice
+	FOREIGN KEY (invoice_id, tenant_id)
+	REFERENCES invoices(id, tenant_id);
+
+-- recurring_invoices stays outside of row level security so the scheduler
+-- can claim the due schedules of every tenant; it is read within the
+-- caller's tenant only and writes what a schedule generates for the
+-- schedule's tenant.
+ALTER TABLE customers ENABLE ROW LEVEL SECURITY;
+ALTER TABLE customers FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON customers
+	USING (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'))
+	WITH CHECK (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'));
+
+ALTER TABLE tax_rates ENABLE ROW LEVEL SECURITY;
+ALTER TABLE tax_rates FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON tax_rates
+	USING (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'))
+	WITH CHECK (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'));
+
+ALTER TABLE invoices ENABLE ROW LEVEL SECURITY;
+ALTER TABLE invoices FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON invoices
+	USING (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'))
+	WITH CHECK (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'));
+
+ALTER TABLE invoice_lines ENABLE ROW LEVEL SECURITY;
+ALTER TABLE invoice_lines FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON invoice_lines
+	USING (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'))
+	WITH CHECK (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'));
+
+ALTER TABLE payments ENABLE ROW LEVEL SECURITY;
+ALTER TABLE payments FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON payments
+	USING (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'))
+	WITH CHECK (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'));
+
+ALTER TABLE payment_allocations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE payment_allocations FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON payment_allocations
+	USING (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'))
+	WITH CHECK (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'));
+
+ALTER TABLE credit_notes ENABLE ROW LEVEL SECURITY;
+ALTER TABLE credit_notes FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON credit_notes
+	USING (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'))
+	WITH CHECK (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'));
+
+ALTER TABLE credit_note_lines ENABLE ROW LEVEL SECURITY;
+ALTER TABLE credit_note_lines FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON credit_note_lines
+	USING (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'))
+	WITH CHECK (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'));
+
+ALTER TABLE quotes ENABLE ROW LEVEL SECURITY;
+ALTER TABLE quotes FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON quotes
+	USING (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'))
+	WITH CHECK (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'));
+
+ALTER TABLE quote_lines ENABLE ROW LEVEL SECURITY;
+ALTER TABLE quote_lines FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON quote_lines
+	USING (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'))
+	WITH CHECK (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'));
+
+ALTER TABLE recurring_invoice_lines ENABLE ROW LEVEL SECURITY;
+ALTER TABLE recurring_invoice_lines FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON recurring_invoice_lines
+	USING (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'))
+	WITH CHECK (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'));
+
+ALTER TABLE number_sequences ENABLE ROW LEVEL SECURITY;
+ALTER TABLE number_sequences FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON number_sequences
+	USING (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'))
+	WITH CHECK (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'));
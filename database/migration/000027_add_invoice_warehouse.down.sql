@@ -0,0 +1,3 @@
+ALTER TABLE invoices
+DROP CONSTRAINT IF EXISTS fk_warehouse,
+DROP COLUMN IF EXISTS warehouse_id;
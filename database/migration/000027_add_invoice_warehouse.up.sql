@@ -0,0 +1,7 @@
+-- The warehouse an invoice sells its goods from. Invoices without one sell
+-- from their tenant's default warehouse.
+ALTER TABLE invoices
+ADD COLUMN warehouse_id BYTEA,
+ADD CONSTRAINT fk_warehouse
+	FOREIGN KEY (warehouse_id, tenant_id)
+	REFERENCES warehouses(id, tenant_id);
@@ -0,0 +1,4 @@
+-- Every tenant prints its own details on its invoices. Until they are set,
+-- the server fills in the default tenant's from its configuration.
+ALTER TABLE tenants
+ADD COLUMN seller JSONB NOT NULL DEFAULT '{}'::JSONB;
@@ -0,0 +1,10 @@
+DROP FUNCTION IF EXISTS release_expired_stock_reservations(TIMESTAMP);
+DROP FUNCTION IF EXISTS claim_due_recurring_invoice(TIMESTAMP, BYTEA[]);
+
+DROP POLICY IF EXISTS tenant_isolation ON stock_reservations;
+ALTER TABLE stock_reservations NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE stock_reservations DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON recurring_invoices;
+ALTER TABLE recurring_invoices NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE recurring_invoices DISABLE ROW LEVEL SECURITY;
@@ -0,0 +1,54 @@
+-- recurring_invoices and stock_reservations were left outside of row level
+-- security so the scheduler and the reservation sweeper could reach every
+-- tenant's rows. Both are now isolated like the other tables, and those two
+-- jobs go through the functions below instead.
+ALTER TABLE recurring_invoices ENABLE ROW LEVEL SECURITY;
+ALTER TABLE recurring_invoices FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON recurring_invoices
+	USING (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'))
+	WITH CHECK (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'));
+
+ALTER TABLE stock_reservations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE stock_reservations FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON stock_reservations
+	USING (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'))
+	WITH CHECK (tenant_id = decode(current_setting('app.tenant_id', true), 'hex'));
+
+-- The only ways past row level security for the server. They run as their
+-- owner, the superuser the migrations run as, and do no more than their
+-- job needs.
+--
+-- claim_due_recurring_invoice locks the next schedule of any tenant that is
+-- due by due_by and not skipped, and only tells which one it is and whose; the
+-- scheduler then scopes its transaction to that tenant to read and run it.
+CREATE OR REPLACE FUNCTION claim_due_recurring_invoice(due_by TIMESTAMP, skipped BYTEA[])
+RETURNS TABLE (id BYTEA, tenant_id BYTEA)
+LANGUAGE sql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+	SELECT r.id, r.tenant_id
+	FROM recurring_invoices r
+	WHERE r.deleted_at IS NULL
+		AND r.next_run_at <= due_by
+		AND NOT (r.id = ANY(skipped))
+	ORDER BY r.next_run_at
+	LIMIT 1
+	FOR UPDATE SKIP LOCKED;
+$$;
+
+-- release_expired_stock_reservations deletes the reservations of every
+-- tenant that expired by expired_by, and answers how many.
+CREATE OR REPLACE FUNCTION release_expired_stock_reservations(expired_by TIMESTAMP)
+RETURNS BIGINT
+LANGUAGE sql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+	WITH released AS (
+		DELETE FROM stock_reservations
+		WHERE expires_at <= expired_by
+		RETURNING 1
+	)
+	SELECT count(*) FROM released;
+$$;
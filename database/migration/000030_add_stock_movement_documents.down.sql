@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_stock_movement_credit_note;
+DROP INDEX IF EXISTS idx_stock_movement_invoice;
+
+ALTER TABLE stock_movements
+DROP CONSTRAINT IF EXISTS fk_credit_note,
+DROP CONSTRAINT IF EXISTS fk_invoice,
+DROP COLUMN IF EXISTS credit_note_id,
+DROP COLUMN IF EXISTS invoice_id;
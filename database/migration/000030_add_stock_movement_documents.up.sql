@@ -0,0 +1,45 @@
+-- Movements booked for an invoice or a credit note name it, so what an
+-- invoice sold and got back is found by id rather than by the free-text
+-- reference. A credit note's returns name both the note and its invoice.
+ALTER TABLE stock_movements
+ADD COLUMN invoice_id BYTEA,
+ADD COLUMN credit_note_id BYTEA,
+ADD CONSTRAINT fk_invoice
+	FOREIGN KEY (invoice_id, tenant_id)
+	REFERENCES invoices(id, tenant_id),
+ADD CONSTRAINT fk_credit_note
+	FOREIGN KEY (credit_note_id, tenant_id)
+	REFERENCES credit_notes(id, tenant_id);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movement_invoice ON stock_movements(invoice_id) WHERE invoice_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_stock_movement_credit_note ON stock_movements(credit_note_id) WHERE credit_note_id IS NOT NULL;
+
+-- Link the movements booked before from their references. The ledger is
+-- append-only and under row level security, both lifted meanwhile.
+ALTER TABLE stock_movements NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE invoices NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE credit_notes NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE stock_movements DISABLE TRIGGER trg_stock_movement_append_only;
+
+UPDATE stock_movements m
+SET
+	credit_note_id = c.id,
+	invoice_id = c.invoice_id
+FROM credit_notes c
+WHERE m.movement_type = 'return'
+	AND m.reason <> 'invoice voided'
+	AND m.reference = c.number
+	AND m.tenant_id = c.tenant_id;
+
+UPDATE stock_movements m
+SET invoice_id = i.id
+FROM invoices i
+WHERE m.movement_type IN ('sale', 'return')
+	AND m.credit_note_id IS NULL
+	AND m.reference = i.number
+	AND m.tenant_id = i.tenant_id;
+
+ALTER TABLE stock_movements ENABLE TRIGGER trg_stock_movement_append_only;
+ALTER TABLE credit_notes FORCE ROW LEVEL SECURITY;
+ALTER TABLE invoices FORCE ROW LEVEL SECURITY;
+ALTER TABLE stock_movements FORCE ROW LEVEL SECURITY;
@@ -0,0 +1,11 @@
+DELETE FROM payment_allocations
+WHERE payment_id IN (SELECT id FROM payments WHERE method = 'credit_note');
+DELETE FROM payments WHERE method = 'credit_note';
+
+ALTER TABLE payments
+DROP CONSTRAINT IF EXISTS payments_method_check,
+ADD CONSTRAINT payments_method_check
+	CHECK (method IN ('bank_transfer', 'card', 'cash', 'cheque', 'other'));
+
+ALTER TABLE credit_note_lines
+DROP COLUMN IF EXISTS tax_amount;
@@ -0,0 +1,26 @@
+-- Credit note lines keep the tax they took back, so that the credit
+-- completing an invoice line can take whatever tax is left of it instead of
+-- recalculating a rounded share. Lines credited before took the tax
+-- calculated from their rate.
+ALTER TABLE credit_note_lines
+ADD COLUMN tax_amount NUMERIC(12,2);
+
+ALTER TABLE credit_note_lines NO FORCE ROW LEVEL SECURITY;
+
+UPDATE credit_note_lines
+SET tax_amount = CASE WHEN price_includes_tax
+	THEN amount * quantity - ROUND(amount * quantity * 10000.0 / (10000 + tax_rate), 2)
+	ELSE ROUND(amount * quantity * tax_rate / 10000.0, 2)
+END;
+
+ALTER TABLE credit_note_lines FORCE ROW LEVEL SECURITY;
+
+ALTER TABLE credit_note_lines
+ALTER COLUMN tax_amount SET NOT NULL;
+
+-- What a credit note owes back beyond the invoice balance is held as the
+-- customer's credit, in a payment of its own.
+ALTER TABLE payments
+DROP CONSTRAINT IF EXISTS payments_method_check,
+ADD CONSTRAINT payments_method_check
+	CHECK (method IN ('bank_transfer', 'card', 'cash', 'cheque', 'other', 'credit_note'));
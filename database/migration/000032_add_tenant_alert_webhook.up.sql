@@ -0,0 +1,4 @@
+-- Every tenant posts its stock alerts to its own webhook. Without one, the
+-- alerts go to the server's configured webhook, or only to its log.
+ALTER TABLE tenants
+ADD COLUMN alert_webhook TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,118 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+type APIKeyQuerier struct {
+	pool *pgxpool.Pool
+}
+
+const apiKeyColumns = `
+	id,
+	created_at,
+	tenant_id,
+	name,
+	scopes,
+	secret_hash,
+	expires_at,
+	last_used_at,
+	revoked_at
+`
+
+func scanAPIKey(row pgx.Row, item *model.APIKey) error {
+	return row.Scan(
+		&item.ID,
+		&item.CreatedAt,
+		&item.TenantID,
+		&item.Name,
+		&item.Scopes,
+		&item.SecretHash,
+		&item.ExpiresAt,
+		&item.LastUsedAt,
+		&item.RevokedAt,
+	)
+}
+
+// Fetch lists the API keys of a tenant, revoked ones included.
+func (q *APIKeyQuerier) Fetch(ctx context.Context, tenantID ulid.ULID) (res APIKeyList, err error) {
+	rows, err := q.pool.Query(
+		ctx,
+		`SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE tenant_id = $1
+		ORDER BY id;`,
+		tenantID,
+	)
+	if err != nil {
+		return emptyAPIKeys, err
+	}
+	defer rows.Close()
+
+	var items []model.APIKey
+	for rows.Next() {
+		var item model.APIKey
+		if err := scanAPIKey(rows, &item); err != nil {
+			return emptyAPIKeys, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return emptyAPIKeys, err
+	}
+
+	if len(items) == 0 {
+		return emptyAPIKeys, nil
+	}
+
+	return APIKeyList{
+		Count: len(items),
+		Data:  items,
+	}, nil
+}
+
+func (q *APIKeyQuerier) GetOneByID(ctx context.Context, id ulid.ULID) (res model.APIKey, err error) {
+	row := q.pool.QueryRow(
+		ctx,
+		`SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE id = $1;`,
+		id,
+	)
+	var item model.APIKey
+	if err := scanAPIKey(row, &item); err != nil {
+		if err == pgx.ErrNoRows {
+			return item, model.ErrAPIKeyNotFound
+		}
+		return item, err
+	}
+	return item, nil
+}
+
+type APIKeyList struct {
+	Count int            `json:"count"`
+	Data  []model.APIKey `json:"data"`
+}
+
+var emptyAPIKeys = APIKeyList{
+	Count: 0,
+	Data:  []model.APIKey{},
+}
+
+type APIKeyReadModel interface {
+	Fetch(ctx context.Context, tenantID ulid.ULID) (res APIKeyList, err error)
+	GetOneByID(ctx context.Context, id ulid.ULID) (res model.APIKey, err error)
+}
+
+func NewAPIKeyReadModel(
+	pool *pgxpool.Pool,
+) APIKeyReadModel {
+	return &APIKeyQuerier{
+		pool: pool,
+	}
+}
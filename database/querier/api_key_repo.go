@@ -0,0 +1,92 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+func (q *APIKeyQuerier) Save(ctx context.Context, data model.APIKey) error {
+	query := `
+		INSERT INTO api_keys (
+			id,
+			created_at,
+			tenant_id,
+			name,
+			scopes,
+			secret_hash,
+			expires_at
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7
+		);
+	`
+	_, err := q.pool.Exec(
+		ctx,
+		query,
+		data.ID,
+		data.CreatedAt,
+		data.TenantID,
+		data.Name,
+		data.Scopes,
+		data.SecretHash,
+		data.ExpiresAt,
+	)
+
+	return err
+}
+
+func (q *APIKeyQuerier) Revoke(ctx context.Context, data model.APIKey) error {
+	query := `
+		UPDATE api_keys
+		SET revoked_at = $2
+		WHERE id = $1;
+	`
+	_, err := q.pool.Exec(
+		ctx,
+		query,
+		data.ID,
+		data.RevokedAt,
+	)
+
+	return err
+}
+
+// MarkUsed records that the key authenticated a request at at.
+func (q *APIKeyQuerier) MarkUsed(ctx context.Context, id ulid.ULID, at time.Time) error {
+	query := `
+		UPDATE api_keys
+		SET last_used_at = $2
+		WHERE id = $1;
+	`
+	_, err := q.pool.Exec(
+		ctx,
+		query,
+		id,
+		at,
+	)
+
+	return err
+}
+
+type APIKeyWriteModel interface {
+	Save(ctx context.Context, data model.APIKey) error
+	Revoke(ctx context.Context, data model.APIKey) error
+	MarkUsed(ctx context.Context, id ulid.ULID, at time.Time) error
+}
+
+func NewAPIKeyWriteModel(
+	pool *pgxpool.Pool,
+) APIKeyWriteModel {
+	return &APIKeyQuerier{
+		pool: pool,
+	}
+}
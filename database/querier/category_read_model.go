@@ -3,7 +3,7 @@ package querier
 import (
 	"context"
 	"flukis/invokiss/app/model"
-	"time"
+	"strings"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -15,27 +15,43 @@ type CategoryQuerier struct {
 	pool *pgxpool.Pool
 }
 
-func (q *CategoryQuerier) Fetch(ctx context.Context) (res CategoryList, err error) {
-	var itemCount int
+// CategoryFilter narrows and orders a category list. Sort is name or
+// created_at; soft-deleted categories are only listed with IncludeDeleted.
+type CategoryFilter struct {
+	Page
 
-	row := q.pool.QueryRow(
-		ctx,
-		`
-			SELECT
-				COUNT(id) as c
-			FROM categories;
+	IncludeDeleted bool
+	CreatedSince   null.Time
+}
 
-		`,
-	)
-	if err := row.Scan(&itemCount); err != nil {
-		return emptyCategories, err
+var categorySorts = map[string]string{
+	"name":       "name",
+	"created_at": "created_at",
+}
+
+func (q *CategoryQuerier) Fetch(ctx context.Context, filter CategoryFilter) (res CategoryList, err error) {
+	var args queryArgs
+	var conds = []string{"TRUE"}
+
+	if !filter.IncludeDeleted {
+		conds = append(conds, "deleted_at IS NULL")
+	}
+	if filter.CreatedSince.Valid {
+		conds = append(conds, "created_at >= "+args.add(filter.CreatedSince))
 	}
 
-	if itemCount == 0 {
-		return emptyCategories, nil
+	sortExpr, ok := categorySorts[filter.Sort]
+	if !ok {
+		sortExpr = "id"
+	}
+	after, order := filter.keyset(&args, sortExpr, "id", func(id string) string {
+		return `SELECT ` + sortExpr + `, id FROM categories WHERE id = ` + id
+	})
+	if after != "" {
+		conds = append(conds, after)
 	}
 
-	items := make([]model.Category, itemCount)
+	limit := filter.limit()
 	rows, err := q.pool.Query(
 		ctx,
 		`
@@ -45,55 +61,53 @@ func (q *CategoryQuerier) Fetch(ctx context.Context) (res CategoryList, err erro
 				name,
 				description,
 				updated_at,
-				deleted_at
+				deleted_at,
+				parent_id
 			FROM categories
-			ORDER BY id;
+			WHERE `+strings.Join(conds, " AND ")+`
+			ORDER BY `+order+`
+			LIMIT `+args.add(limit+1)+`;
 		`,
+		args...,
 	)
-
 	if err != nil {
 		return emptyCategories, err
 	}
-
 	defer rows.Close()
-	var i int
-	for i = range items {
-		var id ulid.ULID
-		var createdAt time.Time
-		var name string
-		var description string
-		var updateAt null.Time
-		var deletedAt null.Time
-		if !rows.Next() {
-			break
-		}
+
+	var items []model.Category
+	for rows.Next() {
+		var item model.Category
 		if err := rows.Scan(
-			&id,
-			&createdAt,
-			&name,
-			&description,
-			&updateAt,
-			&deletedAt,
+			&item.ID,
+			&item.CreatedAt,
+			&item.Name,
+			&item.Description,
+			&item.UpdatedAt,
+			&item.DeletedAt,
+			&item.ParentID,
 		); err != nil {
 			return emptyCategories, err
 		}
-
-		items[i] = model.Category{
-			ID:          id,
-			CreatedAt:   createdAt,
-			UpdatedAt:   updateAt,
-			DeletedAt:   deletedAt,
-			Name:        name,
-			Description: description,
-		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return emptyCategories, err
 	}
 
-	list := CategoryList{
-		Count: itemCount,
-		Data:  items,
+	if len(items) == 0 {
+		return emptyCategories, nil
 	}
 
-	return list, nil
+	items, cursor := pageCursor(items, limit, func(c model.Category) ulid.ULID {
+		return c.ID
+	})
+
+	return CategoryList{
+		Count:  len(items),
+		Data:   items,
+		Cursor: cursor,
+	}, nil
 }
 
 func (q *CategoryQuerier) GetOneByID(ctx context.Context, id ulid.ULID) (res model.Category, err error) {
@@ -104,7 +118,8 @@ func (q *CategoryQuerier) GetOneByID(ctx context.Context, id ulid.ULID) (res mod
 		name,
 		description,
 		updated_at,
-		deleted_at
+		deleted_at,
+		parent_id
 	FROM categories
 	WHERE id = $1;
 `
@@ -121,6 +136,7 @@ func (q *CategoryQuerier) GetOneByID(ctx context.Context, id ulid.ULID) (res mod
 		&item.Description,
 		&item.UpdatedAt,
 		&item.DeletedAt,
+		&item.ParentID,
 	); err != nil {
 		if err == pgx.ErrNoRows {
 			return item, model.ErrCategoryNotFound
@@ -133,9 +149,57 @@ func (q *CategoryQuerier) GetOneByID(ctx context.Context, id ulid.ULID) (res mod
 	return item, nil
 }
 
+// Tree returns all active categories nested under their parents, siblings
+// ordered by name.
+func (q *CategoryQuerier) Tree(ctx context.Context) ([]model.CategoryNode, error) {
+	rows, err := q.pool.Query(
+		ctx,
+		`
+			SELECT
+				id,
+				created_at,
+				name,
+				description,
+				updated_at,
+				deleted_at,
+				parent_id
+			FROM categories
+			WHERE deleted_at IS NULL
+			ORDER BY name, id;
+		`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []model.Category
+	for rows.Next() {
+		var item model.Category
+		if err := rows.Scan(
+			&item.ID,
+			&item.CreatedAt,
+			&item.Name,
+			&item.Description,
+			&item.UpdatedAt,
+			&item.DeletedAt,
+			&item.ParentID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return model.BuildCategoryTree(items), nil
+}
+
 type CategoryList struct {
 	Count int              `json:"count"`
 	Data  []model.Category `json:"data"`
+	Cursor
 }
 
 var emptyCategories = CategoryList{
@@ -144,8 +208,9 @@ var emptyCategories = CategoryList{
 }
 
 type CategoryReadModel interface {
-	Fetch(ctx context.Context) (res CategoryList, err error)
+	Fetch(ctx context.Context, filter CategoryFilter) (res CategoryList, err error)
 	GetOneByID(ctx context.Context, id ulid.ULID) (res model.Category, err error)
+	Tree(ctx context.Context) ([]model.CategoryNode, error)
 }
 
 func NewCategoryReadModel(
@@ -2,23 +2,31 @@ package querier
 
 import (
 	"context"
+	"errors"
 	"flukis/invokiss/app/model"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
 )
 
+// Save creates or updates the category. The parent of an existing category
+// is left alone; it changes through Move, which keeps the tree acyclic.
 func (q *CategoryQuerier) Save(ctx context.Context, data model.Category) error {
 	query := `
 		INSERT INTO categories (
 			id,
 			created_at,
 			name,
-			description
+			description,
+			parent_id
 		) VALUES (
 			$1,
 			$2,
 			$3,
-			$4
+			$4,
+			$5
 		) ON CONFLICT(id)
 		DO UPDATE SET
 			created_at = EXCLUDED.created_at,
@@ -33,6 +41,7 @@ func (q *CategoryQuerier) Save(ctx context.Context, data model.Category) error {
 		data.CreatedAt,
 		data.Name,
 		data.Description,
+		data.ParentID,
 	)
 
 	if err != nil {
@@ -42,28 +51,195 @@ func (q *CategoryQuerier) Save(ctx context.Context, data model.Category) error {
 	return nil
 }
 
-func (q *CategoryQuerier) Delete(ctx context.Context, data model.Category) error {
-	query := `
-		UPDATE categories
-		SET deleted_at = CURRENT_TIMESTAMP
-		WHERE id = $1;
-	`
-	_, err := q.pool.Exec(
+// Delete soft-deletes the category. Without reassignTo it refuses while
+// products are still attached; with it, the products are moved to that
+// category in the same transaction.
+func (q *CategoryQuerier) Delete(ctx context.Context, data model.Category, reassignTo *ulid.ULID) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockCategory(ctx, tx, data.ID); err != nil {
+		return err
+	}
+
+	var hasChildren bool
+	row := tx.QueryRow(
 		ctx,
-		query,
+		`SELECT EXISTS (
+			SELECT 1 FROM categories WHERE parent_id = $1 AND deleted_at IS NULL
+		);`,
 		data.ID,
 	)
+	if err := row.Scan(&hasChildren); err != nil {
+		return err
+	}
+	if hasChildren {
+		return model.ErrCategoryHasChildren
+	}
+
+	if reassignTo == nil {
+		var attached bool
+		row := tx.QueryRow(
+			ctx,
+			`SELECT EXISTS (
+				SELECT 1 FROM category_products WHERE category_id = $1
+			);`,
+			data.ID,
+		)
+		if err := row.Scan(&attached); err != nil {
+			return err
+		}
+		if attached {
+			return model.ErrCategoryHasProducts
+		}
+	} else {
+		if *reassignTo == data.ID {
+			return model.ErrCategoryReassignTarget
+		}
+		err := lockCategory(ctx, tx, *reassignTo)
+		if errors.Is(err, model.ErrCategoryNotFound) || errors.Is(err, model.ErrCategoryAlreadyDeleted) {
+			return model.ErrCategoryReassignTarget
+		}
+		if err != nil {
+			return err
+		}
+
+		// Products already in the target category keep their one row.
+		_, err = tx.Exec(
+			ctx,
+			`INSERT INTO category_products (category_id, product_id)
+			SELECT $2, product_id
+			FROM category_products
+			WHERE category_id = $1
+			ON CONFLICT DO NOTHING;`,
+			data.ID,
+			*reassignTo,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			ctx,
+			`DELETE FROM category_products WHERE category_id = $1;`,
+			data.ID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE categories
+		SET deleted_at = CURRENT_TIMESTAMP
+		WHERE id = $1;`,
+		data.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Move nests the category, and with it its whole subtree, under parentID,
+// or moves it to the top when parentID is nil. Moving a category below
+// itself or one of its descendants is refused.
+func (q *CategoryQuerier) Move(ctx context.Context, id ulid.ULID, parentID *ulid.ULID) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Two moves checked side by side could each pass and still close a
+	// loop together, so moves within a tenant take turns.
+	_, err = tx.Exec(
+		ctx,
+		`SELECT pg_advisory_xact_lock(hashtext('category_tree:' || current_setting('app.tenant_id', true)));`,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := lockCategory(ctx, tx, id); err != nil {
+		return err
+	}
 
+	if parentID != nil {
+		err := lockCategory(ctx, tx, *parentID)
+		if errors.Is(err, model.ErrCategoryAlreadyDeleted) {
+			err = model.ErrCategoryNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var cycle bool
+		row := tx.QueryRow(
+			ctx,
+			`WITH RECURSIVE ancestors AS (
+				SELECT id, parent_id FROM categories WHERE id = $2
+				UNION
+				SELECT c.id, c.parent_id
+				FROM categories c
+				JOIN ancestors a ON c.id = a.parent_id
+			)
+			SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $1);`,
+			id,
+			*parentID,
+		)
+		if err := row.Scan(&cycle); err != nil {
+			return err
+		}
+		if cycle {
+			return model.ErrCategoryCycle
+		}
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE categories
+		SET parent_id = $2,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1;`,
+		id,
+		parentID,
+	)
 	if err != nil {
 		return err
 	}
 
+	return tx.Commit(ctx)
+}
+
+// lockCategory locks an active category for the rest of the transaction.
+func lockCategory(ctx context.Context, tx pgx.Tx, id ulid.ULID) error {
+	var deletedAt null.Time
+	row := tx.QueryRow(
+		ctx,
+		`SELECT deleted_at FROM categories WHERE id = $1 FOR UPDATE;`,
+		id,
+	)
+	if err := row.Scan(&deletedAt); err != nil {
+		if err == pgx.ErrNoRows {
+			return model.ErrCategoryNotFound
+		}
+		return err
+	}
+	if deletedAt.Valid {
+		return model.ErrCategoryAlreadyDeleted
+	}
 	return nil
 }
 
 type CategoryWriteModel interface {
 	Save(ctx context.Context, data model.Category) error
-	Delete(ctx context.Context, data model.Category) error
+	Delete(ctx context.Context, data model.Category, reassignTo *ulid.ULID) error
+	Move(ctx context.Context, id ulid.ULID, parentID *ulid.ULID) error
 }
 
 func NewCategoryWriteModel(
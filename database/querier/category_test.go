@@ -0,0 +1,151 @@
+package querier
+
+import (
+	"errors"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/app/notify"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+// saveCategories stores one category per name, each nested in the one
+// before it.
+func saveCategories(t *testing.T, pool *pgxpool.Pool, f tenantFixture, names ...string) []model.Category {
+	t.Helper()
+
+	var (
+		parentID   *ulid.ULID
+		categories []model.Category
+	)
+	for _, name := range names {
+		c := model.NewCategory(parentID, name, "")
+		if err := NewCategoryWriteModel(pool).Save(f.ctx, c); err != nil {
+			t.Fatal(err)
+		}
+		categories = append(categories, c)
+		parentID = &c.ID
+	}
+	return categories
+}
+
+func TestCategoryMoveRefusesCycles(t *testing.T) {
+	pool := testPool(t)
+	f := newTenantFixture(t, pool, "Category moves")
+	other := newTenantFixture(t, pool, "Category moves elsewhere")
+
+	chain := saveCategories(t, pool, f, "Root", "Child", "Grandchild")
+	root, child, grandchild := chain[0], chain[1], chain[2]
+	deleted := saveCategories(t, pool, f, "Deleted")[0]
+	if err := NewCategoryWriteModel(pool).Delete(f.ctx, deleted, nil); err != nil {
+		t.Fatal(err)
+	}
+	elsewhere := saveCategories(t, pool, other, "Elsewhere")[0]
+
+	write := NewCategoryWriteModel(pool)
+	tests := []struct {
+		name     string
+		id       ulid.ULID
+		parentID *ulid.ULID
+		err      error
+	}{
+		{"below itself", root.ID, &root.ID, model.ErrCategoryCycle},
+		{"below its child", root.ID, &child.ID, model.ErrCategoryCycle},
+		{"below its grandchild", root.ID, &grandchild.ID, model.ErrCategoryCycle},
+		{"child below its own child", child.ID, &grandchild.ID, model.ErrCategoryCycle},
+		{"below a deleted category", child.ID, &deleted.ID, model.ErrCategoryNotFound},
+		{"below another tenant's category", child.ID, &elsewhere.ID, model.ErrCategoryNotFound},
+		{"unknown category", ulid.Make(), &root.ID, model.ErrCategoryNotFound},
+		{"grandchild to the top", grandchild.ID, nil, nil},
+		{"root below its former grandchild", root.ID, &grandchild.ID, nil},
+		{"former grandchild below its former child", grandchild.ID, &child.ID, model.ErrCategoryCycle},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := write.Move(f.ctx, tt.id, tt.parentID); !errors.Is(err, tt.err) {
+				t.Fatalf("Move() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	moved, err := NewCategoryReadModel(pool).GetOneByID(f.ctx, root.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.ParentID == nil || *moved.ParentID != grandchild.ID {
+		t.Errorf("root parent = %v, want %s", moved.ParentID, grandchild.ID)
+	}
+	top, err := NewCategoryReadModel(pool).GetOneByID(f.ctx, grandchild.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if top.ParentID != nil {
+		t.Errorf("grandchild parent = %s, want none", top.ParentID)
+	}
+}
+
+func TestCategoryDelete(t *testing.T) {
+	pool := testPool(t)
+	f := newTenantFixture(t, pool, "Category deletes")
+	writeCategory := NewCategoryWriteModel(pool)
+	writeProduct := NewProductWriteModel(pool, notify.NewLogNotifier())
+
+	chain := saveCategories(t, pool, f, "Parent", "Child")
+	parent, child := chain[0], chain[1]
+	target := saveCategories(t, pool, f, "Target")[0]
+	gone := saveCategories(t, pool, f, "Gone")[0]
+	if err := writeCategory.Delete(f.ctx, gone, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The product is in both the child and the target, and stays in the
+	// target once when the child's products are moved there.
+	if err := writeProduct.AssignCategories(f.ctx, f.product.ID, []ulid.ULID{child.ID, target.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	missing := ulid.Make()
+	tests := []struct {
+		name       string
+		category   model.Category
+		reassignTo *ulid.ULID
+		err        error
+	}{
+		{"with subcategories", parent, nil, model.ErrCategoryHasChildren},
+		{"with products", child, nil, model.ErrCategoryHasProducts},
+		{"moving products to itself", child, &child.ID, model.ErrCategoryReassignTarget},
+		{"moving products to a deleted category", child, &gone.ID, model.ErrCategoryReassignTarget},
+		{"moving products to an unknown category", child, &missing, model.ErrCategoryReassignTarget},
+		{"moving products to the target", child, &target.ID, nil},
+		{"once the subcategory is gone", parent, nil, nil},
+		{"again", child, &target.ID, model.ErrCategoryAlreadyDeleted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := writeCategory.Delete(f.ctx, tt.category, tt.reassignTo); !errors.Is(err, tt.err) {
+				t.Fatalf("Delete() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	product, err := NewProductReadModel(pool).GetOneByID(f.ctx, f.product.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(product.Categories) != 1 || product.Categories[0].ID != target.ID {
+		t.Errorf("product categories = %+v, want only %s", product.Categories, target.ID)
+	}
+
+	var rows int
+	if err := pool.QueryRow(
+		f.ctx,
+		`SELECT COUNT(*) FROM category_products WHERE product_id = $1;`,
+		f.product.ID,
+	).Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 1 {
+		t.Errorf("product has %d category rows, want 1", rows)
+	}
+}
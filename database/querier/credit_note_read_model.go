@@ -0,0 +1,244 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/app/notify"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+type CreditNoteQuerier struct {
+	pool             *pgxpool.Pool
+	creditNoteNumber model.NumberFormat
+	notifier         notify.Notifier
+}
+
+const creditNoteColumns = `
+	id,
+	created_at,
+	updated_at,
+	deleted_at,
+	number,
+	invoice_id,
+	customer_id,
+	bill_to,
+	currency,
+	reason,
+	restock,
+	issued_at,
+	applied_amount
+`
+
+func scanCreditNote(row pgx.Row, item *model.CreditNote) error {
+	err := row.Scan(
+		&item.ID,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+		&item.DeletedAt,
+		&item.Number,
+		&item.InvoiceID,
+		&item.CustomerID,
+		&item.BillTo,
+		&item.Currency,
+		&item.Reason,
+		&item.Restock,
+		&item.IssuedAt,
+		&item.Applied,
+	)
+	item.Applied.Currency = item.Currency
+	return err
+}
+
+func (q *CreditNoteQuerier) Fetch(ctx context.Context) (res CreditNoteList, err error) {
+	rows, err := q.pool.Query(
+		ctx,
+		`SELECT `+creditNoteColumns+`
+		FROM credit_notes
+		WHERE deleted_at IS NULL
+		ORDER BY id;`,
+	)
+	if err != nil {
+		return emptyCreditNotes, err
+	}
+	defer rows.Close()
+
+	var items []model.CreditNote
+	for rows.Next() {
+		var item model.CreditNote
+		if err := scanCreditNote(rows, &item); err != nil {
+			return emptyCreditNotes, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return emptyCreditNotes, err
+	}
+
+	if len(items) == 0 {
+		return emptyCreditNotes, nil
+	}
+
+	var ids = make([]ulid.ULID, len(items))
+	for idx := range items {
+		ids[idx] = items[idx].ID
+	}
+
+	lines, err := fetchCreditNoteLines(ctx, q.pool, ids)
+	if err != nil {
+		return emptyCreditNotes, err
+	}
+
+	for idx := range items {
+		items[idx].Lines = lines[items[idx].ID]
+	}
+
+	list := CreditNoteList{
+		Count: len(items),
+		Data:  items,
+	}
+
+	return list, nil
+}
+
+func (q *CreditNoteQuerier) GetOneByID(ctx context.Context, id ulid.ULID) (res model.CreditNote, err error) {
+	row := q.pool.QueryRow(
+		ctx,
+		`SELECT `+creditNoteColumns+`
+		FROM credit_notes
+		WHERE id = $1;`,
+		id,
+	)
+	var item model.CreditNote
+	if err := scanCreditNote(row, &item); err != nil {
+		if err == pgx.ErrNoRows {
+			return item, model.ErrCreditNoteNotFound
+		}
+		return item, err
+	}
+	if item.DeletedAt.Valid {
+		return item, model.ErrCreditNoteAlreadyDeleted
+	}
+
+	lines, err := fetchCreditNoteLines(ctx, q.pool, []ulid.ULID{id})
+	if err != nil {
+		return item, err
+	}
+	item.Lines = lines[id]
+
+	return item, nil
+}
+
+func fetchCreditNoteLines(ctx context.Context, db dbtx, creditNoteIds []ulid.ULID) (map[ulid.ULID][]model.CreditNoteLine, error) {
+	rows, err := db.Query(ctx, `
+		SELECT
+			id,
+			credit_note_id,
+			invoice_line_id,
+			product_id,
+			sku,
+			name,
+			amount,
+			currency,
+			quantity,
+			tax_class,
+			tax_name,
+			tax_rate,
+			price_includes_tax,
+			tax_amount
+		FROM credit_note_lines
+		WHERE credit_note_id = ANY($1::BYTEA[])
+		ORDER BY credit_note_id, position;
+	`, creditNoteIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines = make(map[ulid.ULID][]model.CreditNoteLine, len(creditNoteIds))
+	for rows.Next() {
+		var l model.CreditNoteLine
+		if err := rows.Scan(
+			&l.ID,
+			&l.CreditNoteID,
+			&l.InvoiceLineID,
+			&l.ProductID,
+			&l.Sku,
+			&l.Name,
+			&l.Amount,
+			&l.Amount.Currency,
+			&l.Quantity,
+			&l.TaxClass,
+			&l.TaxName,
+			&l.TaxRate,
+			&l.PriceIncludesTax,
+			&l.TaxAmount,
+		); err != nil {
+			return nil, err
+		}
+		l.TaxAmount.Currency = l.Amount.Currency
+		lines[l.CreditNoteID] = append(lines[l.CreditNoteID], l)
+	}
+
+	return lines, rows.Err()
+}
+
+// creditedLines sums, per invoice line, what earlier credit notes have
+// already credited.
+func creditedLines(ctx context.Context, db dbtx, invoiceID ulid.ULID) (map[ulid.ULID]model.Credited, error) {
+	rows, err := db.Query(ctx, `
+		SELECT
+			l.invoice_line_id,
+			SUM(l.quantity),
+			SUM(l.tax_amount),
+			MIN(l.currency)
+		FROM credit_note_lines l
+		JOIN credit_notes c ON c.id = l.credit_note_id
+		WHERE c.invoice_id = $1
+			AND c.deleted_at IS NULL
+		GROUP BY l.invoice_line_id;
+	`, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var credited = map[ulid.ULID]model.Credited{}
+	for rows.Next() {
+		var (
+			lineID ulid.ULID
+			c      model.Credited
+		)
+		if err := rows.Scan(&lineID, &c.Quantity, &c.Tax, &c.Tax.Currency); err != nil {
+			return nil, err
+		}
+		credited[lineID] = c
+	}
+
+	return credited, rows.Err()
+}
+
+type CreditNoteList struct {
+	Count int                `json:"count"`
+	Data  []model.CreditNote `json:"data"`
+}
+
+var emptyCreditNotes = CreditNoteList{
+	Count: 0,
+	Data:  []model.CreditNote{},
+}
+
+type CreditNoteReadModel interface {
+	Fetch(ctx context.Context) (res CreditNoteList, err error)
+	GetOneByID(ctx context.Context, id ulid.ULID) (res model.CreditNote, err error)
+}
+
+func NewCreditNoteReadModel(
+	pool *pgxpool.Pool,
+) CreditNoteReadModel {
+	return &CreditNoteQuerier{
+		pool: pool,
+	}
+}
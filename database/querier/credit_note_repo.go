@@ -0,0 +1,319 @@
+package querier
+
+import (
+	"context"
+	"errors"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/app/notify"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+// Save locks the invoice, lets build raise a credit note against it and
+// stores the note under the next credit note number. The credit is taken off
+// the invoice balance, whatever exceeds it is held as the customer's credit
+// and, when the note asks for it, the credited quantities go back into
+// stock, all in the same transaction.
+func (q *CreditNoteQuerier) Save(
+	ctx context.Context,
+	invoiceID ulid.ULID,
+	build func(inv model.Invoice, credited map[ulid.ULID]model.Credited) (model.CreditNote, error),
+) (model.CreditNote, error) {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return model.CreditNote{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	inv, err := lockInvoice(ctx, tx, invoiceID)
+	if err != nil {
+		return model.CreditNote{}, err
+	}
+	lines, err := fetchInvoiceLines(ctx, tx, []ulid.ULID{inv.ID})
+	if err != nil {
+		return model.CreditNote{}, err
+	}
+	inv.Lines = lines[inv.ID]
+
+	credited, err := creditedLines(ctx, tx, inv.ID)
+	if err != nil {
+		return model.CreditNote{}, err
+	}
+
+	item, err := build(inv, credited)
+	if err != nil {
+		return item, err
+	}
+
+	item.Applied, err = inv.ApplyCredit(item.Total(), item.IssuedAt)
+	if err != nil {
+		return item, err
+	}
+
+	item.Number, err = nextNumber(ctx, tx, q.creditNoteNumber, item.IssuedAt)
+	if err != nil {
+		return item, err
+	}
+
+	query := `
+		INSERT INTO credit_notes (
+			id,
+			created_at,
+			number,
+			invoice_id,
+			customer_id,
+			bill_to,
+			currency,
+			reason,
+			restock,
+			issued_at,
+			applied_amount
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7,
+			$8,
+			$9,
+			$10,
+			$11
+		);
+	`
+	_, err = tx.Exec(
+		ctx,
+		query,
+		item.ID,
+		item.CreatedAt,
+		item.Number,
+		item.InvoiceID,
+		item.CustomerID,
+		item.BillTo,
+		item.Currency,
+		item.Reason,
+		item.Restock,
+		item.IssuedAt,
+		item.Applied,
+	)
+	if err != nil {
+		return item, err
+	}
+
+	if err := insertCreditNoteLines(ctx, tx, item.Lines); err != nil {
+		return item, err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE invoices
+		SET
+			credited_amount = $2,
+			status = $3,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1;`,
+		inv.ID,
+		inv.CreditedAmount,
+		inv.Status,
+	)
+	if err != nil {
+		return item, err
+	}
+
+	var alerts stockAlerts
+	if item.Restock {
+		if err := restockCreditNoteLines(ctx, tx, inv, item, &alerts); err != nil {
+			return item, err
+		}
+	}
+
+	if credit, ok := item.CustomerCredit(); ok {
+		if err := insertPayment(ctx, tx, credit); err != nil {
+			return item, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return item, err
+	}
+	alerts.notify(ctx, q.notifier)
+	return item, nil
+}
+
+func insertCreditNoteLines(ctx context.Context, tx pgx.Tx, lines []model.CreditNoteLine) error {
+	query := `
+		INSERT INTO credit_note_lines (
+			id,
+			credit_note_id,
+			invoice_line_id,
+			product_id,
+			position,
+			sku,
+			name,
+			amount,
+			currency,
+			quantity,
+			tax_class,
+			tax_name,
+			tax_rate,
+			price_includes_tax,
+			tax_amount
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7,
+			$8,
+			$9,
+			$10,
+			$11,
+			$12,
+			$13,
+			$14,
+			$15
+		);
+	`
+
+	batch := &pgx.Batch{}
+	for idx := range lines {
+		batch.Queue(
+			query,
+			lines[idx].ID,
+			lines[idx].CreditNoteID,
+			lines[idx].InvoiceLineID,
+			lines[idx].ProductID,
+			idx,
+			lines[idx].Sku,
+			lines[idx].Name,
+			lines[idx].Amount,
+			lines[idx].Amount.Currency,
+			lines[idx].Quantity,
+			lines[idx].TaxClass,
+			lines[idx].TaxName,
+			lines[idx].TaxRate,
+			lines[idx].PriceIncludesTax,
+			lines[idx].TaxAmount,
+		)
+	}
+
+	return tx.SendBatch(ctx, batch).Close()
+}
+
+// restockCreditNoteLines books the credited goods back into the warehouse
+// the invoice sold them from, as returns referencing the credit note. Only
+// what the invoice sold out of stock and earlier credit notes didn't return
+// yet comes back; the rest of the credit never left the shelf. The stock
+// alerts the returns raise are added to alerts.
+func restockCreditNoteLines(ctx context.Context, tx pgx.Tx, inv model.Invoice, note model.CreditNote, alerts *stockAlerts) error {
+	var lines = make([]model.CreditNoteLine, len(note.Lines))
+	copy(lines, note.Lines)
+	// Inventories are locked in a fixed order so that concurrent restocks
+	// cannot deadlock.
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i].ProductID.Compare(lines[j].ProductID) < 0
+	})
+
+	returnable, err := returnableQuantities(ctx, tx, inv)
+	if err != nil {
+		return err
+	}
+
+	actor := auth.ActorFrom(ctx)
+	for _, l := range lines {
+		quantity := min(l.Quantity, returnable[l.ProductID])
+		if quantity <= 0 {
+			continue
+		}
+		returnable[l.ProductID] -= quantity
+
+		// Goods come back even when the product is no longer sold.
+		stock, _, err := lockStock(ctx, tx, l.ProductID, inv.WarehouseID)
+		if err != nil && !errors.Is(err, model.ErrProductAlreadyDeleted) {
+			return err
+		}
+
+		movement, err := stock.Move(
+			l.ProductID,
+			model.StockReturn,
+			quantity,
+			note.Reason,
+			note.Number,
+			actor,
+			note.IssuedAt,
+		)
+		if err != nil {
+			return err
+		}
+		movement.InvoiceID = &inv.ID
+		movement.CreditNoteID = &note.ID
+		if err := recordStockMovement(ctx, tx, stock, movement, alerts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// returnableQuantities sums per product what the ledger shows the invoice
+// sold, less what its credit notes, or voiding it, have returned since.
+// Every one of those movements names the invoice.
+func returnableQuantities(ctx context.Context, tx pgx.Tx, inv model.Invoice) (map[ulid.ULID]int, error) {
+	rows, err := tx.Query(
+		ctx,
+		`SELECT
+			product_id,
+			-SUM(quantity)
+		FROM stock_movements
+		WHERE invoice_id = $1 AND movement_type IN ($2, $3)
+		GROUP BY product_id;`,
+		inv.ID,
+		model.StockSale,
+		model.StockReturn,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var returnable = map[ulid.ULID]int{}
+	for rows.Next() {
+		var (
+			productID ulid.ULID
+			quantity  int
+		)
+		if err := rows.Scan(&productID, &quantity); err != nil {
+			return nil, err
+		}
+		returnable[productID] = quantity
+	}
+
+	return returnable, rows.Err()
+}
+
+type CreditNoteWriteModel interface {
+	Save(
+		ctx context.Context,
+		invoiceID ulid.ULID,
+		build func(inv model.Invoice, credited map[ulid.ULID]model.Credited) (model.CreditNote, error),
+	) (model.CreditNote, error)
+}
+
+func NewCreditNoteWriteModel(
+	pool *pgxpool.Pool,
+	creditNoteNumber model.NumberFormat,
+	notifier notify.Notifier,
+) CreditNoteWriteModel {
+	return &CreditNoteQuerier{
+		pool:             pool,
+		creditNoteNumber: creditNoteNumber,
+		notifier:         notifier,
+	}
+}
@@ -0,0 +1,129 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+type CustomerQuerier struct {
+	pool *pgxpool.Pool
+}
+
+const customerColumns = `
+	id,
+	created_at,
+	updated_at,
+	deleted_at,
+	legal_name,
+	billing_address,
+	shipping_address,
+	tax_id,
+	currency,
+	payment_term_days,
+	emails
+`
+
+func scanCustomer(row pgx.Row, item *model.Customer) error {
+	return row.Scan(
+		&item.ID,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+		&item.DeletedAt,
+		&item.LegalName,
+		&item.BillingAddress,
+		&item.ShippingAddress,
+		&item.TaxID,
+		&item.Currency,
+		&item.PaymentTermDays,
+		&item.Emails,
+	)
+}
+
+func (q *CustomerQuerier) Fetch(ctx context.Context) (res CustomerList, err error) {
+	rows, err := q.pool.Query(
+		ctx,
+		`SELECT `+customerColumns+`
+		FROM customers
+		WHERE deleted_at IS NULL
+		ORDER BY id;`,
+	)
+	if err != nil {
+		return emptyCustomers, err
+	}
+	defer rows.Close()
+
+	var items []model.Customer
+	for rows.Next() {
+		var item model.Customer
+		if err := scanCustomer(rows, &item); err != nil {
+			return emptyCustomers, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return emptyCustomers, err
+	}
+
+	if len(items) == 0 {
+		return emptyCustomers, nil
+	}
+
+	list := CustomerList{
+		Count: len(items),
+		Data:  items,
+	}
+
+	return list, nil
+}
+
+func (q *CustomerQuerier) GetOneByID(ctx context.Context, id ulid.ULID) (res model.Customer, err error) {
+	return getCustomer(ctx, q.pool, id)
+}
+
+func getCustomer(ctx context.Context, db dbtx, id ulid.ULID) (model.Customer, error) {
+	row := db.QueryRow(
+		ctx,
+		`SELECT `+customerColumns+`
+		FROM customers
+		WHERE id = $1;`,
+		id,
+	)
+	var item model.Customer
+	if err := scanCustomer(row, &item); err != nil {
+		if err == pgx.ErrNoRows {
+			return item, model.ErrCustomerNotFound
+		}
+		return item, err
+	}
+	if item.DeletedAt.Valid {
+		return item, model.ErrCustomerAlreadyDeleted
+	}
+	return item, nil
+}
+
+type CustomerList struct {
+	Count int              `json:"count"`
+	Data  []model.Customer `json:"data"`
+}
+
+var emptyCustomers = CustomerList{
+	Count: 0,
+	Data:  []model.Customer{},
+}
+
+type CustomerReadModel interface {
+	Fetch(ctx context.Context) (res CustomerList, err error)
+	GetOneByID(ctx context.Context, id ulid.ULID) (res model.Customer, err error)
+}
+
+func NewCustomerReadModel(
+	pool *pgxpool.Pool,
+) CustomerReadModel {
+	return &CustomerQuerier{
+		pool: pool,
+	}
+}
@@ -0,0 +1,94 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func (q *CustomerQuerier) Save(ctx context.Context, data model.Customer) error {
+	query := `
+		INSERT INTO customers (
+			id,
+			created_at,
+			legal_name,
+			billing_address,
+			shipping_address,
+			tax_id,
+			currency,
+			payment_term_days,
+			emails
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7,
+			$8,
+			$9
+		) ON CONFLICT(id)
+		DO UPDATE SET
+			legal_name = EXCLUDED.legal_name,
+			billing_address = EXCLUDED.billing_address,
+			shipping_address = EXCLUDED.shipping_address,
+			tax_id = EXCLUDED.tax_id,
+			currency = EXCLUDED.currency,
+			payment_term_days = EXCLUDED.payment_term_days,
+			emails = EXCLUDED.emails,
+			updated_at = CURRENT_TIMESTAMP;
+	`
+	_, err := q.pool.Exec(
+		ctx,
+		query,
+		data.ID,
+		data.CreatedAt,
+		data.LegalName,
+		data.BillingAddress,
+		data.ShippingAddress,
+		data.TaxID,
+		data.Currency,
+		data.PaymentTermDays,
+		data.Emails,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (q *CustomerQuerier) Delete(ctx context.Context, data model.Customer) error {
+	query := `
+		UPDATE customers
+		SET deleted_at = CURRENT_TIMESTAMP
+		WHERE id = $1;
+	`
+	_, err := q.pool.Exec(
+		ctx,
+		query,
+		data.ID,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type CustomerWriteModel interface {
+	Save(ctx context.Context, data model.Customer) error
+	Delete(ctx context.Context, data model.Customer) error
+}
+
+func NewCustomerWriteModel(
+	pool *pgxpool.Pool,
+) CustomerWriteModel {
+	return &CustomerQuerier{
+		pool: pool,
+	}
+}
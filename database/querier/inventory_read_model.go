@@ -0,0 +1,112 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+type InventoryQuerier struct {
+	pool *pgxpool.Pool
+}
+
+// LowStock lists the stock of active products, in the warehouse or in
+// every warehouse when warehouseID is nil, that is at or below its reorder
+// point. The largest shortfalls come first.
+func (q *InventoryQuerier) LowStock(ctx context.Context, warehouseID *ulid.ULID) (res LowStockList, err error) {
+	rows, err := q.pool.Query(
+		ctx,
+		`SELECT
+			p.id,
+			p.sku,
+			p.name,
+			w.id,
+			w.code,
+			i.quantity,
+			`+reservedQuantity+`,
+			COALESCE(
+				(
+					SELECT SUM(t.quantity)
+					FROM warehouse_transfers t
+					WHERE t.product_id = i.product_id
+						AND t.to_warehouse_id = i.warehouse_id
+						AND t.status = 'in_transit'
+				),
+				0
+			),
+			i.reorder_point,
+			i.reorder_quantity
+		FROM inventories i
+		JOIN products p ON i.product_id = p.id
+		JOIN warehouses w ON i.warehouse_id = w.id
+		WHERE i.reorder_point IS NOT NULL
+			AND i.quantity <= i.reorder_point
+			AND p.deleted_at IS NULL
+			AND w.deleted_at IS NULL
+			AND ($1::BYTEA IS NULL OR i.warehouse_id = $1)
+		ORDER BY i.reorder_point - i.quantity DESC, p.name, w.code;`,
+		warehouseID,
+	)
+	if err != nil {
+		return emptyLowStock, err
+	}
+	defer rows.Close()
+
+	var items []model.LowStock
+	for rows.Next() {
+		var item model.LowStock
+		if err := rows.Scan(
+			&item.ProductID,
+			&item.Sku,
+			&item.Name,
+			&item.WarehouseID,
+			&item.WarehouseCode,
+			&item.OnHand,
+			&item.Reserved,
+			&item.InTransit,
+			&item.ReorderPoint,
+			&item.ReorderQuantity,
+		); err != nil {
+			return emptyLowStock, err
+		}
+		item.Available = item.OnHand - item.Reserved
+		item.Shortfall = item.ReorderPoint - item.OnHand
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return emptyLowStock, err
+	}
+
+	if len(items) == 0 {
+		return emptyLowStock, nil
+	}
+
+	return LowStockList{
+		Count: len(items),
+		Data:  items,
+	}, nil
+}
+
+type LowStockList struct {
+	Count int              `json:"count"`
+	Data  []model.LowStock `json:"data"`
+}
+
+var emptyLowStock = LowStockList{
+	Count: 0,
+	Data:  []model.LowStock{},
+}
+
+type InventoryReadModel interface {
+	LowStock(ctx context.Context, warehouseID *ulid.ULID) (res LowStockList, err error)
+}
+
+func NewInventoryReadModel(
+	pool *pgxpool.Pool,
+) InventoryReadModel {
+	return &InventoryQuerier{
+		pool: pool,
+	}
+}
@@ -0,0 +1,213 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/app/notify"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+type InvoiceQuerier struct {
+	pool          *pgxpool.Pool
+	invoiceNumber model.NumberFormat
+	notifier      notify.Notifier
+}
+
+const invoiceColumns = `
+	id,
+	created_at,
+	updated_at,
+	deleted_at,
+	number,
+	customer_id,
+	COALESCE(bill_to, '{}'::JSONB),
+	currency,
+	status,
+	payment_term_days,
+	issued_at,
+	due_at,
+	voided_at,
+	paid_amount,
+	credited_amount,
+	recurring_invoice_id,
+	warehouse_id
+`
+
+func scanInvoice(row pgx.Row, item *model.Invoice) error {
+	err := row.Scan(
+		&item.ID,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+		&item.DeletedAt,
+		&item.Number,
+		&item.CustomerID,
+		&item.BillTo,
+		&item.Currency,
+		&item.Status,
+		&item.PaymentTermDays,
+		&item.IssuedAt,
+		&item.DueAt,
+		&item.VoidedAt,
+		&item.PaidAmount,
+		&item.CreditedAmount,
+		&item.RecurringInvoiceID,
+		&item.WarehouseID,
+	)
+	item.PaidAmount.Currency = item.Currency
+	item.CreditedAmount.Currency = item.Currency
+	return err
+}
+
+func (q *InvoiceQuerier) Fetch(ctx context.Context) (res InvoiceList, err error) {
+	rows, err := q.pool.Query(
+		ctx,
+		`SELECT `+invoiceColumns+`
+		FROM invoices
+		WHERE deleted_at IS NULL
+		ORDER BY id;`,
+	)
+	if err != nil {
+		return emptyInvoices, err
+	}
+	defer rows.Close()
+
+	var items []model.Invoice
+	for rows.Next() {
+		var item model.Invoice
+		if err := scanInvoice(rows, &item); err != nil {
+			return emptyInvoices, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return emptyInvoices, err
+	}
+
+	if len(items) == 0 {
+		return emptyInvoices, nil
+	}
+
+	var ids = make([]ulid.ULID, len(items))
+	for idx := range items {
+		ids[idx] = items[idx].ID
+	}
+
+	lines, err := fetchInvoiceLines(ctx, q.pool, ids)
+	if err != nil {
+		return emptyInvoices, err
+	}
+
+	for idx := range items {
+		items[idx].Lines = lines[items[idx].ID]
+	}
+
+	list := InvoiceList{
+		Count: len(items),
+		Data:  items,
+	}
+
+	return list, nil
+}
+
+func (q *InvoiceQuerier) GetOneByID(ctx context.Context, id ulid.ULID) (res model.Invoice, err error) {
+	row := q.pool.QueryRow(
+		ctx,
+		`SELECT `+invoiceColumns+`
+		FROM invoices
+		WHERE id = $1;`,
+		id,
+	)
+	var item model.Invoice
+	if err := scanInvoice(row, &item); err != nil {
+		if err == pgx.ErrNoRows {
+			return item, model.ErrInvoiceNotFound
+		}
+		return item, err
+	}
+	if item.DeletedAt.Valid {
+		return item, model.ErrInvoiceAlreadyDeleted
+	}
+
+	lines, err := fetchInvoiceLines(ctx, q.pool, []ulid.ULID{id})
+	if err != nil {
+		return item, err
+	}
+	item.Lines = lines[id]
+
+	return item, nil
+}
+
+func fetchInvoiceLines(ctx context.Context, db dbtx, invoiceIds []ulid.ULID) (map[ulid.ULID][]model.InvoiceLine, error) {
+	rows, err := db.Query(ctx, `
+		SELECT
+			id,
+			invoice_id,
+			product_id,
+			sku,
+			name,
+			amount,
+			currency,
+			quantity,
+			tax_class,
+			tax_name,
+			tax_rate,
+			price_includes_tax
+		FROM invoice_lines
+		WHERE invoice_id = ANY($1::BYTEA[])
+		ORDER BY invoice_id, position;
+	`, invoiceIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines = make(map[ulid.ULID][]model.InvoiceLine, len(invoiceIds))
+	for rows.Next() {
+		var l model.InvoiceLine
+		if err := rows.Scan(
+			&l.ID,
+			&l.InvoiceID,
+			&l.ProductID,
+			&l.Sku,
+			&l.Name,
+			&l.Amount,
+			&l.Amount.Currency,
+			&l.Quantity,
+			&l.TaxClass,
+			&l.TaxName,
+			&l.TaxRate,
+			&l.PriceIncludesTax,
+		); err != nil {
+			return nil, err
+		}
+		lines[l.InvoiceID] = append(lines[l.InvoiceID], l)
+	}
+
+	return lines, rows.Err()
+}
+
+type InvoiceList struct {
+	Count int             `json:"count"`
+	Data  []model.Invoice `json:"data"`
+}
+
+var emptyInvoices = InvoiceList{
+	Count: 0,
+	Data:  []model.Invoice{},
+}
+
+type InvoiceReadModel interface {
+	Fetch(ctx context.Context) (res InvoiceList, err error)
+	GetOneByID(ctx context.Context, id ulid.ULID) (res model.Invoice, err error)
+}
+
+func NewInvoiceReadModel(
+	pool *pgxpool.Pool,
+) InvoiceReadModel {
+	return &InvoiceQuerier{
+		pool: pool,
+	}
+}
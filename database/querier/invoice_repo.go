@@ -0,0 +1,442 @@
+package querier
+
+import (
+	"context"
+	"errors"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/app/notify"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+func (q *InvoiceQuerier) Save(ctx context.Context, data model.Invoice) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertInvoice(ctx, tx, data); err != nil {
+		return invoiceWarehouseError(err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Edit locks a draft invoice and lets change rewrite its customer and lines.
+// Issued invoices are rejected by the model. A draft holding stock holds
+// the new lines instead, until the same time.
+func (q *InvoiceQuerier) Edit(ctx context.Context, id ulid.ULID, change func(*model.Invoice) error) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	item, err := lockInvoice(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if item.Status != model.InvoiceStatusDraft {
+		return model.ErrInvoiceAlreadyIssued
+	}
+	if err := change(&item); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE invoices
+		SET
+			customer_id = $2,
+			bill_to = $3,
+			currency = $4,
+			payment_term_days = $5,
+			total_amount = $6,
+			warehouse_id = $7,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1;
+	`
+	_, err = tx.Exec(
+		ctx,
+		query,
+		item.ID,
+		item.CustomerID,
+		item.BillTo,
+		item.Currency,
+		item.PaymentTermDays,
+		item.Total(),
+		item.WarehouseID,
+	)
+	if err != nil {
+		return invoiceWarehouseError(err)
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`DELETE FROM invoice_lines WHERE invoice_id = $1;`,
+		item.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := insertInvoiceLines(ctx, tx, item.Lines); err != nil {
+		return err
+	}
+
+	expiresAt, held, err := heldUntil(ctx, tx, item.ID)
+	if err != nil {
+		return err
+	}
+	if held {
+		if _, err := reserveInvoice(ctx, tx, item, expiresAt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Reserve holds the stock of the draft invoice's lines until expiresAt,
+// replacing what it held before.
+func (q *InvoiceQuerier) Reserve(ctx context.Context, id ulid.ULID, expiresAt time.Time) ([]model.StockReservation, error) {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	item, err := lockInvoice(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if item.Status != model.InvoiceStatusDraft {
+		return nil, model.ErrInvoiceAlreadyIssued
+	}
+	lines, err := fetchInvoiceLines(ctx, tx, []ulid.ULID{item.ID})
+	if err != nil {
+		return nil, err
+	}
+	item.Lines = lines[item.ID]
+
+	reservations, err := reserveInvoice(ctx, tx, item, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return reservations, tx.Commit(ctx)
+}
+
+// Release gives back the stock the draft invoice holds.
+func (q *InvoiceQuerier) Release(ctx context.Context, id ulid.ULID) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	item, err := lockInvoice(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if item.Status != model.InvoiceStatusDraft {
+		return model.ErrInvoiceAlreadyIssued
+	}
+	if err := releaseInvoice(ctx, tx, item.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Transition locks the invoice, lets change move it to its next status and
+// stores the result. Whether the transition is allowed is decided by change,
+// usually one of the status methods of model.Invoice. A draft that is issued
+// consumes the stock it holds; one that is voided releases it. Voiding an
+// issued invoice returns the stock it sold.
+func (q *InvoiceQuerier) Transition(ctx context.Context, id ulid.ULID, change func(*model.Invoice) error) (model.Invoice, error) {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return model.Invoice{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	item, err := lockInvoice(ctx, tx, id)
+	if err != nil {
+		return item, err
+	}
+
+	wasDraft := item.Status == model.InvoiceStatusDraft
+	if err := change(&item); err != nil {
+		return item, err
+	}
+
+	if item.IssuedAt.Valid && !item.Number.Valid {
+		number, err := nextNumber(ctx, tx, q.invoiceNumber, item.IssuedAt.Time)
+		if err != nil {
+			return item, err
+		}
+		item.Number = null.StringFrom(number)
+	}
+
+	query := `
+		UPDATE invoices
+		SET
+			number = $2,
+			bill_to = $3,
+			status = $4,
+			payment_term_days = $5,
+			issued_at = $6,
+			due_at = $7,
+			voided_at = $8,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1;
+	`
+	_, err = tx.Exec(
+		ctx,
+		query,
+		item.ID,
+		item.Number,
+		item.BillTo,
+		item.Status,
+		item.PaymentTermDays,
+		item.IssuedAt,
+		item.DueAt,
+		item.VoidedAt,
+	)
+	if err != nil {
+		return item, err
+	}
+
+	var alerts stockAlerts
+	switch {
+	case !wasDraft && item.Status == model.InvoiceStatusVoid:
+		err = returnInvoice(ctx, tx, item, &alerts)
+	case !wasDraft:
+	case item.Status == model.InvoiceStatusIssued:
+		err = consumeInvoice(ctx, tx, item, &alerts)
+	case item.Status != model.InvoiceStatusDraft:
+		err = releaseInvoice(ctx, tx, item.ID)
+	}
+	if err != nil {
+		return item, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return item, err
+	}
+	alerts.notify(ctx, q.notifier)
+	return item, nil
+}
+
+// Delete soft-deletes a draft invoice. Issued invoices are kept for the
+// records and can only be voided.
+func (q *InvoiceQuerier) Delete(ctx context.Context, data model.Invoice) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	item, err := lockInvoice(ctx, tx, data.ID)
+	if err != nil {
+		return err
+	}
+	if item.Status != model.InvoiceStatusDraft {
+		return model.ErrInvoiceAlreadyIssued
+	}
+	if err := releaseInvoice(ctx, tx, item.ID); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE invoices
+		SET deleted_at = CURRENT_TIMESTAMP
+		WHERE id = $1;
+	`
+	_, err = tx.Exec(
+		ctx,
+		query,
+		item.ID,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// insertInvoice stores a new invoice with its lines inside tx.
+func insertInvoice(ctx context.Context, tx pgx.Tx, data model.Invoice) error {
+	query := `
+		INSERT INTO invoices (
+			id,
+			created_at,
+			number,
+			customer_id,
+			bill_to,
+			currency,
+			status,
+			payment_term_days,
+			issued_at,
+			due_at,
+			total_amount,
+			recurring_invoice_id,
+			warehouse_id
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7,
+			$8,
+			$9,
+			$10,
+			$11,
+			$12,
+			$13
+		);
+	`
+	_, err := tx.Exec(
+		ctx,
+		query,
+		data.ID,
+		data.CreatedAt,
+		data.Number,
+		data.CustomerID,
+		data.BillTo,
+		data.Currency,
+		data.Status,
+		data.PaymentTermDays,
+		data.IssuedAt,
+		data.DueAt,
+		data.Total(),
+		data.RecurringInvoiceID,
+		data.WarehouseID,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return insertInvoiceLines(ctx, tx, data.Lines)
+}
+
+// invoiceWarehouseError reports a warehouse the tenant has none by the id
+// of as not found, and passes other failures to store the invoice on.
+func invoiceWarehouseError(err error) error {
+	var pgxError *pgconn.PgError
+	if errors.As(err, &pgxError) && pgxError.Code == "23503" && pgxError.ConstraintName == "fk_warehouse" {
+		return model.ErrWarehouseNotFound
+	}
+	return err
+}
+
+// lockInvoice reads the invoice header and holds a row lock on it until tx
+// ends.
+func lockInvoice(ctx context.Context, tx pgx.Tx, id ulid.ULID) (model.Invoice, error) {
+	row := tx.QueryRow(
+		ctx,
+		`SELECT `+invoiceColumns+`
+		FROM invoices
+		WHERE id = $1
+		FOR UPDATE;`,
+		id,
+	)
+	var item model.Invoice
+	if err := scanInvoice(row, &item); err != nil {
+		if err == pgx.ErrNoRows {
+			return item, model.ErrInvoiceNotFound
+		}
+		return item, err
+	}
+	if item.DeletedAt.Valid {
+		return item, model.ErrInvoiceAlreadyDeleted
+	}
+	return item, nil
+}
+
+func insertInvoiceLines(ctx context.Context, tx pgx.Tx, lines []model.InvoiceLine) error {
+	query := `
+		INSERT INTO invoice_lines (
+			id,
+			invoice_id,
+			product_id,
+			position,
+			sku,
+			name,
+			amount,
+			currency,
+			quantity,
+			tax_class,
+			tax_name,
+			tax_rate,
+			price_includes_tax
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7,
+			$8,
+			$9,
+			$10,
+			$11,
+			$12,
+			$13
+		);
+	`
+
+	batch := &pgx.Batch{}
+	for idx := range lines {
+		batch.Queue(
+			query,
+			lines[idx].ID,
+			lines[idx].InvoiceID,
+			lines[idx].ProductID,
+			idx,
+			lines[idx].Sku,
+			lines[idx].Name,
+			lines[idx].Amount,
+			lines[idx].Amount.Currency,
+			lines[idx].Quantity,
+			lines[idx].TaxClass,
+			lines[idx].TaxName,
+			lines[idx].TaxRate,
+			lines[idx].PriceIncludesTax,
+		)
+	}
+
+	return tx.SendBatch(ctx, batch).Close()
+}
+
+type InvoiceWriteModel interface {
+	Save(ctx context.Context, data model.Invoice) error
+	Edit(ctx context.Context, id ulid.ULID, change func(*model.Invoice) error) error
+	Transition(ctx context.Context, id ulid.ULID, change func(*model.Invoice) error) (model.Invoice, error)
+	Delete(ctx context.Context, data model.Invoice) error
+	Reserve(ctx context.Context, id ulid.ULID, expiresAt time.Time) ([]model.StockReservation, error)
+	Release(ctx context.Context, id ulid.ULID) error
+}
+
+func NewInvoiceWriteModel(
+	pool *pgxpool.Pool,
+	invoiceNumber model.NumberFormat,
+	notifier notify.Notifier,
+) InvoiceWriteModel {
+	return &InvoiceQuerier{
+		pool:          pool,
+		invoiceNumber: invoiceNumber,
+		notifier:      notifier,
+	}
+}
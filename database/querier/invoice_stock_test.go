@@ -0,0 +1,237 @@
+package querier
+
+import (
+	"context"
+	"errors"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/app/notify"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+// onHand reads what the fixture's product has on hand in the default
+// warehouse.
+func (f tenantFixture) onHand(t *testing.T, db dbtx) int {
+	t.Helper()
+
+	var quantity int
+	if err := db.QueryRow(
+		f.ctx,
+		`SELECT quantity FROM inventories WHERE id = $1;`,
+		f.product.Inventory.ID,
+	).Scan(&quantity); err != nil {
+		t.Fatal(err)
+	}
+	return quantity
+}
+
+func TestInvoiceVoidReturnsStock(t *testing.T) {
+	pool := testPool(t)
+	f := newTenantFixture(t, pool, "Tenant A")
+
+	if err := NewProductWriteModel(pool, notify.NewLogNotifier()).AssignQuantity(f.ctx, f.product.ID, nil, 10, "count", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	writeInvoice := NewInvoiceWriteModel(pool, testInvoiceNumber, notify.NewLogNotifier())
+	inv, err := writeInvoice.Transition(f.ctx, f.invoice.ID, func(inv *model.Invoice) error {
+		return inv.Issue(time.Now())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.onHand(t, pool), 9; got != want {
+		t.Fatalf("on hand after issuing = %d, want %d", got, want)
+	}
+
+	if _, err := writeInvoice.Transition(f.ctx, inv.ID, func(inv *model.Invoice) error {
+		return inv.Void(time.Now())
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.onHand(t, pool), 10; got != want {
+		t.Errorf("on hand after voiding = %d, want %d", got, want)
+	}
+
+	var returned int
+	if err := pool.QueryRow(
+		f.ctx,
+		`SELECT COALESCE(SUM(quantity), 0) FROM stock_movements WHERE movement_type = $1 AND invoice_id = $2;`,
+		model.StockReturn,
+		inv.ID,
+	).Scan(&returned); err != nil {
+		t.Fatal(err)
+	}
+	if returned != 1 {
+		t.Errorf("returns booked for invoice %s = %d, want 1", inv.Number.String, returned)
+	}
+}
+
+func TestInvoiceSellsFromItsWarehouse(t *testing.T) {
+	pool := testPool(t)
+	f := newTenantFixture(t, pool, "Tenant A")
+
+	// Unlike the fixture's product, this one can't be sold short.
+	product := model.NewProduct("STOCKED-SKU", "Stocked product", "", nil, model.NewMoney(1000, "USD"), 0, model.TaxClassStandard, false, false)
+	writeProduct := NewProductWriteModel(pool, notify.NewLogNotifier())
+	if err := writeProduct.Save(f.ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	warehouse := model.NewWarehouse("EAST", "East warehouse")
+	if err := NewWarehouseWriteModel(pool).Save(f.ctx, warehouse); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeProduct.AssignQuantity(f.ctx, product.ID, &warehouse.ID, 5, "count", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	writeInvoice := NewInvoiceWriteModel(pool, testInvoiceNumber, notify.NewLogNotifier())
+	issue := func(warehouseID *ulid.ULID) error {
+		inv, err := model.NewInvoice(f.customer, []model.InvoiceLine{model.NewInvoiceLine(product, 2, f.taxRate)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := inv.SetWarehouse(warehouseID); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeInvoice.Save(f.ctx, inv); err != nil {
+			t.Fatal(err)
+		}
+		_, err = writeInvoice.Transition(f.ctx, inv.ID, func(inv *model.Invoice) error {
+			return inv.Issue(time.Now())
+		})
+		return err
+	}
+
+	// The default warehouse has none of it.
+	if err := issue(nil); !errors.Is(err, model.ErrInsufficientStock) {
+		t.Errorf("issuing from the default warehouse: error = %v, want %v", err, model.ErrInsufficientStock)
+	}
+	if err := issue(&warehouse.ID); err != nil {
+		t.Fatalf("issuing from the stocked warehouse: %v", err)
+	}
+
+	var quantity int
+	if err := pool.QueryRow(
+		f.ctx,
+		`SELECT quantity FROM inventories WHERE product_id = $1 AND warehouse_id = $2;`,
+		product.ID,
+		warehouse.ID,
+	).Scan(&quantity); err != nil {
+		t.Fatal(err)
+	}
+	if quantity != 3 {
+		t.Errorf("on hand in the stocked warehouse = %d, want 3", quantity)
+	}
+}
+
+func TestRecurringInvoiceShortOfStockIsDrafted(t *testing.T) {
+	pool := testPool(t)
+	f := newTenantFixture(t, pool, "Tenant A")
+
+	product := model.NewProduct("SCARCE-SKU", "Scarce product", "", nil, model.NewMoney(1000, "USD"), 0, model.TaxClassStandard, false, false)
+	if err := NewProductWriteModel(pool, notify.NewLogNotifier()).Save(f.ctx, product); err != nil {
+		t.Fatal(err)
+	}
+
+	due, err := model.NewRecurringInvoice(
+		f.customer.ID,
+		model.RecurrenceMonthly,
+		time.Now().Day(),
+		time.Now().Add(-time.Minute),
+		null.Time{},
+		null.Int{},
+		true,
+		[]model.RecurringInvoiceLine{model.NewRecurringInvoiceLine(product.ID, 1)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeRecurring := NewRecurringInvoiceWriteModel(pool, testInvoiceNumber, notify.NewLogNotifier())
+	if err := writeRecurring.Save(f.ctx, due); err != nil {
+		t.Fatal(err)
+	}
+
+	errOther := errors.New("schedule of another test")
+	now := time.Now()
+	_, err = writeRecurring.RunDue(context.Background(), now, func(ctx context.Context, r *model.RecurringInvoice) ([]model.Invoice, error) {
+		if r.ID != due.ID {
+			return nil, errOther
+		}
+		inv, err := r.Generate(f.customer, []model.InvoiceLine{model.NewInvoiceLine(product, 1, f.taxRate)}, now)
+		if err != nil {
+			return nil, err
+		}
+		return []model.Invoice{inv}, nil
+	})
+	if err != nil && !errors.Is(err, errOther) {
+		t.Fatal(err)
+	}
+
+	schedule, err := NewRecurringInvoiceReadModel(pool).GetOneByID(f.ctx, due.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !schedule.NextRunAt.Time.After(now) {
+		t.Errorf("next run = %v, want the schedule moved past %v", schedule.NextRunAt.Time, now)
+	}
+
+	invoices, err := NewInvoiceReadModel(pool).Fetch(f.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, inv := range invoices.Data {
+		if inv.RecurringInvoiceID == nil || *inv.RecurringInvoiceID != due.ID {
+			continue
+		}
+		found = true
+		if inv.Status != model.InvoiceStatusDraft || inv.Number.Valid {
+			t.Errorf("generated invoice = %s numbered %q, want an unnumbered draft", inv.Status, inv.Number.String)
+		}
+	}
+	if !found {
+		t.Error("the due schedule generated no invoice")
+	}
+}
+
+func TestCreditNoteRestockNamesItsDocuments(t *testing.T) {
+	pool := testPool(t)
+	f := newTenantFixture(t, pool, "Tenant A")
+
+	if err := NewProductWriteModel(pool, notify.NewLogNotifier()).AssignQuantity(f.ctx, f.product.ID, nil, 10, "count", ""); err != nil {
+		t.Fatal(err)
+	}
+	inv, err := NewInvoiceWriteModel(pool, testInvoiceNumber, notify.NewLogNotifier()).Transition(f.ctx, f.invoice.ID, func(inv *model.Invoice) error {
+		return inv.Issue(time.Now())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	note, err := NewCreditNoteWriteModel(pool, model.NumberFormat{Series: "credit_note", Prefix: "CN", Padding: 6}, notify.NewLogNotifier()).Save(f.ctx, inv.ID, func(inv model.Invoice, credited map[ulid.ULID]model.Credited) (model.CreditNote, error) {
+		return model.NewCreditNote(inv, credited, []model.CreditedLine{{InvoiceLineID: inv.Lines[0].ID, Quantity: 1}}, "damaged", true, time.Now())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.onHand(t, pool), 10; got != want {
+		t.Errorf("on hand after the credit note = %d, want %d", got, want)
+	}
+
+	var invoiceID, creditNoteID *ulid.ULID
+	if err := pool.QueryRow(
+		f.ctx,
+		`SELECT invoice_id, credit_note_id FROM stock_movements WHERE product_id = $1 AND movement_type = $2;`,
+		f.product.ID,
+		model.StockReturn,
+	).Scan(&invoiceID, &creditNoteID); err != nil {
+		t.Fatal(err)
+	}
+	if invoiceID == nil || *invoiceID != inv.ID || creditNoteID == nil || *creditNoteID != note.ID {
+		t.Errorf("return booked for invoice %v and credit note %v, want %s and %s", invoiceID, creditNoteID, inv.ID, note.ID)
+	}
+}
@@ -0,0 +1,43 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// nextNumber draws the next running number of the format's series, which
+// every tenant has its own of, and renders it. The sequence row stays locked
+// until tx ends, so concurrent issuers wait for each other and a rolled back
+// transaction gives its number back, keeping the series free of gaps and
+// duplicates.
+func nextNumber(ctx context.Context, tx pgx.Tx, format model.NumberFormat, at time.Time) (string, error) {
+	query := `
+		INSERT INTO number_sequences (
+			series,
+			period,
+			last_value
+		) VALUES (
+			$1,
+			$2,
+			1
+		) ON CONFLICT(tenant_id, series, period)
+		DO UPDATE SET
+			last_value = number_sequences.last_value + 1,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING last_value;
+	`
+	var seq int64
+	if err := tx.QueryRow(
+		ctx,
+		query,
+		format.Series,
+		format.Period(at),
+	).Scan(&seq); err != nil {
+		return "", err
+	}
+
+	return format.Format(at, seq), nil
+}
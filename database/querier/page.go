@@ -0,0 +1,74 @@
+package querier
+
+import (
+	"fmt"
+
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+// Page asks for the rows of a list that come after the row with id After,
+// in keyset order: by Sort, ties broken by id. Sort names one of the list's
+// sort options and falls back to the id.
+type Page struct {
+	After *ulid.ULID
+	Limit int
+	Sort  string
+	Desc  bool
+}
+
+func (p Page) limit() int {
+	if p.Limit <= 0 || p.Limit > MaxPageLimit {
+		return DefaultPageLimit
+	}
+	return p.Limit
+}
+
+// Cursor tells whether there is a page after the one returned, and the
+// After to ask for it with.
+type Cursor struct {
+	NextCursor *ulid.ULID `json:"next_cursor"`
+	HasMore    bool       `json:"has_more"`
+}
+
+// queryArgs numbers the arguments of a query as it is being built.
+type queryArgs []any
+
+func (a *queryArgs) add(v any) string {
+	*a = append(*a, v)
+	return fmt.Sprintf("$%d", len(*a))
+}
+
+// keyset returns the condition selecting the rows after the page's After
+// row, and the ORDER BY matching it. sortExpr is evaluated for the After
+// row by afterRow, a subquery selecting sortExpr and the id of the row with
+// the given id.
+func (p Page) keyset(args *queryArgs, sortExpr, idExpr string, afterRow func(id string) string) (cond, order string) {
+	dir, cmp := "ASC", ">"
+	if p.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	order = fmt.Sprintf("%s %s, %s %s", sortExpr, dir, idExpr, dir)
+	if p.After != nil {
+		cond = fmt.Sprintf("(%s, %s) %s (%s)", sortExpr, idExpr, cmp, afterRow(args.add(*p.After)))
+	}
+	return cond, order
+}
+
+// pageCursor trims the extra row fetched past the limit and tells whether
+// there was one.
+func pageCursor[T any](items []T, limit int, id func(T) ulid.ULID) ([]T, Cursor) {
+	if len(items) <= limit {
+		return items, Cursor{}
+	}
+	items = items[:limit]
+	next := id(items[limit-1])
+	return items, Cursor{
+		NextCursor: &next,
+		HasMore:    true,
+	}
+}
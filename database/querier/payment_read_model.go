@@ -0,0 +1,226 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+type PaymentQuerier struct {
+	pool *pgxpool.Pool
+}
+
+const paymentColumns = `
+	id,
+	created_at,
+	updated_at,
+	deleted_at,
+	customer_id,
+	amount,
+	unallocated_amount,
+	currency,
+	method,
+	reference,
+	received_at
+`
+
+func scanPayment(row pgx.Row, item *model.Payment) error {
+	err := row.Scan(
+		&item.ID,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+		&item.DeletedAt,
+		&item.CustomerID,
+		&item.Amount,
+		&item.Unallocated,
+		&item.Amount.Currency,
+		&item.Method,
+		&item.Reference,
+		&item.ReceivedAt,
+	)
+	item.Unallocated.Currency = item.Amount.Currency
+	return err
+}
+
+func (q *PaymentQuerier) Fetch(ctx context.Context) (res PaymentList, err error) {
+	rows, err := q.pool.Query(
+		ctx,
+		`SELECT `+paymentColumns+`
+		FROM payments
+		WHERE deleted_at IS NULL
+		ORDER BY id;`,
+	)
+	if err != nil {
+		return emptyPayments, err
+	}
+	defer rows.Close()
+
+	var items []model.Payment
+	for rows.Next() {
+		var item model.Payment
+		if err := scanPayment(rows, &item); err != nil {
+			return emptyPayments, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return emptyPayments, err
+	}
+
+	if len(items) == 0 {
+		return emptyPayments, nil
+	}
+
+	var ids = make([]ulid.ULID, len(items))
+	for idx := range items {
+		ids[idx] = items[idx].ID
+	}
+
+	allocations, err := fetchPaymentAllocations(ctx, q.pool, ids)
+	if err != nil {
+		return emptyPayments, err
+	}
+
+	for idx := range items {
+		items[idx].Allocations = allocations[items[idx].ID]
+		if items[idx].Allocations == nil {
+			items[idx].Allocations = []model.PaymentAllocation{}
+		}
+	}
+
+	list := PaymentList{
+		Count: len(items),
+		Data:  items,
+	}
+
+	return list, nil
+}
+
+func (q *PaymentQuerier) GetOneByID(ctx context.Context, id ulid.ULID) (res model.Payment, err error) {
+	row := q.pool.QueryRow(
+		ctx,
+		`SELECT `+paymentColumns+`
+		FROM payments
+		WHERE id = $1;`,
+		id,
+	)
+	var item model.Payment
+	if err := scanPayment(row, &item); err != nil {
+		if err == pgx.ErrNoRows {
+			return item, model.ErrPaymentNotFound
+		}
+		return item, err
+	}
+	if item.DeletedAt.Valid {
+		return item, model.ErrPaymentAlreadyDeleted
+	}
+
+	allocations, err := fetchPaymentAllocations(ctx, q.pool, []ulid.ULID{id})
+	if err != nil {
+		return item, err
+	}
+	item.Allocations = allocations[id]
+	if item.Allocations == nil {
+		item.Allocations = []model.PaymentAllocation{}
+	}
+
+	return item, nil
+}
+
+// CustomerCredit is what the customer has paid but not yet allocated to any
+// invoice, one amount per currency.
+func (q *PaymentQuerier) CustomerCredit(ctx context.Context, customerID ulid.ULID) (res []model.Money, err error) {
+	rows, err := q.pool.Query(
+		ctx,
+		`SELECT
+			SUM(unallocated_amount),
+			currency
+		FROM payments
+		WHERE customer_id = $1
+			AND deleted_at IS NULL
+			AND unallocated_amount > 0
+		GROUP BY currency
+		ORDER BY currency;`,
+		customerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var credit = []model.Money{}
+	for rows.Next() {
+		var m model.Money
+		if err := rows.Scan(&m, &m.Currency); err != nil {
+			return nil, err
+		}
+		credit = append(credit, m)
+	}
+
+	return credit, rows.Err()
+}
+
+func fetchPaymentAllocations(ctx context.Context, db dbtx, paymentIds []ulid.ULID) (map[ulid.ULID][]model.PaymentAllocation, error) {
+	rows, err := db.Query(ctx, `
+		SELECT
+			a.id,
+			a.created_at,
+			a.payment_id,
+			a.invoice_id,
+			a.amount,
+			p.currency
+		FROM payment_allocations a
+		JOIN payments p ON p.id = a.payment_id
+		WHERE a.payment_id = ANY($1::BYTEA[])
+		ORDER BY a.payment_id, a.id;
+	`, paymentIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var allocations = make(map[ulid.ULID][]model.PaymentAllocation, len(paymentIds))
+	for rows.Next() {
+		var a model.PaymentAllocation
+		if err := rows.Scan(
+			&a.ID,
+			&a.CreatedAt,
+			&a.PaymentID,
+			&a.InvoiceID,
+			&a.Amount,
+			&a.Amount.Currency,
+		); err != nil {
+			return nil, err
+		}
+		allocations[a.PaymentID] = append(allocations[a.PaymentID], a)
+	}
+
+	return allocations, rows.Err()
+}
+
+type PaymentList struct {
+	Count int             `json:"count"`
+	Data  []model.Payment `json:"data"`
+}
+
+var emptyPayments = PaymentList{
+	Count: 0,
+	Data:  []model.Payment{},
+}
+
+type PaymentReadModel interface {
+	Fetch(ctx context.Context) (res PaymentList, err error)
+	GetOneByID(ctx context.Context, id ulid.ULID) (res model.Payment, err error)
+	CustomerCredit(ctx context.Context, customerID ulid.ULID) (res []model.Money, err error)
+}
+
+func NewPaymentReadModel(
+	pool *pgxpool.Pool,
+) PaymentReadModel {
+	return &PaymentQuerier{
+		pool: pool,
+	}
+}
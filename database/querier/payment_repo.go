@@ -0,0 +1,219 @@
+package querier
+
+import (
+	"bytes"
+	"context"
+	"flukis/invokiss/app/model"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+// Allocation asks for amount of a payment to be applied to an invoice.
+type Allocation struct {
+	InvoiceID ulid.ULID
+	Amount    model.Money
+}
+
+// Save records a received payment and applies it to the given invoices in
+// the same transaction. Whatever is left stays on the payment as customer
+// credit.
+func (q *PaymentQuerier) Save(ctx context.Context, data model.Payment, allocations []Allocation) (model.Payment, error) {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return data, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertPayment(ctx, tx, data); err != nil {
+		return data, err
+	}
+
+	if err := allocatePayment(ctx, tx, &data, allocations); err != nil {
+		return data, err
+	}
+
+	return data, tx.Commit(ctx)
+}
+
+func insertPayment(ctx context.Context, tx pgx.Tx, data model.Payment) error {
+	query := `
+		INSERT INTO payments (
+			id,
+			created_at,
+			customer_id,
+			amount,
+			unallocated_amount,
+			currency,
+			method,
+			reference,
+			received_at
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7,
+			$8,
+			$9
+		);
+	`
+	_, err := tx.Exec(
+		ctx,
+		query,
+		data.ID,
+		data.CreatedAt,
+		data.CustomerID,
+		data.Amount,
+		data.Unallocated,
+		data.Amount.Currency,
+		data.Method,
+		data.Reference,
+		data.ReceivedAt,
+	)
+	return err
+}
+
+// Allocate applies the unallocated part of an existing payment, i.e. the
+// customer's credit, to invoices.
+func (q *PaymentQuerier) Allocate(ctx context.Context, id ulid.ULID, allocations []Allocation) (model.Payment, error) {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return model.Payment{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	row := tx.QueryRow(
+		ctx,
+		`SELECT `+paymentColumns+`
+		FROM payments
+		WHERE id = $1
+		FOR UPDATE;`,
+		id,
+	)
+	var item model.Payment
+	if err := scanPayment(row, &item); err != nil {
+		if err == pgx.ErrNoRows {
+			return item, model.ErrPaymentNotFound
+		}
+		return item, err
+	}
+	if item.DeletedAt.Valid {
+		return item, model.ErrPaymentAlreadyDeleted
+	}
+
+	existing, err := fetchPaymentAllocations(ctx, tx, []ulid.ULID{id})
+	if err != nil {
+		return item, err
+	}
+	item.Allocations = append([]model.PaymentAllocation{}, existing[id]...)
+
+	if err := allocatePayment(ctx, tx, &item, allocations); err != nil {
+		return item, err
+	}
+
+	return item, tx.Commit(ctx)
+}
+
+// allocatePayment locks every invoice involved, lets the model apply the
+// amounts and stores invoices, allocations and the payment's remainder.
+// Invoices are locked in id order so concurrent allocations cannot deadlock.
+func allocatePayment(ctx context.Context, tx pgx.Tx, p *model.Payment, allocations []Allocation) error {
+	if len(allocations) == 0 {
+		return nil
+	}
+
+	sorted := append([]Allocation{}, allocations...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].InvoiceID[:], sorted[j].InvoiceID[:]) < 0
+	})
+
+	now := time.Now()
+	for _, a := range sorted {
+		inv, err := lockInvoice(ctx, tx, a.InvoiceID)
+		if err != nil {
+			return err
+		}
+		lines, err := fetchInvoiceLines(ctx, tx, []ulid.ULID{inv.ID})
+		if err != nil {
+			return err
+		}
+		inv.Lines = lines[inv.ID]
+
+		allocation, err := p.Allocate(&inv, a.Amount, now)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			ctx,
+			`UPDATE invoices
+			SET
+				paid_amount = $2,
+				status = $3,
+				updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1;`,
+			inv.ID,
+			inv.PaidAmount,
+			inv.Status,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			ctx,
+			`INSERT INTO payment_allocations (
+				id,
+				created_at,
+				payment_id,
+				invoice_id,
+				amount
+			) VALUES (
+				$1,
+				$2,
+				$3,
+				$4,
+				$5
+			);`,
+			allocation.ID,
+			allocation.CreatedAt,
+			allocation.PaymentID,
+			allocation.InvoiceID,
+			allocation.Amount,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.Exec(
+		ctx,
+		`UPDATE payments
+		SET
+			unallocated_amount = $2,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1;`,
+		p.ID,
+		p.Unallocated,
+	)
+	return err
+}
+
+type PaymentWriteModel interface {
+	Save(ctx context.Context, data model.Payment, allocations []Allocation) (model.Payment, error)
+	Allocate(ctx context.Context, id ulid.ULID, allocations []Allocation) (model.Payment, error)
+}
+
+func NewPaymentWriteModel(
+	pool *pgxpool.Pool,
+) PaymentWriteModel {
+	return &PaymentQuerier{
+		pool: pool,
+	}
+}
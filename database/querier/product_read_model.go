@@ -4,9 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"flukis/invokiss/app/model"
-	"fmt"
+	"flukis/invokiss/app/notify"
 	"strings"
-	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -15,142 +14,245 @@ import (
 )
 
 type ProductQuerier struct {
-	pool *pgxpool.Pool
+	pool     *pgxpool.Pool
+	notifier notify.Notifier
 }
 
-// FetchByCategoryID implements ProductReadModel.
-func (q *ProductQuerier) FetchByCategoryID(ctx context.Context, filt []ulid.ULID) (res ProductList, err error) {
-	var itemCount int
+// ProductFilter narrows and orders a product list. Sort is one of name,
+// amount, created_at or stock. IncludeDescendants makes a category filter
+// match the products of its subcategories too.
+type ProductFilter struct {
+	Page
+
+	IncludeDescendants bool
+
+	// Currency limits the list to the products priced in it. Amounts only
+	// compare within one currency, so filtering or sorting by amount needs
+	// it.
+	Currency     string
+	MinAmount    *model.Money
+	MaxAmount    *model.Money
+	InStock      bool
+	CreatedSince null.Time
+}
 
-	row := q.pool.QueryRow(
-		ctx,
-		`
-			SELECT
-				COUNT(p.id) as cc
-			FROM
-				products p
-			LEFT JOIN
-				category_products cp ON p.id = cp.product_id
-			LEFT JOIN
-				categories c ON cp.category_id = c.id
-			WHERE
-				cp.category_id = ANY($1::BYTEA[])
-		`,
-		filt,
-	)
-	if err := row.Scan(&itemCount); err != nil {
-		return emptyProducts, err
+// productStock joins the on-hand quantity of the product aliased p, summed
+// over its warehouses, as i.quantity.
+const productStock = `LEFT JOIN LATERAL (
+	SELECT SUM(quantity) AS quantity
+	FROM inventories
+	WHERE product_id = p.id
+) i ON true`
+
+// productReserved sums the unexpired reservations of the product aliased p.
+const productReserved = `COALESCE(
+	(
+		SELECT SUM(r.quantity)
+		FROM stock_reservations r
+		WHERE r.product_id = p.id AND r.expires_at > CURRENT_TIMESTAMP
+	),
+	0
+)`
+
+// productInTransit sums what is being transferred of the product aliased p.
+const productInTransit = `COALESCE(
+	(
+		SELECT SUM(t.quantity)
+		FROM warehouse_transfers t
+		WHERE t.product_id = p.id AND t.status = 'in_transit'
+	),
+	0
+)`
+
+var productSorts = map[string]string{
+	"name":       "p.name",
+	"amount":     "p.amount",
+	"created_at": "p.created_at",
+	"stock":      "COALESCE(i.quantity, 0)",
+}
+
+// FetchByCategoryID lists the active products in any of the categories.
+func (q *ProductQuerier) FetchByCategoryID(ctx context.Context, filt []ulid.ULID, filter ProductFilter) (res ProductList, err error) {
+	if filt == nil {
+		filt = []ulid.ULID{}
 	}
+	return q.fetchProducts(ctx, filt, filter)
+}
 
-	if itemCount == 0 {
-		return emptyProducts, nil
+// Fetch lists the active products.
+func (q *ProductQuerier) Fetch(ctx context.Context, filter ProductFilter) (res ProductList, err error) {
+	return q.fetchProducts(ctx, nil, filter)
+}
+
+// fetchProducts pages through the active products, only those in one of
+// categoryIDs unless it is nil.
+func (q *ProductQuerier) fetchProducts(ctx context.Context, categoryIDs []ulid.ULID, filter ProductFilter) (ProductList, error) {
+	var args queryArgs
+	var conds = []string{"p.deleted_at IS NULL"}
+
+	if categoryIDs != nil && filter.IncludeDescendants {
+		conds = append(conds, `EXISTS (
+			WITH RECURSIVE subtree AS (
+				SELECT id
+				FROM categories
+				WHERE id = ANY(`+args.add(categoryIDs)+`::BYTEA[])
+				UNION
+				SELECT c.id
+				FROM categories c
+				JOIN subtree s ON c.parent_id = s.id
+				WHERE c.deleted_at IS NULL
+			)
+			SELECT 1
+			FROM category_products cp
+			JOIN subtree s ON cp.category_id = s.id
+			WHERE cp.product_id = p.id
+		)`)
+	} else if categoryIDs != nil {
+		conds = append(conds, `EXISTS (
+			SELECT 1
+			FROM category_products cp
+			WHERE cp.product_id = p.id
+				AND cp.category_id = ANY(`+args.add(categoryIDs)+`::BYTEA[])
+		)`)
+	}
+	if filter.Currency == "" && (filter.MinAmount != nil || filter.MaxAmount != nil || filter.Sort == "amount") {
+		return emptyProducts, model.ErrProductPriceCurrency
+	}
+	if filter.Currency != "" {
+		conds = append(conds, "p.currency = "+args.add(filter.Currency))
+	}
+	if filter.MinAmount != nil {
+		conds = append(conds, "p.amount >= "+args.add(*filter.MinAmount))
+	}
+	if filter.MaxAmount != nil {
+		conds = append(conds, "p.amount <= "+args.add(*filter.MaxAmount))
+	}
+	if filter.InStock {
+		conds = append(conds, "COALESCE(i.quantity, 0) > 0")
+	}
+	if filter.CreatedSince.Valid {
+		conds = append(conds, "p.created_at >= "+args.add(filter.CreatedSince))
 	}
 
-	fmt.Println(itemCount)
+	sortExpr, ok := productSorts[filter.Sort]
+	if !ok {
+		sortExpr = "p.id"
+	}
+	after, order := filter.keyset(&args, sortExpr, "p.id", func(id string) string {
+		return `SELECT ` + sortExpr + `, p.id
+			FROM products p
+			` + productStock + `
+			WHERE p.id = ` + id
+	})
+	if after != "" {
+		conds = append(conds, after)
+	}
 
-	items := make([]model.Product, itemCount)
+	limit := filter.limit()
 	rows, err := q.pool.Query(
 		ctx,
 		`
 			SELECT
-				p.id AS product_id,
+				p.id,
 				p.created_at,
 				p.sku,
-				p.name AS product_name,
-				p.description AS product_description,
+				p.name,
+				p.description,
 				p.amount,
+				p.currency,
+				p.tax_class,
+				p.price_includes_tax,
+				p.allow_backorder,
 				p.image,
-				JSON_AGG(
-					JSON_BUILD_OBJECT(
-						'name', c.name
-					)
+				COALESCE(
+					(
+						SELECT JSON_AGG(JSON_BUILD_OBJECT('name', c.name) ORDER BY c.name)
+						FROM category_products cp
+						JOIN categories c ON cp.category_id = c.id
+						WHERE cp.product_id = p.id
+					),
+					'[]'
 				) AS categories,
-				i.quantity AS inventory_quantity
+				COALESCE(i.quantity, 0) AS inventory_quantity,
+				`+productReserved+` AS inventory_reserved,
+				`+productInTransit+` AS inventory_in_transit
 			FROM
 				products p
-			LEFT JOIN
-				category_products cp ON p.id = cp.product_id
-			LEFT JOIN
-				categories c ON cp.category_id = c.id
-			LEFT JOIN
-				inventories i ON p.inventory_id = i.id  -- Join with inventories table
+			`+productStock+`
 			WHERE
-				cp.category_id = ANY($1::BYTEA[])
-			GROUP BY
-				p.id, p.created_at, p.sku, p.name, p.description, p.amount, p.image, i.quantity
+				`+strings.Join(conds, " AND ")+`
 			ORDER BY
-				p.id;	
+				`+order+`
+			LIMIT `+args.add(limit+1)+`;
 		`,
-		filt,
+		args...,
 	)
-
 	if err != nil {
 		return emptyProducts, err
 	}
-
 	defer rows.Close()
-	var i int
-	for i = range items {
+
+	var items []model.Product
+	for rows.Next() {
 		var (
-			id          ulid.ULID
-			sku         string
-			name        string
-			description string
-			image       *[]byte
-			amount      float64
-			createdAt   time.Time
-			cats        []byte
-			inventory   int
+			item model.Product
+			cats []byte
 		)
-
-		if !rows.Next() {
-			break
-		}
 		if err := rows.Scan(
-			&id,
-			&createdAt,
-			&sku,
-			&name,
-			&description,
-			&amount,
-			&image,
+			&item.ID,
+			&item.CreatedAt,
+			&item.Sku,
+			&item.Name,
+			&item.Description,
+			&item.Amount,
+			&item.Amount.Currency,
+			&item.TaxClass,
+			&item.PriceIncludesTax,
+			&item.AllowBackorder,
+			&item.Image,
 			&cats,
-			&inventory,
+			&item.Inventory.Quantity,
+			&item.Inventory.Reserved,
+			&item.Inventory.InTransit,
 		); err != nil {
 			return emptyProducts, err
 		}
 
-		var categories []model.Category
-
-		if err := json.Unmarshal(
-			cats,
-			&categories,
-		); err != nil {
-			continue
+		if err := json.Unmarshal(cats, &item.Categories); err != nil {
+			return emptyProducts, err
 		}
 
-		var inv = model.Inventory{
-			Quantity: inventory,
-		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return emptyProducts, err
+	}
 
-		items[i] = model.Product{
-			ID:          id,
-			Sku:         sku,
-			Name:        name,
-			Description: description,
-			Image:       image,
-			Amount:      amount,
-			Categories:  categories,
-			Inventory:   inv,
-		}
+	if len(items) == 0 {
+		return emptyProducts, nil
 	}
 
-	list := ProductList{
-		Count: itemCount,
-		Data:  items,
+	items, cursor := pageCursor(items, limit, func(p model.Product) ulid.ULID {
+		return p.ID
+	})
+
+	var ids = make([]ulid.ULID, len(items))
+	for idx := range items {
+		ids[idx] = items[idx].ID
+	}
+	locations, err := fetchStockLocations(ctx, q.pool, ids)
+	if err != nil {
+		return emptyProducts, err
+	}
+	for idx := range items {
+		items[idx].Locations = locations[items[idx].ID]
 	}
 
-	return list, nil
+	return ProductList{
+		Count:  len(items),
+		Data:   items,
+		Cursor: cursor,
+	}, nil
 }
 
 func (q *ProductQuerier) GetOneByID(ctx context.Context, id ulid.ULID) (res model.Product, err error) {
@@ -162,14 +264,19 @@ func (q *ProductQuerier) GetOneByID(ctx context.Context, id ulid.ULID) (res mode
 			p.name AS product_name,
 			p.description AS product_description,
 			p.amount,
+			p.currency,
+			p.tax_class,
+			p.price_includes_tax,
+			p.allow_backorder,
 			p.image,
-			i.quantity AS inventory_quantity,
+			COALESCE(i.quantity, 0) AS inventory_quantity,
+			` + productReserved + ` AS inventory_reserved,
+			` + productInTransit + ` AS inventory_in_transit,
 			p.updated_at,
 			p.deleted_at
 		FROM
 			products p
-		LEFT JOIN
-			inventories i ON p.inventory_id = i.id
+		` + productStock + `
 		WHERE
 			p.id = $1;
 	`
@@ -186,8 +293,14 @@ func (q *ProductQuerier) GetOneByID(ctx context.Context, id ulid.ULID) (res mode
 		&item.Name,
 		&item.Description,
 		&item.Amount,
+		&item.Amount.Currency,
+		&item.TaxClass,
+		&item.PriceIncludesTax,
+		&item.AllowBackorder,
 		&item.Image,
 		&item.Inventory.Quantity,
+		&item.Inventory.Reserved,
+		&item.Inventory.InTransit,
 		&item.UpdatedAt,
 		&item.DeletedAt,
 	); err != nil {
@@ -200,6 +313,12 @@ func (q *ProductQuerier) GetOneByID(ctx context.Context, id ulid.ULID) (res mode
 		return item, model.ErrProductAlreadyDeleted
 	}
 
+	locations, err := fetchStockLocations(ctx, q.pool, []ulid.ULID{item.ID})
+	if err != nil {
+		return item, err
+	}
+	item.Locations = locations[item.ID]
+
 	rows, err := q.pool.Query(ctx, `
 		SELECT category_id, product_id
 		FROM category_products
@@ -252,111 +371,70 @@ func (q *ProductQuerier) GetOneByID(ctx context.Context, id ulid.ULID) (res mode
 	return item, nil
 }
 
-func (q *ProductQuerier) Fetch(ctx context.Context) (res ProductList, err error) {
-	var itemCount int
-
-	row := q.pool.QueryRow(
-		ctx,
-		`
-			SELECT
-				COUNT(id) as c
-			FROM products;
-
-		`,
-	)
-	if err := row.Scan(&itemCount); err != nil {
-		return emptyProducts, err
-	}
-
-	if itemCount == 0 {
-		return emptyProducts, nil
-	}
-
-	items := make([]model.Product, itemCount)
-	rows, err := q.pool.Query(
-		ctx,
-		`
-			SELECT
-				p.id AS product_id,
-				p.sku,
-				p.name AS product_name,
-				p.description AS product_description,
-				p.amount,
-				p.image,
-				STRING_AGG(c.name, ',' ORDER BY c.name) AS category_names
-			FROM
-				products p
-			LEFT JOIN
-				category_products cp ON p.id = cp.product_id
-			LEFT JOIN
-				categories c ON cp.category_id = c.id
-			GROUP BY
-				p.id, p.sku, p.name, p.description, p.amount, p.image
-			ORDER BY p.id;
-		`,
-	)
-
+// fetchStockLocations reads the stock per warehouse of the products, the
+// default warehouse first. Every product has an entry, if only an empty one.
+func fetchStockLocations(ctx context.Context, db dbtx, productIDs []ulid.ULID) (map[ulid.ULID][]model.StockLocation, error) {
+	rows, err := db.Query(ctx, `
+		SELECT
+			i.product_id,
+			w.id,
+			w.code,
+			i.quantity,
+			`+reservedQuantity+`,
+			COALESCE(
+				(
+					SELECT SUM(t.quantity)
+					FROM warehouse_transfers t
+					WHERE t.product_id = i.product_id
+						AND t.to_warehouse_id = i.warehouse_id
+						AND t.status = 'in_transit'
+				),
+				0
+			),
+			i.reorder_point,
+			i.reorder_quantity
+		FROM inventories i
+		JOIN warehouses w ON i.warehouse_id = w.id
+		WHERE i.product_id = ANY($1::BYTEA[])
+		ORDER BY i.product_id, w.is_default DESC, w.code;
+	`, productIDs)
 	if err != nil {
-		return emptyProducts, err
+		return nil, err
 	}
-
 	defer rows.Close()
-	var i int
-	for i = range items {
-		var id ulid.ULID
-		var sku string
-		var name string
-		var description string
-		var image *[]byte
-		var amount float64
-		var cat null.String
-		if !rows.Next() {
-			break
-		}
+
+	var locations = make(map[ulid.ULID][]model.StockLocation, len(productIDs))
+	for _, id := range productIDs {
+		locations[id] = []model.StockLocation{}
+	}
+	for rows.Next() {
+		var (
+			productID ulid.ULID
+			l         model.StockLocation
+		)
 		if err := rows.Scan(
-			&id,
-			&sku,
-			&name,
-			&description,
-			&amount,
-			&image,
-			&cat,
+			&productID,
+			&l.WarehouseID,
+			&l.WarehouseCode,
+			&l.OnHand,
+			&l.Reserved,
+			&l.InTransit,
+			&l.ReorderPoint,
+			&l.ReorderQuantity,
 		); err != nil {
-			return emptyProducts, err
+			return nil, err
 		}
-
-		var categoryNames []string
-		if cat.Valid {
-			categoryNames = strings.Split(cat.String, ",")
-		}
-
-		var categories = make([]model.Category, len(categoryNames))
-		for idx := range categoryNames {
-			categories[idx].Name = categoryNames[idx]
-		}
-
-		items[i] = model.Product{
-			ID:          id,
-			Sku:         sku,
-			Name:        name,
-			Description: description,
-			Image:       image,
-			Amount:      amount,
-			Categories:  categories,
-		}
-	}
-
-	list := ProductList{
-		Count: itemCount,
-		Data:  items,
+		l.Available = l.OnHand - l.Reserved
+		locations[productID] = append(locations[productID], l)
 	}
 
-	return list, nil
+	return locations, rows.Err()
 }
 
 type ProductList struct {
 	Count int             `json:"count"`
 	Data  []model.Product `json:"data"`
+	Cursor
 }
 
 var emptyProducts = ProductList{
@@ -365,8 +443,9 @@ var emptyProducts = ProductList{
 }
 
 type ProductReadModel interface {
-	FetchByCategoryID(ctx context.Context, filt []ulid.ULID) (res ProductList, err error)
-	Fetch(ctx context.Context) (res ProductList, err error)
+	FetchByCategoryID(ctx context.Context, filt []ulid.ULID, filter ProductFilter) (res ProductList, err error)
+	Fetch(ctx context.Context, filter ProductFilter) (res ProductList, err error)
+	Search(ctx context.Context, text string, categoryIDs []ulid.ULID, limit int) (res ProductSearchList, err error)
 	GetOneByID(ctx context.Context, id ulid.ULID) (res model.Product, err error)
 }
 
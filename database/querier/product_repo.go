@@ -3,37 +3,59 @@ package querier
 import (
 	"context"
 	"errors"
+	"flukis/invokiss/app/auth"
 	"flukis/invokiss/app/model"
+	"flukis/invokiss/app/notify"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
 )
 
+// Save creates or updates the product. A new product is stocked in the
+// default warehouse. Its inventory starts empty and the initial quantity is
+// booked as the opening entry of its stock ledger; afterwards stock only
+// changes through the ledger.
 func (q *ProductQuerier) Save(ctx context.Context, data model.Product) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	warehouseID, err := defaultWarehouse(ctx, tx)
+	if err != nil {
+		return err
+	}
+
 	queryInv := `
 		INSERT INTO inventories (
 			id,
 			created_at,
+			product_id,
+			warehouse_id,
 			quantity
 		) VALUES (
 			$1,
 			$2,
-			$3
+			$3,
+			$4,
+			0
 		) ON CONFLICT(id)
-		DO UPDATE SET
-			created_at = EXCLUDED.created_at,
-			quantity = EXCLUDED.quantity,
-			updated_at = CURRENT_TIMESTAMP;
+		DO NOTHING;
 	`
-	_, err := q.pool.Exec(
+	created, err := tx.Exec(
 		ctx,
 		queryInv,
 		data.Inventory.ID,
 		data.Inventory.CreatedAt,
-		data.Inventory.Quantity,
+		data.ID,
+		warehouseID,
 	)
 
 	if err != nil {
@@ -49,6 +71,10 @@ func (q *ProductQuerier) Save(ctx context.Context, data model.Product) error {
 			description,
 			image,
 			amount,
+			currency,
+			tax_class,
+			price_includes_tax,
+			allow_backorder,
 			inventory_id
 		) VALUES (
 			$1,
@@ -58,7 +84,11 @@ func (q *ProductQuerier) Save(ctx context.Context, data model.Product) error {
 			$5,
 			$6,
 			$7,
-			$8
+			$8,
+			$9,
+			$10,
+			$11,
+			$12
 		) ON CONFLICT(id)
 		DO UPDATE SET
 			created_at = EXCLUDED.created_at,
@@ -67,10 +97,14 @@ func (q *ProductQuerier) Save(ctx context.Context, data model.Product) error {
 			description = EXCLUDED.description,
 			image = EXCLUDED.image,
 			amount = EXCLUDED.amount,
+			currency = EXCLUDED.currency,
+			tax_class = EXCLUDED.tax_class,
+			price_includes_tax = EXCLUDED.price_includes_tax,
+			allow_backorder = EXCLUDED.allow_backorder,
 			inventory_id = EXCLUDED.inventory_id,
 			updated_at = CURRENT_TIMESTAMP;
 	`
-	_, err = q.pool.Exec(
+	_, err = tx.Exec(
 		ctx,
 		query,
 		data.ID,
@@ -80,6 +114,10 @@ func (q *ProductQuerier) Save(ctx context.Context, data model.Product) error {
 		data.Description,
 		data.Image,
 		data.Amount,
+		data.Amount.Currency,
+		data.TaxClass,
+		data.PriceIncludesTax,
+		data.AllowBackorder,
 		data.Inventory.ID,
 	)
 
@@ -94,6 +132,31 @@ func (q *ProductQuerier) Save(ctx context.Context, data model.Product) error {
 		return err
 	}
 
+	var alerts stockAlerts
+	if created.RowsAffected() == 1 && data.Inventory.Quantity != 0 {
+		inv := data.Inventory
+		inv.Quantity = 0
+		movement, err := inv.Move(
+			data.ID,
+			model.StockAdjustment,
+			data.Inventory.Quantity,
+			"opening balance",
+			"",
+			auth.ActorFrom(ctx),
+			data.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		if err := recordStockMovement(ctx, tx, inv, movement, &alerts); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	alerts.notify(ctx, q.notifier)
 	return nil
 }
 
@@ -132,6 +195,7 @@ func (q *ProductQuerier) AssignCategories(ctx context.Context, productId ulid.UL
 		INSERT INTO category_products
 			(product_id, category_id)
 		VALUES %s
+		ON CONFLICT DO NOTHING
 	`, statement)
 
 	_, err := q.pool.Exec(
@@ -147,27 +211,330 @@ func (q *ProductQuerier) AssignCategories(ctx context.Context, productId ulid.UL
 	return nil
 }
 
-// AssignQuantity implements ProductWriteModel.
-func (q *ProductQuerier) AssignQuantity(ctx context.Context, productId ulid.ULID, qty int) error {
-	query := `
-		UPDATE inventories
-		SET quantity = $2
-		WHERE id = (SELECT inventory_id FROM products WHERE id = $1);
-	`
-	_, err := q.pool.Exec(
+// ReplaceCategories makes ids the product's whole category set: pairs no
+// longer wanted are removed and missing ones added, in one transaction.
+func (q *ProductQuerier) ReplaceCategories(ctx context.Context, productId ulid.ULID, ids []ulid.ULID) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	deletedAt, err := lockProduct(ctx, tx, productId)
+	if err != nil {
+		return err
+	}
+	if deletedAt.Valid {
+		return model.ErrProductAlreadyDeleted
+	}
+
+	var active int
+	row := tx.QueryRow(
 		ctx,
-		query,
+		`SELECT COUNT(id)
+		FROM categories
+		WHERE id = ANY($1::BYTEA[])
+			AND deleted_at IS NULL;`,
+		ids,
+	)
+	if err := row.Scan(&active); err != nil {
+		return err
+	}
+	var unique = make(map[ulid.ULID]struct{}, len(ids))
+	for _, id := range ids {
+		unique[id] = struct{}{}
+	}
+	if active != len(unique) {
+		return model.ErrCategoryNotFound
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`DELETE FROM category_products
+		WHERE product_id = $1
+			AND NOT (category_id = ANY($2::BYTEA[]));`,
 		productId,
-		qty,
+		ids,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`INSERT INTO category_products (product_id, category_id)
+		SELECT $1, UNNEST($2::BYTEA[])
+		ON CONFLICT DO NOTHING;`,
+		productId,
+		ids,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Restore undoes the soft delete of a product.
+func (q *ProductQuerier) Restore(ctx context.Context, id ulid.ULID) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	deletedAt, err := lockProduct(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if !deletedAt.Valid {
+		return model.ErrProductNotDeleted
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE products
+		SET deleted_at = NULL,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1;`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// lockProduct locks the product for the rest of the transaction and
+// returns when it was deleted, if it was.
+func lockProduct(ctx context.Context, tx pgx.Tx, id ulid.ULID) (null.Time, error) {
+	var deletedAt null.Time
+	row := tx.QueryRow(
+		ctx,
+		`SELECT deleted_at FROM products WHERE id = $1 FOR UPDATE;`,
+		id,
 	)
+	if err := row.Scan(&deletedAt); err != nil {
+		if err == pgx.ErrNoRows {
+			return deletedAt, model.ErrProductNotFound
+		}
+		return deletedAt, err
+	}
+	return deletedAt, nil
+}
 
+// AssignQuantity sets the on-hand quantity of the product in the warehouse,
+// or the default one when warehouseID is nil, after a stock count. The
+// difference is recorded as an adjustment in the stock ledger.
+func (q *ProductQuerier) AssignQuantity(ctx context.Context, productId ulid.ULID, warehouseID *ulid.ULID, qty int, reason, reference string) error {
+	tx, err := q.pool.Begin(ctx)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback(ctx)
 
+	var inv model.Inventory
+	if warehouseID == nil {
+		inv, err = lockInventory(ctx, tx, productId)
+	} else {
+		inv, err = lockWarehouseInventory(ctx, tx, productId, *warehouseID)
+	}
+	if err != nil {
+		return err
+	}
+	if inv.Quantity == qty {
+		return nil
+	}
+
+	movement, err := inv.Move(
+		productId,
+		model.StockAdjustment,
+		qty-inv.Quantity,
+		reason,
+		reference,
+		auth.ActorFrom(ctx),
+		time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+	var alerts stockAlerts
+	if err := recordStockMovement(ctx, tx, inv, movement, &alerts); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	alerts.notify(ctx, q.notifier)
 	return nil
 }
 
+// AdjustQuantity moves the on-hand quantity of the product in the
+// warehouse, or the default one when warehouseID is nil, by the signed
+// quantity of the movement. The change and its ledger entry are a single
+// statement, so concurrent adjustments can't lose updates. Decrements only
+// take stock that isn't reserved, unless the product allows backorders.
+func (q *ProductQuerier) AdjustQuantity(ctx context.Context, movement model.StockMovement, warehouseID *ulid.ULID) (model.StockMovement, error) {
+	if warehouseID != nil {
+		if err := ensureInventory(ctx, q.pool, movement.ProductID, *warehouseID); err != nil {
+			return movement, err
+		}
+	}
+
+	row := q.pool.QueryRow(
+		ctx,
+		`WITH moved AS (
+			UPDATE inventories i
+			SET
+				quantity = i.quantity + $2,
+				updated_at = CURRENT_TIMESTAMP
+			FROM products p
+			WHERE
+				p.id = $1
+				AND (
+					($9::BYTEA IS NULL AND i.id = p.inventory_id)
+					OR (i.product_id = p.id AND i.warehouse_id = $9)
+				)
+				AND NOT EXISTS (
+					SELECT 1 FROM warehouses w
+					WHERE w.id = i.warehouse_id AND w.deleted_at IS NOT NULL
+				)
+				AND p.deleted_at IS NULL
+				AND ($2 > 0 OR p.allow_backorder OR i.quantity + $2 >= `+reservedQuantity+`)
+			RETURNING i.id, i.warehouse_id, i.quantity, i.reorder_point, i.reorder_quantity
+		)
+		INSERT INTO stock_movements (
+			id,
+			created_at,
+			inventory_id,
+			product_id,
+			movement_type,
+			quantity,
+			quantity_after,
+			reason,
+			reference,
+			actor
+		)
+		SELECT
+			$3,
+			$4,
+			moved.id,
+			$1,
+			$5,
+			$2,
+			moved.quantity,
+			$6,
+			$7,
+			$8
+		FROM moved
+		RETURNING
+			inventory_id,
+			(SELECT warehouse_id FROM moved),
+			quantity_after,
+			(SELECT reorder_point FROM moved),
+			(SELECT reorder_quantity FROM moved);`,
+		movement.ProductID,
+		movement.Quantity,
+		movement.ID,
+		movement.CreatedAt,
+		movement.Type,
+		movement.Reason,
+		movement.Reference,
+		movement.Actor,
+		warehouseID,
+	)
+	var inv model.Inventory
+	err := row.Scan(
+		&movement.InventoryID,
+		&movement.WarehouseID,
+		&movement.QuantityAfter,
+		&inv.ReorderPoint,
+		&inv.ReorderQuantity,
+	)
+	if err == nil {
+		var alerts stockAlerts
+		alerts.add(inv, movement)
+		alerts.notify(ctx, q.notifier)
+		return movement, nil
+	}
+	if err != pgx.ErrNoRows {
+		return movement, err
+	}
+
+	// Nothing was moved; tell why.
+	var deleted bool
+	err = q.pool.QueryRow(
+		ctx,
+		`SELECT deleted_at IS NOT NULL FROM products WHERE id = $1;`,
+		movement.ProductID,
+	).Scan(&deleted)
+	switch {
+	case err == pgx.ErrNoRows:
+		return movement, model.ErrProductNotFound
+	case err != nil:
+		return movement, err
+	case deleted:
+		return movement, model.ErrProductAlreadyDeleted
+	}
+	if warehouseID != nil {
+		err = q.pool.QueryRow(
+			ctx,
+			`SELECT deleted_at IS NOT NULL FROM warehouses WHERE id = $1;`,
+			warehouseID,
+		).Scan(&deleted)
+		switch {
+		case err == pgx.ErrNoRows:
+			return movement, model.ErrWarehouseNotFound
+		case err != nil:
+			return movement, err
+		case deleted:
+			return movement, model.ErrWarehouseAlreadyDeleted
+		}
+	}
+	return movement, model.ErrInsufficientStock
+}
+
+// SetReorderPoint sets the reorder point and quantity of the product in the
+// warehouse, or the default one when warehouseID is nil. A null point
+// turns the stock alerts off.
+func (q *ProductQuerier) SetReorderPoint(ctx context.Context, productId ulid.ULID, warehouseID *ulid.ULID, point null.Int, quantity int) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var inv model.Inventory
+	if warehouseID == nil {
+		inv, err = lockInventory(ctx, tx, productId)
+	} else {
+		inv, err = lockWarehouseInventory(ctx, tx, productId, *warehouseID)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE inventories
+		SET
+			reorder_point = $2,
+			reorder_quantity = $3,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1;`,
+		inv.ID,
+		point,
+		quantity,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
 // Edit implements ProductWriteModel.
 func (q *ProductQuerier) Edit(ctx context.Context, data model.Product) error {
 	query := `		
@@ -180,6 +547,10 @@ func (q *ProductQuerier) Edit(ctx context.Context, data model.Product) error {
 			description = $5,
 			image = $6,
 			amount = $7,
+			currency = $8,
+			tax_class = $9,
+			price_includes_tax = $10,
+			allow_backorder = $11,
 			updated_at = CURRENT_TIMESTAMP
 		WHERE
 			id = $1;	
@@ -194,6 +565,10 @@ func (q *ProductQuerier) Edit(ctx context.Context, data model.Product) error {
 		data.Description,
 		data.Image,
 		data.Amount,
+		data.Amount.Currency,
+		data.TaxClass,
+		data.PriceIncludesTax,
+		data.AllowBackorder,
 	)
 
 	if err != nil {
@@ -214,14 +589,20 @@ type ProductWriteModel interface {
 	Save(ctx context.Context, data model.Product) error
 	Edit(ctx context.Context, data model.Product) error
 	AssignCategories(ctx context.Context, productId ulid.ULID, data []ulid.ULID) error
-	AssignQuantity(ctx context.Context, productId ulid.ULID, qty int) error
+	ReplaceCategories(ctx context.Context, productId ulid.ULID, ids []ulid.ULID) error
+	AssignQuantity(ctx context.Context, productId ulid.ULID, warehouseID *ulid.ULID, qty int, reason, reference string) error
+	AdjustQuantity(ctx context.Context, movement model.StockMovement, warehouseID *ulid.ULID) (model.StockMovement, error)
+	SetReorderPoint(ctx context.Context, productId ulid.ULID, warehouseID *ulid.ULID, point null.Int, quantity int) error
 	Delete(ctx context.Context, data model.Product) error
+	Restore(ctx context.Context, id ulid.ULID) error
 }
 
 func NewProductWriteModel(
 	pool *pgxpool.Pool,
+	notifier notify.Notifier,
 ) ProductWriteModel {
 	return &ProductQuerier{
-		pool: pool,
+		pool:     pool,
+		notifier: notifier,
 	}
 }
@@ -0,0 +1,179 @@
+package querier
+
+import (
+	"context"
+	"encoding/json"
+	"flukis/invokiss/app/model"
+	"strings"
+	"unicode"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// ProductSearchResult is a product matching a search, with how well it
+// matched and its name and description with the matches wrapped in
+// <mark></mark>.
+type ProductSearchResult struct {
+	Product    model.Product `json:"product"`
+	Rank       float64       `json:"rank"`
+	Highlights struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	} `json:"highlights"`
+}
+
+type ProductSearchList struct {
+	Count int                   `json:"count"`
+	Data  []ProductSearchResult `json:"data"`
+}
+
+var emptyProductSearch = ProductSearchList{
+	Count: 0,
+	Data:  []ProductSearchResult{},
+}
+
+// searchQuery turns what was typed into a tsquery that matches products
+// containing every word, or a word starting with it.
+func searchQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for idx := range words {
+		words[idx] += ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// Search finds the active products whose name or description contain the
+// words of text, or whose SKU resembles it, best matches first. Unless
+// categoryIDs is nil, only products in one of them are searched.
+func (q *ProductQuerier) Search(ctx context.Context, text string, categoryIDs []ulid.ULID, limit int) (res ProductSearchList, err error) {
+	var args queryArgs
+	tsquery := args.add(searchQuery(text))
+	sku := args.add(strings.TrimSpace(text))
+
+	var conds = []string{
+		"p.deleted_at IS NULL",
+		`(
+			p.search_vector @@ query
+			OR p.sku % ` + sku + `
+			OR p.sku ILIKE '%' || ` + sku + ` || '%'
+		)`,
+	}
+	if categoryIDs != nil {
+		conds = append(conds, `EXISTS (
+			SELECT 1
+			FROM category_products cp
+			WHERE cp.product_id = p.id
+				AND cp.category_id = ANY(`+args.add(categoryIDs)+`::BYTEA[])
+		)`)
+	}
+
+	rows, err := q.pool.Query(
+		ctx,
+		`
+			SELECT
+				p.id,
+				p.created_at,
+				p.sku,
+				p.name,
+				p.description,
+				p.amount,
+				p.currency,
+				p.tax_class,
+				p.price_includes_tax,
+				p.allow_backorder,
+				p.image,
+				COALESCE(
+					(
+						SELECT JSON_AGG(JSON_BUILD_OBJECT('name', c.name) ORDER BY c.name)
+						FROM category_products cp
+						JOIN categories c ON cp.category_id = c.id
+						WHERE cp.product_id = p.id
+					),
+					'[]'
+				) AS categories,
+				COALESCE(i.quantity, 0) AS inventory_quantity,
+				`+productReserved+` AS inventory_reserved,
+				`+productInTransit+` AS inventory_in_transit,
+				ts_rank(p.search_vector, query) + similarity(p.sku, `+sku+`) AS rank,
+				ts_headline('simple', p.name, query, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+				ts_headline('simple', p.description, query, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2')
+			FROM
+				products p
+			CROSS JOIN
+				to_tsquery('simple', `+tsquery+`) query
+			`+productStock+`
+			WHERE
+				`+strings.Join(conds, " AND ")+`
+			ORDER BY
+				rank DESC, p.id
+			LIMIT `+args.add(limit)+`;
+		`,
+		args...,
+	)
+	if err != nil {
+		return emptyProductSearch, err
+	}
+	defer rows.Close()
+
+	var items []ProductSearchResult
+	for rows.Next() {
+		var (
+			item ProductSearchResult
+			cats []byte
+		)
+		if err := rows.Scan(
+			&item.Product.ID,
+			&item.Product.CreatedAt,
+			&item.Product.Sku,
+			&item.Product.Name,
+			&item.Product.Description,
+			&item.Product.Amount,
+			&item.Product.Amount.Currency,
+			&item.Product.TaxClass,
+			&item.Product.PriceIncludesTax,
+			&item.Product.AllowBackorder,
+			&item.Product.Image,
+			&cats,
+			&item.Product.Inventory.Quantity,
+			&item.Product.Inventory.Reserved,
+			&item.Product.Inventory.InTransit,
+			&item.Rank,
+			&item.Highlights.Name,
+			&item.Highlights.Description,
+		); err != nil {
+			return emptyProductSearch, err
+		}
+
+		if err := json.Unmarshal(cats, &item.Product.Categories); err != nil {
+			return emptyProductSearch, err
+		}
+
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return emptyProductSearch, err
+	}
+
+	if len(items) == 0 {
+		return emptyProductSearch, nil
+	}
+
+	var ids = make([]ulid.ULID, len(items))
+	for idx := range items {
+		ids[idx] = items[idx].Product.ID
+	}
+	locations, err := fetchStockLocations(ctx, q.pool, ids)
+	if err != nil {
+		return emptyProductSearch, err
+	}
+	for idx := range items {
+		items[idx].Product.Locations = locations[items[idx].Product.ID]
+	}
+
+	return ProductSearchList{
+		Count: len(items),
+		Data:  items,
+	}, nil
+}
@@ -0,0 +1,199 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+type QuoteQuerier struct {
+	pool *pgxpool.Pool
+}
+
+const quoteColumns = `
+	id,
+	created_at,
+	updated_at,
+	deleted_at,
+	customer_id,
+	bill_to,
+	currency,
+	payment_term_days,
+	status,
+	expires_at,
+	accepted_at,
+	rejected_at,
+	invoice_id
+`
+
+func scanQuote(row pgx.Row, item *model.Quote) error {
+	return row.Scan(
+		&item.ID,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+		&item.DeletedAt,
+		&item.CustomerID,
+		&item.BillTo,
+		&item.Currency,
+		&item.PaymentTermDays,
+		&item.Status,
+		&item.ExpiresAt,
+		&item.AcceptedAt,
+		&item.RejectedAt,
+		&item.InvoiceID,
+	)
+}
+
+func (q *QuoteQuerier) Fetch(ctx context.Context) (res QuoteList, err error) {
+	rows, err := q.pool.Query(
+		ctx,
+		`SELECT `+quoteColumns+`
+		FROM quotes
+		WHERE deleted_at IS NULL
+		ORDER BY id;`,
+	)
+	if err != nil {
+		return emptyQuotes, err
+	}
+	defer rows.Close()
+
+	var items []model.Quote
+	for rows.Next() {
+		var item model.Quote
+		if err := scanQuote(rows, &item); err != nil {
+			return emptyQuotes, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return emptyQuotes, err
+	}
+
+	if len(items) == 0 {
+		return emptyQuotes, nil
+	}
+
+	var ids = make([]ulid.ULID, len(items))
+	for idx := range items {
+		ids[idx] = items[idx].ID
+	}
+
+	lines, err := fetchQuoteLines(ctx, q.pool, ids)
+	if err != nil {
+		return emptyQuotes, err
+	}
+
+	for idx := range items {
+		items[idx].Lines = lines[items[idx].ID]
+	}
+
+	list := QuoteList{
+		Count: len(items),
+		Data:  items,
+	}
+
+	return list, nil
+}
+
+func (q *QuoteQuerier) GetOneByID(ctx context.Context, id ulid.ULID) (res model.Quote, err error) {
+	row := q.pool.QueryRow(
+		ctx,
+		`SELECT `+quoteColumns+`
+		FROM quotes
+		WHERE id = $1;`,
+		id,
+	)
+	var item model.Quote
+	if err := scanQuote(row, &item); err != nil {
+		if err == pgx.ErrNoRows {
+			return item, model.ErrQuoteNotFound
+		}
+		return item, err
+	}
+	if item.DeletedAt.Valid {
+		return item, model.ErrQuoteAlreadyDeleted
+	}
+
+	lines, err := fetchQuoteLines(ctx, q.pool, []ulid.ULID{id})
+	if err != nil {
+		return item, err
+	}
+	item.Lines = lines[id]
+
+	return item, nil
+}
+
+func fetchQuoteLines(ctx context.Context, db dbtx, quoteIds []ulid.ULID) (map[ulid.ULID][]model.QuoteLine, error) {
+	rows, err := db.Query(ctx, `
+		SELECT
+			id,
+			quote_id,
+			product_id,
+			sku,
+			name,
+			amount,
+			currency,
+			quantity,
+			tax_class,
+			tax_name,
+			tax_rate,
+			price_includes_tax
+		FROM quote_lines
+		WHERE quote_id = ANY($1::BYTEA[])
+		ORDER BY quote_id, position;
+	`, quoteIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines = make(map[ulid.ULID][]model.QuoteLine, len(quoteIds))
+	for rows.Next() {
+		var l model.QuoteLine
+		if err := rows.Scan(
+			&l.ID,
+			&l.QuoteID,
+			&l.ProductID,
+			&l.Sku,
+			&l.Name,
+			&l.Amount,
+			&l.Amount.Currency,
+			&l.Quantity,
+			&l.TaxClass,
+			&l.TaxName,
+			&l.TaxRate,
+			&l.PriceIncludesTax,
+		); err != nil {
+			return nil, err
+		}
+		lines[l.QuoteID] = append(lines[l.QuoteID], l)
+	}
+
+	return lines, rows.Err()
+}
+
+type QuoteList struct {
+	Count int           `json:"count"`
+	Data  []model.Quote `json:"data"`
+}
+
+var emptyQuotes = QuoteList{
+	Count: 0,
+	Data:  []model.Quote{},
+}
+
+type QuoteReadModel interface {
+	Fetch(ctx context.Context) (res QuoteList, err error)
+	GetOneByID(ctx context.Context, id ulid.ULID) (res model.Quote, err error)
+}
+
+func NewQuoteReadModel(
+	pool *pgxpool.Pool,
+) QuoteReadModel {
+	return &QuoteQuerier{
+		pool: pool,
+	}
+}
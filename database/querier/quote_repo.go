@@ -0,0 +1,244 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+func (q *QuoteQuerier) Save(ctx context.Context, data model.Quote) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO quotes (
+			id,
+			created_at,
+			customer_id,
+			bill_to,
+			currency,
+			payment_term_days,
+			status,
+			expires_at
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7,
+			$8
+		);
+	`
+	_, err = tx.Exec(
+		ctx,
+		query,
+		data.ID,
+		data.CreatedAt,
+		data.CustomerID,
+		data.BillTo,
+		data.Currency,
+		data.PaymentTermDays,
+		data.Status,
+		data.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := insertQuoteLines(ctx, tx, data.Lines); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Transition locks the quote and stores the status change made by change,
+// usually Accept or Reject of model.Quote.
+func (q *QuoteQuerier) Transition(ctx context.Context, id ulid.ULID, change func(*model.Quote) error) (model.Quote, error) {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return model.Quote{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	item, err := lockQuote(ctx, tx, id)
+	if err != nil {
+		return item, err
+	}
+
+	if err := change(&item); err != nil {
+		return item, err
+	}
+
+	if err := updateQuoteStatus(ctx, tx, item); err != nil {
+		return item, err
+	}
+
+	return item, tx.Commit(ctx)
+}
+
+// Convert creates the draft invoice of an accepted quote. The quote stays
+// locked while the invoice is written, and a quote that was converted
+// before returns its existing invoice instead, so converting is idempotent.
+// created tells whether a new invoice was made.
+func (q *QuoteQuerier) Convert(ctx context.Context, id ulid.ULID, convert func(*model.Quote) (model.Invoice, error)) (invoiceID ulid.ULID, created bool, err error) {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return invoiceID, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	item, err := lockQuote(ctx, tx, id)
+	if err != nil {
+		return invoiceID, false, err
+	}
+	if item.InvoiceID != nil {
+		return *item.InvoiceID, false, nil
+	}
+
+	lines, err := fetchQuoteLines(ctx, tx, []ulid.ULID{id})
+	if err != nil {
+		return invoiceID, false, err
+	}
+	item.Lines = lines[id]
+
+	inv, err := convert(&item)
+	if err != nil {
+		return invoiceID, false, err
+	}
+
+	if err := insertInvoice(ctx, tx, inv); err != nil {
+		return invoiceID, false, err
+	}
+
+	if err := updateQuoteStatus(ctx, tx, item); err != nil {
+		return invoiceID, false, err
+	}
+
+	return inv.ID, true, tx.Commit(ctx)
+}
+
+func updateQuoteStatus(ctx context.Context, tx pgx.Tx, item model.Quote) error {
+	query := `
+		UPDATE quotes
+		SET
+			status = $2,
+			accepted_at = $3,
+			rejected_at = $4,
+			invoice_id = $5,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1;
+	`
+	_, err := tx.Exec(
+		ctx,
+		query,
+		item.ID,
+		item.Status,
+		item.AcceptedAt,
+		item.RejectedAt,
+		item.InvoiceID,
+	)
+	return err
+}
+
+// lockQuote reads the quote header and holds a row lock on it until tx
+// ends.
+func lockQuote(ctx context.Context, tx pgx.Tx, id ulid.ULID) (model.Quote, error) {
+	row := tx.QueryRow(
+		ctx,
+		`SELECT `+quoteColumns+`
+		FROM quotes
+		WHERE id = $1
+		FOR UPDATE;`,
+		id,
+	)
+	var item model.Quote
+	if err := scanQuote(row, &item); err != nil {
+		if err == pgx.ErrNoRows {
+			return item, model.ErrQuoteNotFound
+		}
+		return item, err
+	}
+	if item.DeletedAt.Valid {
+		return item, model.ErrQuoteAlreadyDeleted
+	}
+	return item, nil
+}
+
+func insertQuoteLines(ctx context.Context, tx pgx.Tx, lines []model.QuoteLine) error {
+	query := `
+		INSERT INTO quote_lines (
+			id,
+			quote_id,
+			product_id,
+			position,
+			sku,
+			name,
+			amount,
+			currency,
+			quantity,
+			tax_class,
+			tax_name,
+			tax_rate,
+			price_includes_tax
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7,
+			$8,
+			$9,
+			$10,
+			$11,
+			$12,
+			$13
+		);
+	`
+
+	batch := &pgx.Batch{}
+	for idx := range lines {
+		batch.Queue(
+			query,
+			lines[idx].ID,
+			lines[idx].QuoteID,
+			lines[idx].ProductID,
+			idx,
+			lines[idx].Sku,
+			lines[idx].Name,
+			lines[idx].Amount,
+			lines[idx].Amount.Currency,
+			lines[idx].Quantity,
+			lines[idx].TaxClass,
+			lines[idx].TaxName,
+			lines[idx].TaxRate,
+			lines[idx].PriceIncludesTax,
+		)
+	}
+
+	return tx.SendBatch(ctx, batch).Close()
+}
+
+type QuoteWriteModel interface {
+	Save(ctx context.Context, data model.Quote) error
+	Transition(ctx context.Context, id ulid.ULID, change func(*model.Quote) error) (model.Quote, error)
+	Convert(ctx context.Context, id ulid.ULID, convert func(*model.Quote) (model.Invoice, error)) (invoiceID ulid.ULID, created bool, err error)
+}
+
+func NewQuoteWriteModel(
+	pool *pgxpool.Pool,
+) QuoteWriteModel {
+	return &QuoteQuerier{
+		pool: pool,
+	}
+}
@@ -0,0 +1,188 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/app/notify"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+type RecurringInvoiceQuerier struct {
+	pool          *pgxpool.Pool
+	invoiceNumber model.NumberFormat
+	notifier      notify.Notifier
+}
+
+const recurringInvoiceColumns = `
+	id,
+	created_at,
+	updated_at,
+	deleted_at,
+	tenant_id,
+	customer_id,
+	billing_interval,
+	anchor_day,
+	starts_at,
+	ends_at,
+	max_count,
+	auto_issue,
+	generated_count,
+	next_run_at
+`
+
+func scanRecurringInvoice(row pgx.Row, item *model.RecurringInvoice) error {
+	return row.Scan(
+		&item.ID,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+		&item.DeletedAt,
+		&item.TenantID,
+		&item.CustomerID,
+		&item.Interval,
+		&item.AnchorDay,
+		&item.StartsAt,
+		&item.EndsAt,
+		&item.MaxCount,
+		&item.AutoIssue,
+		&item.GeneratedCount,
+		&item.NextRunAt,
+	)
+}
+
+func (q *RecurringInvoiceQuerier) Fetch(ctx context.Context) (res RecurringInvoiceList, err error) {
+	rows, err := q.pool.Query(
+		ctx,
+		`SELECT `+recurringInvoiceColumns+`
+		FROM recurring_invoices
+		WHERE deleted_at IS NULL
+		ORDER BY id;`,
+	)
+	if err != nil {
+		return emptyRecurringInvoices, err
+	}
+	defer rows.Close()
+
+	var items []model.RecurringInvoice
+	for rows.Next() {
+		var item model.RecurringInvoice
+		if err := scanRecurringInvoice(rows, &item); err != nil {
+			return emptyRecurringInvoices, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return emptyRecurringInvoices, err
+	}
+
+	if len(items) == 0 {
+		return emptyRecurringInvoices, nil
+	}
+
+	var ids = make([]ulid.ULID, len(items))
+	for idx := range items {
+		ids[idx] = items[idx].ID
+	}
+
+	lines, err := fetchRecurringInvoiceLines(ctx, q.pool, ids)
+	if err != nil {
+		return emptyRecurringInvoices, err
+	}
+
+	for idx := range items {
+		items[idx].Lines = lines[items[idx].ID]
+	}
+
+	list := RecurringInvoiceList{
+		Count: len(items),
+		Data:  items,
+	}
+
+	return list, nil
+}
+
+func (q *RecurringInvoiceQuerier) GetOneByID(ctx context.Context, id ulid.ULID) (res model.RecurringInvoice, err error) {
+	row := q.pool.QueryRow(
+		ctx,
+		`SELECT `+recurringInvoiceColumns+`
+		FROM recurring_invoices
+		WHERE id = $1;`,
+		id,
+	)
+	var item model.RecurringInvoice
+	if err := scanRecurringInvoice(row, &item); err != nil {
+		if err == pgx.ErrNoRows {
+			return item, model.ErrRecurringInvoiceNotFound
+		}
+		return item, err
+	}
+	if item.DeletedAt.Valid {
+		return item, model.ErrRecurringInvoiceAlreadyDeleted
+	}
+
+	lines, err := fetchRecurringInvoiceLines(ctx, q.pool, []ulid.ULID{id})
+	if err != nil {
+		return item, err
+	}
+	item.Lines = lines[id]
+
+	return item, nil
+}
+
+func fetchRecurringInvoiceLines(ctx context.Context, db dbtx, ids []ulid.ULID) (map[ulid.ULID][]model.RecurringInvoiceLine, error) {
+	rows, err := db.Query(ctx, `
+		SELECT
+			id,
+			recurring_invoice_id,
+			product_id,
+			quantity
+		FROM recurring_invoice_lines
+		WHERE recurring_invoice_id = ANY($1::BYTEA[])
+		ORDER BY recurring_invoice_id, position;
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines = make(map[ulid.ULID][]model.RecurringInvoiceLine, len(ids))
+	for rows.Next() {
+		var l model.RecurringInvoiceLine
+		if err := rows.Scan(
+			&l.ID,
+			&l.RecurringInvoiceID,
+			&l.ProductID,
+			&l.Quantity,
+		); err != nil {
+			return nil, err
+		}
+		lines[l.RecurringInvoiceID] = append(lines[l.RecurringInvoiceID], l)
+	}
+
+	return lines, rows.Err()
+}
+
+type RecurringInvoiceList struct {
+	Count int                      `json:"count"`
+	Data  []model.RecurringInvoice `json:"data"`
+}
+
+var emptyRecurringInvoices = RecurringInvoiceList{
+	Count: 0,
+	Data:  []model.RecurringInvoice{},
+}
+
+type RecurringInvoiceReadModel interface {
+	Fetch(ctx context.Context) (res RecurringInvoiceList, err error)
+	GetOneByID(ctx context.Context, id ulid.ULID) (res model.RecurringInvoice, err error)
+}
+
+func NewRecurringInvoiceReadModel(
+	pool *pgxpool.Pool,
+) RecurringInvoiceReadModel {
+	return &RecurringInvoiceQuerier{
+		pool: pool,
+	}
+}
@@ -0,0 +1,323 @@
+package querier
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/app/notify"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/guregu/null.v4"
+)
+
+func (q *RecurringInvoiceQuerier) Save(ctx context.Context, data model.RecurringInvoice) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO recurring_invoices (
+			id,
+			created_at,
+			customer_id,
+			billing_interval,
+			anchor_day,
+			starts_at,
+			ends_at,
+			max_count,
+			auto_issue,
+			generated_count,
+			next_run_at
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7,
+			$8,
+			$9,
+			$10,
+			$11
+		);
+	`
+	_, err = tx.Exec(
+		ctx,
+		query,
+		data.ID,
+		data.CreatedAt,
+		data.CustomerID,
+		data.Interval,
+		data.AnchorDay,
+		data.StartsAt,
+		data.EndsAt,
+		data.MaxCount,
+		data.AutoIssue,
+		data.GeneratedCount,
+		data.NextRunAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO recurring_invoice_lines (
+			id,
+			recurring_invoice_id,
+			product_id,
+			position,
+			quantity
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5
+		);
+	`
+	batch := &pgx.Batch{}
+	for idx := range data.Lines {
+		batch.Queue(
+			query,
+			data.Lines[idx].ID,
+			data.Lines[idx].RecurringInvoiceID,
+			data.Lines[idx].ProductID,
+			idx,
+			data.Lines[idx].Quantity,
+		)
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (q *RecurringInvoiceQuerier) Delete(ctx context.Context, data model.RecurringInvoice) error {
+	query := `
+		UPDATE recurring_invoices
+		SET deleted_at = CURRENT_TIMESTAMP
+		WHERE id = $1;
+	`
+	_, err := q.pool.Exec(
+		ctx,
+		query,
+		data.ID,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RunDue generates the invoices of every schedule that is due at at. Each
+// schedule is claimed with FOR UPDATE SKIP LOCKED in a transaction of its
+// own, so several replicas can run at once without billing a schedule
+// twice. generate produces the invoices for all occurrences the schedule
+// missed and moves it on; they are stored, numbered and their stock sold
+// when issued, together with the schedule's new state. Invoices there isn't
+// enough stock to issue are stored as drafts. A schedule that fails is
+// skipped for the rest of the run and its error returned along with the
+// others.
+func (q *RecurringInvoiceQuerier) RunDue(
+	ctx context.Context,
+	at time.Time,
+	generate func(ctx context.Context, r *model.RecurringInvoice) ([]model.Invoice, error),
+) (generated int, err error) {
+	var (
+		failed = []ulid.ULID{}
+		errs   []error
+	)
+	for {
+		id, n, ok, err := q.runNext(ctx, at, failed, generate)
+		if err != nil {
+			if !ok {
+				errs = append(errs, err)
+				return generated, errors.Join(errs...)
+			}
+			failed = append(failed, id)
+			errs = append(errs, err)
+			continue
+		}
+		if !ok {
+			return generated, errors.Join(errs...)
+		}
+		generated += n
+	}
+}
+
+// runNext claims and runs one due schedule. ok is false when none was left
+// to claim, or when the error happened before one was.
+func (q *RecurringInvoiceQuerier) runNext(
+	ctx context.Context,
+	at time.Time,
+	skip []ulid.ULID,
+	generate func(ctx context.Context, r *model.RecurringInvoice) ([]model.Invoice, error),
+) (id ulid.ULID, generated int, ok bool, err error) {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return id, 0, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Schedules are claimed among every tenant's through the one function
+	// row level security is bypassed for; the rest of the transaction is
+	// scoped to the schedule's own tenant, which is what it generates for.
+	var tenantID ulid.ULID
+	if err := tx.QueryRow(
+		ctx,
+		`SELECT id, tenant_id FROM claim_due_recurring_invoice($1, $2::BYTEA[]);`,
+		at,
+		skip,
+	).Scan(&id, &tenantID); err != nil {
+		if err == pgx.ErrNoRows {
+			return id, 0, false, nil
+		}
+		return id, 0, false, err
+	}
+	if _, err := tx.Exec(
+		ctx,
+		`SELECT set_config('app.tenant_id', $1, true);`,
+		hex.EncodeToString(tenantID[:]),
+	); err != nil {
+		return id, 0, true, err
+	}
+
+	row := tx.QueryRow(
+		ctx,
+		`SELECT `+recurringInvoiceColumns+`
+		FROM recurring_invoices
+		WHERE id = $1;`,
+		id,
+	)
+	var item model.RecurringInvoice
+	if err := scanRecurringInvoice(row, &item); err != nil {
+		return id, 0, true, err
+	}
+
+	lines, err := fetchRecurringInvoiceLines(ctx, tx, []ulid.ULID{item.ID})
+	if err != nil {
+		return item.ID, 0, true, err
+	}
+	item.Lines = lines[item.ID]
+
+	invoices, err := generate(ctx, &item)
+	if err != nil {
+		return item.ID, 0, true, err
+	}
+
+	var alerts stockAlerts
+	for _, inv := range invoices {
+		if err := q.insertGenerated(ctx, tx, inv, &alerts); err != nil {
+			return item.ID, 0, true, err
+		}
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE recurring_invoices
+		SET
+			generated_count = $2,
+			next_run_at = $3,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1;`,
+		item.ID,
+		item.GeneratedCount,
+		item.NextRunAt,
+	)
+	if err != nil {
+		return item.ID, 0, true, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return item.ID, 0, true, err
+	}
+	alerts.notify(auth.WithTenant(ctx, item.TenantID), q.notifier)
+	return item.ID, len(invoices), true, nil
+}
+
+// insertGenerated stores an invoice a schedule generated. An issued one is
+// numbered and sells its stock; when there isn't enough of it, the invoice
+// is kept as a draft instead, to be issued by hand once the stock is in, so
+// that the schedule still moves on.
+func (q *RecurringInvoiceQuerier) insertGenerated(ctx context.Context, tx pgx.Tx, inv model.Invoice, alerts *stockAlerts) error {
+	if inv.Status != model.InvoiceStatusIssued {
+		return insertInvoice(ctx, tx, inv)
+	}
+
+	// Rolling back to the savepoint undoes the issue, giving the number
+	// back to the series as well.
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer sp.Rollback(ctx)
+
+	issued := inv
+	if !issued.Number.Valid {
+		number, err := nextNumber(ctx, sp, q.invoiceNumber, issued.IssuedAt.Time)
+		if err != nil {
+			return err
+		}
+		issued.Number = null.StringFrom(number)
+	}
+	if err := insertInvoice(ctx, sp, issued); err != nil {
+		return err
+	}
+
+	var sold stockAlerts
+	err = consumeInvoice(ctx, sp, issued, &sold)
+	if err == nil {
+		*alerts = append(*alerts, sold...)
+		return sp.Commit(ctx)
+	}
+	if !errors.Is(err, model.ErrInsufficientStock) {
+		return err
+	}
+	if err := sp.Rollback(ctx); err != nil {
+		return err
+	}
+
+	log.Warn().
+		Str("recurring_invoice_id", inv.RecurringInvoiceID.String()).
+		Str("invoice_id", inv.ID.String()).
+		Msg("not enough stock to issue a recurring invoice, kept as a draft")
+	inv.Status = model.InvoiceStatusDraft
+	inv.Number = null.String{}
+	inv.IssuedAt = null.Time{}
+	inv.DueAt = null.Time{}
+	return insertInvoice(ctx, tx, inv)
+}
+
+type RecurringInvoiceWriteModel interface {
+	Save(ctx context.Context, data model.RecurringInvoice) error
+	Delete(ctx context.Context, data model.RecurringInvoice) error
+	RunDue(
+		ctx context.Context,
+		at time.Time,
+		generate func(ctx context.Context, r *model.RecurringInvoice) ([]model.Invoice, error),
+	) (generated int, err error)
+}
+
+func NewRecurringInvoiceWriteModel(
+	pool *pgxpool.Pool,
+	invoiceNumber model.NumberFormat,
+	notifier notify.Notifier,
+) RecurringInvoiceWriteModel {
+	return &RecurringInvoiceQuerier{
+		pool:          pool,
+		invoiceNumber: invoiceNumber,
+		notifier:      notifier,
+	}
+}
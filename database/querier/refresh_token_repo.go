@@ -0,0 +1,175 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+type RefreshTokenQuerier struct {
+	pool *pgxpool.Pool
+}
+
+func (q *RefreshTokenQuerier) Save(ctx context.Context, data model.RefreshToken) error {
+	return insertRefreshToken(ctx, q.pool, data)
+}
+
+// Rotate locks the refresh token and lets rotate use it up for its
+// successor. When rotate reports the token was used or revoked before, the
+// whole family is revoked and stays revoked even though an error is
+// returned, because a replayed token means it leaked.
+func (q *RefreshTokenQuerier) Rotate(
+	ctx context.Context,
+	id ulid.ULID,
+	rotate func(*model.RefreshToken) (model.RefreshToken, error),
+) (model.RefreshToken, error) {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return model.RefreshToken{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	row := tx.QueryRow(
+		ctx,
+		`SELECT
+			id,
+			created_at,
+			user_id,
+			family_id,
+			expires_at,
+			used_at,
+			revoked_at
+		FROM refresh_tokens
+		WHERE id = $1
+		FOR UPDATE;`,
+		id,
+	)
+	var item model.RefreshToken
+	if err := row.Scan(
+		&item.ID,
+		&item.CreatedAt,
+		&item.UserID,
+		&item.FamilyID,
+		&item.ExpiresAt,
+		&item.UsedAt,
+		&item.RevokedAt,
+	); err != nil {
+		if err == pgx.ErrNoRows {
+			return item, model.ErrRefreshTokenNotFound
+		}
+		return item, err
+	}
+
+	next, err := rotate(&item)
+	if err == model.ErrRefreshTokenReused {
+		if err := revokeFamily(ctx, tx, item.FamilyID, time.Now()); err != nil {
+			return next, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return next, err
+		}
+		return next, model.ErrRefreshTokenReused
+	}
+	if err != nil {
+		return next, err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE refresh_tokens
+		SET used_at = $2
+		WHERE id = $1;`,
+		item.ID,
+		item.UsedAt,
+	)
+	if err != nil {
+		return next, err
+	}
+
+	if err := insertRefreshToken(ctx, tx, next); err != nil {
+		return next, err
+	}
+
+	return next, tx.Commit(ctx)
+}
+
+// Revoke ends the session the refresh token belongs to.
+func (q *RefreshTokenQuerier) Revoke(ctx context.Context, id ulid.ULID) error {
+	var familyID ulid.ULID
+	err := q.pool.QueryRow(
+		ctx,
+		`SELECT family_id FROM refresh_tokens WHERE id = $1;`,
+		id,
+	).Scan(&familyID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return model.ErrRefreshTokenNotFound
+		}
+		return err
+	}
+
+	return revokeFamily(ctx, q.pool, familyID, time.Now())
+}
+
+func revokeFamily(ctx context.Context, db dbtx, familyID ulid.ULID, at time.Time) error {
+	_, err := db.Exec(
+		ctx,
+		`UPDATE refresh_tokens
+		SET revoked_at = $2
+		WHERE family_id = $1
+			AND revoked_at IS NULL;`,
+		familyID,
+		at,
+	)
+	return err
+}
+
+func insertRefreshToken(ctx context.Context, db dbtx, data model.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (
+			id,
+			created_at,
+			user_id,
+			family_id,
+			expires_at
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5
+		);
+	`
+	_, err := db.Exec(
+		ctx,
+		query,
+		data.ID,
+		data.CreatedAt,
+		data.UserID,
+		data.FamilyID,
+		data.ExpiresAt,
+	)
+	return err
+}
+
+type RefreshTokenWriteModel interface {
+	Save(ctx context.Context, data model.RefreshToken) error
+	Rotate(
+		ctx context.Context,
+		id ulid.ULID,
+		rotate func(*model.RefreshToken) (model.RefreshToken, error),
+	) (model.RefreshToken, error)
+	Revoke(ctx context.Context, id ulid.ULID) error
+}
+
+func NewRefreshTokenWriteModel(
+	pool *pgxpool.Pool,
+) RefreshTokenWriteModel {
+	return &RefreshTokenQuerier{
+		pool: pool,
+	}
+}
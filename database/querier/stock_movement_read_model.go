@@ -0,0 +1,131 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+type StockMovementQuerier struct {
+	pool *pgxpool.Pool
+}
+
+const stockMovementColumns = `
+	id,
+	created_at,
+	inventory_id,
+	product_id,
+	movement_type,
+	quantity,
+	quantity_after,
+	reason,
+	reference,
+	actor,
+	(
+		SELECT warehouse_id
+		FROM inventories
+		WHERE inventories.id = stock_movements.inventory_id
+	) AS warehouse_id,
+	invoice_id,
+	credit_note_id
+`
+
+func scanStockMovement(row pgx.Row, item *model.StockMovement) error {
+	return row.Scan(
+		&item.ID,
+		&item.CreatedAt,
+		&item.InventoryID,
+		&item.ProductID,
+		&item.Type,
+		&item.Quantity,
+		&item.QuantityAfter,
+		&item.Reason,
+		&item.Reference,
+		&item.Actor,
+		&item.WarehouseID,
+		&item.InvoiceID,
+		&item.CreditNoteID,
+	)
+}
+
+// FetchByProductID pages through the ledger of a product, oldest first
+// unless the page asks otherwise.
+func (q *StockMovementQuerier) FetchByProductID(ctx context.Context, productID ulid.ULID, page Page) (res StockMovementList, err error) {
+	var args queryArgs
+	var conds = []string{"product_id = " + args.add(productID)}
+
+	after, order := page.keyset(&args, "id", "id", func(id string) string {
+		return `SELECT id, id FROM stock_movements WHERE id = ` + id
+	})
+	if after != "" {
+		conds = append(conds, after)
+	}
+
+	limit := page.limit()
+	rows, err := q.pool.Query(
+		ctx,
+		`SELECT `+stockMovementColumns+`
+		FROM stock_movements
+		WHERE `+strings.Join(conds, " AND ")+`
+		ORDER BY `+order+`
+		LIMIT `+args.add(limit+1)+`;`,
+		args...,
+	)
+	if err != nil {
+		return emptyStockMovements, err
+	}
+	defer rows.Close()
+
+	var items []model.StockMovement
+	for rows.Next() {
+		var item model.StockMovement
+		if err := scanStockMovement(rows, &item); err != nil {
+			return emptyStockMovements, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return emptyStockMovements, err
+	}
+
+	if len(items) == 0 {
+		return emptyStockMovements, nil
+	}
+
+	items, cursor := pageCursor(items, limit, func(m model.StockMovement) ulid.ULID {
+		return m.ID
+	})
+
+	return StockMovementList{
+		Count:  len(items),
+		Data:   items,
+		Cursor: cursor,
+	}, nil
+}
+
+type StockMovementList struct {
+	Count int                   `json:"count"`
+	Data  []model.StockMovement `json:"data"`
+	Cursor
+}
+
+var emptyStockMovements = StockMovementList{
+	Count: 0,
+	Data:  []model.StockMovement{},
+}
+
+type StockMovementReadModel interface {
+	FetchByProductID(ctx context.Context, productID ulid.ULID, page Page) (res StockMovementList, err error)
+}
+
+func NewStockMovementReadModel(
+	pool *pgxpool.Pool,
+) StockMovementReadModel {
+	return &StockMovementQuerier{
+		pool: pool,
+	}
+}
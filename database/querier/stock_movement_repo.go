@@ -0,0 +1,250 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/app/notify"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oklog/ulid/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// lockInventory locks the inventory of the product in the default
+// warehouse for the rest of the transaction. For a deleted product the
+// inventory is returned together with ErrProductAlreadyDeleted.
+func lockInventory(ctx context.Context, tx pgx.Tx, productID ulid.ULID) (model.Inventory, error) {
+	var (
+		inv     model.Inventory
+		deleted bool
+	)
+	row := tx.QueryRow(
+		ctx,
+		`SELECT
+			i.id,
+			i.created_at,
+			i.updated_at,
+			i.warehouse_id,
+			i.quantity,
+			i.reorder_point,
+			i.reorder_quantity,
+			p.deleted_at IS NOT NULL
+		FROM products p
+		JOIN inventories i ON p.inventory_id = i.id
+		WHERE p.id = $1
+		FOR UPDATE OF i;`,
+		productID,
+	)
+	if err := row.Scan(
+		&inv.ID,
+		&inv.CreatedAt,
+		&inv.UpdatedAt,
+		&inv.WarehouseID,
+		&inv.Quantity,
+		&inv.ReorderPoint,
+		&inv.ReorderQuantity,
+		&deleted,
+	); err != nil {
+		if err == pgx.ErrNoRows {
+			return inv, model.ErrProductNotFound
+		}
+		return inv, err
+	}
+	if deleted {
+		return inv, model.ErrProductAlreadyDeleted
+	}
+	return inv, nil
+}
+
+// lockWarehouseInventory locks the inventory of the product in the
+// warehouse for the rest of the transaction, starting an empty one if the
+// product wasn't stocked there yet. Like lockInventory, a deleted product's
+// inventory is returned together with ErrProductAlreadyDeleted.
+func lockWarehouseInventory(ctx context.Context, tx pgx.Tx, productID, warehouseID ulid.ULID) (model.Inventory, error) {
+	var inv model.Inventory
+	if err := ensureInventory(ctx, tx, productID, warehouseID); err != nil {
+		return inv, err
+	}
+
+	var deleted bool
+	row := tx.QueryRow(
+		ctx,
+		`SELECT
+			i.id,
+			i.created_at,
+			i.updated_at,
+			i.warehouse_id,
+			i.quantity,
+			`+reservedQuantity+`,
+			i.reorder_point,
+			i.reorder_quantity,
+			p.deleted_at IS NOT NULL
+		FROM inventories i
+		JOIN products p ON i.product_id = p.id
+		JOIN warehouses w ON i.warehouse_id = w.id
+		WHERE i.product_id = $1
+			AND i.warehouse_id = $2
+			AND w.deleted_at IS NULL
+		FOR UPDATE OF i;`,
+		productID,
+		warehouseID,
+	)
+	err := row.Scan(
+		&inv.ID,
+		&inv.CreatedAt,
+		&inv.UpdatedAt,
+		&inv.WarehouseID,
+		&inv.Quantity,
+		&inv.Reserved,
+		&inv.ReorderPoint,
+		&inv.ReorderQuantity,
+		&deleted,
+	)
+	if err == pgx.ErrNoRows {
+		return inv, missingInventoryError(ctx, tx, productID)
+	}
+	if err != nil {
+		return inv, err
+	}
+	if deleted {
+		return inv, model.ErrProductAlreadyDeleted
+	}
+	return inv, nil
+}
+
+// ensureInventory starts an empty inventory of the product in the
+// warehouse, unless there is one or either of them doesn't exist.
+func ensureInventory(ctx context.Context, db dbtx, productID, warehouseID ulid.ULID) error {
+	_, err := db.Exec(
+		ctx,
+		`INSERT INTO inventories (
+			id,
+			product_id,
+			warehouse_id,
+			quantity
+		)
+		SELECT $3, p.id, w.id, 0
+		FROM products p, warehouses w
+		WHERE p.id = $1 AND w.id = $2 AND w.deleted_at IS NULL
+		ON CONFLICT (product_id, warehouse_id) DO NOTHING;`,
+		productID,
+		warehouseID,
+		ulid.Make(),
+	)
+	return err
+}
+
+// missingInventoryError tells whether it is the product or the warehouse
+// that an inventory couldn't be found for.
+func missingInventoryError(ctx context.Context, db dbtx, productID ulid.ULID) error {
+	var exists bool
+	err := db.QueryRow(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM products WHERE id = $1);`,
+		productID,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return model.ErrProductNotFound
+	}
+	return model.ErrWarehouseNotFound
+}
+
+// recordStockMovement appends the movement to the ledger and sets the
+// inventory to the quantity the movement left it at, so both always agree.
+// The alert the movement raises on inv, if any, is added to alerts, to be
+// sent once tx committed.
+func recordStockMovement(ctx context.Context, tx pgx.Tx, inv model.Inventory, m model.StockMovement, alerts *stockAlerts) error {
+	_, err := tx.Exec(
+		ctx,
+		`INSERT INTO stock_movements (
+			id,
+			created_at,
+			inventory_id,
+			product_id,
+			movement_type,
+			quantity,
+			quantity_after,
+			reason,
+			reference,
+			actor,
+			invoice_id,
+			credit_note_id
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7,
+			$8,
+			$9,
+			$10,
+			$11,
+			$12
+		);`,
+		m.ID,
+		m.CreatedAt,
+		m.InventoryID,
+		m.ProductID,
+		m.Type,
+		m.Quantity,
+		m.QuantityAfter,
+		m.Reason,
+		m.Reference,
+		m.Actor,
+		m.InvoiceID,
+		m.CreditNoteID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE inventories
+		SET
+			quantity = $2,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1;`,
+		m.InventoryID,
+		m.QuantityAfter,
+	)
+	if err != nil {
+		return err
+	}
+
+	alerts.add(inv, m)
+	return nil
+}
+
+// stockAlerts collects the alerts raised by the stock movements of a
+// transaction, which are only sent after it committed.
+type stockAlerts []model.StockAlert
+
+// add keeps the alert the movement raised on inv, if any.
+func (a *stockAlerts) add(inv model.Inventory, m model.StockMovement) {
+	if alert, ok := inv.Alert(m); ok {
+		*a = append(*a, alert)
+	}
+}
+
+// notify hands the collected alerts, raised in the tenant of ctx, to the
+// notifier, which queues them to be sent. Failures are only logged, as the
+// stock already changed.
+func (a stockAlerts) notify(ctx context.Context, notifier notify.Notifier) {
+	if notifier == nil {
+		return
+	}
+	tenantID, _ := auth.TenantFrom(ctx)
+	for _, alert := range a {
+		alert.TenantID = tenantID
+		if err := notifier.Notify(ctx, alert); err != nil {
+			log.Error().Err(err).Str("product_id", alert.ProductID.String()).Msg("cannot send stock alert")
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package querier
+
+import (
+	"context"
+	"errors"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/model"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+// reservedQuantity sums the unexpired reservations of the inventory
+// aliased i.
+const reservedQuantity = `COALESCE(
+	(
+		SELECT SUM(r.quantity)
+		FROM stock_reservations r
+		WHERE r.inventory_id = i.id AND r.expires_at > CURRENT_TIMESTAMP
+	),
+	0
+)`
+
+type StockReservationQuerier struct {
+	pool *pgxpool.Pool
+}
+
+// ReleaseExpired deletes the reservations of every tenant that expired by
+// at, through the function row level security is bypassed for. Expired
+// reservations already stopped counting against the available stock; this
+// only keeps the table small.
+func (q *StockReservationQuerier) ReleaseExpired(ctx context.Context, at time.Time) (released int64, err error) {
+	err = q.pool.QueryRow(
+		ctx,
+		`SELECT release_expired_stock_reservations($1);`,
+		at,
+	).Scan(&released)
+	return released, err
+}
+
+type StockReservationWriteModel interface {
+	ReleaseExpired(ctx context.Context, at time.Time) (int64, error)
+}
+
+func NewStockReservationWriteModel(
+	pool *pgxpool.Pool,
+) StockReservationWriteModel {
+	return &StockReservationQuerier{
+		pool: pool,
+	}
+}
+
+// reserveInvoice replaces the reservations of the draft invoice by holds on
+// the quantities of its lines until expiresAt. The inventories are locked
+// in product order, so concurrent reservations can't deadlock.
+func reserveInvoice(ctx context.Context, tx pgx.Tx, inv model.Invoice, expiresAt time.Time) ([]model.StockReservation, error) {
+	if err := releaseInvoice(ctx, tx, inv.ID); err != nil {
+		return nil, err
+	}
+
+	var (
+		quantities = map[ulid.ULID]int{}
+		products   []ulid.ULID
+	)
+	for _, l := range inv.Lines {
+		if _, ok := quantities[l.ProductID]; !ok {
+			products = append(products, l.ProductID)
+		}
+		quantities[l.ProductID] += l.Quantity
+	}
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].Compare(products[j]) < 0
+	})
+
+	now := time.Now()
+	var reservations = make([]model.StockReservation, 0, len(products))
+	for _, productID := range products {
+		r, err := model.NewStockReservation(inv.ID, productID, quantities[productID], expiresAt, now)
+		if err != nil {
+			return nil, err
+		}
+
+		stock, allowBackorder, err := lockStock(ctx, tx, productID, inv.WarehouseID)
+		if err != nil {
+			return nil, err
+		}
+		if err := stock.Reserve(&r, allowBackorder); err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec(
+			ctx,
+			`INSERT INTO stock_reservations (
+				id,
+				created_at,
+				inventory_id,
+				product_id,
+				invoice_id,
+				quantity,
+				expires_at
+			) VALUES (
+				$1,
+				$2,
+				$3,
+				$4,
+				$5,
+				$6,
+				$7
+			);`,
+			r.ID,
+			r.CreatedAt,
+			r.InventoryID,
+			r.ProductID,
+			r.InvoiceID,
+			r.Quantity,
+			r.ExpiresAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, r)
+	}
+
+	return reservations, nil
+}
+
+// releaseInvoice drops the reservations of the invoice.
+func releaseInvoice(ctx context.Context, tx pgx.Tx, invoiceID ulid.ULID) error {
+	_, err := tx.Exec(
+		ctx,
+		`DELETE FROM stock_reservations WHERE invoice_id = $1;`,
+		invoiceID,
+	)
+	return err
+}
+
+// heldUntil tells until when the invoice holds stock, if it holds any.
+func heldUntil(ctx context.Context, tx pgx.Tx, invoiceID ulid.ULID) (time.Time, bool, error) {
+	var expiresAt *time.Time
+	err := tx.QueryRow(
+		ctx,
+		`SELECT MAX(expires_at)
+		FROM stock_reservations
+		WHERE invoice_id = $1 AND expires_at > CURRENT_TIMESTAMP;`,
+		invoiceID,
+	).Scan(&expiresAt)
+	if err != nil || expiresAt == nil {
+		return time.Time{}, false, err
+	}
+	return *expiresAt, true, nil
+}
+
+// consumeInvoice books the sale of every line of the just issued invoice
+// in the stock ledger, under the invoice number. What the invoice's
+// unexpired reservations hold is sold from the hold; the rest, including
+// what expired reservations held, has to be available unless the product
+// allows backorders. The reservations are consumed either way, and the
+// stock alerts the sales raise added to alerts.
+func consumeInvoice(ctx context.Context, tx pgx.Tx, inv model.Invoice, alerts *stockAlerts) error {
+	lines, err := fetchInvoiceLines(ctx, tx, []ulid.ULID{inv.ID})
+	if err != nil {
+		return err
+	}
+
+	var (
+		quantities = map[ulid.ULID]int{}
+		products   []ulid.ULID
+	)
+	for _, l := range lines[inv.ID] {
+		if _, ok := quantities[l.ProductID]; !ok {
+			products = append(products, l.ProductID)
+		}
+		quantities[l.ProductID] += l.Quantity
+	}
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].Compare(products[j]) < 0
+	})
+
+	held, err := heldQuantities(ctx, tx, inv.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, productID := range products {
+		// Goods invoiced before the product was deleted are still sold.
+		stock, allowBackorder, err := lockStock(ctx, tx, productID, inv.WarehouseID)
+		if err != nil && !errors.Is(err, model.ErrProductAlreadyDeleted) {
+			return err
+		}
+		if err := stock.Consume(quantities[productID], held[productID], allowBackorder); err != nil {
+			return err
+		}
+		movement, err := stock.Move(
+			productID,
+			model.StockSale,
+			-quantities[productID],
+			"invoice issued",
+			inv.Number.String,
+			auth.ActorFrom(ctx),
+			inv.IssuedAt.Time,
+		)
+		if err != nil {
+			return err
+		}
+		movement.InvoiceID = &inv.ID
+		if err := recordStockMovement(ctx, tx, stock, movement, alerts); err != nil {
+			return err
+		}
+	}
+
+	return releaseInvoice(ctx, tx, inv.ID)
+}
+
+// returnInvoice books what the voided invoice sold and didn't get back yet
+// through credit notes back into stock, as returns under the invoice number.
+// The stock alerts the returns raise are added to alerts.
+func returnInvoice(ctx context.Context, tx pgx.Tx, inv model.Invoice, alerts *stockAlerts) error {
+	returnable, err := returnableQuantities(ctx, tx, inv)
+	if err != nil {
+		return err
+	}
+
+	var products = make([]ulid.ULID, 0, len(returnable))
+	for productID, quantity := range returnable {
+		if quantity > 0 {
+			products = append(products, productID)
+		}
+	}
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].Compare(products[j]) < 0
+	})
+
+	for _, productID := range products {
+		// Goods come back even when the product is no longer sold.
+		stock, _, err := lockStock(ctx, tx, productID, inv.WarehouseID)
+		if err != nil && !errors.Is(err, model.ErrProductAlreadyDeleted) {
+			return err
+		}
+		movement, err := stock.Move(
+			productID,
+			model.StockReturn,
+			returnable[productID],
+			"invoice voided",
+			inv.Number.String,
+			auth.ActorFrom(ctx),
+			inv.VoidedAt.Time,
+		)
+		if err != nil {
+			return err
+		}
+		movement.InvoiceID = &inv.ID
+		if err := recordStockMovement(ctx, tx, stock, movement, alerts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// heldQuantities sums what the unexpired reservations of the invoice hold
+// per product.
+func heldQuantities(ctx context.Context, tx pgx.Tx, invoiceID ulid.ULID) (map[ulid.ULID]int, error) {
+	rows, err := tx.Query(
+		ctx,
+		`SELECT product_id, SUM(quantity)
+		FROM stock_reservations
+		WHERE invoice_id = $1 AND expires_at > CURRENT_TIMESTAMP
+		GROUP BY product_id;`,
+		invoiceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var held = map[ulid.ULID]int{}
+	for rows.Next() {
+		var (
+			productID ulid.ULID
+			quantity  int
+		)
+		if err := rows.Scan(&productID, &quantity); err != nil {
+			return nil, err
+		}
+		held[productID] = quantity
+	}
+
+	return held, rows.Err()
+}
+
+// lockStock locks the product's inventory in the warehouse an invoice
+// sells from, the default one when warehouseID is nil, along with how much
+// of it is reserved and whether the product allows backorders. Like
+// lockWarehouseInventory, it stocks the product empty in a warehouse it
+// wasn't stocked in yet, and a deleted product's inventory is returned
+// together with model.ErrProductAlreadyDeleted.
+func lockStock(ctx context.Context, tx pgx.Tx, productID ulid.ULID, warehouseID *ulid.ULID) (model.Inventory, bool, error) {
+	var (
+		stock          model.Inventory
+		allowBackorder bool
+		deleted        bool
+	)
+	if warehouseID != nil {
+		if err := ensureInventory(ctx, tx, productID, *warehouseID); err != nil {
+			return stock, false, err
+		}
+	}
+
+	err := tx.QueryRow(
+		ctx,
+		`SELECT
+			i.id,
+			i.created_at,
+			i.updated_at,
+			i.warehouse_id,
+			i.quantity,
+			`+reservedQuantity+`,
+			i.reorder_point,
+			i.reorder_quantity,
+			p.allow_backorder,
+			p.deleted_at IS NOT NULL
+		FROM products p
+		JOIN inventories i ON i.product_id = p.id
+		JOIN warehouses w ON i.warehouse_id = w.id
+		WHERE p.id = $1
+			AND CASE
+				WHEN $2::BYTEA IS NULL THEN i.id = p.inventory_id
+				ELSE i.warehouse_id = $2
+			END
+			AND w.deleted_at IS NULL
+		FOR UPDATE OF i;`,
+		productID,
+		warehouseID,
+	).Scan(
+		&stock.ID,
+		&stock.CreatedAt,
+		&stock.UpdatedAt,
+		&stock.WarehouseID,
+		&stock.Quantity,
+		&stock.Reserved,
+		&stock.ReorderPoint,
+		&stock.ReorderQuantity,
+		&allowBackorder,
+		&deleted,
+	)
+	if err == pgx.ErrNoRows {
+		if warehouseID == nil {
+			return stock, false, model.ErrProductNotFound
+		}
+		return stock, false, missingInventoryError(ctx, tx, productID)
+	}
+	if err != nil {
+		return stock, false, err
+	}
+	if deleted {
+		return stock, allowBackorder, model.ErrProductAlreadyDeleted
+	}
+	return stock, allowBackorder, nil
+}
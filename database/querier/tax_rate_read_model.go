@@ -0,0 +1,162 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+type TaxRateQuerier struct {
+	pool *pgxpool.Pool
+}
+
+const taxRateColumns = `
+	id,
+	created_at,
+	updated_at,
+	deleted_at,
+	class,
+	jurisdiction,
+	name,
+	rate,
+	valid_from,
+	valid_to
+`
+
+func scanTaxRate(row pgx.Row, item *model.TaxRate) error {
+	return row.Scan(
+		&item.ID,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+		&item.DeletedAt,
+		&item.Class,
+		&item.Jurisdiction,
+		&item.Name,
+		&item.Rate,
+		&item.ValidFrom,
+		&item.ValidTo,
+	)
+}
+
+func (q *TaxRateQuerier) Fetch(ctx context.Context) (res TaxRateList, err error) {
+	rows, err := q.pool.Query(
+		ctx,
+		`SELECT `+taxRateColumns+`
+		FROM tax_rates
+		WHERE deleted_at IS NULL
+		ORDER BY class, jurisdiction, valid_from;`,
+	)
+	if err != nil {
+		return emptyTaxRates, err
+	}
+	defer rows.Close()
+
+	var items []model.TaxRate
+	for rows.Next() {
+		var item model.TaxRate
+		if err := scanTaxRate(rows, &item); err != nil {
+			return emptyTaxRates, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return emptyTaxRates, err
+	}
+
+	if len(items) == 0 {
+		return emptyTaxRates, nil
+	}
+
+	list := TaxRateList{
+		Count: len(items),
+		Data:  items,
+	}
+
+	return list, nil
+}
+
+func (q *TaxRateQuerier) GetOneByID(ctx context.Context, id ulid.ULID) (res model.TaxRate, err error) {
+	row := q.pool.QueryRow(
+		ctx,
+		`SELECT `+taxRateColumns+`
+		FROM tax_rates
+		WHERE id = $1;`,
+		id,
+	)
+	var item model.TaxRate
+	if err := scanTaxRate(row, &item); err != nil {
+		if err == pgx.ErrNoRows {
+			return item, model.ErrTaxRateNotFound
+		}
+		return item, err
+	}
+	if item.DeletedAt.Valid {
+		return item, model.ErrTaxRateAlreadyDeleted
+	}
+	return item, nil
+}
+
+// FindEffective returns the rate of class in effect at the given time,
+// preferring a rate of the jurisdiction over the catch-all one. Exempt
+// supplies, and zero-rated ones without a configured rate, are untaxed.
+func (q *TaxRateQuerier) FindEffective(ctx context.Context, class model.TaxClass, jurisdiction string, at time.Time) (res model.TaxRate, err error) {
+	if class == model.TaxClassExempt {
+		return model.UntaxedRate(class), nil
+	}
+
+	row := q.pool.QueryRow(
+		ctx,
+		`SELECT `+taxRateColumns+`
+		FROM tax_rates
+		WHERE
+			class = $1
+			AND jurisdiction IN ($2, '')
+			AND deleted_at IS NULL
+			AND valid_from <= $3
+			AND (valid_to IS NULL OR valid_to > $3)
+		ORDER BY jurisdiction DESC
+		LIMIT 1;`,
+		class,
+		jurisdiction,
+		at,
+	)
+	var item model.TaxRate
+	if err := scanTaxRate(row, &item); err != nil {
+		if err == pgx.ErrNoRows {
+			if class == model.TaxClassZero {
+				return model.UntaxedRate(class), nil
+			}
+			return item, model.ErrTaxRateNotFound
+		}
+		return item, err
+	}
+	return item, nil
+}
+
+type TaxRateList struct {
+	Count int             `json:"count"`
+	Data  []model.TaxRate `json:"data"`
+}
+
+var emptyTaxRates = TaxRateList{
+	Count: 0,
+	Data:  []model.TaxRate{},
+}
+
+type TaxRateReadModel interface {
+	Fetch(ctx context.Context) (res TaxRateList, err error)
+	GetOneByID(ctx context.Context, id ulid.ULID) (res model.TaxRate, err error)
+	FindEffective(ctx context.Context, class model.TaxClass, jurisdiction string, at time.Time) (res model.TaxRate, err error)
+}
+
+func NewTaxRateReadModel(
+	pool *pgxpool.Pool,
+) TaxRateReadModel {
+	return &TaxRateQuerier{
+		pool: pool,
+	}
+}
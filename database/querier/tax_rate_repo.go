@@ -0,0 +1,141 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Save stores the rate unless its period overlaps another rate of the same
+// class and jurisdiction, which would make the lookup ambiguous.
+func (q *TaxRateQuerier) Save(ctx context.Context, data model.TaxRate) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialise writers of the same class and jurisdiction so two
+	// overlapping rates can not slip in concurrently. Rates only overlap
+	// within a tenant, so the lock is the tenant's too and other tenants
+	// don't wait on it.
+	_, err = tx.Exec(
+		ctx,
+		`SELECT pg_advisory_xact_lock(hashtext(
+			'tax_rates:' || current_setting('app.tenant_id', true) || ':' || $1 || ':' || $2
+		));`,
+		data.Class,
+		data.Jurisdiction,
+	)
+	if err != nil {
+		return err
+	}
+
+	var overlaps bool
+	if err := tx.QueryRow(
+		ctx,
+		`
+			SELECT EXISTS (
+				SELECT 1
+				FROM tax_rates
+				WHERE
+					class = $1
+					AND jurisdiction = $2
+					AND id <> $3
+					AND deleted_at IS NULL
+					AND valid_from < COALESCE($5, 'infinity'::TIMESTAMP)
+					AND COALESCE(valid_to, 'infinity'::TIMESTAMP) > $4
+			);
+		`,
+		data.Class,
+		data.Jurisdiction,
+		data.ID,
+		data.ValidFrom,
+		data.ValidTo,
+	).Scan(&overlaps); err != nil {
+		return err
+	}
+	if overlaps {
+		return model.ErrTaxRateOverlap
+	}
+
+	query := `
+		INSERT INTO tax_rates (
+			id,
+			created_at,
+			class,
+			jurisdiction,
+			name,
+			rate,
+			valid_from,
+			valid_to
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7,
+			$8
+		) ON CONFLICT(id)
+		DO UPDATE SET
+			class = EXCLUDED.class,
+			jurisdiction = EXCLUDED.jurisdiction,
+			name = EXCLUDED.name,
+			rate = EXCLUDED.rate,
+			valid_from = EXCLUDED.valid_from,
+			valid_to = EXCLUDED.valid_to,
+			updated_at = CURRENT_TIMESTAMP;
+	`
+	_, err = tx.Exec(
+		ctx,
+		query,
+		data.ID,
+		data.CreatedAt,
+		data.Class,
+		data.Jurisdiction,
+		data.Name,
+		data.Rate,
+		data.ValidFrom,
+		data.ValidTo,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (q *TaxRateQuerier) Delete(ctx context.Context, data model.TaxRate) error {
+	query := `
+		UPDATE tax_rates
+		SET deleted_at = CURRENT_TIMESTAMP
+		WHERE id = $1;
+	`
+	_, err := q.pool.Exec(
+		ctx,
+		query,
+		data.ID,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type TaxRateWriteModel interface {
+	Save(ctx context.Context, data model.TaxRate) error
+	Delete(ctx context.Context, data model.TaxRate) error
+}
+
+func NewTaxRateWriteModel(
+	pool *pgxpool.Pool,
+) TaxRateWriteModel {
+	return &TaxRateQuerier{
+		pool: pool,
+	}
+}
@@ -0,0 +1,25 @@
+package querier
+
+import (
+	"context"
+	"encoding/hex"
+	"flukis/invokiss/app/auth"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ScopeConnection is meant as the pool's BeforeAcquire hook. It sets the
+// app.tenant_id setting the row level security policies read to the tenant
+// of ctx, or clears it when ctx has none, before a connection is handed out.
+// Without a tenant the scoped tables look empty and cannot be written to.
+func ScopeConnection(ctx context.Context, conn *pgx.Conn) bool {
+	var tenant string
+	if id, ok := auth.TenantFrom(ctx); ok {
+		tenant = hex.EncodeToString(id[:])
+	}
+
+	// A connection that cannot be scoped is dropped rather than reused
+	// with the previous tenant.
+	_, err := conn.Exec(ctx, `SELECT set_config('app.tenant_id', $1, false);`, tenant)
+	return err == nil
+}
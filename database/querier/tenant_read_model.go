@@ -0,0 +1,89 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+// TenantQuerier reads and writes the tenants themselves, which are not
+// scoped to any tenant; every query names the tenant it is about.
+type TenantQuerier struct {
+	pool *pgxpool.Pool
+}
+
+const tenantColumns = `
+	id,
+	created_at,
+	updated_at,
+	deleted_at,
+	name,
+	seller,
+	alert_webhook
+`
+
+func scanTenant(row pgx.Row, item *model.Tenant) error {
+	return row.Scan(
+		&item.ID,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+		&item.DeletedAt,
+		&item.Name,
+		&item.Seller,
+		&item.AlertWebhook,
+	)
+}
+
+func (q *TenantQuerier) GetOneByID(ctx context.Context, id ulid.ULID) (res model.Tenant, err error) {
+	row := q.pool.QueryRow(
+		ctx,
+		`SELECT `+tenantColumns+`
+		FROM tenants
+		WHERE id = $1 AND deleted_at IS NULL;`,
+		id,
+	)
+	var item model.Tenant
+	if err := scanTenant(row, &item); err != nil {
+		if err == pgx.ErrNoRows {
+			return item, model.ErrTenantNotFound
+		}
+		return item, err
+	}
+	return item, nil
+}
+
+// AlertWebhook is the URL the tenant wants its stock alerts posted to,
+// empty when it has none.
+func (q *TenantQuerier) AlertWebhook(ctx context.Context, id ulid.ULID) (string, error) {
+	var url string
+	err := q.pool.QueryRow(
+		ctx,
+		`SELECT alert_webhook
+		FROM tenants
+		WHERE id = $1 AND deleted_at IS NULL;`,
+		id,
+	).Scan(&url)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", model.ErrTenantNotFound
+		}
+		return "", err
+	}
+	return url, nil
+}
+
+type TenantReadModel interface {
+	GetOneByID(ctx context.Context, id ulid.ULID) (res model.Tenant, err error)
+	AlertWebhook(ctx context.Context, id ulid.ULID) (string, error)
+}
+
+func NewTenantReadModel(
+	pool *pgxpool.Pool,
+) TenantReadModel {
+	return &TenantQuerier{
+		pool: pool,
+	}
+}
@@ -0,0 +1,128 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/model"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+// Provision creates the tenant with its default warehouse and admin, the
+// first user to log in to it with.
+func (q *TenantQuerier) Provision(ctx context.Context, tenant model.Tenant, admin model.User) error {
+	if admin.TenantID != tenant.ID || admin.Role != model.RoleAdmin {
+		return model.ErrTenantAdminInvalid
+	}
+
+	// The warehouse is written in the new tenant, so the connection has to
+	// be scoped to it.
+	ctx = auth.WithTenant(ctx, tenant.ID)
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(
+		ctx,
+		`INSERT INTO tenants (
+			id,
+			created_at,
+			name,
+			seller
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4
+		);`,
+		tenant.ID,
+		tenant.CreatedAt,
+		tenant.Name,
+		tenant.Seller,
+	); err != nil {
+		return err
+	}
+	if _, err := defaultWarehouse(ctx, tx); err != nil {
+		return err
+	}
+	if err := saveUser(ctx, tx, admin); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// EditSeller replaces the details the tenant prints on its invoices.
+func (q *TenantQuerier) EditSeller(ctx context.Context, id ulid.ULID, seller model.Seller) error {
+	tag, err := q.pool.Exec(
+		ctx,
+		`UPDATE tenants
+		SET
+			seller = $2,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND deleted_at IS NULL;`,
+		id,
+		seller,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return model.ErrTenantNotFound
+	}
+	return nil
+}
+
+// EditAlertWebhook sets the URL the tenant's stock alerts are posted to; an
+// empty one sends them to the server's own.
+func (q *TenantQuerier) EditAlertWebhook(ctx context.Context, id ulid.ULID, url string) error {
+	tag, err := q.pool.Exec(
+		ctx,
+		`UPDATE tenants
+		SET
+			alert_webhook = $2,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND deleted_at IS NULL;`,
+		id,
+		url,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return model.ErrTenantNotFound
+	}
+	return nil
+}
+
+// SeedSeller sets the details the tenant prints on its invoices unless
+// they were set before.
+func (q *TenantQuerier) SeedSeller(ctx context.Context, id ulid.ULID, seller model.Seller) error {
+	_, err := q.pool.Exec(
+		ctx,
+		`UPDATE tenants
+		SET seller = $2
+		WHERE id = $1 AND seller = '{}'::JSONB;`,
+		id,
+		seller,
+	)
+	return err
+}
+
+type TenantWriteModel interface {
+	Provision(ctx context.Context, tenant model.Tenant, admin model.User) error
+	EditSeller(ctx context.Context, id ulid.ULID, seller model.Seller) error
+	EditAlertWebhook(ctx context.Context, id ulid.ULID, url string) error
+	SeedSeller(ctx context.Context, id ulid.ULID, seller model.Seller) error
+}
+
+func NewTenantWriteModel(
+	pool *pgxpool.Pool,
+) TenantWriteModel {
+	return &TenantQuerier{
+		pool: pool,
+	}
+}
@@ -0,0 +1,599 @@
+package querier
+
+import (
+	"context"
+	"errors"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/app/notify"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+// The tests in this file run against the Postgres database in
+// TEST_DATABASE_URL, migrated to the latest version, and are skipped without
+// it. The URL has to log in as a role row level security applies to, like
+// the one the server connects as. Every run provisions tenants of its own
+// and leaves them behind.
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	cfg, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.BeforeAcquire = ScopeConnection
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	var bypasses bool
+	if err := pool.QueryRow(
+		context.Background(),
+		`SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user;`,
+	).Scan(&bypasses); err != nil {
+		t.Fatal(err)
+	}
+	if bypasses {
+		t.Fatal("TEST_DATABASE_URL logs in as a role that bypasses row level security")
+	}
+
+	return pool
+}
+
+var testInvoiceNumber = model.NumberFormat{
+	Series:  "invoice",
+	Prefix:  "INV",
+	Padding: 6,
+}
+
+// tenantFixture is a tenant with one of every billing document.
+type tenantFixture struct {
+	ctx       context.Context
+	id        ulid.ULID
+	customer  model.Customer
+	taxRate   model.TaxRate
+	product   model.Product
+	invoice   model.Invoice
+	payment   model.Payment
+	recurring model.RecurringInvoice
+}
+
+func newTenantFixture(t *testing.T, pool *pgxpool.Pool, name string) tenantFixture {
+	t.Helper()
+
+	f := tenantFixture{id: ulid.Make()}
+	if _, err := pool.Exec(
+		context.Background(),
+		`INSERT INTO tenants (id, name) VALUES ($1, $2);`,
+		f.id,
+		name,
+	); err != nil {
+		t.Fatal(err)
+	}
+	f.ctx = auth.WithTenant(context.Background(), f.id)
+
+	// Both tenants use the same SKU, which only has to be unique within
+	// each of them.
+	f.product = model.NewProduct("TEST-SKU", name+" product", "", nil, model.NewMoney(1000, "USD"), 0, model.TaxClassStandard, false, true)
+	if err := NewProductWriteModel(pool, notify.NewLogNotifier()).Save(f.ctx, f.product); err != nil {
+		t.Fatal(err)
+	}
+
+	f.customer = model.NewCustomer(name+" customer", model.Address{}, model.Address{}, "", "USD", 30, []string{})
+	if err := NewCustomerWriteModel(pool).Save(f.ctx, f.customer); err != nil {
+		t.Fatal(err)
+	}
+
+	var err error
+	f.taxRate, err = model.NewTaxRate(model.TaxClassStandard, "", "VAT", 2000, time.Now().Add(-time.Hour), null.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewTaxRateWriteModel(pool).Save(f.ctx, f.taxRate); err != nil {
+		t.Fatal(err)
+	}
+
+	f.invoice = f.newInvoice(t, f.customer, f.product)
+	if err := NewInvoiceWriteModel(pool, testInvoiceNumber, notify.NewLogNotifier()).Save(f.ctx, f.invoice); err != nil {
+		t.Fatal(err)
+	}
+
+	f.payment, err = model.NewPayment(f.customer.ID, model.NewMoney(500, "USD"), model.PaymentMethodBankTransfer, "", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.payment, err = NewPaymentWriteModel(pool).Save(f.ctx, f.payment, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	f.recurring, err = model.NewRecurringInvoice(
+		f.customer.ID,
+		model.RecurrenceMonthly,
+		1,
+		time.Now().AddDate(1, 0, 0),
+		null.Time{},
+		null.Int{},
+		false,
+		[]model.RecurringInvoiceLine{model.NewRecurringInvoiceLine(f.product.ID, 1)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewRecurringInvoiceWriteModel(pool, testInvoiceNumber, notify.NewLogNotifier()).Save(f.ctx, f.recurring); err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+func (f tenantFixture) newInvoice(t *testing.T, customer model.Customer, product model.Product) model.Invoice {
+	t.Helper()
+
+	inv, err := model.NewInvoice(customer, []model.InvoiceLine{model.NewInvoiceLine(product, 1, f.taxRate)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inv
+}
+
+func TestTenantIsolationReads(t *testing.T) {
+	pool := testPool(t)
+	a := newTenantFixture(t, pool, "Tenant A")
+	b := newTenantFixture(t, pool, "Tenant B")
+
+	if _, err := NewCustomerReadModel(pool).GetOneByID(b.ctx, a.customer.ID); !errors.Is(err, model.ErrCustomerNotFound) {
+		t.Errorf("customer of another tenant: error = %v, want %v", err, model.ErrCustomerNotFound)
+	}
+	if _, err := NewTaxRateReadModel(pool).GetOneByID(b.ctx, a.taxRate.ID); !errors.Is(err, model.ErrTaxRateNotFound) {
+		t.Errorf("tax rate of another tenant: error = %v, want %v", err, model.ErrTaxRateNotFound)
+	}
+	if _, err := NewInvoiceReadModel(pool).GetOneByID(b.ctx, a.invoice.ID); !errors.Is(err, model.ErrInvoiceNotFound) {
+		t.Errorf("invoice of another tenant: error = %v, want %v", err, model.ErrInvoiceNotFound)
+	}
+	if _, err := NewPaymentReadModel(pool).GetOneByID(b.ctx, a.payment.ID); !errors.Is(err, model.ErrPaymentNotFound) {
+		t.Errorf("payment of another tenant: error = %v, want %v", err, model.ErrPaymentNotFound)
+	}
+	if _, err := NewRecurringInvoiceReadModel(pool).GetOneByID(b.ctx, a.recurring.ID); !errors.Is(err, model.ErrRecurringInvoiceNotFound) {
+		t.Errorf("recurring invoice of another tenant: error = %v, want %v", err, model.ErrRecurringInvoiceNotFound)
+	}
+	if _, err := NewProductReadModel(pool).GetOneByID(b.ctx, a.product.ID); !errors.Is(err, model.ErrProductNotFound) {
+		t.Errorf("product of another tenant: error = %v, want %v", err, model.ErrProductNotFound)
+	}
+
+	customers, err := NewCustomerReadModel(pool).Fetch(b.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range customers.Data {
+		if c.ID != b.customer.ID {
+			t.Errorf("customer list has %s, want only %s", c.ID, b.customer.ID)
+		}
+	}
+
+	invoices, err := NewInvoiceReadModel(pool).Fetch(b.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, inv := range invoices.Data {
+		if inv.ID != b.invoice.ID {
+			t.Errorf("invoice list has %s, want only %s", inv.ID, b.invoice.ID)
+		}
+	}
+
+	payments, err := NewPaymentReadModel(pool).Fetch(b.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range payments.Data {
+		if p.ID != b.payment.ID {
+			t.Errorf("payment list has %s, want only %s", p.ID, b.payment.ID)
+		}
+	}
+
+	recurring, err := NewRecurringInvoiceReadModel(pool).Fetch(b.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range recurring.Data {
+		if r.ID != b.recurring.ID {
+			t.Errorf("recurring invoice list has %s, want only %s", r.ID, b.recurring.ID)
+		}
+	}
+
+	credit, err := NewPaymentReadModel(pool).CustomerCredit(b.ctx, a.customer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(credit) != 0 {
+		t.Errorf("credit of another tenant's customer = %v, want none", credit)
+	}
+
+	// A connection without a tenant sees nothing at all.
+	var count int
+	if err := pool.QueryRow(
+		context.Background(),
+		`SELECT
+			(SELECT COUNT(*) FROM customers) +
+			(SELECT COUNT(*) FROM invoices) +
+			(SELECT COUNT(*) FROM invoice_lines) +
+			(SELECT COUNT(*) FROM payments) +
+			(SELECT COUNT(*) FROM tax_rates) +
+			(SELECT COUNT(*) FROM number_sequences);`,
+	).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("rows seen without a tenant = %d, want 0", count)
+	}
+}
+
+func TestTenantIsolationWrites(t *testing.T) {
+	pool := testPool(t)
+	a := newTenantFixture(t, pool, "Tenant A")
+	b := newTenantFixture(t, pool, "Tenant B")
+
+	changed := a.customer
+	changed.LegalName = "Taken over"
+	if err := NewCustomerWriteModel(pool).Save(b.ctx, changed); err == nil {
+		t.Error("saving over another tenant's customer succeeded")
+	}
+	if err := NewCustomerWriteModel(pool).Delete(b.ctx, a.customer); err != nil {
+		t.Fatal(err)
+	}
+	customer, err := NewCustomerReadModel(pool).GetOneByID(a.ctx, a.customer.ID)
+	if err != nil {
+		t.Fatalf("customer after another tenant changed it: %v", err)
+	}
+	if customer.LegalName != a.customer.LegalName {
+		t.Errorf("customer name = %q, want %q", customer.LegalName, a.customer.LegalName)
+	}
+
+	if err := NewTaxRateWriteModel(pool).Delete(b.ctx, a.taxRate); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTaxRateReadModel(pool).GetOneByID(a.ctx, a.taxRate.ID); err != nil {
+		t.Errorf("tax rate after another tenant deleted it: %v", err)
+	}
+
+	writeInvoice := NewInvoiceWriteModel(pool, testInvoiceNumber, notify.NewLogNotifier())
+	if err := writeInvoice.Delete(b.ctx, a.invoice); !errors.Is(err, model.ErrInvoiceNotFound) {
+		t.Errorf("deleting another tenant's invoice: error = %v, want %v", err, model.ErrInvoiceNotFound)
+	}
+	if _, err := writeInvoice.Transition(b.ctx, a.invoice.ID, func(inv *model.Invoice) error {
+		return inv.Issue(time.Now())
+	}); !errors.Is(err, model.ErrInvoiceNotFound) {
+		t.Errorf("issuing another tenant's invoice: error = %v, want %v", err, model.ErrInvoiceNotFound)
+	}
+	if err := writeInvoice.Edit(b.ctx, a.invoice.ID, func(inv *model.Invoice) error {
+		return inv.SetCustomer(b.customer)
+	}); !errors.Is(err, model.ErrInvoiceNotFound) {
+		t.Errorf("editing another tenant's invoice: error = %v, want %v", err, model.ErrInvoiceNotFound)
+	}
+	invoice, err := NewInvoiceReadModel(pool).GetOneByID(a.ctx, a.invoice.ID)
+	if err != nil {
+		t.Fatalf("invoice after another tenant changed it: %v", err)
+	}
+	if invoice.Status != model.InvoiceStatusDraft || invoice.CustomerID != a.customer.ID {
+		t.Errorf("invoice = %s for %s, want draft for %s", invoice.Status, invoice.CustomerID, a.customer.ID)
+	}
+
+	if _, err := NewPaymentWriteModel(pool).Allocate(b.ctx, a.payment.ID, nil); !errors.Is(err, model.ErrPaymentNotFound) {
+		t.Errorf("allocating another tenant's payment: error = %v, want %v", err, model.ErrPaymentNotFound)
+	}
+
+	if err := NewRecurringInvoiceWriteModel(pool, testInvoiceNumber, notify.NewLogNotifier()).Delete(b.ctx, a.recurring); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRecurringInvoiceReadModel(pool).GetOneByID(a.ctx, a.recurring.ID); err != nil {
+		t.Errorf("recurring invoice after another tenant deleted it: %v", err)
+	}
+
+	// Plain statements are filtered the same way.
+	for _, tt := range []struct {
+		query string
+		id    ulid.ULID
+	}{
+		{`UPDATE invoices SET payment_term_days = 99 WHERE id = $1;`, a.invoice.ID},
+		{`DELETE FROM invoice_lines WHERE invoice_id = $1;`, a.invoice.ID},
+		{`UPDATE customers SET legal_name = 'Taken over' WHERE id = $1;`, a.customer.ID},
+		{`DELETE FROM payments WHERE customer_id = $1;`, a.customer.ID},
+	} {
+		tag, err := pool.Exec(b.ctx, tt.query, tt.id)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		if tag.RowsAffected() != 0 {
+			t.Errorf("%s changed %d rows of another tenant", tt.query, tag.RowsAffected())
+		}
+	}
+}
+
+func TestTenantIsolationForeignKeys(t *testing.T) {
+	pool := testPool(t)
+	a := newTenantFixture(t, pool, "Tenant A")
+	b := newTenantFixture(t, pool, "Tenant B")
+
+	writeInvoice := NewInvoiceWriteModel(pool, testInvoiceNumber, notify.NewLogNotifier())
+
+	err := writeInvoice.Save(b.ctx, b.newInvoice(t, a.customer, b.product))
+	requireForeignKeyViolation(t, "invoice for another tenant's customer", err)
+
+	err = writeInvoice.Save(b.ctx, b.newInvoice(t, b.customer, a.product))
+	requireForeignKeyViolation(t, "invoice line for another tenant's product", err)
+
+	payment, err := model.NewPayment(a.customer.ID, model.NewMoney(100, "USD"), model.PaymentMethodCash, "", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewPaymentWriteModel(pool).Save(b.ctx, payment, nil)
+	requireForeignKeyViolation(t, "payment from another tenant's customer", err)
+
+	_, err = pool.Exec(
+		b.ctx,
+		`INSERT INTO payment_allocations (id, payment_id, invoice_id, amount) VALUES ($1, $2, $3, 1);`,
+		ulid.Make(),
+		b.payment.ID,
+		a.invoice.ID,
+	)
+	requireForeignKeyViolation(t, "allocation to another tenant's invoice", err)
+
+	_, err = pool.Exec(
+		b.ctx,
+		`INSERT INTO quotes (id, customer_id, bill_to, currency, expires_at, invoice_id)
+		VALUES ($1, $2, '{}', 'USD', CURRENT_TIMESTAMP, $3);`,
+		ulid.Make(),
+		b.customer.ID,
+		a.invoice.ID,
+	)
+	requireForeignKeyViolation(t, "quote converted into another tenant's invoice", err)
+
+	recurring, err := model.NewRecurringInvoice(
+		a.customer.ID,
+		model.RecurrenceMonthly,
+		1,
+		time.Now(),
+		null.Time{},
+		null.Int{},
+		false,
+		[]model.RecurringInvoiceLine{model.NewRecurringInvoiceLine(b.product.ID, 1)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = NewRecurringInvoiceWriteModel(pool, testInvoiceNumber, notify.NewLogNotifier()).Save(b.ctx, recurring)
+	requireForeignKeyViolation(t, "recurring invoice for another tenant's customer", err)
+}
+
+func TestTenantNumberSequences(t *testing.T) {
+	pool := testPool(t)
+	a := newTenantFixture(t, pool, "Tenant A")
+	b := newTenantFixture(t, pool, "Tenant B")
+
+	writeInvoice := NewInvoiceWriteModel(pool, testInvoiceNumber, notify.NewLogNotifier())
+	issue := func(f tenantFixture) string {
+		inv, err := writeInvoice.Transition(f.ctx, f.invoice.ID, func(inv *model.Invoice) error {
+			return inv.Issue(time.Now())
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return inv.Number.String
+	}
+
+	// Every tenant numbers its invoices on its own, so the first invoice of
+	// both gets the first number.
+	if got, want := issue(a), "INV-000001"; got != want {
+		t.Errorf("first number of tenant A = %q, want %q", got, want)
+	}
+	if got, want := issue(b), "INV-000001"; got != want {
+		t.Errorf("first number of tenant B = %q, want %q", got, want)
+	}
+}
+
+func TestTenantProvision(t *testing.T) {
+	pool := testPool(t)
+
+	tenant := model.NewTenant("Provisioned")
+	admin, err := model.NewUser(tenant.ID, tenant.ID.String()+"@provisioned.test", "Admin", "secret", model.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewTenantWriteModel(pool).Provision(context.Background(), tenant, admin); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := auth.WithTenant(context.Background(), tenant.ID)
+	if _, err := NewTenantReadModel(pool).GetOneByID(ctx, tenant.ID); err != nil {
+		t.Errorf("reading the provisioned tenant: %v", err)
+	}
+	warehouses, err := NewWarehouseReadModel(pool).Fetch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warehouses.Data) != 1 || !warehouses.Data[0].IsDefault {
+		t.Errorf("warehouses of the provisioned tenant = %+v, want its default one", warehouses.Data)
+	}
+	user, err := NewUserReadModel(pool).GetOneByEmail(ctx, admin.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.TenantID != tenant.ID || user.Role != model.RoleAdmin {
+		t.Errorf("first user = %s in tenant %s, want an admin of %s", user.Role, user.TenantID, tenant.ID)
+	}
+
+	// The admin has to belong to the tenant.
+	other := model.NewTenant("Misprovisioned")
+	if err := NewTenantWriteModel(pool).Provision(context.Background(), other, admin); !errors.Is(err, model.ErrTenantAdminInvalid) {
+		t.Errorf("Provision() with another tenant's admin: error = %v, want %v", err, model.ErrTenantAdminInvalid)
+	}
+}
+
+func TestTenantSeller(t *testing.T) {
+	pool := testPool(t)
+	a := newTenantFixture(t, pool, "Tenant A")
+	b := newTenantFixture(t, pool, "Tenant B")
+
+	writeTenant := NewTenantWriteModel(pool)
+	readTenant := NewTenantReadModel(pool)
+	seller := model.Seller{Name: "Tenant A Ltd", TaxID: "A-123", Address: model.Address{City: "London", Country: "GB"}}
+	if err := writeTenant.EditSeller(a.ctx, a.id, seller); err != nil {
+		t.Fatal(err)
+	}
+	// Seeding never overwrites the seller a tenant has set.
+	if err := writeTenant.SeedSeller(a.ctx, a.id, model.Seller{Name: "Seeded"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tenant, err := readTenant.GetOneByID(a.ctx, a.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tenant.InvoiceSeller(); got != seller {
+		t.Errorf("seller of tenant A = %+v, want %+v", got, seller)
+	}
+
+	tenant, err = readTenant.GetOneByID(b.ctx, b.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tenant.InvoiceSeller().Name, "Tenant B"; got != want {
+		t.Errorf("seller of tenant B = %q, want the tenant's name %q", got, want)
+	}
+}
+
+func TestTenantRecurringInvoiceRun(t *testing.T) {
+	pool := testPool(t)
+	a := newTenantFixture(t, pool, "Tenant A")
+	b := newTenantFixture(t, pool, "Tenant B")
+
+	due, err := model.NewRecurringInvoice(
+		a.customer.ID,
+		model.RecurrenceMonthly,
+		time.Now().Day(),
+		time.Now().Add(-time.Minute),
+		null.Time{},
+		null.Int{},
+		true,
+		[]model.RecurringInvoiceLine{model.NewRecurringInvoiceLine(a.product.ID, 1)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeRecurring := NewRecurringInvoiceWriteModel(pool, testInvoiceNumber, notify.NewLogNotifier())
+	if err := writeRecurring.Save(a.ctx, due); err != nil {
+		t.Fatal(err)
+	}
+
+	// The scheduler runs without a tenant and claims the schedules of every
+	// tenant; other schedules due in the database are left alone.
+	errOther := errors.New("schedule of another test")
+	now := time.Now()
+	_, err = writeRecurring.RunDue(context.Background(), now, func(ctx context.Context, r *model.RecurringInvoice) ([]model.Invoice, error) {
+		if r.ID != due.ID {
+			return nil, errOther
+		}
+		inv, err := r.Generate(a.customer, []model.InvoiceLine{model.NewInvoiceLine(a.product, 1, a.taxRate)}, now)
+		if err != nil {
+			return nil, err
+		}
+		return []model.Invoice{inv}, nil
+	})
+	if err != nil && !errors.Is(err, errOther) {
+		t.Fatal(err)
+	}
+
+	var found bool
+	invoices, err := NewInvoiceReadModel(pool).Fetch(a.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, inv := range invoices.Data {
+		if inv.RecurringInvoiceID != nil && *inv.RecurringInvoiceID == due.ID {
+			found = true
+			if inv.Number.String != "INV-000001" {
+				t.Errorf("generated invoice number = %q, want the first of tenant A", inv.Number.String)
+			}
+		}
+	}
+	if !found {
+		t.Error("the due schedule generated no invoice for its tenant")
+	}
+
+	invoices, err = NewInvoiceReadModel(pool).Fetch(b.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, inv := range invoices.Data {
+		if inv.ID != b.invoice.ID {
+			t.Errorf("tenant B sees invoice %s generated for tenant A", inv.ID)
+		}
+	}
+	if _, err := NewRecurringInvoiceReadModel(pool).GetOneByID(b.ctx, due.ID); !errors.Is(err, model.ErrRecurringInvoiceNotFound) {
+		t.Errorf("tenant B reading tenant A's schedule: error = %v, want %v", err, model.ErrRecurringInvoiceNotFound)
+	}
+}
+
+func TestTenantStockReservationSweep(t *testing.T) {
+	pool := testPool(t)
+	a := newTenantFixture(t, pool, "Tenant A")
+	b := newTenantFixture(t, pool, "Tenant B")
+
+	writeInvoice := NewInvoiceWriteModel(pool, testInvoiceNumber, notify.NewLogNotifier())
+	if _, err := writeInvoice.Reserve(a.ctx, a.invoice.ID, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	held := func(f tenantFixture) int {
+		var count int
+		if err := pool.QueryRow(
+			f.ctx,
+			`SELECT count(*) FROM stock_reservations WHERE invoice_id = $1;`,
+			a.invoice.ID,
+		).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+	if got := held(a); got != 1 {
+		t.Fatalf("reservations of tenant A = %d, want 1", got)
+	}
+	if got := held(b); got != 0 {
+		t.Errorf("tenant B sees %d reservations of tenant A", got)
+	}
+
+	// The sweeper runs without a tenant and releases the expired
+	// reservations of every tenant.
+	released, err := NewStockReservationWriteModel(pool).ReleaseExpired(context.Background(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if released < 1 {
+		t.Errorf("ReleaseExpired() = %d, want tenant A's reservation released", released)
+	}
+	if got := held(a); got != 0 {
+		t.Errorf("reservations of tenant A after the sweep = %d, want 0", got)
+	}
+}
+
+func requireForeignKeyViolation(t *testing.T, what string, err error) {
+	t.Helper()
+
+	var pgxError *pgconn.PgError
+	if !errors.As(err, &pgxError) || pgxError.Code != "23503" {
+		t.Errorf("%s: error = %v, want a foreign key violation", what, err)
+	}
+}
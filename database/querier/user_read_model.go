@@ -0,0 +1,143 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+type UserQuerier struct {
+	pool *pgxpool.Pool
+}
+
+const userColumns = `
+	id,
+	created_at,
+	updated_at,
+	deleted_at,
+	tenant_id,
+	email,
+	name,
+	role,
+	password_hash
+`
+
+func scanUser(row pgx.Row, item *model.User) error {
+	return row.Scan(
+		&item.ID,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+		&item.DeletedAt,
+		&item.TenantID,
+		&item.Email,
+		&item.Name,
+		&item.Role,
+		&item.PasswordHash,
+	)
+}
+
+// Fetch lists the active users of a tenant.
+func (q *UserQuerier) Fetch(ctx context.Context, tenantID ulid.ULID) (res UserList, err error) {
+	rows, err := q.pool.Query(
+		ctx,
+		`SELECT `+userColumns+`
+		FROM users
+		WHERE deleted_at IS NULL AND tenant_id = $1
+		ORDER BY id;`,
+		tenantID,
+	)
+	if err != nil {
+		return emptyUsers, err
+	}
+	defer rows.Close()
+
+	var items []model.User
+	for rows.Next() {
+		var item model.User
+		if err := scanUser(rows, &item); err != nil {
+			return emptyUsers, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return emptyUsers, err
+	}
+
+	if len(items) == 0 {
+		return emptyUsers, nil
+	}
+
+	list := UserList{
+		Count: len(items),
+		Data:  items,
+	}
+
+	return list, nil
+}
+
+func (q *UserQuerier) GetOneByID(ctx context.Context, id ulid.ULID) (res model.User, err error) {
+	row := q.pool.QueryRow(
+		ctx,
+		`SELECT `+userColumns+`
+		FROM users
+		WHERE id = $1;`,
+		id,
+	)
+	return getUser(row)
+}
+
+// GetOneByEmail finds the active user with the email address, compared case
+// insensitively.
+func (q *UserQuerier) GetOneByEmail(ctx context.Context, email string) (res model.User, err error) {
+	row := q.pool.QueryRow(
+		ctx,
+		`SELECT `+userColumns+`
+		FROM users
+		WHERE email = $1
+			AND deleted_at IS NULL;`,
+		strings.ToLower(email),
+	)
+	return getUser(row)
+}
+
+func getUser(row pgx.Row) (model.User, error) {
+	var item model.User
+	if err := scanUser(row, &item); err != nil {
+		if err == pgx.ErrNoRows {
+			return item, model.ErrUserNotFound
+		}
+		return item, err
+	}
+	if item.DeletedAt.Valid {
+		return item, model.ErrUserAlreadyDeleted
+	}
+	return item, nil
+}
+
+type UserList struct {
+	Count int          `json:"count"`
+	Data  []model.User `json:"data"`
+}
+
+var emptyUsers = UserList{
+	Count: 0,
+	Data:  []model.User{},
+}
+
+type UserReadModel interface {
+	Fetch(ctx context.Context, tenantID ulid.ULID) (res UserList, err error)
+	GetOneByID(ctx context.Context, id ulid.ULID) (res model.User, err error)
+	GetOneByEmail(ctx context.Context, email string) (res model.User, err error)
+}
+
+func NewUserReadModel(
+	pool *pgxpool.Pool,
+) UserReadModel {
+	return &UserQuerier{
+		pool: pool,
+	}
+}
@@ -0,0 +1,125 @@
+package querier
+
+import (
+	"context"
+	"errors"
+	"flukis/invokiss/app/model"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func (q *UserQuerier) Save(ctx context.Context, data model.User) error {
+	return saveUser(ctx, q.pool, data)
+}
+
+func saveUser(ctx context.Context, db dbtx, data model.User) error {
+	query := `
+		INSERT INTO users (
+			id,
+			created_at,
+			tenant_id,
+			email,
+			name,
+			role,
+			password_hash
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7
+		) ON CONFLICT(id)
+		DO UPDATE SET
+			email = EXCLUDED.email,
+			name = EXCLUDED.name,
+			role = EXCLUDED.role,
+			password_hash = EXCLUDED.password_hash,
+			updated_at = CURRENT_TIMESTAMP;
+	`
+	_, err := db.Exec(
+		ctx,
+		query,
+		data.ID,
+		data.CreatedAt,
+		data.TenantID,
+		data.Email,
+		data.Name,
+		data.Role,
+		data.PasswordHash,
+	)
+
+	if err != nil {
+		var pgxError *pgconn.PgError
+		if errors.As(err, &pgxError) {
+			if pgxError.Code == "23505" {
+				return model.ErrUserEmailDuplicated
+			}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Delete soft-deletes the user and revokes every session it still has.
+func (q *UserQuerier) Delete(ctx context.Context, data model.User) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE users
+		SET deleted_at = CURRENT_TIMESTAMP
+		WHERE id = $1;`,
+		data.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1
+			AND revoked_at IS NULL;`,
+		data.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// HasUsers reports whether any active user exists, which decides whether
+// the configured first administrator still has to be created.
+func (q *UserQuerier) HasUsers(ctx context.Context) (bool, error) {
+	var exists bool
+	err := q.pool.QueryRow(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM users WHERE deleted_at IS NULL);`,
+	).Scan(&exists)
+	return exists, err
+}
+
+type UserWriteModel interface {
+	Save(ctx context.Context, data model.User) error
+	Delete(ctx context.Context, data model.User) error
+	HasUsers(ctx context.Context) (bool, error)
+}
+
+func NewUserWriteModel(
+	pool *pgxpool.Pool,
+) UserWriteModel {
+	return &UserQuerier{
+		pool: pool,
+	}
+}
@@ -0,0 +1,116 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+type WarehouseQuerier struct {
+	pool *pgxpool.Pool
+}
+
+const warehouseColumns = `
+	id,
+	created_at,
+	updated_at,
+	deleted_at,
+	code,
+	name,
+	is_default
+`
+
+func scanWarehouse(row pgx.Row, item *model.Warehouse) error {
+	return row.Scan(
+		&item.ID,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+		&item.DeletedAt,
+		&item.Code,
+		&item.Name,
+		&item.IsDefault,
+	)
+}
+
+// Fetch lists the active warehouses, the default one first.
+func (q *WarehouseQuerier) Fetch(ctx context.Context) (res WarehouseList, err error) {
+	rows, err := q.pool.Query(
+		ctx,
+		`SELECT `+warehouseColumns+`
+		FROM warehouses
+		WHERE deleted_at IS NULL
+		ORDER BY is_default DESC, code;`,
+	)
+	if err != nil {
+		return emptyWarehouses, err
+	}
+	defer rows.Close()
+
+	var items []model.Warehouse
+	for rows.Next() {
+		var item model.Warehouse
+		if err := scanWarehouse(rows, &item); err != nil {
+			return emptyWarehouses, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return emptyWarehouses, err
+	}
+
+	if len(items) == 0 {
+		return emptyWarehouses, nil
+	}
+
+	return WarehouseList{
+		Count: len(items),
+		Data:  items,
+	}, nil
+}
+
+func (q *WarehouseQuerier) GetOneByID(ctx context.Context, id ulid.ULID) (res model.Warehouse, err error) {
+	row := q.pool.QueryRow(
+		ctx,
+		`SELECT `+warehouseColumns+`
+		FROM warehouses
+		WHERE id = $1;`,
+		id,
+	)
+	var item model.Warehouse
+	if err := scanWarehouse(row, &item); err != nil {
+		if err == pgx.ErrNoRows {
+			return item, model.ErrWarehouseNotFound
+		}
+		return item, err
+	}
+	if item.DeletedAt.Valid {
+		return item, model.ErrWarehouseAlreadyDeleted
+	}
+	return item, nil
+}
+
+type WarehouseList struct {
+	Count int               `json:"count"`
+	Data  []model.Warehouse `json:"data"`
+}
+
+var emptyWarehouses = WarehouseList{
+	Count: 0,
+	Data:  []model.Warehouse{},
+}
+
+type WarehouseReadModel interface {
+	Fetch(ctx context.Context) (res WarehouseList, err error)
+	GetOneByID(ctx context.Context, id ulid.ULID) (res model.Warehouse, err error)
+}
+
+func NewWarehouseReadModel(
+	pool *pgxpool.Pool,
+) WarehouseReadModel {
+	return &WarehouseQuerier{
+		pool: pool,
+	}
+}
@@ -0,0 +1,185 @@
+package querier
+
+import (
+	"context"
+	"errors"
+	"flukis/invokiss/app/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+// Save creates the warehouse. The first warehouse of a tenant becomes its
+// default one.
+func (q *WarehouseQuerier) Save(ctx context.Context, data model.Warehouse) error {
+	_, err := q.pool.Exec(
+		ctx,
+		`INSERT INTO warehouses (
+			id,
+			created_at,
+			code,
+			name,
+			is_default
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			NOT EXISTS (SELECT 1 FROM warehouses WHERE is_default)
+		);`,
+		data.ID,
+		data.CreatedAt,
+		data.Code,
+		data.Name,
+	)
+	return warehouseError(err)
+}
+
+func (q *WarehouseQuerier) Edit(ctx context.Context, data model.Warehouse) error {
+	tag, err := q.pool.Exec(
+		ctx,
+		`UPDATE warehouses
+		SET
+			code = $2,
+			name = $3,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND deleted_at IS NULL;`,
+		data.ID,
+		data.Code,
+		data.Name,
+	)
+	if err != nil {
+		return warehouseError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return model.ErrWarehouseNotFound
+	}
+	return nil
+}
+
+// Delete soft-deletes a warehouse that holds no stock and has none on its
+// way in or out. The default warehouse is kept.
+func (q *WarehouseQuerier) Delete(ctx context.Context, id ulid.ULID) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	item, err := lockWarehouse(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if item.IsDefault {
+		return model.ErrWarehouseIsDefault
+	}
+
+	var hasStock bool
+	row := tx.QueryRow(
+		ctx,
+		`SELECT
+			EXISTS (
+				SELECT 1 FROM inventories WHERE warehouse_id = $1 AND quantity <> 0
+			) OR EXISTS (
+				SELECT 1 FROM warehouse_transfers
+				WHERE (from_warehouse_id = $1 OR to_warehouse_id = $1)
+					AND status = 'in_transit'
+			);`,
+		id,
+	)
+	if err := row.Scan(&hasStock); err != nil {
+		return err
+	}
+	if hasStock {
+		return model.ErrWarehouseHasStock
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE warehouses
+		SET deleted_at = CURRENT_TIMESTAMP
+		WHERE id = $1;`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// lockWarehouse reads the warehouse and holds a row lock on it until tx
+// ends.
+func lockWarehouse(ctx context.Context, tx pgx.Tx, id ulid.ULID) (model.Warehouse, error) {
+	row := tx.QueryRow(
+		ctx,
+		`SELECT `+warehouseColumns+`
+		FROM warehouses
+		WHERE id = $1
+		FOR UPDATE;`,
+		id,
+	)
+	var item model.Warehouse
+	if err := scanWarehouse(row, &item); err != nil {
+		if err == pgx.ErrNoRows {
+			return item, model.ErrWarehouseNotFound
+		}
+		return item, err
+	}
+	if item.DeletedAt.Valid {
+		return item, model.ErrWarehouseAlreadyDeleted
+	}
+	return item, nil
+}
+
+// defaultWarehouse returns the id of the tenant's default warehouse,
+// creating one for tenants that don't have any yet.
+func defaultWarehouse(ctx context.Context, tx pgx.Tx) (ulid.ULID, error) {
+	_, err := tx.Exec(
+		ctx,
+		`INSERT INTO warehouses (
+			id,
+			code,
+			name,
+			is_default
+		)
+		SELECT $1, 'MAIN', 'Main warehouse', true
+		WHERE NOT EXISTS (SELECT 1 FROM warehouses WHERE is_default)
+		ON CONFLICT DO NOTHING;`,
+		ulid.Make(),
+	)
+	if err != nil {
+		return ulid.ULID{}, err
+	}
+
+	var id ulid.ULID
+	err = tx.QueryRow(
+		ctx,
+		`SELECT id FROM warehouses WHERE is_default;`,
+	).Scan(&id)
+	return id, err
+}
+
+func warehouseError(err error) error {
+	var pgxError *pgconn.PgError
+	if errors.As(err, &pgxError) && pgxError.Code == "23505" {
+		return model.ErrWarehouseCodeDuplicated
+	}
+	return err
+}
+
+type WarehouseWriteModel interface {
+	Save(ctx context.Context, data model.Warehouse) error
+	Edit(ctx context.Context, data model.Warehouse) error
+	Delete(ctx context.Context, id ulid.ULID) error
+}
+
+func NewWarehouseWriteModel(
+	pool *pgxpool.Pool,
+) WarehouseWriteModel {
+	return &WarehouseQuerier{
+		pool: pool,
+	}
+}
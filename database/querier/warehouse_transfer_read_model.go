@@ -0,0 +1,167 @@
+package querier
+
+import (
+	"context"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/app/notify"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+type WarehouseTransferQuerier struct {
+	pool     *pgxpool.Pool
+	notifier notify.Notifier
+}
+
+// WarehouseTransferFilter narrows a transfer list to a status, a product
+// and transfers from or to a warehouse.
+type WarehouseTransferFilter struct {
+	Page
+
+	Status      model.WarehouseTransferStatus
+	ProductID   *ulid.ULID
+	WarehouseID *ulid.ULID
+}
+
+const warehouseTransferColumns = `
+	id,
+	created_at,
+	updated_at,
+	product_id,
+	from_warehouse_id,
+	to_warehouse_id,
+	quantity,
+	status,
+	reference,
+	shipped_at,
+	received_at,
+	cancelled_at
+`
+
+func scanWarehouseTransfer(row pgx.Row, item *model.WarehouseTransfer) error {
+	return row.Scan(
+		&item.ID,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+		&item.ProductID,
+		&item.FromWarehouseID,
+		&item.ToWarehouseID,
+		&item.Quantity,
+		&item.Status,
+		&item.Reference,
+		&item.ShippedAt,
+		&item.ReceivedAt,
+		&item.CancelledAt,
+	)
+}
+
+// Fetch pages through the transfers, oldest first unless the page asks
+// otherwise.
+func (q *WarehouseTransferQuerier) Fetch(ctx context.Context, filter WarehouseTransferFilter) (res WarehouseTransferList, err error) {
+	var args queryArgs
+	var conds = []string{"TRUE"}
+
+	if filter.Status != "" {
+		conds = append(conds, "status = "+args.add(filter.Status))
+	}
+	if filter.ProductID != nil {
+		conds = append(conds, "product_id = "+args.add(*filter.ProductID))
+	}
+	if filter.WarehouseID != nil {
+		warehouse := args.add(*filter.WarehouseID)
+		conds = append(conds, "(from_warehouse_id = "+warehouse+" OR to_warehouse_id = "+warehouse+")")
+	}
+
+	after, order := filter.keyset(&args, "id", "id", func(id string) string {
+		return `SELECT id, id FROM warehouse_transfers WHERE id = ` + id
+	})
+	if after != "" {
+		conds = append(conds, after)
+	}
+
+	limit := filter.limit()
+	rows, err := q.pool.Query(
+		ctx,
+		`SELECT `+warehouseTransferColumns+`
+		FROM warehouse_transfers
+		WHERE `+strings.Join(conds, " AND ")+`
+		ORDER BY `+order+`
+		LIMIT `+args.add(limit+1)+`;`,
+		args...,
+	)
+	if err != nil {
+		return emptyWarehouseTransfers, err
+	}
+	defer rows.Close()
+
+	var items []model.WarehouseTransfer
+	for rows.Next() {
+		var item model.WarehouseTransfer
+		if err := scanWarehouseTransfer(rows, &item); err != nil {
+			return emptyWarehouseTransfers, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return emptyWarehouseTransfers, err
+	}
+
+	if len(items) == 0 {
+		return emptyWarehouseTransfers, nil
+	}
+
+	items, cursor := pageCursor(items, limit, func(t model.WarehouseTransfer) ulid.ULID {
+		return t.ID
+	})
+
+	return WarehouseTransferList{
+		Count:  len(items),
+		Data:   items,
+		Cursor: cursor,
+	}, nil
+}
+
+func (q *WarehouseTransferQuerier) GetOneByID(ctx context.Context, id ulid.ULID) (res model.WarehouseTransfer, err error) {
+	row := q.pool.QueryRow(
+		ctx,
+		`SELECT `+warehouseTransferColumns+`
+		FROM warehouse_transfers
+		WHERE id = $1;`,
+		id,
+	)
+	var item model.WarehouseTransfer
+	if err := scanWarehouseTransfer(row, &item); err != nil {
+		if err == pgx.ErrNoRows {
+			return item, model.ErrWarehouseTransferNotFound
+		}
+		return item, err
+	}
+	return item, nil
+}
+
+type WarehouseTransferList struct {
+	Count int                       `json:"count"`
+	Data  []model.WarehouseTransfer `json:"data"`
+	Cursor
+}
+
+var emptyWarehouseTransfers = WarehouseTransferList{
+	Count: 0,
+	Data:  []model.WarehouseTransfer{},
+}
+
+type WarehouseTransferReadModel interface {
+	Fetch(ctx context.Context, filter WarehouseTransferFilter) (res WarehouseTransferList, err error)
+	GetOneByID(ctx context.Context, id ulid.ULID) (res model.WarehouseTransfer, err error)
+}
+
+func NewWarehouseTransferReadModel(
+	pool *pgxpool.Pool,
+) WarehouseTransferReadModel {
+	return &WarehouseTransferQuerier{
+		pool: pool,
+	}
+}
@@ -0,0 +1,222 @@
+package querier
+
+import (
+	"context"
+	"errors"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/app/notify"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+// Ship takes the transfer's stock out of the source warehouse, booked as a
+// transfer in the stock ledger, and keeps it in transit. Only stock that
+// isn't reserved can be shipped.
+func (q *WarehouseTransferQuerier) Ship(ctx context.Context, data model.WarehouseTransfer) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Both inventories are locked in warehouse order, so that concurrent
+	// transfers between the same warehouses cannot deadlock. Locking the
+	// destination also stocks it empty if it wasn't yet.
+	var (
+		source model.Inventory
+		first  = data.FromWarehouseID
+		second = data.ToWarehouseID
+	)
+	if first.Compare(second) > 0 {
+		first, second = second, first
+	}
+	for _, warehouseID := range []ulid.ULID{first, second} {
+		inv, err := lockWarehouseInventory(ctx, tx, data.ProductID, warehouseID)
+		if err != nil {
+			return err
+		}
+		if warehouseID == data.FromWarehouseID {
+			source = inv
+		}
+	}
+
+	if data.Quantity > source.Available() {
+		return model.ErrInsufficientStock
+	}
+
+	movement, err := source.Move(
+		data.ProductID,
+		model.StockTransfer,
+		-data.Quantity,
+		"transfer shipped",
+		data.ID.String(),
+		auth.ActorFrom(ctx),
+		data.ShippedAt,
+	)
+	if err != nil {
+		return err
+	}
+	var alerts stockAlerts
+	if err := recordStockMovement(ctx, tx, source, movement, &alerts); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`INSERT INTO warehouse_transfers (
+			id,
+			created_at,
+			product_id,
+			from_warehouse_id,
+			to_warehouse_id,
+			quantity,
+			status,
+			reference,
+			shipped_at
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7,
+			$8,
+			$9
+		);`,
+		data.ID,
+		data.CreatedAt,
+		data.ProductID,
+		data.FromWarehouseID,
+		data.ToWarehouseID,
+		data.Quantity,
+		data.Status,
+		data.Reference,
+		data.ShippedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	alerts.notify(ctx, q.notifier)
+	return nil
+}
+
+// Settle locks a transfer in transit and lets change receive or cancel it.
+// The stock goes into the destination warehouse once received, or back
+// into the source once cancelled, booked as a transfer in the stock
+// ledger.
+func (q *WarehouseTransferQuerier) Settle(ctx context.Context, id ulid.ULID, change func(*model.WarehouseTransfer) error) (model.WarehouseTransfer, error) {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return model.WarehouseTransfer{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	item, err := lockWarehouseTransfer(ctx, tx, id)
+	if err != nil {
+		return item, err
+	}
+	if err := change(&item); err != nil {
+		return item, err
+	}
+
+	var (
+		warehouseID = item.ToWarehouseID
+		reason      = "transfer received"
+		at          = item.ReceivedAt.Time
+	)
+	if item.Status == model.WarehouseTransferCancelled {
+		warehouseID = item.FromWarehouseID
+		reason = "transfer cancelled"
+		at = item.CancelledAt.Time
+	}
+
+	// Goods in transit still arrive when the product is no longer sold.
+	inv, err := lockWarehouseInventory(ctx, tx, item.ProductID, warehouseID)
+	if err != nil && !errors.Is(err, model.ErrProductAlreadyDeleted) {
+		return item, err
+	}
+	movement, err := inv.Move(
+		item.ProductID,
+		model.StockTransfer,
+		item.Quantity,
+		reason,
+		item.ID.String(),
+		auth.ActorFrom(ctx),
+		at,
+	)
+	if err != nil {
+		return item, err
+	}
+	var alerts stockAlerts
+	if err := recordStockMovement(ctx, tx, inv, movement, &alerts); err != nil {
+		return item, err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE warehouse_transfers
+		SET
+			status = $2,
+			received_at = $3,
+			cancelled_at = $4,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1;`,
+		item.ID,
+		item.Status,
+		item.ReceivedAt,
+		item.CancelledAt,
+	)
+	if err != nil {
+		return item, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return item, err
+	}
+	alerts.notify(ctx, q.notifier)
+	return item, nil
+}
+
+// lockWarehouseTransfer reads the transfer and holds a row lock on it until
+// tx ends.
+func lockWarehouseTransfer(ctx context.Context, tx pgx.Tx, id ulid.ULID) (model.WarehouseTransfer, error) {
+	row := tx.QueryRow(
+		ctx,
+		`SELECT `+warehouseTransferColumns+`
+		FROM warehouse_transfers
+		WHERE id = $1
+		FOR UPDATE;`,
+		id,
+	)
+	var item model.WarehouseTransfer
+	if err := scanWarehouseTransfer(row, &item); err != nil {
+		if err == pgx.ErrNoRows {
+			return item, model.ErrWarehouseTransferNotFound
+		}
+		return item, err
+	}
+	return item, nil
+}
+
+type WarehouseTransferWriteModel interface {
+	Ship(ctx context.Context, data model.WarehouseTransfer) error
+	Settle(ctx context.Context, id ulid.ULID, change func(*model.WarehouseTransfer) error) (model.WarehouseTransfer, error)
+}
+
+func NewWarehouseTransferWriteModel(
+	pool *pgxpool.Pool,
+	notifier notify.Notifier,
+) WarehouseTransferWriteModel {
+	return &WarehouseTransferQuerier{
+		pool:     pool,
+		notifier: notifier,
+	}
+}
@@ -2,11 +2,19 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"flukis/invokiss/app/auth"
+	"flukis/invokiss/app/billing"
 	"flukis/invokiss/app/http/controller"
+	"flukis/invokiss/app/http/middleware"
+	"flukis/invokiss/app/model"
+	"flukis/invokiss/app/pdf"
+	"flukis/invokiss/app/scheduler"
 	"flukis/invokiss/database/querier"
 	"fmt"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -27,22 +35,96 @@ func main() {
 	cfg := loadConfig(configFileName)
 	ctx := context.Background()
 
-	pool, err := pgxpool.New(
+	poolCfg, err := pgxpool.ParseConfig(cfg.DBCfg.ConnStr())
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid database configuration")
+	}
+	poolCfg.BeforeAcquire = querier.ScopeConnection
+
+	pool, err := pgxpool.NewWithConfig(
 		ctx,
-		cfg.DBCfg.ConnStr(),
+		poolCfg,
 	)
 	if err != nil {
 		log.Error().Err(err).Msg("unable to connect to database")
 	}
 
-	writeProduct := querier.NewProductWriteModel(pool)
+	if flag.Arg(0) == "tenant" {
+		if err := provisionTenant(ctx, querier.NewTenantWriteModel(pool), flag.Args()[1:]); err != nil {
+			log.Fatal().Err(err).Msg("unable to provision the tenant")
+		}
+		return
+	}
+
+	writeTenant := querier.NewTenantWriteModel(pool)
+	readTenant := querier.NewTenantReadModel(pool)
+
+	// Stock alerts are sent in the background, once the request that raised
+	// them is answered.
+	stockNotifier := cfg.Stock.Notifier(readTenant)
+	go stockNotifier.Run(ctx)
+
+	writeProduct := querier.NewProductWriteModel(pool, stockNotifier)
 	readProduct := querier.NewProductReadModel(pool)
+	readStockMovement := querier.NewStockMovementReadModel(pool)
+	readInventory := querier.NewInventoryReadModel(pool)
+	writeStockReservation := querier.NewStockReservationWriteModel(pool)
+	writeWarehouse := querier.NewWarehouseWriteModel(pool)
+	readWarehouse := querier.NewWarehouseReadModel(pool)
+	writeWarehouseTransfer := querier.NewWarehouseTransferWriteModel(pool, stockNotifier)
+	readWarehouseTransfer := querier.NewWarehouseTransferReadModel(pool)
 	writeCategory := querier.NewCategoryWriteModel(pool)
 	readCategory := querier.NewCategoryReadModel(pool)
+	writeCustomer := querier.NewCustomerWriteModel(pool)
+	readCustomer := querier.NewCustomerReadModel(pool)
+	writeTaxRate := querier.NewTaxRateWriteModel(pool)
+	readTaxRate := querier.NewTaxRateReadModel(pool)
+	writeInvoice := querier.NewInvoiceWriteModel(
+		pool,
+		cfg.Numbering.Invoice.NumberFormat("invoice"),
+		stockNotifier,
+	)
+	readInvoice := querier.NewInvoiceReadModel(pool)
+	writeCreditNote := querier.NewCreditNoteWriteModel(
+		pool,
+		cfg.Numbering.CreditNote.NumberFormat("credit_note"),
+		stockNotifier,
+	)
+	readCreditNote := querier.NewCreditNoteReadModel(pool)
+	writeRecurringInvoice := querier.NewRecurringInvoiceWriteModel(
+		pool,
+		cfg.Numbering.Invoice.NumberFormat("invoice"),
+		stockNotifier,
+	)
+	readRecurringInvoice := querier.NewRecurringInvoiceReadModel(pool)
+	writeUser := querier.NewUserWriteModel(pool)
+	readUser := querier.NewUserReadModel(pool)
+	writeRefreshToken := querier.NewRefreshTokenWriteModel(pool)
+	writeAPIKey := querier.NewAPIKeyWriteModel(pool)
+	readAPIKey := querier.NewAPIKeyReadModel(pool)
+	writeQuote := querier.NewQuoteWriteModel(pool)
+	readQuote := querier.NewQuoteReadModel(pool)
+	writePayment := querier.NewPaymentWriteModel(pool)
+	readPayment := querier.NewPaymentReadModel(pool)
 
 	productController := controller.NewProductController(
 		writeProduct,
 		readProduct,
+		readStockMovement,
+	)
+
+	inventoryController := controller.NewInventoryController(
+		readInventory,
+	)
+
+	warehouseController := controller.NewWarehouseController(
+		writeWarehouse,
+		readWarehouse,
+	)
+
+	warehouseTransferController := controller.NewWarehouseTransferController(
+		writeWarehouseTransfer,
+		readWarehouseTransfer,
 	)
 
 	categoryController := controller.NewCategoryController(
@@ -50,10 +132,129 @@ func main() {
 		readCategory,
 	)
 
+	customerController := controller.NewCustomerController(
+		writeCustomer,
+		readCustomer,
+	)
+
+	invoiceController := controller.NewInvoiceController(
+		writeInvoice,
+		readInvoice,
+		readProduct,
+		readCustomer,
+		readTaxRate,
+		readTenant,
+		pdf.NewInvoiceRenderer(),
+		cfg.Stock.ReservationTTL(),
+	)
+
+	taxRateController := controller.NewTaxRateController(
+		writeTaxRate,
+		readTaxRate,
+	)
+
+	paymentController := controller.NewPaymentController(
+		writePayment,
+		readPayment,
+		readCustomer,
+	)
+
+	creditNoteController := controller.NewCreditNoteController(
+		writeCreditNote,
+		readCreditNote,
+	)
+
+	quoteController := controller.NewQuoteController(
+		writeQuote,
+		readQuote,
+		readInvoice,
+		readProduct,
+		readCustomer,
+		readTaxRate,
+	)
+
+	recurringInvoiceController := controller.NewRecurringInvoiceController(
+		writeRecurringInvoice,
+		readRecurringInvoice,
+		readProduct,
+		readCustomer,
+	)
+
+	recurringInvoiceScheduler := scheduler.NewRecurringInvoiceScheduler(
+		writeRecurringInvoice,
+		readCustomer,
+		billing.NewLineBuilder(readProduct, readTaxRate),
+		time.Second*time.Duration(cfg.Scheduler.RecurringInvoiceInterval),
+	)
+	if cfg.Scheduler.RecurringInvoiceInterval > 0 {
+		go recurringInvoiceScheduler.Run(ctx)
+	}
+
+	stockReservationSweeper := scheduler.NewStockReservationSweeper(
+		writeStockReservation,
+		time.Second*time.Duration(cfg.Scheduler.StockReservationInterval),
+	)
+	if cfg.Scheduler.StockReservationInterval > 0 {
+		go stockReservationSweeper.Run(ctx)
+	}
+
+	issuer := auth.NewTokenIssuer(
+		cfg.JwtCfg.Secret,
+		cfg.JwtCfg.AccessTTL(),
+		cfg.JwtCfg.RefreshTTL(),
+	)
+
+	authController := controller.NewAuthController(
+		readUser,
+		writeRefreshToken,
+		issuer,
+	)
+
+	userController := controller.NewUserController(
+		writeUser,
+		readUser,
+	)
+	apiKeyController := controller.NewAPIKeyController(
+		writeAPIKey,
+		readAPIKey,
+	)
+	tenantController := controller.NewTenantController(
+		writeTenant,
+		readTenant,
+	)
+
+	if err := createAdmin(ctx, writeUser, cfg.Admin); err != nil {
+		log.Error().Err(err).Msg("unable to create the first user")
+	}
+	if err := writeTenant.SeedSeller(ctx, model.DefaultTenantID, cfg.Seller.Seller()); err != nil {
+		log.Error().Err(err).Msg("unable to set the default tenant's seller")
+	}
+
 	r := chi.NewRouter()
 
-	r.Mount("/api/product", productController.Routes())
-	r.Mount("/api/category", categoryController.Routes())
+	r.Mount("/api/auth", authController.Routes())
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Authenticate(issuer, readAPIKey, writeAPIKey))
+
+		r.Mount("/api/product", productController.Routes())
+		r.Mount("/api/inventory", inventoryController.Routes())
+		r.Mount("/api/warehouse", warehouseController.Routes())
+		r.Mount("/api/warehouse-transfer", warehouseTransferController.Routes())
+		r.Mount("/api/category", categoryController.Routes())
+		r.Mount("/api/user", userController.Routes())
+		r.Mount("/api/api-key", apiKeyController.Routes())
+		r.Mount("/api/tenant", tenantController.Routes())
+
+		// Billing reads products and restocks inventories, which are only
+		// visible within the caller's tenant.
+		r.Mount("/api/customer", customerController.Routes())
+		r.Mount("/api/invoice", invoiceController.Routes())
+		r.Mount("/api/tax-rate", taxRateController.Routes())
+		r.Mount("/api/payment", paymentController.Routes())
+		r.Mount("/api/credit-note", creditNoteController.Routes())
+		r.Mount("/api/quote", quoteController.Routes())
+		r.Mount("/api/recurring-invoice", recurringInvoiceController.Routes())
+	})
 
 	log.Info().Msg(fmt.Sprintf("starting up server on: %s", cfg.Listen.Addr()))
 	server := &http.Server{
@@ -69,3 +270,60 @@ func main() {
 	}
 	log.Info().Msg("server stop")
 }
+
+// provisionTenant creates a tenant, with its default warehouse and first
+// admin, from the arguments of the tenant command:
+//
+//	invokiss -c config.yml tenant -name "Acme" -admin-email admin@acme.test
+//
+// The admin's password is read from TENANT_ADMIN_PASSWORD rather than the
+// command line.
+func provisionTenant(ctx context.Context, writeTenant querier.TenantWriteModel, args []string) error {
+	var name, email, adminName string
+	flags := flag.NewFlagSet("tenant", flag.ExitOnError)
+	flags.StringVar(&name, "name", "", "Name of the tenant")
+	flags.StringVar(&email, "admin-email", "", "Email of the tenant's first admin")
+	flags.StringVar(&adminName, "admin-name", "Administrator", "Name of the tenant's first admin")
+	flags.Parse(args)
+
+	password := os.Getenv("TENANT_ADMIN_PASSWORD")
+	if name == "" || email == "" || password == "" {
+		return errors.New("tenant: -name, -admin-email and TENANT_ADMIN_PASSWORD are required")
+	}
+
+	tenant := model.NewTenant(name)
+	admin, err := model.NewUser(tenant.ID, email, adminName, password, model.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if err := writeTenant.Provision(ctx, tenant, admin); err != nil {
+		return err
+	}
+
+	log.Info().Str("tenant", tenant.ID.String()).Str("email", admin.Email).Msg("provisioned the tenant")
+	return nil
+}
+
+// createAdmin creates the configured first user on an empty users table, so
+// there is someone to log in with.
+func createAdmin(ctx context.Context, writeUser querier.UserWriteModel, cfg adminConfig) error {
+	if cfg.Email == "" || cfg.Password == "" {
+		return nil
+	}
+
+	exists, err := writeUser.HasUsers(ctx)
+	if err != nil || exists {
+		return err
+	}
+
+	admin, err := model.NewUser(model.DefaultTenantID, cfg.Email, cfg.Name, cfg.Password, model.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if err := writeUser.Save(ctx, admin); err != nil {
+		return err
+	}
+
+	log.Info().Str("email", admin.Email).Msg("created the first user")
+	return nil
+}